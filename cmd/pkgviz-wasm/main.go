@@ -0,0 +1,46 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command pkgviz-wasm exposes pkgviz to JavaScript, so that a browser page can
+// graph Go source without a server:
+//
+//	const {dot, error} = pkgvizWriteGraph("example.com/pasted", {"main.go": src});
+//
+// Build it with `GOOS=js GOARCH=wasm go build -o pkgviz.wasm ./cmd/pkgviz-wasm`
+// and load it with the wasm_exec.js that ships with Go.
+package main
+
+import (
+	"syscall/js"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+func main() {
+	js.Global().Set("pkgvizWriteGraph", js.FuncOf(writeGraph))
+
+	// Keep the exported function around for the lifetime of the page.
+	select {}
+}
+
+// writeGraph takes a package name and an object mapping file paths to their
+// source, and returns an object with either the dot graph or an error.
+func writeGraph(this js.Value, args []js.Value) interface{} {
+	if len(args) < 2 {
+		return map[string]interface{}{"error": "usage: pkgvizWriteGraph(pkgName, files)"}
+	}
+	pkgName := args[0].String()
+
+	files := map[string]string{}
+	keys := js.Global().Get("Object").Call("keys", args[1])
+	for i := 0; i < keys.Length(); i++ {
+		filename := keys.Index(i).String()
+		files[filename] = args[1].Get(filename).String()
+	}
+
+	dotFile, err := pkgviz.WriteGraphFromFiles(pkgName, files)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	return map[string]interface{}{"dot": dotFile}
+}
@@ -0,0 +1,59 @@
+// Command pkgviz-web serves a playground for pkgviz: a page where you can
+// paste Go source or enter an import path, pick options, and explore the
+// graph. The page is backed by the same API as `pkgviz serve`.
+package main
+
+import (
+	"embed"
+	"flag"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/tiegz/pkgviz-go/pkg/server"
+)
+
+//go:embed static
+var static embed.FS
+
+func main() {
+	addr := flag.String("addr", ":8080", "The address to listen on.")
+	cacheDir := flag.String("cache", filepath.Join(os.TempDir(), "pkgviz"), "The directory to keep fetched modules and rendered graphs in.")
+	worker := flag.String("worker", "pkgviz", "The pkgviz command, which analyzes fetched modules in a separate process. If it can't be found, they're analyzed in this process.")
+	timeout := flag.Duration("timeout", 2*time.Minute, "The longest that building a graph may take.")
+	rateLimit := flag.Int("rate-limit", 0, "How many graphs each client may request per minute (0 is unlimited).")
+	rateBurst := flag.Int("rate-burst", 10, "How many graphs each client may request at once, before the rate limit applies.")
+	flag.Parse()
+
+	config := server.Config{
+		CacheDir:  *cacheDir,
+		Timeout:   *timeout,
+		RateLimit: *rateLimit,
+		RateBurst: *rateBurst,
+	}
+	if workerPath, err := exec.LookPath(*worker); err == nil {
+		config.Worker = []string{workerPath}
+	} else {
+		log.Printf("Analyzing fetched modules in this process, since %v wasn't found", *worker)
+	}
+
+	s, err := server.New(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	staticFiles, err := fs.Sub(static, "static")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/graph", s)
+	mux.Handle("/", http.FileServer(http.FS(staticFiles)))
+
+	log.Printf("Serving the playground on %v", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
@@ -0,0 +1,192 @@
+// The playground asks the server's /graph API for an svg, and makes it
+// zoomable, pannable and clickable.
+(function() {
+  var form = document.getElementById('form');
+  var graph = document.getElementById('graph');
+  var status = document.getElementById('status');
+  var downloads = document.getElementById('downloads');
+
+  function mode() {
+    return document.querySelector('input[name=mode]:checked').value;
+  }
+
+  document.querySelectorAll('input[name=mode]').forEach(function(input) {
+    input.addEventListener('change', function() {
+      document.getElementById('source-input').hidden = mode() !== 'source';
+      document.getElementById('pkg-input').hidden = mode() !== 'pkg';
+    });
+  });
+
+  // parseFiles splits txtar-style source, with "-- name --" lines before
+  // each file, into an object of file paths to source.
+  function parseFiles(text) {
+    var files = {};
+    var name = 'main.go';
+    var lines = [];
+    text.split('\n').forEach(function(line) {
+      var match = line.match(/^-- (.+) --$/);
+      if (match) {
+        if (lines.join('').trim() !== '') {
+          files[name] = lines.join('\n');
+        }
+        name = match[1].trim();
+        lines = [];
+      } else {
+        lines.push(line);
+      }
+    });
+    if (lines.join('').trim() !== '') {
+      files[name] = lines.join('\n');
+    }
+    return files;
+  }
+
+  // request returns the fetch() arguments for the graph in the given format.
+  function request(format) {
+    if (mode() === 'source') {
+      return ['graph', {
+        method: 'POST',
+        headers: {'Content-Type': 'application/json'},
+        body: JSON.stringify({
+          package: document.getElementById('package').value,
+          files: parseFiles(document.getElementById('source').value),
+          focus: document.getElementById('focus').value,
+          hops: parseInt(document.getElementById('hops').value, 10) || 0,
+          format: format,
+        }),
+      }];
+    }
+    var params = new URLSearchParams({pkg: document.getElementById('pkg').value, format: format});
+    var version = document.getElementById('version').value;
+    if (version) {
+      params.set('version', version);
+    }
+    return ['graph?' + params.toString()];
+  }
+
+  function render() {
+    status.className = '';
+    status.textContent = 'Graphing...';
+    downloads.textContent = '';
+
+    fetch.apply(null, request('svg')).then(function(resp) {
+      return resp.text().then(function(body) {
+        if (!resp.ok) {
+          throw new Error(body);
+        }
+        var version = resp.headers.get('X-Pkgviz-Version');
+        status.textContent = version ? 'Version ' + version : '';
+        show(body);
+        addDownload('svg', body, 'image/svg+xml');
+        fetch.apply(null, request('dot')).then(function(resp) {
+          return resp.ok ? resp.text() : null;
+        }).then(function(dot) {
+          if (dot) {
+            addDownload('dot', dot, 'text/vnd.graphviz');
+          }
+        });
+      });
+    }).catch(function(err) {
+      status.className = 'error';
+      status.textContent = err.message;
+    });
+  }
+
+  function addDownload(format, body, type) {
+    var link = document.createElement('a');
+    link.href = URL.createObjectURL(new Blob([body], {type: type}));
+    link.download = 'types.' + format;
+    link.textContent = 'Download ' + format;
+    downloads.appendChild(document.createTextNode(' '));
+    downloads.appendChild(link);
+  }
+
+  // show replaces the graph with the svg, and makes it interactive.
+  function show(svgText) {
+    graph.innerHTML = svgText;
+    var svg = graph.querySelector('svg');
+    if (!svg) {
+      return;
+    }
+    svg.removeAttribute('width');
+    svg.removeAttribute('height');
+    var box = svg.viewBox.baseVal;
+    var view = {x: box.x, y: box.y, width: box.width, height: box.height};
+
+    function update() {
+      svg.setAttribute('viewBox', [view.x, view.y, view.width, view.height].join(' '));
+    }
+
+    svg.addEventListener('wheel', function(e) {
+      e.preventDefault();
+      var rect = svg.getBoundingClientRect();
+      var scale = e.deltaY > 0 ? 1.1 : 1 / 1.1;
+      var px = view.x + (e.clientX - rect.left) / rect.width * view.width;
+      var py = view.y + (e.clientY - rect.top) / rect.height * view.height;
+      view.x = px - (px - view.x) * scale;
+      view.y = py - (py - view.y) * scale;
+      view.width *= scale;
+      view.height *= scale;
+      update();
+    });
+
+    var drag = null;
+    svg.addEventListener('mousedown', function(e) {
+      drag = {x: e.clientX, y: e.clientY, moved: false};
+    });
+    window.addEventListener('mousemove', function(e) {
+      if (!drag) {
+        return;
+      }
+      var rect = svg.getBoundingClientRect();
+      view.x -= (e.clientX - drag.x) / rect.width * view.width;
+      view.y -= (e.clientY - drag.y) / rect.height * view.height;
+      drag.moved = drag.moved || e.clientX !== drag.x || e.clientY !== drag.y;
+      drag.x = e.clientX;
+      drag.y = e.clientY;
+      update();
+    });
+    window.addEventListener('mouseup', function() {
+      setTimeout(function() { drag = null; });
+    });
+
+    // Clicking a type focuses the graph of pasted source on it.
+    svg.querySelectorAll('g.node').forEach(function(node) {
+      node.addEventListener('click', function() {
+        if ((drag && drag.moved) || mode() !== 'source') {
+          return;
+        }
+        var name = node.querySelector('text');
+        if (name) {
+          var pkgPath = subpackageOf(svg, node);
+          document.getElementById('focus').value = (pkgPath ? pkgPath + '.' : '') + name.textContent.trim();
+          render();
+        }
+      });
+    });
+  }
+
+  // subpackageOf returns the path of the subpackage whose cluster the node is
+  // drawn in, by joining the labels of the clusters around it, outermost
+  // first.
+  function subpackageOf(svg, node) {
+    var nodeBox = node.getBBox();
+    var clusters = [];
+    svg.querySelectorAll('g.cluster').forEach(function(cluster) {
+      var box = cluster.getBBox();
+      var label = cluster.querySelector('text');
+      if (label && box.x <= nodeBox.x && box.y <= nodeBox.y &&
+          box.x + box.width >= nodeBox.x + nodeBox.width &&
+          box.y + box.height >= nodeBox.y + nodeBox.height) {
+        clusters.push({area: box.width * box.height, label: label.textContent.trim()});
+      }
+    });
+    clusters.sort(function(a, b) { return b.area - a.area; });
+    return clusters.map(function(cluster) { return cluster.label; }).join('/');
+  }
+
+  form.addEventListener('submit', function(e) {
+    e.preventDefault();
+    render();
+  });
+})();
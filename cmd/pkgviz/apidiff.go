@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// apiDiff reports how a package's exported API changed between two git refs,
+// classifying each change as breaking or compatible, and renders the head
+// graph with the changed types highlighted.
+func apiDiff(args []string) error {
+	flags := flag.NewFlagSet("apidiff", flag.ExitOnError)
+	base := flags.String("base", "", "The git ref of the API to compare against, e.g. the last release's tag.")
+	head := flags.String("head", "HEAD", "The git ref with the changes.")
+	image := flags.String("image", "apidiff.png", "Write the head graph to this image, with the changed types in red if any of their changes are breaking, or in green if not (empty to skip).")
+	jsonOutput := flags.Bool("json", false, "Print the changes as JSON.")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pkgviz apidiff -base REF [-head REF] [flags] [package]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *base == "" {
+		flags.Usage()
+		return fmt.Errorf("no base ref given")
+	}
+	pkgName := "."
+	if flags.NArg() > 0 {
+		pkgName = flags.Arg(0)
+	}
+
+	baseDir, cleanupBase, err := checkoutRef(*base)
+	if err != nil {
+		return err
+	}
+	defer cleanupBase()
+	headDir, cleanupHead, err := checkoutRef(*head)
+	if err != nil {
+		return err
+	}
+	defer cleanupHead()
+
+	basePkgName, err := resolvePkgName(baseDir, pkgName)
+	if err != nil {
+		return err
+	}
+	headPkgName, err := resolvePkgName(headDir, pkgName)
+	if err != nil {
+		return err
+	}
+
+	baseGraph := pkgviz.BuildGraphWithOptions(basePkgName, pkgviz.Options{Dir: baseDir})
+	headGraph := pkgviz.BuildGraphWithOptions(headPkgName, pkgviz.Options{Dir: headDir})
+	changes := pkgviz.DiffAPI(baseGraph, headGraph)
+
+	if *image != "" {
+		pkgviz.HighlightAPIChanges(headGraph, changes)
+		if err := writeImage(headGraph, *image); err != nil {
+			return err
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(changes)
+	}
+	writeAPIDiff(os.Stdout, headPkgName, *base, *head, changes)
+	if *image != "" {
+		fmt.Printf("\nImage written to %v\n", *image)
+	}
+	return nil
+}
+
+func writeAPIDiff(w io.Writer, pkgName, base, head string, changes []pkgviz.APIChange) {
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "The API of %s didn't change between %s and %s.\n", pkgName, base, head)
+		return
+	}
+
+	fmt.Fprintf(w, "API changes to %s between %s and %s:\n", pkgName, base, head)
+	for _, breaking := range []bool{true, false} {
+		heading := "Compatible changes:"
+		if breaking {
+			heading = "Breaking changes:"
+		}
+		printedHeading := false
+		for _, change := range changes {
+			if change.Breaking != breaking {
+				continue
+			}
+			if !printedHeading {
+				fmt.Fprintf(w, "\n%s\n", heading)
+				printedHeading = true
+			}
+			fmt.Fprintf(w, "  %s\n", change)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// arch renders the packages of a module and the dependencies between them
+// by their types' references, e.g. `pkgviz arch .`.
+func arch(args []string, dotOnly bool) error {
+	flags := flag.NewFlagSet("arch", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "Print the packages and dependencies as JSON.")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pkgviz arch [flags] MODULE")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one module")
+	}
+	pkgName, err := resolvePkgName(".", flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	graph, err := pkgviz.GraphForPackage(pkgName, pkgviz.Options{})
+	if err != nil {
+		return err
+	}
+	architecture := graph.Architecture()
+
+	// The list goes to stderr when the dot file is written to stdout.
+	summary := os.Stdout
+	if dotOnly {
+		fmt.Println(architecture.String())
+		summary = os.Stderr
+	} else if err := writeImage(strings.NewReader(architecture.String()), imageFilename); err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(summary)
+		enc.SetIndent("", "  ")
+		return enc.Encode(architecture)
+	}
+	fmt.Fprintf(summary, "Found %d package(s) with %d dependencies between them\n", len(architecture.Packages), len(architecture.Dependencies))
+	for _, dependency := range architecture.Dependencies {
+		fmt.Fprintf(summary, "  %v\n", dependency)
+	}
+	if !dotOnly {
+		fmt.Fprintf(summary, "Image written to %v\n", imageFilename)
+	}
+	return nil
+}
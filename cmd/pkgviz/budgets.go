@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// readSizeBudgets reads the size budgets of packages' structs from a JSON
+// file, e.g.:
+//
+//	[
+//		{"packages": "pkg/cache/...", "maxStructSize": 64},
+//		{"packages": "pkg/store", "maxStructSize": 256}
+//	]
+func readSizeBudgets(filename string) ([]pkgviz.SizeBudget, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var budgets []pkgviz.SizeBudget
+	if err := json.Unmarshal(data, &budgets); err != nil {
+		return nil, fmt.Errorf("error reading %v: %v", filename, err)
+	}
+	for i, budget := range budgets {
+		if budget.Packages == "" || budget.MaxStructSize <= 0 {
+			return nil, fmt.Errorf("error reading %v: budget %d needs packages and a positive maxStructSize", filename, i+1)
+		}
+	}
+	return budgets, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+	"github.com/tiegz/pkgviz-go/pkg/rules"
+)
+
+// checkRule is a dependency rule, read from the check command's config
+// (see the rules package for the patterns it can have), or a size budget
+// for the structs in some packages. It's one of:
+//
+//	{"from": "pkg/domain", "mustNotReference": "pkg/http"}
+//	{"only": "pkg/store", "mayReference": "*sql.DB"}
+//	{"packages": "pkg/cache/...", "maxStructSize": 64}
+type checkRule struct {
+	From             string `json:"from,omitempty"`
+	MustNotReference string `json:"mustNotReference,omitempty"`
+	Only             string `json:"only,omitempty"`
+	MayReference     string `json:"mayReference,omitempty"`
+	Packages         string `json:"packages,omitempty"`
+	MaxStructSize    int64  `json:"maxStructSize,omitempty"`
+	// Reason is shown along with the rule's violations.
+	Reason string `json:"reason,omitempty"`
+}
+
+func (r checkRule) rule() *rules.Rule {
+	if r.Only != "" {
+		return rules.Only(r.Only, r.MayReference).Because(r.Reason)
+	}
+	return rules.Forbid(r.From, r.MustNotReference).Because(r.Reason)
+}
+
+// recordsGraph is a graph that's already been flattened to records.
+type recordsGraph pkgviz.Records
+
+func (g recordsGraph) Records() pkgviz.Records {
+	return pkgviz.Records(g)
+}
+
+// check builds the graphs of packages, and lists the references between
+// their types that break the rules in the config, and the structs over
+// their size budgets, exiting non-zero if there are any.
+func check(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	configFile := flags.String("config", "pkgviz-rules.json", "A JSON file with a list of rules, e.g. [{\"from\": \"pkg/domain\", \"mustNotReference\": \"pkg/http\"}, {\"only\": \"pkg/store\", \"mayReference\": \"*sql.DB\"}, {\"packages\": \"pkg/cache/...\", \"maxStructSize\": 64}].")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pkgviz check [-config FILE] [packages]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	data, err := ioutil.ReadFile(*configFile)
+	if err != nil {
+		return err
+	}
+	var checkRules []checkRule
+	if err := json.Unmarshal(data, &checkRules); err != nil {
+		return fmt.Errorf("error reading %v: %v", *configFile, err)
+	}
+	var configRules []*rules.Rule
+	var budgets []pkgviz.SizeBudget
+	for i, rule := range checkRules {
+		switch {
+		case rule.Packages != "" && rule.MaxStructSize > 0:
+			budgets = append(budgets, pkgviz.SizeBudget{Packages: rule.Packages, MaxStructSize: rule.MaxStructSize})
+		case (rule.From == "" || rule.MustNotReference == "") && (rule.Only == "" || rule.MayReference == ""):
+			return fmt.Errorf("error reading %v: rule %d needs either from and mustNotReference, only and mayReference, or packages and maxStructSize", *configFile, i+1)
+		default:
+			configRules = append(configRules, rule.rule())
+		}
+	}
+
+	patterns := flags.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	pkgNames, _, err := listPackages(".", patterns)
+	if err != nil {
+		return err
+	}
+
+	violations, oversized := 0, 0
+	for _, pkgName := range pkgNames {
+		graph, err := pkgviz.GraphForPackage(pkgName, pkgviz.Options{})
+		if err != nil {
+			return err
+		}
+		records := graph.Records()
+
+		// Types whose IDs collided are drawn apart, but the collisions are
+		// listed, since they're easy to miss in the graph.
+		for _, collision := range graph.IDCollisions() {
+			fmt.Printf("%s: types' IDs collided: %v\n", pkgName, collision)
+		}
+
+		// Subpackages are graphed with the packages that import them, so
+		// only check the references from each package once.
+		fromPkg := map[string]bool{}
+		for _, node := range records.Nodes {
+			fromPkg[node.ID] = node.Package == pkgName
+		}
+		var edges []pkgviz.EdgeRecord
+		for _, edge := range records.Edges {
+			if fromPkg[edge.From] {
+				edges = append(edges, edge)
+			}
+		}
+		records.Edges = edges
+
+		if err, ok := rules.Check(recordsGraph(records), configRules...).(*rules.Error); ok {
+			for _, v := range err.Violations {
+				fmt.Println(v)
+			}
+			violations += len(err.Violations)
+		}
+
+		if len(budgets) > 0 {
+			structs, err := graph.OversizedStructs(budgets, "")
+			if err != nil {
+				return err
+			}
+			for _, s := range structs {
+				if s.Package == pkgName {
+					fmt.Println(s)
+					oversized++
+				}
+			}
+		}
+	}
+
+	if violations > 0 && oversized > 0 {
+		return fmt.Errorf("%d references break the rules, and %d structs are over their size budget, in %v", violations, oversized, *configFile)
+	}
+	if violations > 0 {
+		return fmt.Errorf("%d references break the rules in %v", violations, *configFile)
+	}
+	if oversized > 0 {
+		return fmt.Errorf("%d structs are over their size budget in %v", oversized, *configFile)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// writeCycleReport writes a report of the package's cycles to a file, with
+// the references that could be cut to break up each cycle of types.
+func writeCycleReport(filename, pkgName string, cycles []pkgviz.Cycle) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	writeCycles(f, pkgName, cycles)
+	return f.Close()
+}
+
+func writeCycles(w io.Writer, pkgName string, cycles []pkgviz.Cycle) {
+	if len(cycles) == 0 {
+		fmt.Fprintf(w, "No cycles in %s\n", pkgName)
+		return
+	}
+
+	fmt.Fprintf(w, "%d cycle(s) in %s\n", len(cycles), pkgName)
+	for i, cycle := range cycles {
+		fmt.Fprintf(w, "\n%d. %v\n", i+1, cycle)
+		if len(cycle.Cuts) > 0 {
+			fmt.Fprintln(w, "   Removing one of these references would break it up the most:")
+			for _, cut := range cycle.Cuts {
+				fmt.Fprintf(w, "   - %v\n", cut)
+			}
+		}
+	}
+}
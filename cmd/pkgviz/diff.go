@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// visualDiff renders a single graph of a package at two git refs, marking
+// the types, fields and references that were added, removed or changed.
+func visualDiff(base, head, pkgName string, dotOnly bool) error {
+	baseDir, cleanupBase, err := checkoutRef(base)
+	if err != nil {
+		return err
+	}
+	defer cleanupBase()
+	headDir, cleanupHead, err := checkoutRef(head)
+	if err != nil {
+		return err
+	}
+	defer cleanupHead()
+
+	basePkgName, err := resolvePkgName(baseDir, pkgName)
+	if err != nil {
+		return err
+	}
+	headPkgName, err := resolvePkgName(headDir, pkgName)
+	if err != nil {
+		return err
+	}
+
+	baseGraph := pkgviz.BuildGraphWithOptions(basePkgName, pkgviz.Options{Dir: baseDir})
+	headGraph := pkgviz.BuildGraphWithOptions(headPkgName, pkgviz.Options{Dir: headDir})
+	diffGraph := pkgviz.VisualDiff(baseGraph, headGraph)
+
+	if dotOnly {
+		diffGraph.WriteTo(os.Stdout)
+		fmt.Println()
+		return nil
+	}
+	if err := writeImage(diffGraph, imageFilename); err != nil {
+		return err
+	}
+	fmt.Printf("Image written to %v\n", imageFilename)
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+const (
+	docDiagramFilename = "doc_diagram.svg"
+
+	// The lines between these markers in doc.go are replaced with a
+	// reference to the diagram. As directives, godoc doesn't show them.
+	docDiagramBeginMarker = "//pkgviz:begin"
+	docDiagramEndMarker   = "//pkgviz:end"
+)
+
+// docDiagram writes the graph of each package to doc_diagram.svg in its
+// directory, and with -inject, refers to it from the package's doc.go, so
+// that the package docs and diagram can be kept in sync with go:generate:
+//
+//	//go:generate pkgviz doc-diagram -inject
+func docDiagram(args []string) error {
+	flags := flag.NewFlagSet("doc-diagram", flag.ExitOnError)
+	inject := flags.Bool("inject", false, "Also refer to the diagram from each package's doc.go, between "+docDiagramBeginMarker+" and "+docDiagramEndMarker+" lines (doc.go is created if there isn't one).")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pkgviz doc-diagram [-inject] [packages]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	patterns := flags.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	pkgNames, dirs, err := listPackages(".", patterns)
+	if err != nil {
+		return err
+	}
+
+	for i, pkgName := range pkgNames {
+		graph, err := pkgviz.GraphForPackage(pkgName, pkgviz.Options{})
+		if err != nil {
+			return err
+		}
+		svg, err := pkgviz.RenderGraph(graph.String(), "svg")
+		if err != nil {
+			return err
+		}
+		if err := writeFileIfChanged(filepath.Join(dirs[i], docDiagramFilename), svg); err != nil {
+			return err
+		}
+		if *inject {
+			if err := injectDocDiagram(dirs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// injectDocDiagram replaces the lines between the markers in the doc.go of
+// the package in dir with a reference to the diagram. If there's no doc.go,
+// one is created with just the markers and reference.
+func injectDocDiagram(dir string) error {
+	docPath := filepath.Join(dir, "doc.go")
+	reference := []string{
+		docDiagramBeginMarker,
+		"//",
+		"// The types in this package, and the references between them, are",
+		"// diagrammed in " + docDiagramFilename + ".",
+		docDiagramEndMarker,
+	}
+
+	src, err := ioutil.ReadFile(docPath)
+	if os.IsNotExist(err) {
+		pkgName, err := packageClauseName(dir)
+		if err != nil {
+			return err
+		}
+		src := strings.Join(reference, "\n") + "\npackage " + pkgName + "\n"
+		return writeFileIfChanged(docPath, []byte(src))
+	} else if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(src), "\n")
+	begin, end := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case docDiagramBeginMarker:
+			begin = i
+		case docDiagramEndMarker:
+			end = i
+		}
+	}
+	if begin < 0 || end < begin {
+		return fmt.Errorf("%v has no %v and %v lines to refer to the diagram between", docPath, docDiagramBeginMarker, docDiagramEndMarker)
+	}
+
+	updated := append(append(append([]string{}, lines[:begin]...), reference...), lines[end+1:]...)
+	return writeFileIfChanged(docPath, []byte(strings.Join(updated, "\n")))
+}
+
+// packageClauseName returns the name in the package clause of the package
+// in dir.
+func packageClauseName(dir string) (string, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.PackageClauseOnly)
+	if err != nil {
+		return "", err
+	}
+	for name := range pkgs {
+		return name, nil
+	}
+	return "", fmt.Errorf("no Go files in %v", dir)
+}
+
+// writeFileIfChanged writes the file unless it already has the given
+// contents, so that regenerating an unchanged diagram doesn't touch it.
+func writeFileIfChanged(filename string, data []byte) error {
+	if existing, err := ioutil.ReadFile(filename); err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
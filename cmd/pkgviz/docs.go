@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// docs writes the graphs of packages into a static site's content directory,
+// as a page per package that embeds the diagram, so that documentation built
+// with Hugo or Docusaurus always shows the current types.
+//
+// For Hugo, each package gets a page bundle: an index.md with front matter
+// that shows the diagram with the figure shortcode. For Docusaurus, each
+// package gets an MDX partial, _types.mdx, which can be imported into any
+// doc, and an index.mdx page that renders it.
+func docs(args []string) error {
+	flags := flag.NewFlagSet("docs", flag.ExitOnError)
+	out := flags.String("out", "content/types", "The content directory to write a directory per package into.")
+	site := flags.String("site", "hugo", "The static site generator to write pages for: hugo or docusaurus.")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pkgviz docs [flags] package...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no package name given")
+	}
+	if *site != "hugo" && *site != "docusaurus" {
+		return fmt.Errorf("unknown site generator %q", *site)
+	}
+
+	for _, arg := range flags.Args() {
+		// Pages are laid out by import path, so that they're the same
+		// wherever the docs are built from.
+		pkgName, err := resolvePkgName(".", arg)
+		if err != nil {
+			return err
+		}
+		dir := filepath.Join(*out, filepath.FromSlash(pkgName))
+		pkgGraph, err := pkgviz.GraphForPackage(pkgName, pkgviz.Options{})
+		if err != nil {
+			return err
+		}
+		if err := writeDocsPage(dir, *site, pkgGraph.String(), pkgGraph.Records()); err != nil {
+			return err
+		}
+		fmt.Printf("Docs written to %v\n", dir)
+	}
+	return nil
+}
+
+// writeDocsPage renders the graph to types.svg in dir, and writes the page
+// (and for Docusaurus, the partial) that embeds it.
+func writeDocsPage(dir, site, dotFile string, records pkgviz.Records) error {
+	svg, err := pkgviz.RenderGraph(dotFile, "svg")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "types.svg"), svg, 0644); err != nil {
+		return err
+	}
+
+	var page, partial bytes.Buffer
+	switch site {
+	case "hugo":
+		writeDocsFrontMatter(&page, records, "")
+		fmt.Fprintf(&page, "{{< figure src=\"types.svg\" alt=%s >}}\n\n", strconv.Quote("Types in "+records.Package))
+		writeDocsTypeTable(&page, records)
+		return ioutil.WriteFile(filepath.Join(dir, "index.md"), page.Bytes(), 0644)
+	default:
+		fmt.Fprintln(&partial, "{/* Generated by pkgviz; DO NOT EDIT. */}")
+		fmt.Fprintln(&partial)
+		fmt.Fprintf(&partial, "![Types in %s](./types.svg)\n\n", records.Package)
+		writeDocsTypeTable(&partial, records)
+		if err := ioutil.WriteFile(filepath.Join(dir, "_types.mdx"), partial.Bytes(), 0644); err != nil {
+			return err
+		}
+
+		writeDocsFrontMatter(&page, records, records.Package)
+		fmt.Fprintln(&page, "import Types from './_types.mdx';")
+		fmt.Fprintln(&page)
+		fmt.Fprintln(&page, "<Types />")
+		return ioutil.WriteFile(filepath.Join(dir, "index.mdx"), page.Bytes(), 0644)
+	}
+}
+
+// writeDocsFrontMatter writes YAML front matter with the page's title and
+// description, the sidebar label (if any), and the graph's stats for
+// templates to use.
+func writeDocsFrontMatter(w io.Writer, records pkgviz.Records, sidebarLabel string) {
+	fmt.Fprintln(w, "---")
+	fmt.Fprintln(w, "# Generated by pkgviz; DO NOT EDIT.")
+	fmt.Fprintf(w, "title: %s\n", strconv.Quote(records.Package+" types"))
+	fmt.Fprintf(w, "description: %s\n", strconv.Quote("The types declared in "+records.Package+", and the references between them."))
+	if sidebarLabel != "" {
+		fmt.Fprintf(w, "sidebar_label: %s\n", strconv.Quote(sidebarLabel))
+	}
+	fmt.Fprintln(w, "pkgviz:")
+	fmt.Fprintf(w, "  package: %s\n", strconv.Quote(records.Package))
+	fmt.Fprintf(w, "  types: %d\n", countDeclaredTypes(records))
+	fmt.Fprintf(w, "  references: %d\n", len(records.Edges))
+	fmt.Fprintln(w, "---")
+	fmt.Fprintln(w)
+}
+
+// writeDocsTypeTable writes a Markdown table of the graph's types, and the
+// types that each of their fields refers to.
+func writeDocsTypeTable(w io.Writer, records pkgviz.Records) {
+	references := map[string][]string{}
+	for _, edge := range records.Edges {
+		references[edge.From] = append(references[edge.From], fmt.Sprintf("`%s` → `%s`", edge.Field, edge.To))
+	}
+
+	fmt.Fprintln(w, "| Type | Kind | References |")
+	fmt.Fprintln(w, "| --- | --- | --- |")
+	for _, node := range records.Nodes {
+		if node.Kind == "external" {
+			continue
+		}
+		fmt.Fprintf(
+			w,
+			"| `%s` | %s | %s |\n",
+			node.ID,
+			node.Kind,
+			escapeMarkdownTableCell(strings.Join(references[node.ID], ", ")),
+		)
+	}
+}
+
+func countDeclaredTypes(records pkgviz.Records) int {
+	n := 0
+	for _, node := range records.Nodes {
+		if node.Kind != "external" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// writeExport writes a graph exported in another format (e.g. by its
+// WritePlantUML) to a file.
+func writeExport(filename string, write func(w io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
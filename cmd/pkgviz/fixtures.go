@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// goldenFiler is a built graph, which has golden files.
+type goldenFiler interface {
+	GoldenFiles() (dot, records []byte, err error)
+}
+
+// goldenGraph is a graph to write golden files for, named by the files'
+// base name.
+type goldenGraph struct {
+	name  string
+	build func() (goldenFiler, error)
+}
+
+// genFixtures writes the golden files (a .dot and a .json file) of the
+// built-in fixtures, or of the given packages, or with -check, checks that
+// they haven't changed, so that changes to how graphs are drawn show up in
+// review, e.g. in CI:
+//
+//	pkgviz gen-fixtures -dir testdata/golden -check ./...
+func genFixtures(args []string) error {
+	flags := flag.NewFlagSet("gen-fixtures", flag.ExitOnError)
+	dir := flags.String("dir", filepath.Join("testdata", "fixtures"), "The directory to write the golden files to.")
+	checkOnly := flags.Bool("check", false, "Check that the golden files are up to date, rather than writing them, exiting non-zero if any aren't.")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pkgviz gen-fixtures [-dir DIR] [-check] [packages]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var graphs []goldenGraph
+	if patterns := flags.Args(); len(patterns) > 0 {
+		pkgNames, _, err := listPackages(".", patterns)
+		if err != nil {
+			return err
+		}
+		for _, pkgName := range pkgNames {
+			pkgName := pkgName
+			graphs = append(graphs, goldenGraph{
+				name: strings.Replace(pkgName, "/", "_", -1),
+				build: func() (goldenFiler, error) {
+					return pkgviz.GraphForPackage(pkgName, pkgviz.Options{NormalizeTypes: true})
+				},
+			})
+		}
+	} else {
+		for _, fixture := range pkgviz.Fixtures() {
+			fixture := fixture
+			graphs = append(graphs, goldenGraph{
+				name: fixture.Name,
+				build: func() (goldenFiler, error) {
+					return fixture.Build()
+				},
+			})
+		}
+	}
+
+	if !*checkOnly {
+		if err := os.MkdirAll(*dir, 0755); err != nil {
+			return err
+		}
+	}
+	stale := 0
+	for _, graph := range graphs {
+		built, err := graph.build()
+		if err != nil {
+			return err
+		}
+		dot, records, err := built.GoldenFiles()
+		if err != nil {
+			return err
+		}
+		for ext, data := range map[string][]byte{".dot": dot, ".json": records} {
+			filename := filepath.Join(*dir, graph.name+ext)
+			if !*checkOnly {
+				if err := writeFileIfChanged(filename, data); err != nil {
+					return err
+				}
+				continue
+			}
+			if existing, err := ioutil.ReadFile(filename); err != nil || !bytes.Equal(existing, data) {
+				fmt.Printf("%s is out of date\n", filename)
+				stale++
+			}
+		}
+	}
+	if stale > 0 {
+		return fmt.Errorf("%d golden files in %v are out of date: run pkgviz gen-fixtures without -check to update them", stale, *dir)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// checkoutRef checks out a git ref into a temporary worktree, and returns
+// the directory in it that corresponds to the current directory, along with
+// a func that removes the worktree again.
+func checkoutRef(ref string) (string, func(), error) {
+	prefix, err := gitOutput("rev-parse", "--show-prefix")
+	if err != nil {
+		return "", nil, err
+	}
+
+	worktreeDir, err := ioutil.TempDir("", "pkgviz-worktree")
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := gitOutput("worktree", "add", "--detach", worktreeDir, ref); err != nil {
+		os.RemoveAll(worktreeDir)
+		return "", nil, err
+	}
+
+	cleanup := func() {
+		gitOutput("worktree", "remove", "--force", worktreeDir)
+		os.RemoveAll(worktreeDir)
+	}
+	return filepath.Join(worktreeDir, filepath.FromSlash(strings.TrimSpace(prefix))), cleanup, nil
+}
+
+func gitOutput(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("error running '%v': %s", cmd.String(), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// resolvePkgName returns the import path of the package that pkgName (which
+// may be relative, like ".") refers to from dir.
+func resolvePkgName(dir, pkgName string) (string, error) {
+	cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}", pkgName)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("error running '%v': %s", cmd.String(), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// listPackages returns the import paths and directories of the packages
+// that the patterns (like "./...") match from dir.
+func listPackages(dir string, patterns []string) (importPaths, dirs []string, err error) {
+	cmd := exec.Command("go", append([]string{"list", "-f", "{{.ImportPath}}\t{{.Dir}}"}, patterns...)...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, nil, fmt.Errorf("error running '%v': %s", cmd.String(), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, nil, err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if fields := strings.SplitN(line, "\t", 2); len(fields) == 2 {
+			importPaths = append(importPaths, fields[0])
+			dirs = append(dirs, fields[1])
+		}
+	}
+	return importPaths, dirs, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// implementers lists and renders the types in the given packages that
+// implement an interface, e.g. `pkgviz implements io.Reader ./...`.
+func implementers(args []string, dotOnly bool) error {
+	flags := flag.NewFlagSet("implements", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "Print the implementers as JSON.")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pkgviz implements [flags] INTERFACE [packages]")
+		fmt.Fprintln(flags.Output(), "INTERFACE is qualified by its package's import path, e.g. io.Reader or example.com/mod/store.Store.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no interface given")
+	}
+	patterns := flags.Args()[1:]
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	found, graph, err := pkgviz.FindImplementers(flags.Arg(0), patterns, pkgviz.Options{})
+	if err != nil {
+		return err
+	}
+
+	// The list goes to stderr when the dot file is written to stdout.
+	summary := os.Stdout
+	if dotOnly {
+		graph.WriteTo(os.Stdout)
+		fmt.Println()
+		summary = os.Stderr
+	} else if len(found) > 0 {
+		if err := writeImage(graph, imageFilename); err != nil {
+			return err
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(summary)
+		enc.SetIndent("", "  ")
+		return enc.Encode(found)
+	}
+	fmt.Fprintf(summary, "Found %d implementer(s) of %s\n", len(found), flags.Arg(0))
+	for _, implementer := range found {
+		fmt.Fprintf(summary, "  %v\n", implementer)
+	}
+	if !dotOnly && len(found) > 0 {
+		fmt.Fprintf(summary, "Image written to %v\n", imageFilename)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/csv"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// writeImplementsReport writes a matrix of which of a graph's types
+// implement which of its interfaces to a file, as HTML if its name ends in
+// .html, or as CSV otherwise.
+func writeImplementsReport(filename, pkgName string, matrix pkgviz.ImplementsMatrix) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".html", ".htm":
+		err = writeImplementsHTML(f, pkgName, matrix)
+	default:
+		err = writeImplementsCSV(f, matrix)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// implementsCell describes whether a type implements an interface, e.g.
+// "yes", "pointer" (only a pointer to it does) or "missing Close".
+func implementsCell(matrix pkgviz.ImplementsMatrix, iface, typeName string) string {
+	impl, ok := matrix.Implementation(iface, typeName)
+	switch {
+	case !ok:
+		return ""
+	case !impl.Implements():
+		return "missing " + impl.Missing
+	case impl.Pointer:
+		return "pointer"
+	default:
+		return "yes"
+	}
+}
+
+// writeImplementsCSV writes a row for each interface, with a column for
+// each type.
+func writeImplementsCSV(w io.Writer, matrix pkgviz.ImplementsMatrix) error {
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"interface"}, matrix.Types...))
+	for _, iface := range matrix.Interfaces {
+		row := []string{iface}
+		for _, typeName := range matrix.Types {
+			row = append(row, implementsCell(matrix, iface, typeName))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var implementsTemplate = template.Must(template.New("implements").Funcs(template.FuncMap{
+	"cell": implementsCell,
+	// The cell's first word is its class, e.g. "missing".
+	"class": func(cell string) string { return strings.Fields(cell)[0] },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Interfaces implemented in {{.Package}}</title>
+<style>
+body { font-family: Arial, sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #cccccc; padding: 4px 8px; }
+td.yes, td.pointer { background: #c6efce; }
+td.missing { background: #fff2cc; }
+</style>
+</head>
+<body>
+<h1>Interfaces implemented in {{.Package}}</h1>
+<p>Cells say whether the type implements the interface, whether only a pointer to it does, or which method it's missing if that's the only one.</p>
+<table>
+<tr><th></th>{{range .Matrix.Types}}<th>{{.}}</th>{{end}}</tr>
+{{- range $iface := .Matrix.Interfaces}}
+<tr><th>{{$iface}}</th>{{range $type := $.Matrix.Types}}{{with cell $.Matrix $iface $type}}<td class="{{class .}}">{{.}}</td>{{else}}<td></td>{{end}}{{end}}</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+func writeImplementsHTML(w io.Writer, pkgName string, matrix pkgviz.ImplementsMatrix) error {
+	return implementsTemplate.Execute(w, struct {
+		Package string
+		Matrix  pkgviz.ImplementsMatrix
+	}{pkgName, matrix})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// jsonSchema writes a JSON Schema document for each exported struct in a
+// package, e.g. `pkgviz jsonschema -out schemas ./pkg/api`.
+func jsonSchema(args []string) error {
+	flags := flag.NewFlagSet("jsonschema", flag.ExitOnError)
+	outDir := flags.String("out", "", "Write each schema to NAME.schema.json in this directory, rather than all of them to stdout as one JSON object by name.")
+	typeName := flags.String("type", "", "Only write the schema of this type, e.g. User or nested.NestedStruct.")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pkgviz jsonschema [flags] PACKAGE")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one package")
+	}
+	pkgName, err := resolvePkgName(".", flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	graph, err := pkgviz.GraphForPackage(pkgName, pkgviz.Options{})
+	if err != nil {
+		return err
+	}
+	schemas := graph.JSONSchemas()
+	if *typeName != "" {
+		schema, ok := schemas[*typeName]
+		if !ok {
+			return fmt.Errorf("%s has no exported struct %s", pkgName, *typeName)
+		}
+		schemas = map[string]*pkgviz.JSONSchema{*typeName: schema}
+	}
+
+	if *outDir == "" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if *typeName != "" {
+			return enc.Encode(schemas[*typeName])
+		}
+		return enc.Encode(schemas)
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		return err
+	}
+	var names []string
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := json.MarshalIndent(schemas[name], "", "  ")
+		if err != nil {
+			return err
+		}
+		filename := filepath.Join(*outDir, strings.Replace(name, "/", ".", -1)+".schema.json")
+		if err := ioutil.WriteFile(filename, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Schema written to %v\n", filename)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// readLayers reads the layers of an architecture from a JSON file, from the
+// top layer down, e.g.:
+//
+//	[
+//		{"name": "handlers", "packages": ["pkg/http/..."]},
+//		{"name": "services", "packages": ["pkg/service"]},
+//		{"name": "repositories", "packages": ["pkg/store", "pkg/cache"]}
+//	]
+func readLayers(filename string) ([]pkgviz.Layer, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var layers []pkgviz.Layer
+	if err := json.Unmarshal(data, &layers); err != nil {
+		return nil, fmt.Errorf("error reading %v: %v", filename, err)
+	}
+	for i, layer := range layers {
+		if layer.Name == "" || len(layer.Packages) == 0 {
+			return nil, fmt.Errorf("error reading %v: layer %d needs a name and packages", filename, i+1)
+		}
+	}
+	return layers, nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/artifact"
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// lspish serves editor plugins with JSON-RPC 2.0 over stdio, framed with
+// Content-Length headers like the Language Server Protocol. Its methods are:
+//
+//	initialize                                    -> {serverInfo, methods}
+//	pkgviz/graphForFile {file, focusFile, hops,   -> {package, graph}
+//	                     format}
+//	pkgviz/neighborhood {file, line, character,   -> {package, type, graph}
+//	                     hops, format}
+//	textDocument/didSave {textDocument: {uri}}      (notification)
+//	shutdown                                      -> null
+//	exit                                            (notification)
+//
+// Files are paths or file:// URIs, lines and characters are 0-based (with
+// characters counted in bytes), focusFile limits the graph to the types
+// declared in the file, hops defaults to 1, and the format is "dot"
+// (the default) or "svg". Graphs are cached between requests, and a package
+// is only re-analyzed after one of its files is saved.
+func lspish(in io.Reader, out io.Writer) error {
+	s := &lspishServer{
+		cache: pkgviz.NewCache(),
+		out:   out,
+	}
+
+	r := bufio.NewReader(in)
+	for {
+		body, err := readMessage(r)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.reply(nil, nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+
+		result, rpcErr := s.handle(&req)
+		// Notifications have no id, and get no response.
+		if req.ID != nil {
+			s.reply(req.ID, result, rpcErr)
+		}
+	}
+}
+
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+type rpcRequest struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type graphParams struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Character int    `json:"character"`
+	Hops      *int   `json:"hops"`
+	FocusFile bool   `json:"focusFile"`
+	Format    string `json:"format"`
+}
+
+type graphResult struct {
+	Package string `json:"package"`
+	Type    string `json:"type,omitempty"`
+	Graph   string `json:"graph"`
+}
+
+type didSaveParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+}
+
+type lspishServer struct {
+	cache *pkgviz.Cache
+	out   io.Writer
+}
+
+func (s *lspishServer) handle(req *rpcRequest) (interface{}, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"serverInfo": map[string]string{"name": "pkgviz"},
+			"methods":    []string{"pkgviz/graphForFile", "pkgviz/neighborhood"},
+		}, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didSave":
+		var params didSaveParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		s.cache.InvalidateDir(filepath.Dir(filePath(params.TextDocument.URI)))
+		return nil, nil
+	case "pkgviz/graphForFile", "pkgviz/neighborhood":
+		var params graphParams
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.File == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "expected params {file, ...}"}
+		}
+		result, err := s.graph(req.Method == "pkgviz/neighborhood", &params)
+		if err != nil {
+			return nil, &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		return result, nil
+	default:
+		return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", req.Method)}
+	}
+}
+
+func (s *lspishServer) graph(neighborhood bool, params *graphParams) (*graphResult, error) {
+	file, err := filepath.Abs(filePath(params.File))
+	if err != nil {
+		return nil, err
+	}
+	pkgName, err := pkgviz.PackageForFile(file, pkgviz.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &graphResult{Package: pkgName}
+	opts := pkgviz.Options{Cache: s.cache, FocusFile: params.FocusFile, Hops: 1}
+	if params.Hops != nil {
+		opts.Hops = *params.Hops
+	}
+	if neighborhood {
+		if result.Type, err = typeAtPosition(file, params.Line, params.Character); err != nil {
+			return nil, err
+		}
+		opts.Focus = result.Type
+	}
+
+	if result.Graph, err = pkgviz.WriteGraphForFile(file, opts); err != nil {
+		return nil, err
+	}
+	switch params.Format {
+	case "", "dot":
+	case "svg":
+		svg, err := pkgviz.RenderGraph(result.Graph, "svg")
+		if err != nil {
+			return nil, err
+		}
+		result.Graph = string(svg)
+	default:
+		return nil, fmt.Errorf("unknown format %q", params.Format)
+	}
+	return result, nil
+}
+
+func (s *lspishServer) reply(id *json.RawMessage, result interface{}, rpcErr *rpcError) {
+	body, _ := json.Marshal(rpcResponse{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr})
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// readMessage reads the body of the next message, after its headers.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	contentLength := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if i := strings.Index(line, ":"); i >= 0 && strings.EqualFold(line[:i], "Content-Length") {
+			if contentLength, err = strconv.Atoi(strings.TrimSpace(line[i+1:])); err != nil {
+				return nil, fmt.Errorf("invalid header %q", line)
+			}
+		}
+	}
+	if contentLength < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	body := make([]byte, contentLength)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+
+// filePath turns a file:// URI into a path, leaving paths as they are.
+// Windows paths like `C:\foo` parse as URIs with a "c" scheme, so they're
+// left as they are too.
+func filePath(file string) string {
+	if u, err := url.Parse(file); err == nil && u.Scheme == "file" {
+		return artifact.FilePath(u)
+	}
+	return file
+}
+
+// typeAtPosition returns the name of the type under the cursor: either the
+// type being declared, or a type that's referred to. Only types declared in
+// the file's own package can be graphed.
+func typeAtPosition(file string, line, character int) (string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, 0)
+	if err != nil {
+		return "", err
+	}
+
+	// Lines are 1-based in go/token.
+	tf := fset.File(f.Pos())
+	if line < 0 || line+1 > tf.LineCount() {
+		return "", fmt.Errorf("line %d is past the end of %v", line, file)
+	}
+	lineStart, lineEnd := tf.LineStart(line+1), token.Pos(tf.Base()+tf.Size())
+	if line+1 < tf.LineCount() {
+		lineEnd = tf.LineStart(line+2) - 1
+	}
+	if character < 0 || lineStart+token.Pos(character) > lineEnd {
+		return "", fmt.Errorf("character %d is past the end of line %d of %v", character, line, file)
+	}
+	pos := lineStart + token.Pos(character)
+
+	var ident *ast.Ident
+	var qualified bool
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || pos < n.Pos() || pos > n.End() {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if pos >= n.Sel.Pos() {
+				ident, qualified = n.Sel, true
+				return false
+			}
+		case *ast.Ident:
+			ident, qualified = n, false
+		}
+		return true
+	})
+	if ident == nil {
+		return "", fmt.Errorf("no type at %v:%d:%d", file, line, character)
+	}
+	if qualified {
+		return "", fmt.Errorf("%v is not declared in this package", ident.Name)
+	}
+
+	declared, err := typesDeclaredInDir(filepath.Dir(file))
+	if err != nil {
+		return "", err
+	}
+	if !declared[ident.Name] {
+		return "", fmt.Errorf("%v is not a type declared in this package", ident.Name)
+	}
+	return ident.Name, nil
+}
+
+// typesDeclaredInDir returns the names of the types declared in the
+// package in dir.
+func typesDeclaredInDir(dir string) (map[string]bool, error) {
+	filenames, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	declared := map[string]bool{}
+	for _, filename := range filenames {
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filename, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if typeSpec, ok := n.(*ast.TypeSpec); ok {
+				declared[typeSpec.Name.Name] = true
+			}
+			return true
+		})
+	}
+	return declared, nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// frame frames JSON-RPC messages with Content-Length headers.
+func frame(messages ...string) string {
+	var b strings.Builder
+	for _, message := range messages {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n%s", len(message), message)
+	}
+	return b.String()
+}
+
+// readResponses reads every response that lspish wrote.
+func readResponses(t *testing.T, out []byte) []rpcResponse {
+	var responses []rpcResponse
+	r := bufio.NewReader(bytes.NewReader(out))
+	for {
+		body, err := readMessage(r)
+		if err == io.EOF {
+			return responses
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		var response rpcResponse
+		if err := json.Unmarshal(body, &response); err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, response)
+	}
+}
+
+// writeLspishModule writes a module with a package of the given source, and
+// returns the path of its file.
+func writeLspishModule(t *testing.T, src string) string {
+	dir, err := ioutil.TempDir("", "pkgviz-lspish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/lspish\n\ngo 1.16\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "lspish.go")
+	if err := ioutil.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLspishFraming(t *testing.T) {
+	in := frame(
+		`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {}}`,
+		`{"jsonrpc": "2.0", "method": "initialized", "params": {}}`,
+		`{"jsonrpc": "2.0", "id": "two", "method": "pkgviz/unknown"}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "pkgviz/graphForFile", "params": {}}`,
+		`{not json`,
+		`{"jsonrpc": "2.0", "id": 4, "method": "shutdown"}`,
+		`{"jsonrpc": "2.0", "method": "exit"}`,
+		`{"jsonrpc": "2.0", "id": 5, "method": "shutdown"}`,
+	)
+	var out bytes.Buffer
+	if err := lspish(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := readResponses(t, out.Bytes())
+	var summaries []string
+	for _, response := range responses {
+		id := "null"
+		if response.ID != nil {
+			id = string(*response.ID)
+		}
+		code := 0
+		if response.Error != nil {
+			code = response.Error.Code
+		}
+		summaries = append(summaries, fmt.Sprintf("%s %d", id, code))
+	}
+	// The notification gets no response, and nothing after exit is read.
+	expected := []string{"1 0", `"two" -32601`, "3 -32602", "null -32700", "4 0"}
+	if strings.Join(summaries, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected responses %v, got %v", expected, summaries)
+	}
+	if methods := responses[0].Result.(map[string]interface{})["methods"]; fmt.Sprint(methods) != "[pkgviz/graphForFile pkgviz/neighborhood]" {
+		t.Errorf("Expected initialize to list the methods, got %v", methods)
+	}
+}
+
+func TestLspishRejectsUnframedMessages(t *testing.T) {
+	in := "Content-Type: application/json\r\n\r\n{}"
+	if err := lspish(strings.NewReader(in), ioutil.Discard); err == nil || !strings.Contains(err.Error(), "missing Content-Length") {
+		t.Errorf("Expected a missing Content-Length error, got %v", err)
+	}
+	in = "Content-Length: lots\r\n\r\n{}"
+	if err := lspish(strings.NewReader(in), ioutil.Discard); err == nil || !strings.Contains(err.Error(), "invalid header") {
+		t.Errorf("Expected an invalid header error, got %v", err)
+	}
+}
+
+func TestTypeAtPosition(t *testing.T) {
+	file := writeLspishModule(t, "package lspish\n\ntype Server struct {\n\tstore Store\n}\n\ntype Store struct{}")
+
+	for _, test := range []struct {
+		line, character int
+		expected        string
+		err             string
+	}{
+		{2, 5, "Server", ""},
+		{3, 8, "Store", ""},
+		{6, 5, "Store", ""},
+		{6, 19, "", "no type"},
+		{6, 20, "", "past the end of line 6"},
+		{2, 25, "", "past the end of line 2"},
+		{2, -1, "", "past the end of line 2"},
+		{7, 0, "", "line 7 is past the end"},
+		{-1, 0, "", "line -1 is past the end"},
+		{0, 8, "", "not a type declared"},
+	} {
+		actual, err := typeAtPosition(file, test.line, test.character)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected %d:%d to fail with %q, got %q, %v", test.line, test.character, test.err, actual, err)
+			}
+		} else if err != nil || actual != test.expected {
+			t.Errorf("Expected %d:%d to be %s, got %q, %v", test.line, test.character, test.expected, actual, err)
+		}
+	}
+}
+
+func TestLspishDidSaveInvalidatesCache(t *testing.T) {
+	file := writeLspishModule(t, "package lspish\n\ntype Server struct{}\n")
+	request := fmt.Sprintf(`{"jsonrpc": "2.0", "id": 1, "method": "pkgviz/graphForFile", "params": {"file": %q}}`, file)
+	didSave := fmt.Sprintf(`{"jsonrpc": "2.0", "method": "textDocument/didSave", "params": {"textDocument": {"uri": %q}}}`, "file://"+filepath.ToSlash(file))
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- lspish(inR, outW)
+		outW.Close()
+	}()
+	responses := bufio.NewReader(outR)
+	graph := func() string {
+		t.Helper()
+		io.WriteString(inW, frame(request))
+		body, err := readMessage(responses)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var response struct {
+			Result graphResult `json:"result"`
+			Error  *rpcError   `json:"error"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Error != nil {
+			t.Fatalf("Expected a graph, got %v", response.Error.Message)
+		}
+		return response.Result.Graph
+	}
+
+	if actual := graph(); !strings.Contains(actual, ">Server<") {
+		t.Fatalf("Expected Server to be graphed, got %s", actual)
+	}
+	if err := ioutil.WriteFile(file, []byte("package lspish\n\ntype Server struct{}\n\ntype Client struct{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Until the file is saved, the cached graph is served.
+	if actual := graph(); strings.Contains(actual, ">Client<") {
+		t.Errorf("Expected the cached graph before didSave, got %s", actual)
+	}
+	io.WriteString(inW, frame(didSave))
+	if actual := graph(); !strings.Contains(actual, ">Client<") {
+		t.Errorf("Expected Client to be graphed after didSave, got %s", actual)
+	}
+
+	inW.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
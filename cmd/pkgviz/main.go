@@ -3,16 +3,102 @@ package main
 import (
 	"flag"
 	"fmt"
+	"go/types"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
 )
 
+// imageFilename is where the image is written, with the -format's
+// extension rather than png's, if it's given.
+const imageFilename = "out.png"
+
+// imageFormats are the formats that the image can be written in: graphviz's
+// output formats, which are passed to dot as its -T flag, and html, for the
+// page that RenderHTML writes.
+var imageFormats = []string{
+	"bmp", "canon", "cmapx", "dot", "eps", "gif", "html", "imap", "jpeg", "jpg", "json",
+	"pdf", "plain", "png", "ps", "svg", "svgz", "tif", "tiff", "webp", "xdot",
+}
+
+// The defaults of -max-nodes and -max-edges, for the command and the
+// server's workers.
+const (
+	defaultMaxNodes = 1000
+	defaultMaxEdges = 3000
+)
+
 func main() {
+	// The server's workers are run with -worker, which is handled before
+	// any other flags or subcommands, and isn't listed among them.
+	if len(os.Args) > 1 && os.Args[1] == "-worker" {
+		if err := worker(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	dotOnly := flag.Bool("dotOnly", false, "Only output the dot file text instead of writing to an image.")
+	format := flag.String("format", "png", "The format to write the image in, to out.FORMAT: one of graphviz's output formats, e.g. png, svg (which stays sharp when zoomed and has selectable text), pdf, dot (laid out) or cmapx, or html (a page to pan, zoom and search the graph in, and highlight types' references).")
+	svg := flag.Bool("svg", false, "Write the image as SVG, the same as -format svg.")
+	blame := flag.Bool("blame", false, "Annotate types with when they were last modified, and their owners or primary author, from git.")
+	staleAfter := flag.Duration("stale-after", 180*24*time.Hour, "With -blame, gray out types that haven't been modified for this long (0 to disable).")
+	churnDays := flag.Int("churn-days", 0, "Color types on a heat scale by how many commits changed them in this many days (0 to disable).")
+	upload := flag.String("upload", "", "Also upload the rendered graph, its dot file and its JSON model to this location: s3://bucket/path, gs://bucket/path, or a directory (which gets an index.html).")
+	notifyURL := flag.String("notify-url", "", "POST a JSON summary of the run (the package, stats, uploaded artifacts and changes) to this Slack-compatible or generic webhook.")
+	cycles := flag.Bool("cycles", false, "Highlight the types and packages that refer to each other in cycles in red, and list the cycles.")
+	cycleReport := flag.String("cycle-report", "", "Write a report of the cycles, with the references that could be cut to break them up, to this file.")
+	depth := flag.Bool("depth", false, "Color types by their layer, from the types that refer to no others up, with the references that don't point down a layer in red.")
+	weights := flag.Bool("weights", false, "Draw one arrow from each type to each type it refers to, as thick as the number of its fields that do, and annotate the types that many others refer to.")
+	layout := flag.Bool("layout", false, "Annotate structs with their size in memory and padding, and their fields with their offsets and sizes, in the order they're laid out in.")
+	layoutArch := flag.String("layout-arch", "", "With -layout, -padding-report or -size-budgets, the architecture to lay structs out for, e.g. 386 (defaults to the current one).")
+	sizeBudgetsFile := flag.String("size-budgets", "", "A JSON file with the most bytes that the structs in packages may take up, e.g. [{\"packages\": \"pkg/cache/...\", \"maxStructSize\": 64}], to mark and list the structs over their budget (laid out for -layout-arch).")
+	paddingReport := flag.String("padding-report", "", "Write the structs that would be smaller with their fields reordered, and the orders that would make them smallest, to this file.")
+	noCopy := flag.Bool("no-copy", false, "Badge the types that contain a sync.Mutex, sync.WaitGroup, atomic value or the like, which mustn't be copied, and color the arrows from the fields that hold them by value.")
+	contextFields := flag.Bool("context-fields", false, "Mark the struct fields that store a context.Context, which should be passed to each call that needs it instead, and list them.")
+	contextFieldsAllow := flag.String("context-fields-allow", "", "With -context-fields, a comma-separated list of the structs that may store a context.Context, qualified by their subpackage if they're in one, e.g. \"request,jobs.Job\".")
+	vars := flag.Bool("vars", false, "Draw the package-level variables whose types are in the graph as small nodes linked to their types, showing global state and singletons.")
+	funcs := flag.Bool("funcs", false, "Draw the package-level functions, with their signatures, as small nodes linked to the types in the graph that they take or return.")
+	layersFile := flag.String("layers", "", "A JSON file with the layers of the architecture from the top down, e.g. [{\"name\": \"handlers\", \"packages\": [\"pkg/http/...\"]}, {\"name\": \"repositories\", \"packages\": [\"pkg/store\"]}], to draw in rows, with the references that point up a layer in red.")
+	layersStrict := flag.Bool("layers-strict", false, "With -layers, exit non-zero if any references point up a layer.")
+	intendedFile := flag.String("intended", "", "A file with the types and references (e.g. \"Server -> store.DB\") that the package is intended to have, to mark and list how it differs from them.")
+	intendedStrict := flag.Bool("intended-strict", false, "With -intended, exit non-zero if the package differs from the intended architecture.")
+	docCoverage := flag.Bool("doc-coverage", false, "Mark the exported types, fields and methods that have no doc comment, and list them with each package's documentation coverage.")
+	docSummaries := flag.Bool("doc-summaries", false, "Show the first sentence of each type's doc comment under its name.")
+	showPositions := flag.Bool("show-positions", false, "Show the file and line that each type is declared on, e.g. server.go:42, at the bottom of it.")
+	constructors := flag.Bool("constructors", false, "List the functions that construct each type (New... or Must... functions that return it) under its name.")
+	harness := flag.String("harness", "", "Mark the types declared in main packages or only used by tests: tag them in their own colors, or hide them.")
+	generated := flag.String("generated", "", "Mark the types generated by tools like protoc-gen-go or mockgen: tag them with their generator, or group each package's into a collapsed cluster.")
+	dbSchema := flag.Bool("db-schema", false, "Draw the structs with gorm or sqlx (db) tags as database tables, with their columns, keys and relations.")
+	proto := flag.Bool("proto", false, "Collapse the protobuf messages generated by protoc-gen-go, and draw the functions that convert between them and the hand-written types instead.")
+	unused := flag.Bool("unused", false, "Mark the exported types that no other package in the module refers to in gray, and list them.")
+	unread := flag.Bool("unread", false, "Gray out the struct fields that nothing in the module reads, and list them.")
+	implements := flag.String("implements", "", "Write a matrix of which types implement which interfaces, and which are missing only one method, to this file, as HTML if it ends in .html or CSV otherwise.")
+	implementsArrows := flag.Bool("implements-arrows", false, "Draw a dashed arrow with an open triangle from each type to each interface in the graph that it implements.")
+	almostImplements := flag.Bool("almost-implements", false, "List the types that are one or two methods short of implementing an interface, with the signatures of the missing methods.")
+	metrics := flag.String("metrics", "", "Write the fan-in, fan-out, instability and abstractness of each type and package to this file, as JSON if it ends in .json or CSV otherwise.")
+	dependencyMatrix := flag.String("dependency-matrix", "", "Write a matrix of how many references there are from the types in each package to the types in each other package to this file, as HTML if it ends in .html or CSV otherwise.")
+	plantUML := flag.String("plantuml", "", "Also write the graph as a PlantUML class diagram to this file, e.g. types.puml.")
+	d2 := flag.String("d2", "", "Also write the graph in the D2 diagram language to this file, e.g. types.d2.")
+	graphML := flag.String("graphml", "", "Also write the graph as GraphML to this file, e.g. types.graphml, to explore in yEd or Gephi.")
+	sizeBy := flag.String("size-by", "", "Scale the names of the types by a metric: fan-in or fan-out.")
+	maxDepth := flag.Int("max-depth", 0, "Only graph this many levels of subpackages below the package, noting the deeper ones that are left out (0 for every level).")
+	exclude := flag.String("exclude", "", "Leave out the packages whose import paths match this regular expression, and the types whose qualified names do (e.g. example.com/foo/mocks.Store), e.g. \"/mocks$|\\.Mock\" to hide mocks.")
+	focus := flag.String("focus", "", "Only graph this type and the types within -hops references of it, named relative to the package, e.g. \"store.Item\", or by its package's import path.")
+	hops := flag.Int("hops", 1, "With -focus, how many references away from the type to graph types.")
+	maxNodes := flag.Int("max-nodes", defaultMaxNodes, "Draw graphs with more nodes than this with less detail, in stages, until they fit (0 to disable).")
+	maxEdges := flag.Int("max-edges", defaultMaxEdges, "Draw graphs with more arrows than this with less detail, in stages, until they fit (0 to disable).")
+	normalizeTypes := flag.Bool("normalize-types", false, "Spell types the same way whichever Go release built the graph, e.g. any rather than interface{}, so that committed graphs don't change when Go is upgraded.")
+	countArrows := flag.Bool("count-arrows", false, "Label each arrow that stands for several identical ones, e.g. from fields that aren't drawn, with how many it stands for.")
+	diffBase := flag.String("diff-base", "", "With -notify-url, summarize the types that changed since this git ref.")
 	flag.Parse()
 	args := flag.Args()
 
@@ -20,27 +106,556 @@ func main() {
 		log.Fatalln("error: no package name given")
 		return
 	}
-	dotFile := pkgviz.WriteGraph(args[0])
 
+	if args[0] == "watch" {
+		if len(args) < 2 {
+			log.Fatalln("error: no package name given")
+		}
+		if err := watch(args[1], *dotOnly); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "serve" {
+		if err := serve(args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "lsp-ish" {
+		if err := lspish(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "push" {
+		if err := push(args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "pr-summary" {
+		if err := prSummary(args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "diff" {
+		if len(args) != 4 {
+			log.Fatalln("usage: pkgviz diff BASE_REF HEAD_REF PKGNAME")
+		}
+		if err := visualDiff(args[1], args[2], args[3], *dotOnly); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "implements" {
+		if err := implementers(args[1:], *dotOnly); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "arch" {
+		if err := arch(args[1:], *dotOnly); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "jsonschema" {
+		if err := jsonSchema(args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "apidiff" {
+		if err := apiDiff(args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "check" {
+		if err := check(args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "publish" {
+		if err := publish(args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "doc-diagram" {
+		if err := docDiagram(args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "monorepo" {
+		if err := monorepo(args[1:], *dotOnly); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "gen-fixtures" {
+		if err := genFixtures(args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if args[0] == "docs" {
+		if err := docs(args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *svg {
+		*format = "svg"
+	}
+	if !isImageFormat(*format) {
+		log.Fatalf("error: unknown -format %q, expected one of %s", *format, strings.Join(imageFormats, ", "))
+	}
+	if *generated != "" && *generated != pkgviz.GeneratedTag && *generated != pkgviz.GeneratedGroup {
+		log.Fatalf("error: unknown -generated mode %q, expected %q or %q", *generated, pkgviz.GeneratedTag, pkgviz.GeneratedGroup)
+	}
+	if *harness != "" && *harness != pkgviz.HarnessTag && *harness != pkgviz.HarnessHide {
+		log.Fatalf("error: unknown -harness mode %q, expected %q or %q", *harness, pkgviz.HarnessTag, pkgviz.HarnessHide)
+	}
+	if *sizeBy != "" && *sizeBy != pkgviz.MetricFanIn && *sizeBy != pkgviz.MetricFanOut {
+		log.Fatalf("error: unknown -size-by metric %q, expected %q or %q", *sizeBy, pkgviz.MetricFanIn, pkgviz.MetricFanOut)
+	}
+
+	var excludeRe *regexp.Regexp
+	if *exclude != "" {
+		var err error
+		if excludeRe, err = regexp.Compile(*exclude); err != nil {
+			log.Fatalf("error: invalid -exclude: %v", err)
+		}
+	}
+
+	if *layoutArch != "" && types.SizesFor("gc", *layoutArch) == nil {
+		log.Fatalf("error: unknown -layout-arch %q", *layoutArch)
+	}
+
+	var layers []pkgviz.Layer
+	if *layersFile != "" {
+		var err error
+		if layers, err = readLayers(*layersFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	var contextFieldsAllowed []string
+	if *contextFieldsAllow != "" {
+		contextFieldsAllowed = strings.Split(*contextFieldsAllow, ",")
+	}
+
+	var sizeBudgets []pkgviz.SizeBudget
+	if *sizeBudgetsFile != "" {
+		var err error
+		if sizeBudgets, err = readSizeBudgets(*sizeBudgetsFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	var intended *pkgviz.IntendedArchitecture
+	if *intendedFile != "" {
+		data, err := ioutil.ReadFile(*intendedFile)
+		if err == nil {
+			var parsed pkgviz.IntendedArchitecture
+			if parsed, err = pkgviz.ParseIntendedArchitecture(string(data)); err == nil {
+				intended = &parsed
+			} else {
+				err = fmt.Errorf("error reading %v: %v", *intendedFile, err)
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	// Several packages, e.g. siblings, are merged into one graph.
+	pkgNames := args
+	if *upload != "" || *notifyURL != "" {
+		// Artifacts and notifications name the package by its full import
+		// path.
+		pkgNames = make([]string, len(args))
+		for i, arg := range args {
+			var err error
+			if pkgNames[i], err = resolvePkgName(".", arg); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	}
+	pkgName := strings.Join(pkgNames, " ")
+
+	opts := pkgviz.Options{
+		MaxDepth:             *maxDepth,
+		Exclude:              excludeRe,
+		Focus:                *focus,
+		Hops:                 *hops,
+		Blame:                *blame,
+		StaleAfter:           *staleAfter,
+		ChurnWindow:          time.Duration(*churnDays) * 24 * time.Hour,
+		HighlightCycles:      *cycles,
+		ColorByDepth:         *depth,
+		WeightReferences:     *weights,
+		SizeBy:               *sizeBy,
+		Layout:               *layout,
+		LayoutArch:           *layoutArch,
+		SizeBudgets:          sizeBudgets,
+		NoCopy:               *noCopy,
+		ContextFields:        *contextFields,
+		ContextFieldsAllowed: contextFieldsAllowed,
+		Vars:                 *vars,
+		Funcs:                *funcs,
+		ImplementsArrows:     *implementsArrows,
+		DocCoverage:          *docCoverage,
+		DocSummaries:         *docSummaries,
+		ShowPositions:        *showPositions,
+		Constructors:         *constructors,
+		Harness:              *harness,
+		Generated:            *generated,
+		DBSchema:             *dbSchema,
+		ProtoCorrespondence:  *proto,
+		Layers:               layers,
+		Intended:             intended,
+		MaxNodes:             *maxNodes,
+		MaxEdges:             *maxEdges,
+		CountMergedArrows:    *countArrows,
+		NormalizeTypes:       *normalizeTypes,
+	}
+	pkgGraph, err := pkgviz.GraphForPackages(pkgNames, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var unusedTypes []pkgviz.UnusedType
+	if *unused {
+		var err error
+		if unusedTypes, err = pkgviz.UnusedTypes(pkgGraph, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		pkgviz.HighlightUnusedTypes(pkgGraph, unusedTypes)
+	}
+	var unreadFields []pkgviz.UnreadField
+	if *unread {
+		var err error
+		if unreadFields, err = pkgviz.UnreadFields(pkgGraph, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		pkgviz.HighlightUnreadFields(pkgGraph, unreadFields)
+	}
+
+	// Graphs that are too big are reported before they're rendered, which
+	// can take a while.
+	if report := pkgGraph.Oversize(); report != nil {
+		printSizeReport(os.Stderr, strings.Join(args, " "), report, *generated)
+	}
+
+	// The summary goes to stderr when the dot file is written to stdout.
+	summary := os.Stdout
 	if (*dotOnly) == true {
-		fmt.Println(dotFile)
+		pkgGraph.WriteTo(os.Stdout)
+		fmt.Println()
+		summary = os.Stderr
 	} else {
-		imageFilename := "out.png"
-		cmd := exec.Command("dot", "-Tpng", "-o", imageFilename)
-		stdin, _ := cmd.StdinPipe()
-		go func() {
-			defer stdin.Close()
-			io.WriteString(stdin, dotFile)
-		}()
+		imageFile := strings.TrimSuffix(imageFilename, filepath.Ext(imageFilename)) + "." + *format
+		var err error
+		if *format == "html" {
+			err = writeExport(imageFile, func(w io.Writer) error {
+				return pkgviz.RenderHTML(w, pkgGraph, pkgName)
+			})
+		} else {
+			err = writeImage(pkgGraph, imageFile)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 
-		if listCmdOut, err := cmd.CombinedOutput(); err != nil {
-			fmt.Printf("Error running '%v'\n", cmd.String())
-			fmt.Printf("Debug: %s\n", string(listCmdOut))
+		fmt.Printf("Image written to %v\n", imageFile)
+	}
+
+	if *cycles {
+		found := pkgGraph.Cycles()
+		fmt.Fprintf(summary, "Found %d cycle(s)\n", len(found))
+		for _, cycle := range found {
+			fmt.Fprintf(summary, "  %v\n", cycle)
+		}
+	}
+	if *unused {
+		fmt.Fprintf(summary, "Found %d exported type(s) that no other package uses\n", len(unusedTypes))
+		for _, unusedType := range unusedTypes {
+			fmt.Fprintf(summary, "  %v\n", unusedType)
+		}
+	}
+	if *unread {
+		fmt.Fprintf(summary, "Found %d struct field(s) that nothing reads\n", len(unreadFields))
+		for _, unreadField := range unreadFields {
+			fmt.Fprintf(summary, "  %v\n", unreadField)
+		}
+	}
+	if *generated != "" {
+		generatedTypes := pkgGraph.GeneratedTypes()
+		fmt.Fprintf(summary, "Found %d generated type(s)\n", len(generatedTypes))
+		for _, generatedType := range generatedTypes {
+			fmt.Fprintf(summary, "  %v\n", generatedType)
+		}
+	}
+	if *proto {
+		conversions := pkgGraph.ProtoConversions()
+		fmt.Fprintf(summary, "Found %d conversion(s) between types and protobuf messages\n", len(conversions))
+		for _, conversion := range conversions {
+			fmt.Fprintf(summary, "  %v\n", conversion)
+		}
+	}
+	if *docCoverage {
+		fmt.Fprintln(summary, "Documentation coverage:")
+		for _, coverage := range pkgGraph.DocCoverage() {
+			fmt.Fprintf(summary, "  %v\n", coverage)
+			for _, name := range coverage.Undocumented {
+				fmt.Fprintf(summary, "    %s\n", name)
+			}
+		}
+	}
+	for _, note := range pkgGraph.Notes() {
+		fmt.Fprintf(summary, "Note: %s\n", note)
+	}
+	if elided := pkgGraph.Elided(); len(elided) > 0 {
+		fmt.Fprintf(summary, "Reduced the detail of the graph to fit in %d nodes and %d edges:\n", *maxNodes, *maxEdges)
+		for _, e := range elided {
+			fmt.Fprintf(summary, "  %s\n", e)
+		}
+	}
+	if len(sizeBudgets) > 0 {
+		oversized, err := pkgGraph.OversizedStructs(sizeBudgets, *layoutArch)
+		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
+		fmt.Fprintf(summary, "Found %d struct(s) over their size budget\n", len(oversized))
+		for _, s := range oversized {
+			fmt.Fprintf(summary, "  %v\n", s)
+		}
+	}
+	if *contextFields {
+		fields := pkgGraph.ContextFields(contextFieldsAllowed)
+		fmt.Fprintf(summary, "Found %d struct field(s) that store a context.Context\n", len(fields))
+		for _, field := range fields {
+			fmt.Fprintf(summary, "  %v\n", field)
+		}
+	}
+	var layerViolations []pkgviz.LayerViolation
+	if len(layers) > 0 {
+		layerViolations = pkgGraph.LayerViolations(layers)
+		fmt.Fprintf(summary, "Found %d reference(s) that point up a layer\n", len(layerViolations))
+		for _, violation := range layerViolations {
+			fmt.Fprintf(summary, "  %v\n", violation)
+		}
+	}
+	var discrepancies []pkgviz.Discrepancy
+	if intended != nil {
+		discrepancies = pkgGraph.CompareIntended(*intended)
+		fmt.Fprintf(summary, "Found %d difference(s) from the intended architecture\n", len(discrepancies))
+		for _, discrepancy := range discrepancies {
+			fmt.Fprintf(summary, "  %v\n", discrepancy)
+		}
+	}
+	if internalViolations := pkgGraph.InternalViolations(); len(internalViolations) > 0 {
+		fmt.Fprintf(summary, "Found %d reference(s) to internal packages from outside their tree\n", len(internalViolations))
+		for _, violation := range internalViolations {
+			fmt.Fprintf(summary, "  %v\n", violation)
+		}
+	}
+	if collisions := pkgGraph.IDCollisions(); len(collisions) > 0 {
+		fmt.Fprintf(summary, "Found %d set(s) of types whose IDs collided, which were given IDs of their own\n", len(collisions))
+		for _, collision := range collisions {
+			fmt.Fprintf(summary, "  %v\n", collision)
+		}
+	}
+	if *cycleReport != "" {
+		if err := writeCycleReport(*cycleReport, pkgName, pkgGraph.Cycles()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(summary, "Cycle report written to %v\n", *cycleReport)
+	}
+	if *paddingReport != "" {
+		suggestions, err := pkgGraph.PaddingSuggestions(*layoutArch)
+		if err == nil {
+			err = writePaddingReport(*paddingReport, pkgName, suggestions)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(summary, "Padding report written to %v\n", *paddingReport)
+	}
+	if *almostImplements {
+		almost := pkgGraph.AlmostImplements()
+		fmt.Fprintf(summary, "Found %d type(s) that almost implement an interface\n", len(almost))
+		for _, a := range almost {
+			fmt.Fprintf(summary, "  %v\n", a)
+		}
+	}
+	if *implements != "" {
+		if err := writeImplementsReport(*implements, pkgName, pkgGraph.Implements()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(summary, "Implements matrix written to %v\n", *implements)
+	}
+	if *metrics != "" {
+		if err := writeMetricsReport(*metrics, pkgGraph.Metrics()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(summary, "Metrics written to %v\n", *metrics)
+	}
+	if *dependencyMatrix != "" {
+		if err := writeDependencyMatrix(*dependencyMatrix, pkgGraph.DependencyMatrix()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(summary, "Dependency matrix written to %v\n", *dependencyMatrix)
+	}
+	if *plantUML != "" {
+		if err := writeExport(*plantUML, pkgGraph.WritePlantUML); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(summary, "PlantUML class diagram written to %v\n", *plantUML)
+	}
+	if *d2 != "" {
+		if err := writeExport(*d2, pkgGraph.WriteD2); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(summary, "D2 diagram written to %v\n", *d2)
+	}
+	if *graphML != "" {
+		if err := writeExport(*graphML, pkgGraph.WriteGraphML); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(summary, "GraphML written to %v\n", *graphML)
+	}
+
+	var urls []string
+	if *upload != "" {
+		var err error
+		if urls, err = uploadArtifacts(*upload, pkgGraph.String(), pkgGraph.Records()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, url := range urls {
+			fmt.Fprintf(os.Stderr, "Uploaded %v\n", url)
+		}
+	}
+
+	if *notifyURL != "" {
+		var diffs []pkgviz.TypeDiff
+		if *diffBase != "" {
+			baseDir, cleanup, err := checkoutRef(*diffBase)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			baseGraph, err := pkgviz.GraphForPackages(pkgNames, pkgviz.Options{Dir: baseDir})
+			cleanup()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error graphing %v at %v: %v\n", pkgName, *diffBase, err)
+				os.Exit(1)
+			}
+			diffs = pkgviz.DiffGraphs(baseGraph, pkgGraph)
+		}
+		if err := notify(*notifyURL, newNotification(pkgGraph.Records(), urls, *diffBase, diffs)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	// Warnings are printed last, so that they're not lost in the middle of
+	// the output.
+	if warnings := pkgGraph.Warnings(); len(warnings) > 0 {
+		fmt.Fprintf(os.Stderr, "%d warning(s) building the graph:\n", len(warnings))
+		for _, warning := range warnings {
+			fmt.Fprintf(os.Stderr, "  %v\n", warning)
+		}
+	}
 
-		fmt.Printf("Image written to %v\n", imageFilename)
+	if *layersStrict && len(layerViolations) > 0 {
+		fmt.Fprintf(os.Stderr, "%d references point up a layer in %v\n", len(layerViolations), *layersFile)
+		os.Exit(1)
 	}
+	if *intendedStrict && len(discrepancies) > 0 {
+		fmt.Fprintf(os.Stderr, "%d differences from the intended architecture in %v\n", len(discrepancies), *intendedFile)
+		os.Exit(1)
+	}
+}
 
+// isImageFormat returns whether the image can be written in a format.
+func isImageFormat(format string) bool {
+	for _, f := range imageFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// writeImage renders the dot graph to an image with graphviz, in the format
+// of the file's extension (e.g. svg for out.svg), streaming the graph to
+// graphviz, and the image to the file, as they're written.
+func writeImage(dotFile io.WriterTo, imageFilename string) error {
+	f, err := os.Create(imageFilename)
+	if err != nil {
+		return err
+	}
+
+	format := strings.TrimPrefix(filepath.Ext(imageFilename), ".")
+	if err := pkgviz.RenderGraphFrom(f, dotFile, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
@@ -1,7 +1,10 @@
 package main_test
 
-import "testing"
+import (
+	"os"
+	"testing"
+)
 
 func TestMain(m *testing.M) {
-	// TODO: test the CLI
+	os.Exit(m.Run())
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// writeDependencyMatrix writes the number of references between each pair
+// of a graph's packages to a file, as HTML if its name ends in .html, or as
+// CSV otherwise.
+func writeDependencyMatrix(filename string, matrix pkgviz.DependencyMatrix) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".html", ".htm":
+		err = writeDependencyMatrixHTML(f, matrix)
+	default:
+		err = writeDependencyMatrixCSV(f, matrix)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// matrixPkgName returns a package's import path relative to the graphed
+// package's, e.g. "store/sql", or "." for the graphed package itself.
+func matrixPkgName(matrix pkgviz.DependencyMatrix, pkgName string) string {
+	if pkgName == matrix.Package {
+		return "."
+	}
+	return strings.TrimPrefix(pkgName, matrix.Package+"/")
+}
+
+// writeDependencyMatrixCSV writes a row for each package that refers to
+// others, with a column for each package it refers to.
+func writeDependencyMatrixCSV(w io.Writer, matrix pkgviz.DependencyMatrix) error {
+	cw := csv.NewWriter(w)
+	header := []string{"from \\ to"}
+	for _, pkgName := range matrix.Packages {
+		header = append(header, matrixPkgName(matrix, pkgName))
+	}
+	cw.Write(header)
+	for i, pkgName := range matrix.Packages {
+		row := []string{matrixPkgName(matrix, pkgName)}
+		for _, count := range matrix.References[i] {
+			row = append(row, strconv.Itoa(count))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+var dependencyMatrixTemplate = template.Must(template.New("matrix").Funcs(template.FuncMap{
+	"name": matrixPkgName,
+	// heat shades a cell by its count relative to the largest one.
+	"heat": func(count, max int) template.CSS {
+		if count == 0 || max == 0 {
+			return ""
+		}
+		return template.CSS(fmt.Sprintf("background: rgba(217, 83, 79, %.2f)", 0.15+0.85*float64(count)/float64(max)))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Package dependencies in {{.Matrix.Package}}</title>
+<style>
+body { font-family: Arial, sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #cccccc; padding: 4px 8px; }
+td { text-align: right; }
+td.self { background: #eeeeee; }
+</style>
+</head>
+<body>
+<h1>Package dependencies in {{.Matrix.Package}}</h1>
+<p>Cells count the references from the types in the row's package to the types in the column's package.</p>
+<table>
+<tr><th>from \ to</th>{{range .Matrix.Packages}}<th>{{name $.Matrix .}}</th>{{end}}</tr>
+{{- range $i, $from := .Matrix.Packages}}
+<tr><th>{{name $.Matrix $from}}</th>{{range $j, $count := index $.Matrix.References $i}}{{if eq $i $j}}<td class="self"></td>{{else if $count}}<td style="{{heat $count $.Max}}">{{$count}}</td>{{else}}<td></td>{{end}}{{end}}</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+func writeDependencyMatrixHTML(w io.Writer, matrix pkgviz.DependencyMatrix) error {
+	max := 0
+	for _, row := range matrix.References {
+		for _, count := range row {
+			if count > max {
+				max = count
+			}
+		}
+	}
+	return dependencyMatrixTemplate.Execute(w, struct {
+		Matrix pkgviz.DependencyMatrix
+		Max    int
+	}{matrix, max})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// writeMetricsReport writes the coupling metrics of a graph to a file, as
+// JSON if its name ends in .json, or as CSV otherwise.
+func writeMetricsReport(filename string, metrics pkgviz.Metrics) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if filepath.Ext(filename) == ".json" {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(metrics)
+	} else {
+		err = writeMetricsCSV(f, metrics)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeMetricsCSV writes a row for each package and then each type, with
+// the columns that don't apply to types left empty.
+func writeMetricsCSV(w io.Writer, metrics pkgviz.Metrics) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"level", "package", "type", "kind", "types", "abstract", "fan_in", "fan_out", "instability", "abstractness", "distance"})
+	for _, p := range metrics.Packages {
+		cw.Write([]string{
+			"package", p.Package, "", "",
+			strconv.Itoa(p.Types), strconv.Itoa(p.Abstract),
+			strconv.Itoa(p.FanIn), strconv.Itoa(p.FanOut),
+			formatMetric(p.Instability), formatMetric(p.Abstractness), formatMetric(p.Distance),
+		})
+	}
+	for _, t := range metrics.Types {
+		cw.Write([]string{
+			"type", t.Package, t.Name, t.Kind,
+			"", "",
+			strconv.Itoa(t.FanIn), strconv.Itoa(t.FanOut),
+			formatMetric(t.Instability), "", "",
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatMetric(f float64) string {
+	return strconv.FormatFloat(f, 'f', 3, 64)
+}
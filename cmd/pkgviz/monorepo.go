@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// monorepo graphs every module in a repository, found by walking it for
+// go.mod files: all of them in one graph, with a cluster per module, or with
+// -out, each in a graph of its own, with an index.html that links to them.
+func monorepo(args []string, dotOnly bool) error {
+	flags := flag.NewFlagSet("monorepo", flag.ExitOnError)
+	out := flags.String("out", "", "Write each module's graph into a directory per module in this directory instead, as types.svg and types.dot, with an index.html that links to them.")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pkgviz monorepo [flags] [dir]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	root := "."
+	if flags.NArg() > 0 {
+		root = flags.Arg(0)
+	}
+	modules, err := pkgviz.FindModules(root)
+	if err != nil {
+		return err
+	}
+	if len(modules) == 0 {
+		return fmt.Errorf("no go.mod files found in %v", root)
+	}
+
+	if *out != "" {
+		return writeModuleGraphs(*out, modules)
+	}
+
+	graph, err := pkgviz.GraphForModules(modules, pkgviz.Options{Dir: root})
+	if err != nil {
+		return err
+	}
+	if dotOnly {
+		graph.WriteTo(os.Stdout)
+		fmt.Println()
+		return nil
+	}
+	if err := writeImage(graph, imageFilename); err != nil {
+		return err
+	}
+	fmt.Printf("Image written to %v, with %d modules\n", imageFilename, len(modules))
+	return nil
+}
+
+// writeModuleGraphs writes each module's graph into a directory named by its
+// path in out, and an index.html that shows them all. Modules that can't be
+// graphed are listed in the index with why, rather than stopping the rest.
+func writeModuleGraphs(out string, modules []pkgviz.Module) error {
+	var index strings.Builder
+	index.WriteString("<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>pkgviz modules</title></head>\n<body>\n<h1>Modules</h1>\n<ul>\n")
+
+	failed := 0
+	for _, module := range modules {
+		fmt.Fprintf(&index, "<li><h2>%s</h2>\n", html.EscapeString(module.Path))
+		dir := filepath.Join(out, filepath.FromSlash(module.Path))
+		if err := writeModuleGraph(dir, module); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "Cannot graph %v: %v\n", module.Path, err)
+			fmt.Fprintf(&index, "<p>Cannot be graphed: %s</p></li>\n", html.EscapeString(err.Error()))
+			continue
+		}
+		fmt.Printf("Graph of %v written to %v\n", module.Path, dir)
+		fmt.Fprintf(&index, "<p><a href=\"%[1]s/types.svg\">types.svg</a> <a href=\"%[1]s/types.dot\">types.dot</a></p>\n", html.EscapeString(module.Path))
+		fmt.Fprintf(&index, "<img src=\"%s/types.svg\" style=\"max-width: 100%%\"></li>\n", html.EscapeString(module.Path))
+	}
+	index.WriteString("</ul>\n</body>\n</html>\n")
+
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+	indexFilename := filepath.Join(out, "index.html")
+	if err := ioutil.WriteFile(indexFilename, []byte(index.String()), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Index written to %v\n", indexFilename)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d modules couldn't be graphed", failed, len(modules))
+	}
+	return nil
+}
+
+// writeModuleGraph writes the graph of every package in the module to dir,
+// as types.svg and types.dot.
+func writeModuleGraph(dir string, module pkgviz.Module) error {
+	graph, err := pkgviz.GraphForPackage("./...", pkgviz.Options{Dir: module.Dir})
+	if err != nil {
+		return err
+	}
+	dotFile := graph.String()
+	svg, err := pkgviz.RenderGraph(dotFile, "svg")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "types.dot"), []byte(dotFile), 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, "types.svg"), svg, 0644)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// A notification is POSTed to a webhook when a run completes. Its text is
+// what Slack-compatible webhooks show, and the rest is for generic ones.
+type notification struct {
+	Text      string            `json:"text"`
+	Package   string            `json:"package"`
+	Stats     notificationStats `json:"stats"`
+	Artifacts []string          `json:"artifacts,omitempty"`
+	Diff      *notificationDiff `json:"diff,omitempty"`
+}
+
+type notificationStats struct {
+	Packages   int `json:"packages"`
+	Types      int `json:"types"`
+	References int `json:"references"`
+}
+
+type notificationDiff struct {
+	Base    string            `json:"base"`
+	Added   int               `json:"added"`
+	Removed int               `json:"removed"`
+	Changed int               `json:"changed"`
+	Types   []pkgviz.TypeDiff `json:"types"`
+}
+
+func newNotification(records pkgviz.Records, artifacts []string, diffBase string, diffs []pkgviz.TypeDiff) notification {
+	n := notification{Package: records.Package, Artifacts: artifacts}
+
+	packages := map[string]bool{}
+	for _, node := range records.Nodes {
+		if node.Kind != "external" {
+			packages[node.Package] = true
+			n.Stats.Types++
+		}
+	}
+	n.Stats.Packages = len(packages)
+	n.Stats.References = len(records.Edges)
+
+	n.Text = fmt.Sprintf(
+		"pkgviz graphed %s: %d types in %d packages, with %d references.",
+		records.Package, n.Stats.Types, n.Stats.Packages, n.Stats.References,
+	)
+	if diffBase != "" {
+		n.Diff = &notificationDiff{Base: diffBase, Types: append([]pkgviz.TypeDiff{}, diffs...)}
+		for _, diff := range diffs {
+			switch diff.Change {
+			case pkgviz.TypeAdded:
+				n.Diff.Added++
+			case pkgviz.TypeRemoved:
+				n.Diff.Removed++
+			case pkgviz.TypeChanged:
+				n.Diff.Changed++
+			}
+		}
+		n.Text += fmt.Sprintf(
+			" Since %s: %d added, %d removed and %d changed.",
+			diffBase, n.Diff.Added, n.Diff.Removed, n.Diff.Changed,
+		)
+	}
+	if len(artifacts) > 0 {
+		n.Text += "\n" + strings.Join(artifacts, "\n")
+	}
+	return n
+}
+
+// notify POSTs the notification as JSON to the webhook.
+func notify(webhookURL string, n notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("error notifying %v: %v: %s", webhookURL, resp.Status, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// printSizeReport writes what made the graph of pkgName bigger than
+// -max-nodes or -max-edges, and the commands that would graph less of it,
+// so that graphviz isn't left to lay out more than anyone can read.
+func printSizeReport(w io.Writer, pkgName string, report *pkgviz.SizeReport, generated string) {
+	fmt.Fprintf(w, "The graph has %d nodes and %d edges, more than the %d nodes and %d edges it should have (-max-nodes and -max-edges).\n", report.Nodes, report.Edges, report.MaxNodes, report.MaxEdges)
+	if !report.Fits {
+		fmt.Fprintln(w, "Even with less detail it doesn't fit, so graphviz may take a very long time to lay it out.")
+	}
+	if len(report.Packages) > 0 {
+		fmt.Fprintf(w, "  Packages with the most types: %s\n", joinContributors(report.Packages))
+	}
+	if len(report.Hubs) > 0 {
+		fmt.Fprintf(w, "  Types with the most arrows: %s\n", joinContributors(report.Hubs))
+	}
+
+	var suggestions []string
+	if len(report.Hubs) > 0 {
+		suggestions = append(suggestions, fmt.Sprintf("pkgviz -focus %s -hops 1 %s", report.Hubs[0].Name, pkgName))
+	}
+	if len(report.Packages) > 1 {
+		suggestions = append(suggestions, fmt.Sprintf("pkgviz %s", report.Packages[0].Name))
+	}
+	if report.Generated > 0 && generated != pkgviz.GeneratedGroup {
+		suggestions = append(suggestions, fmt.Sprintf("pkgviz -generated %s %s", pkgviz.GeneratedGroup, pkgName))
+	}
+	if len(suggestions) > 0 {
+		fmt.Fprintln(w, "To graph less of it, try:")
+		for _, suggestion := range suggestions {
+			fmt.Fprintf(w, "  %s\n", suggestion)
+		}
+	}
+}
+
+func joinContributors(contributors []pkgviz.SizeContributor) string {
+	var names []string
+	for _, c := range contributors {
+		names = append(names, c.String())
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// writePaddingReport writes the structs that would be smaller with their
+// fields reordered to a file, with the orders that would make them smallest.
+func writePaddingReport(filename, pkgName string, suggestions []pkgviz.PaddingSuggestion) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	writePaddingSuggestions(f, pkgName, suggestions)
+	return f.Close()
+}
+
+func writePaddingSuggestions(w io.Writer, pkgName string, suggestions []pkgviz.PaddingSuggestion) {
+	if len(suggestions) == 0 {
+		fmt.Fprintf(w, "No structs in %s would be smaller with their fields reordered\n", pkgName)
+		return
+	}
+
+	var wasted int64
+	for _, suggestion := range suggestions {
+		wasted += suggestion.Wasted()
+	}
+	fmt.Fprintf(w, "%d struct(s) in %s would be %d bytes smaller in total with their fields reordered\n", len(suggestions), pkgName, wasted)
+	for _, suggestion := range suggestions {
+		fmt.Fprintf(w, "\n%s: %d bytes, could be %d bytes (%d wasted) in this order:\n", suggestion.Type, suggestion.Size, suggestion.OptimalSize, suggestion.Wasted())
+		fmt.Fprintf(w, "  %s\n", strings.Join(suggestion.Fields, "\n  "))
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// prSummary prints a Markdown summary of how a package's types changed
+// between two git refs, with before and after graphs, for posting as a
+// pull request comment.
+func prSummary(args []string) error {
+	flags := flag.NewFlagSet("pr-summary", flag.ExitOnError)
+	base := flags.String("base", "main", "The git ref to compare against, e.g. the pull request's base branch.")
+	head := flags.String("head", "HEAD", "The git ref with the changes.")
+	imageDir := flags.String("image-dir", "", "Write the graphs to this directory and link to them, instead of embedding them as data URIs (which some sites limit the size of).")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pkgviz pr-summary [flags] [package]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	pkgName := "."
+	if flags.NArg() > 0 {
+		pkgName = flags.Arg(0)
+	}
+
+	baseDir, cleanupBase, err := checkoutRef(*base)
+	if err != nil {
+		return err
+	}
+	defer cleanupBase()
+	headDir, cleanupHead, err := checkoutRef(*head)
+	if err != nil {
+		return err
+	}
+	defer cleanupHead()
+
+	// The package is graphed by its import path at both refs, since a
+	// relative path may not resolve at the base.
+	headPkgName, err := resolvePkgName(headDir, pkgName)
+	if err != nil {
+		return err
+	}
+	headGraph, err := pkgviz.GraphForPackage(headPkgName, pkgviz.Options{Dir: headDir})
+	if err != nil {
+		return err
+	}
+
+	// A package that the pull request adds is compared to an empty graph,
+	// so that all of its types are added.
+	baseGraph, err := pkgviz.GraphForPackage(headPkgName, pkgviz.Options{Dir: baseDir})
+	if errors.Is(err, pkgviz.ErrPackageNotFound) {
+		baseGraph, err = pkgviz.EmptyGraph(headPkgName), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	baseImageURL, err := renderImageURL(baseGraph.String(), *imageDir, "pkgviz-base.png")
+	if err != nil {
+		return err
+	}
+	headImageURL, err := renderImageURL(headGraph.String(), *imageDir, "pkgviz-head.png")
+	if err != nil {
+		return err
+	}
+
+	writePRSummary(os.Stdout, headPkgName, *base, *head, pkgviz.DiffGraphs(baseGraph, headGraph), baseImageURL, headImageURL)
+	return nil
+}
+
+// renderImageURL renders the dot graph to a png, and returns a URL for it:
+// either a file in imageDir, or if that's empty, a data URI.
+func renderImageURL(dotFile, imageDir, imageFilename string) (string, error) {
+	image, err := pkgviz.RenderGraph(dotFile, "png")
+	if err != nil {
+		return "", err
+	}
+	if imageDir == "" {
+		return "data:image/png;base64," + base64.StdEncoding.EncodeToString(image), nil
+	}
+
+	if err := os.MkdirAll(imageDir, 0755); err != nil {
+		return "", err
+	}
+	imagePath := filepath.Join(imageDir, imageFilename)
+	if err := ioutil.WriteFile(imagePath, image, 0644); err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(imagePath), nil
+}
+
+func writePRSummary(w io.Writer, pkgName, base, head string, diffs []pkgviz.TypeDiff, baseImageURL, headImageURL string) {
+	counts := map[pkgviz.TypeChange]int{}
+	for _, diff := range diffs {
+		counts[diff.Change]++
+	}
+
+	fmt.Fprintf(w, "### Type changes in `%s`\n\n", pkgName)
+	if len(diffs) == 0 {
+		fmt.Fprintf(w, "No types changed between `%s` and `%s`.\n\n", base, head)
+	} else {
+		fmt.Fprintf(
+			w,
+			"%d added, %d removed and %d changed between `%s` and `%s`.\n\n",
+			counts[pkgviz.TypeAdded],
+			counts[pkgviz.TypeRemoved],
+			counts[pkgviz.TypeChanged],
+			base,
+			head,
+		)
+		fmt.Fprintln(w, "| Change | Type | Kind | Details |")
+		fmt.Fprintln(w, "| --- | --- | --- | --- |")
+		for _, diff := range diffs {
+			fmt.Fprintf(
+				w,
+				"| %s | `%s` | %s | %s |\n",
+				diff.Change,
+				diff.QualifiedName(),
+				diff.Kind,
+				escapeMarkdownTableCell(strings.Join(diff.Details, "<br>")),
+			)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "<details><summary>Before (<code>%s</code>)</summary>\n\n![Types before](%s)\n\n</details>\n\n", base, baseImageURL)
+	fmt.Fprintf(w, "<details open><summary>After (<code>%s</code>)</summary>\n\n![Types after](%s)\n\n</details>\n", head, headImageURL)
+}
+
+func escapeMarkdownTableCell(s string) string {
+	return strings.Replace(s, "|", "\\|", -1)
+}
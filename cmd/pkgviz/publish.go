@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// publish builds the graph of a package and publishes it to a wiki page,
+// which is created on the first run and updated in place after that.
+func publish(args []string) error {
+	flags := flag.NewFlagSet("publish", flag.ExitOnError)
+	confluenceURL := flags.String("confluence", "", "The Confluence site to publish to, e.g. https://mycompany.atlassian.net/wiki (credentials default to $CONFLUENCE_USER and $CONFLUENCE_API_TOKEN, or $CONFLUENCE_TOKEN for a personal access token).")
+	space := flags.String("space", "", "The key of the Confluence space to publish the page in.")
+	title := flags.String("title", "", "The page's title (defaults to \"PACKAGE types\"), which identifies it in the space.")
+	parent := flags.String("parent", "", "The ID of the page to create the page under (defaults to the space's home page).")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: pkgviz publish -confluence URL -space KEY [flags] package")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one package name")
+	}
+	if *confluenceURL == "" {
+		return fmt.Errorf("no Confluence site given")
+	}
+	if *space == "" {
+		return fmt.Errorf("no Confluence space given")
+	}
+
+	pkgName, err := resolvePkgName(".", flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	pkgGraph, err := pkgviz.GraphForPackage(pkgName, pkgviz.Options{})
+	if err != nil {
+		return err
+	}
+	records := pkgGraph.Records()
+	svg, err := pkgviz.RenderGraph(pkgGraph.String(), "svg")
+	if err != nil {
+		return err
+	}
+	if *title == "" {
+		*title = pkgName + " types"
+	}
+
+	c := &confluence{
+		baseURL:  strings.TrimSuffix(*confluenceURL, "/"),
+		user:     os.Getenv("CONFLUENCE_USER"),
+		apiToken: os.Getenv("CONFLUENCE_API_TOKEN"),
+		token:    os.Getenv("CONFLUENCE_TOKEN"),
+		client:   &http.Client{Timeout: time.Minute},
+	}
+	pageURL, err := c.publishPage(context.Background(), *space, *title, *parent, confluencePageBody(records), "types.svg", svg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Published %v\n", pageURL)
+	return nil
+}
+
+// confluencePageBody returns the page's body, in Confluence's XHTML-based
+// storage format: the graph (attached as the given image) and a table of
+// the types and what their fields refer to.
+func confluencePageBody(records pkgviz.Records) string {
+	references := map[string][]string{}
+	for _, edge := range records.Edges {
+		references[edge.From] = append(references[edge.From], fmt.Sprintf("<code>%s</code> → <code>%s</code>", html.EscapeString(edge.Field), html.EscapeString(edge.To)))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<p>The types declared in <code>%s</code>, and the references between them. Generated by pkgviz; edits will be overwritten.</p>", html.EscapeString(records.Package))
+	b.WriteString(`<p><ac:image><ri:attachment ri:filename="types.svg" /></ac:image></p>`)
+	b.WriteString("<table><tbody><tr><th>Type</th><th>Kind</th><th>References</th></tr>")
+	for _, node := range records.Nodes {
+		if node.Kind == "external" {
+			continue
+		}
+		fmt.Fprintf(
+			&b,
+			"<tr><td><code>%s</code></td><td>%s</td><td>%s</td></tr>",
+			html.EscapeString(node.ID),
+			html.EscapeString(node.Kind),
+			strings.Join(references[node.ID], "<br />"),
+		)
+	}
+	b.WriteString("</tbody></table>")
+	return b.String()
+}
+
+// confluence is a client of the Confluence REST API.
+type confluence struct {
+	baseURL  string // e.g. https://mycompany.atlassian.net/wiki
+	user     string
+	apiToken string
+	token    string // a personal access token, used instead of user and apiToken
+	client   *http.Client
+}
+
+type confluencePage struct {
+	ID        string               `json:"id,omitempty"`
+	Type      string               `json:"type"`
+	Title     string               `json:"title"`
+	Space     *confluenceSpace     `json:"space,omitempty"`
+	Ancestors []confluenceAncestor `json:"ancestors,omitempty"`
+	Version   *confluenceVersion   `json:"version,omitempty"`
+	Body      struct {
+		Storage struct {
+			Value          string `json:"value"`
+			Representation string `json:"representation"`
+		} `json:"storage"`
+	} `json:"body"`
+	Links struct {
+		Base  string `json:"base"`
+		WebUI string `json:"webui"`
+	} `json:"_links"`
+}
+
+type confluenceSpace struct {
+	Key string `json:"key"`
+}
+
+type confluenceAncestor struct {
+	ID string `json:"id"`
+}
+
+type confluenceVersion struct {
+	Number int `json:"number"`
+}
+
+// publishPage creates or updates the page with the given title in the space,
+// attaching (or updating) the image that its body refers to, and returns the
+// page's URL.
+func (c *confluence) publishPage(ctx context.Context, space, title, parent, body, imageName string, image []byte) (string, error) {
+	var existing struct {
+		Results []confluencePage `json:"results"`
+	}
+	query := url.Values{"spaceKey": {space}, "title": {title}, "expand": {"version"}}
+	if err := c.do(ctx, "GET", "/rest/api/content?"+query.Encode(), "", nil, &existing); err != nil {
+		return "", err
+	}
+
+	page := confluencePage{Type: "page", Title: title, Space: &confluenceSpace{Key: space}}
+	page.Body.Storage.Value = body
+	page.Body.Storage.Representation = "storage"
+
+	var published confluencePage
+	if len(existing.Results) == 0 {
+		if parent != "" {
+			page.Ancestors = []confluenceAncestor{{ID: parent}}
+		}
+		if err := c.doJSON(ctx, "POST", "/rest/api/content", page, &published); err != nil {
+			return "", err
+		}
+	} else {
+		// Pages are updated by posting their next version. Updating leaves
+		// the page where it is, even if -parent has changed.
+		current := existing.Results[0]
+		page.ID = current.ID
+		page.Version = &confluenceVersion{Number: 1}
+		if current.Version != nil {
+			page.Version.Number = current.Version.Number + 1
+		}
+		if err := c.doJSON(ctx, "PUT", "/rest/api/content/"+url.PathEscape(current.ID), page, &published); err != nil {
+			return "", err
+		}
+	}
+
+	// PUT creates the attachment, or adds a new version of it if the page
+	// already has one with the same name.
+	var form bytes.Buffer
+	w := multipart.NewWriter(&form)
+	part, err := w.CreateFormFile("file", imageName)
+	if err != nil {
+		return "", err
+	}
+	part.Write(image)
+	w.WriteField("minorEdit", "true")
+	w.Close()
+	if err := c.do(ctx, "PUT", "/rest/api/content/"+url.PathEscape(published.ID)+"/child/attachment", w.FormDataContentType(), &form, nil); err != nil {
+		return "", err
+	}
+
+	if published.Links.Base != "" {
+		return published.Links.Base + published.Links.WebUI, nil
+	}
+	return c.baseURL + published.Links.WebUI, nil
+}
+
+func (c *confluence) doJSON(ctx context.Context, method, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, method, path, "application/json", bytes.NewReader(body), out)
+}
+
+// do sends a request to the API, and decodes its JSON response into out (if
+// it's not nil).
+func (c *confluence) do(ctx context.Context, method, path, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	// Attachments can only be uploaded with XSRF checks turned off.
+	req.Header.Set("X-Atlassian-Token", "no-check")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.user != "" {
+		req.SetBasicAuth(c.user, c.apiToken)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("error calling Confluence (%v %v): %v: %s", method, path, resp.Status, bytes.TrimSpace(respBody))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
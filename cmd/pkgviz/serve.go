@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/tiegz/pkgviz-go/pkg/server"
+)
+
+// serve runs an http server that graphs any public (or configured private)
+// import path on demand.
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "The address to listen on.")
+	cacheDir := flags.String("cache", filepath.Join(os.TempDir(), "pkgviz"), "The directory to keep fetched modules and rendered graphs in.")
+	maxConcurrent := flags.Int("max-concurrent", 0, "The most graphs to build at once (defaults to the number of CPUs).")
+	timeout := flags.Duration("timeout", 2*time.Minute, "The longest that building a graph may take.")
+	maxMemory := flags.Int64("max-memory", 2<<30, "The most memory, in bytes, that each subprocess may use.")
+	maxCPU := flags.Duration("max-cpu", time.Minute, "The most CPU time that each subprocess may use.")
+	rateLimit := flags.Int("rate-limit", 0, "How many graphs each client may request per minute (0 is unlimited).")
+	rateBurst := flags.Int("rate-burst", 10, "How many graphs each client may request at once, before the rate limit applies.")
+	authFile := flags.String("auth-file", "", "A JSON file of the credentials that clients must present, e.g. [{\"token\": \"...\", \"prefixes\": [\"github.com/mycompany\"]}].")
+	private := flags.String("private", os.Getenv("GOPRIVATE"), "Glob patterns of private module paths, like GOPRIVATE, which are fetched directly from their repositories with the credentials in -netrc or the SSH agent.")
+	netrc := flags.String("netrc", "", "The .netrc file with credentials for the hosts of private modules (defaults to $NETRC or ~/.netrc).")
+	maxNodes := flags.Int("max-nodes", defaultMaxNodes, "Draw graphs with more nodes than this with less detail, in stages, until they fit (0 to disable).")
+	maxEdges := flags.Int("max-edges", defaultMaxEdges, "Draw graphs with more arrows than this with less detail, in stages, until they fit (0 to disable).")
+	flags.Parse(args)
+
+	var credentials []server.Credential
+	if *authFile != "" {
+		data, err := ioutil.ReadFile(*authFile)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &credentials); err != nil {
+			return fmt.Errorf("error reading %v: %v", *authFile, err)
+		}
+	}
+
+	// Packages are analyzed by another pkgviz process, so that a package
+	// which crashes or exhausts it can't take the server down with it.
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	s, err := server.New(server.Config{
+		CacheDir:      *cacheDir,
+		MaxConcurrent: *maxConcurrent,
+		Worker:        []string{executable, "-max-nodes", strconv.Itoa(*maxNodes), "-max-edges", strconv.Itoa(*maxEdges)},
+		Timeout:       *timeout,
+		MaxMemory:     *maxMemory,
+		MaxCPU:        *maxCPU,
+		RateLimit:     *rateLimit,
+		RateBurst:     *rateBurst,
+		Credentials:   credentials,
+		Private:       *private,
+		Netrc:         *netrc,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Serving graphs on %v", *addr)
+	return http.ListenAndServe(*addr, s)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/tiegz/pkgviz-go/pkg/artifact"
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// uploadArtifacts uploads the graph rendered as an image, its dot file and
+// its JSON model, under the package's import path at the given location, and
+// returns their URLs.
+func uploadArtifacts(location, dotFile string, records pkgviz.Records) ([]string, error) {
+	ctx := context.Background()
+	uploader, err := artifact.Open(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	model, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	image, err := pkgviz.RenderGraph(dotFile, "png")
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, a := range []struct {
+		name        string
+		data        []byte
+		contentType string
+	}{
+		{"types.png", image, "image/png"},
+		{"types.dot", []byte(dotFile), "text/vnd.graphviz; charset=utf-8"},
+		{"types.json", model, "application/json"},
+	} {
+		url, err := uploader.Upload(ctx, records.Package+"/"+a.name, a.data, a.contentType)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, nil
+}
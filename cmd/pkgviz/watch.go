@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// Editors often write a file in several steps (truncate, write, rename), so
+// wait for the events to settle before rebuilding.
+const watchDebounce = 200 * time.Millisecond
+
+// watch renders the graph of pkgName, then re-renders it every time a Go
+// file in one of the graphed packages changes. Only the packages whose
+// files changed are re-analyzed.
+func watch(pkgName string, dotOnly bool) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	cache := pkgviz.NewCache()
+	watchedDirs := map[string]bool{}
+
+	render := func() error {
+		graph := pkgviz.BuildGraphWithOptions(pkgName, pkgviz.Options{Cache: cache})
+		if dotOnly {
+			graph.WriteTo(os.Stdout)
+			fmt.Println()
+		} else if err := writeImage(graph, imageFilename); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			fmt.Printf("Image written to %v\n", imageFilename)
+		}
+
+		// Packages may have been added to the graph since the last build.
+		for _, dir := range cache.Dirs() {
+			if watchedDirs[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				return err
+			}
+			watchedDirs[dir] = true
+		}
+		return nil
+	}
+
+	if err := render(); err != nil {
+		return err
+	}
+	fmt.Printf("Watching %d package directories for changes...\n", len(watchedDirs))
+
+	changedDirs := map[string]bool{}
+	var debounce <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !strings.HasSuffix(event.Name, ".go") || event.Op == fsnotify.Chmod {
+				continue
+			}
+			changedDirs[filepath.Dir(event.Name)] = true
+			debounce = time.After(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintln(os.Stderr, err)
+		case <-debounce:
+			for dir := range changedDirs {
+				for _, changedPkgName := range cache.InvalidateDir(dir) {
+					fmt.Printf("Changed: %s\n", changedPkgName)
+				}
+				delete(changedDirs, dir)
+			}
+			if err := render(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// worker prints the dot graph of a single package, for the server's
+// sandboxed worker processes. Unlike the rest of the command, it never
+// treats its argument as a subcommand, so whatever package a request names
+// is only ever graphed.
+func worker(args []string) error {
+	flags := flag.NewFlagSet("worker", flag.ExitOnError)
+	maxNodes := flags.Int("max-nodes", defaultMaxNodes, "Draw graphs with more nodes than this with less detail (0 to disable).")
+	maxEdges := flags.Int("max-edges", defaultMaxEdges, "Draw graphs with more arrows than this with less detail (0 to disable).")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: pkgviz -worker [-max-nodes N] [-max-edges N] PKGNAME")
+	}
+
+	pkgGraph, err := pkgviz.GraphForPackage(flags.Arg(0), pkgviz.Options{MaxNodes: *maxNodes, MaxEdges: *maxEdges})
+	if err != nil {
+		return err
+	}
+	pkgGraph.WriteTo(os.Stdout)
+	fmt.Println()
+	return nil
+}
@@ -1,3 +1,15 @@
 module github.com/tiegz/pkgviz-go
 
-go 1.13
+go 1.22.0
+
+require (
+	github.com/fsnotify/fsnotify v1.5.1
+	github.com/neo4j/neo4j-go-driver/v4 v4.4.7
+	golang.org/x/tools v0.26.0
+)
+
+require (
+	golang.org/x/mod v0.21.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+	golang.org/x/sys v0.26.0 // indirect
+)
@@ -0,0 +1,87 @@
+// Package artifact uploads rendered graphs and their models to shared
+// storage, so that scheduled jobs can publish them automatically. Storage is
+// opened by URI, e.g. "s3://bucket/path", "gs://bucket/path" or a local
+// directory, and each kind of storage registers the URI schemes it handles.
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// An Uploader stores artifacts under a location.
+type Uploader interface {
+	// Upload stores data as the artifact with the given slash-separated
+	// name, relative to the uploader's location, replacing any artifact
+	// with the same name. It returns the artifact's URL.
+	Upload(ctx context.Context, name string, data []byte, contentType string) (string, error)
+}
+
+// An Opener opens the Uploader at a URI with one of its registered schemes.
+type Opener func(ctx context.Context, uri *url.URL) (Uploader, error)
+
+var (
+	mu      sync.Mutex
+	openers = map[string]Opener{}
+)
+
+// Register makes a kind of Uploader available to Open under the given URI
+// schemes.
+func Register(opener Opener, schemes ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, scheme := range schemes {
+		if _, ok := openers[scheme]; ok {
+			panic(fmt.Sprintf("artifact: scheme %q is already registered", scheme))
+		}
+		openers[scheme] = opener
+	}
+}
+
+// Schemes returns the URI schemes of the registered uploaders.
+func Schemes() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var schemes []string
+	for scheme := range openers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open opens the Uploader at uri. A uri without a scheme is a local
+// directory.
+func Open(ctx context.Context, uri string) (Uploader, error) {
+	if !strings.Contains(uri, "://") {
+		return openDir(ctx, &url.URL{Scheme: "file", Path: uri})
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	opener, ok := openers[u.Scheme]
+	mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown upload location %q, expected one of %v or a directory", u.Scheme, Schemes())
+	}
+	return opener(ctx, u)
+}
+
+// joinKey joins an object prefix, like the path of "s3://bucket/path", with
+// an artifact's name.
+func joinKey(prefix, name string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
@@ -0,0 +1,134 @@
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func init() {
+	Register(openDir, "file")
+}
+
+// A Dir is an Uploader that writes artifacts to a local (or mounted)
+// directory, and keeps an index.html there that links to all of them.
+type Dir struct {
+	dir string
+}
+
+func openDir(ctx context.Context, uri *url.URL) (Uploader, error) {
+	dir := FilePath(uri)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Dir{dir: dir}, nil
+}
+
+func (d *Dir) Upload(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	filename := filepath.Join(d.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		return "", err
+	}
+	if err := d.writeIndex(); err != nil {
+		return "", err
+	}
+
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	return FileURL(abs), nil
+}
+
+// FileURL returns the file:// URL of an absolute path, e.g.
+// "file:///home/me/out.png", or on Windows, "file:///C:/Users/me/out.png"
+// for `C:\Users\me\out.png` and "file:////server/share/out.png" for
+// `\\server\share\out.png`.
+func FileURL(filename string) string {
+	path := filepath.ToSlash(filename)
+	if !strings.HasPrefix(path, "/") {
+		// A drive letter, e.g. C:/Users.
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// FilePath returns the path of a file:// URL, undoing FileURL. Relative
+// paths can be written as e.g. "file://out/artifacts".
+func FilePath(uri *url.URL) string {
+	path := uri.Path
+	if uri.Host != "" && uri.Host != "localhost" {
+		path = uri.Host + path
+	}
+	if len(path) >= 3 && path[0] == '/' && isDriveLetter(path[1]) && path[2] == ':' {
+		// A drive letter, e.g. /C:/Users.
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+func isDriveLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// writeIndex rewrites index.html with a link to every artifact in the
+// directory, and the images inline.
+func (d *Dir) writeIndex() error {
+	var names []string
+	err := filepath.Walk(d.dir, func(filename string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(d.dir, filename)
+		if err != nil {
+			return err
+		}
+		if name != "index.html" && !strings.HasPrefix(info.Name(), ".") {
+			names = append(names, filepath.ToSlash(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>pkgviz</title></head>\n<body>\n<ul>\n")
+	for _, name := range names {
+		link := html.EscapeString((&url.URL{Path: name}).String())
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a>", link, html.EscapeString(name))
+		if ext := filepath.Ext(name); ext == ".png" || ext == ".svg" {
+			fmt.Fprintf(&b, "<br><img src=\"%s\" style=\"max-width: 100%%\">", link)
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ul>\n</body>\n</html>\n")
+
+	// Written atomically, so that it's never served half-written.
+	f, err := ioutil.TempFile(d.dir, ".index")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(d.dir, "index.html"))
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package artifact
+
+// The file:// URLs of absolute paths.
+var fileURLTests = []struct {
+	path string
+	url  string
+}{
+	{"/home/me/out.png", "file:///home/me/out.png"},
+	{"/home/me/my graphs/out.png", "file:///home/me/my%20graphs/out.png"},
+}
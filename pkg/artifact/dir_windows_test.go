@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package artifact
+
+// The file:// URLs of absolute paths.
+var fileURLTests = []struct {
+	path string
+	url  string
+}{
+	{`C:\Users\me\out.png`, "file:///C:/Users/me/out.png"},
+	{`C:\Users\me\my graphs\out.png`, "file:///C:/Users/me/my%20graphs/out.png"},
+	{`\\server\share\out.png`, "file:////server/share/out.png"},
+}
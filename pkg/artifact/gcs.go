@@ -0,0 +1,99 @@
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register(openGCS, "gs")
+}
+
+// GCS is an Uploader that puts artifacts in a Google Cloud Storage bucket.
+type GCS struct {
+	bucket string
+	prefix string
+	token  string
+	client *http.Client
+}
+
+// openGCS opens a URI like "gs://bucket/path". The OAuth access token is
+// $GOOGLE_OAUTH_ACCESS_TOKEN, or else the default service account's when
+// running on Google Cloud, or else gcloud's.
+func openGCS(ctx context.Context, uri *url.URL) (Uploader, error) {
+	if uri.Host == "" {
+		return nil, fmt.Errorf("no bucket in %v", uri)
+	}
+	token, err := gcsAccessToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot upload to %v: %v", uri, err)
+	}
+	return &GCS{bucket: uri.Host, prefix: uri.Path, token: token, client: http.DefaultClient}, nil
+}
+
+func gcsAccessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	// The metadata server is only there on Google Cloud, so don't wait long
+	// for it anywhere else.
+	metadataCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequest("GET", "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	if resp, err := http.DefaultClient.Do(req.WithContext(metadataCtx)); err == nil {
+		defer resp.Body.Close()
+		var token struct {
+			AccessToken string `json:"access_token"`
+		}
+		if resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(&token) == nil && token.AccessToken != "" {
+			return token.AccessToken, nil
+		}
+	}
+
+	out, err := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token").Output()
+	if err != nil {
+		return "", fmt.Errorf("no credentials: set GOOGLE_OAUTH_ACCESS_TOKEN, run on Google Cloud, or log in with gcloud (%v)", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (g *GCS) Upload(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	key := joinKey(g.prefix, name)
+	uploadURL := fmt.Sprintf(
+		"https://storage.googleapis.com/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		url.PathEscape(g.bucket),
+		url.QueryEscape(key),
+	)
+	req, err := http.NewRequest("POST", uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+g.token)
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("cannot upload gs://%s/%s: %v: %s", g.bucket, key, resp.Status, bytes.TrimSpace(body))
+	}
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", g.bucket, escapeKey(key)), nil
+}
@@ -2,5 +2,5 @@ package nested
 
 type nestedStruct struct {
 	name                  string
-	selfReferentialStruct *NestedStruct
+	selfReferentialStruct *nestedStruct
 }
@@ -0,0 +1,76 @@
+// Package graphstore pushes pkgviz graphs into graph databases, so that an
+// architecture knowledge graph can be kept up to date with the code (e.g.
+// from CI). Stores are opened by URI, and each kind of store registers the
+// URI schemes it handles.
+package graphstore
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// A Store is a graph database that graphs can be pushed to.
+type Store interface {
+	// Push upserts the graph's types as nodes, and the references between
+	// them as edges. Pushing a graph again updates its types, and replaces
+	// their references.
+	Push(ctx context.Context, records pkgviz.Records) error
+
+	Close() error
+}
+
+// An Opener opens the Store at a URI with one of its registered schemes.
+type Opener func(ctx context.Context, uri *url.URL) (Store, error)
+
+var (
+	mu      sync.Mutex
+	openers = map[string]Opener{}
+)
+
+// Register makes a kind of Store available to Open under the given URI
+// schemes.
+func Register(opener Opener, schemes ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, scheme := range schemes {
+		if _, ok := openers[scheme]; ok {
+			panic(fmt.Sprintf("graphstore: scheme %q is already registered", scheme))
+		}
+		openers[scheme] = opener
+	}
+}
+
+// Schemes returns the URI schemes of the registered stores.
+func Schemes() []string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var schemes []string
+	for scheme := range openers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open opens the Store at uri, e.g. "bolt://localhost:7687".
+func Open(ctx context.Context, uri string) (Store, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	opener, ok := openers[u.Scheme]
+	mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown graph store %q, expected one of %v", u.Scheme, Schemes())
+	}
+	return opener(ctx, u)
+}
@@ -0,0 +1,44 @@
+package graphstore_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/tiegz/pkgviz-go/pkg/graphstore"
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+type fakeStore struct {
+	pushed []pkgviz.Records
+}
+
+func (s *fakeStore) Push(ctx context.Context, records pkgviz.Records) error {
+	s.pushed = append(s.pushed, records)
+	return nil
+}
+
+func (s *fakeStore) Close() error {
+	return nil
+}
+
+func TestOpen(t *testing.T) {
+	store := &fakeStore{}
+	var opened *url.URL
+	graphstore.Register(func(ctx context.Context, uri *url.URL) (graphstore.Store, error) {
+		opened = uri
+		return store, nil
+	}, "fake")
+
+	s, err := graphstore.Open(context.Background(), "fake://host/db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != store || opened.Host != "host" {
+		t.Errorf("Expected the fake store to be opened at host, got %v at %v", s, opened)
+	}
+
+	if _, err := graphstore.Open(context.Background(), "unknown://host"); err == nil {
+		t.Error("Expected an error for an unknown scheme")
+	}
+}
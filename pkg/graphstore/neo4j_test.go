@@ -0,0 +1,43 @@
+package graphstore
+
+import (
+	"testing"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+func TestNeo4jParams(t *testing.T) {
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/chain", map[string]string{
+		"chain.go": "package chain\n\ntype Hash [32]byte\n\ntype Block struct{ hash Hash }\n",
+	}, pkgviz.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodesByLabel, ids, edges, err := neo4jParams(graph.Records())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arrays := nodesByLabel["Array"]; len(arrays) != 1 || arrays[0].(map[string]interface{})["name"] != "Hash" {
+		t.Errorf("Expected Hash to be pushed as an Array, got %v", nodesByLabel)
+	}
+	if len(ids) != 2 {
+		t.Errorf("Expected Block's and Hash's references to be replaced, got %v", ids)
+	}
+	found := false
+	for _, edge := range edges {
+		edge := edge.(map[string]interface{})
+		if edge["field"] == "hash" && edge["to"] == "example.com/chain.Hash" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a reference from Block.hash to Hash, got %v", edges)
+	}
+
+	// Types of kinds without a label aren't silently left out.
+	records := pkgviz.Records{Nodes: []pkgviz.NodeRecord{{ID: "example.com/chain.Widget", Kind: "widget"}}}
+	if _, _, _, err := neo4jParams(records); err == nil {
+		t.Error("Expected an error for a type of an unknown kind")
+	}
+}
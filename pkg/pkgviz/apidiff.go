@@ -0,0 +1,202 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// An APIChange is a change to the exported API of a graphed package: an
+// exported type that was added or removed, or an exported field or method
+// of one that was added, removed or changed.
+type APIChange struct {
+	// Package is the type's package, relative to the graphed package (so
+	// it's empty for types in the graphed package itself).
+	Package string `json:"package,omitempty"`
+	Type    string `json:"type"`
+	// Member is e.g. "field Name" or "method Close", or empty if the type
+	// itself was added, removed or changed.
+	Member string     `json:"member,omitempty"`
+	Change TypeChange `json:"change"`
+	// Breaking is whether code using the base API may not compile against
+	// the head API.
+	Breaking bool `json:"breaking"`
+	// Details describes the change, e.g. "changed from func() to
+	// func() error".
+	Details string `json:"details"`
+}
+
+func (c APIChange) String() string {
+	name := c.Type
+	if c.Package != "" {
+		name = c.Package + "." + c.Type
+	}
+	if c.Member == "" {
+		return fmt.Sprintf("%s type %s: %s", c.Change, name, c.Details)
+	}
+	return fmt.Sprintf("%s: %s %s: %s", name, c.Change, c.Member, c.Details)
+}
+
+// DiffAPI compares the exported types, fields and methods of two graphs of
+// the same package (e.g. at two versions), and returns the changes, sorted
+// by package, type and member.
+//
+// Removing or changing anything is breaking, as is adding a method to an
+// interface that can be implemented outside of its package. Adding types,
+// fields and methods otherwise isn't.
+func DiffAPI(base, head *pkg) []APIChange {
+	baseTypes, headTypes := apiTypes(base), apiTypes(head)
+
+	var changes []APIChange
+	for key, baseType := range baseTypes {
+		headType, ok := headTypes[key]
+		if !ok {
+			changes = append(changes, APIChange{Package: key.pkgPath, Type: key.name, Change: TypeRemoved, Breaking: true, Details: baseType.kind})
+			continue
+		}
+		if baseType.kind != headType.kind {
+			changes = append(changes, APIChange{
+				Package:  key.pkgPath,
+				Type:     key.name,
+				Change:   TypeChanged,
+				Breaking: true,
+				Details:  fmt.Sprintf("changed from %s to %s", baseType.kind, headType.kind),
+			})
+			continue
+		}
+
+		for member, baseSignature := range baseType.members {
+			headSignature, ok := headType.members[member]
+			if !ok {
+				changes = append(changes, APIChange{Package: key.pkgPath, Type: key.name, Member: member, Change: TypeRemoved, Breaking: true, Details: baseSignature})
+			} else if baseSignature != headSignature {
+				changes = append(changes, APIChange{
+					Package:  key.pkgPath,
+					Type:     key.name,
+					Member:   member,
+					Change:   TypeChanged,
+					Breaking: true,
+					Details:  fmt.Sprintf("changed from %s to %s", baseSignature, headSignature),
+				})
+			}
+		}
+		for member, headSignature := range headType.members {
+			if _, ok := baseType.members[member]; !ok {
+				// Types outside of the package can't implement an interface
+				// with unexported methods, so only they can be extended.
+				breaking := headType.kind == "interface" && baseType.implementable
+				changes = append(changes, APIChange{Package: key.pkgPath, Type: key.name, Member: member, Change: TypeAdded, Breaking: breaking, Details: headSignature})
+			}
+		}
+	}
+	for key, headType := range headTypes {
+		if _, ok := baseTypes[key]; !ok {
+			changes = append(changes, APIChange{Package: key.pkgPath, Type: key.name, Change: TypeAdded, Details: headType.kind})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Package != changes[j].Package {
+			return changes[i].Package < changes[j].Package
+		}
+		if changes[i].Type != changes[j].Type {
+			return changes[i].Type < changes[j].Type
+		}
+		return changes[i].Member < changes[j].Member
+	})
+	return changes
+}
+
+// The colors behind the names of the types that HighlightAPIChanges
+// highlights.
+const (
+	compatibleChangeColor = "#c6efce"
+	breakingChangeColor   = "#ffc7ce"
+)
+
+// HighlightAPIChanges colors the types in the graph (usually the head graph
+// of DiffAPI) that the changes are to, by whether any of their changes are
+// breaking. Removed types aren't in the head graph, so they can't be.
+func HighlightAPIChanges(p *pkg, changes []APIChange) {
+	breaking := map[diffKey]bool{}
+	for _, change := range changes {
+		key := diffKey{change.Package, change.Type}
+		breaking[key] = breaking[key] || change.Breaking
+	}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		if isBreaking, ok := breaking[diffKey{pkgPath, node.typeObj.Name()}]; !ok {
+			return
+		} else if isBreaking {
+			node.headerColor = breakingChangeColor
+		} else {
+			node.headerColor = compatibleChangeColor
+		}
+	})
+}
+
+// apiType is the exported API of a type.
+type apiType struct {
+	kind string
+	// members are the type's exported fields and methods, keyed by e.g.
+	// "field Name", with their types.
+	members map[string]string
+	// implementable is whether an interface can be implemented outside of
+	// its package, i.e. it has no unexported methods.
+	implementable bool
+}
+
+func apiTypes(p *pkg) map[diffKey]apiType {
+	apiTypes := map[diffKey]apiType{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil || !node.typeObj.Exported() || isInternalPkgPath(pkgPath) {
+			return
+		}
+		qualifier := types.RelativeTo(node.typeObj.Pkg())
+		t := apiType{kind: node.typeType, members: map[string]string{}, implementable: true}
+
+		switch underlying := node.typeObj.Type().Underlying().(type) {
+		case *types.Struct:
+			for i := 0; i < underlying.NumFields(); i++ {
+				if f := underlying.Field(i); f.Exported() {
+					t.members["field "+f.Name()] = types.TypeString(f.Type(), qualifier)
+				}
+			}
+		case *types.Interface:
+			for i := 0; i < underlying.NumMethods(); i++ {
+				m := underlying.Method(i)
+				if m.Exported() {
+					t.members["method "+m.Name()] = types.TypeString(m.Type(), qualifier)
+				} else {
+					t.implementable = false
+				}
+			}
+		default:
+			t.members["underlying type"] = types.TypeString(underlying, qualifier)
+		}
+
+		if named, ok := node.typeObj.Type().(*types.Named); ok {
+			for i := 0; i < named.NumMethods(); i++ {
+				if m := named.Method(i); m.Exported() {
+					t.members["method "+m.Name()] = types.TypeString(m.Type(), qualifier)
+				}
+			}
+		}
+		apiTypes[diffKey{pkgPath, node.typeObj.Name()}] = t
+	})
+	return apiTypes
+}
+
+// isInternalPkgPath returns whether the package can only be imported from
+// within the graphed package, so isn't part of its API.
+func isInternalPkgPath(pkgPath string) bool {
+	for _, elem := range strings.Split(pkgPath, "/") {
+		if elem == "internal" {
+			return true
+		}
+	}
+	return false
+}
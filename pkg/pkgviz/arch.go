@@ -0,0 +1,125 @@
+package pkgviz
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The smallest and largest font sizes of the packages in an architecture
+// graph, which are scaled between by their number of types.
+const (
+	minArchFontSize = 12
+	maxArchFontSize = 36
+)
+
+// An Architecture is a graph of the packages in a graph, with a dependency
+// between two packages wherever the types in one refer to the types in the
+// other, rather than wherever one imports the other.
+type Architecture struct {
+	// Package is the graphed package's import path.
+	Package      string           `json:"package"`
+	Packages     []ArchPackage    `json:"packages"`
+	Dependencies []ArchDependency `json:"dependencies"`
+}
+
+// An ArchPackage is a package in an Architecture.
+type ArchPackage struct {
+	Package string `json:"package"` // the package's import path
+	Types   int    `json:"types"`
+}
+
+// An ArchDependency is a dependency between two packages in an
+// Architecture.
+type ArchDependency struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// References is how many references there are from the types in From to
+	// the types in To.
+	References int `json:"references"`
+}
+
+func (d ArchDependency) String() string {
+	return fmt.Sprintf("%s -> %s (%d)", d.From, d.To, d.References)
+}
+
+// Architecture returns the graph of the graph's packages and the
+// dependencies between them, sorted by package.
+func (p *pkg) Architecture() Architecture {
+	matrix := p.DependencyMatrix()
+	arch := Architecture{Package: matrix.Package}
+
+	types := map[string]int{}
+	for _, node := range p.Records().Nodes {
+		if node.Kind != "external" {
+			types[node.Package]++
+		}
+	}
+	for i, from := range matrix.Packages {
+		arch.Packages = append(arch.Packages, ArchPackage{Package: from, Types: types[from]})
+		for j, to := range matrix.Packages {
+			if count := matrix.References[i][j]; count > 0 {
+				arch.Dependencies = append(arch.Dependencies, ArchDependency{From: from, To: to, References: count})
+			}
+		}
+	}
+	return arch
+}
+
+// String writes out the dot graph of the architecture, with the packages
+// sized by how many types they have, and the dependencies as thick as how
+// many references they stand for.
+func (a Architecture) String() string {
+	maxTypes := 0
+	for _, archPkg := range a.Packages {
+		if archPkg.Types > maxTypes {
+			maxTypes = archPkg.Types
+		}
+	}
+
+	out := fmt.Sprintf("digraph V {\n"+
+		"  graph [label=< <br/><b>%s</b> >, labelloc=b, fontsize=10 fontname=Arial];\n"+
+		"  node [fontname=Arial shape=box style=rounded];\n"+
+		"  edge [fontname=Arial];\n",
+		escapeHtml(a.Package),
+	)
+	for _, archPkg := range a.Packages {
+		out = fmt.Sprintf("%s  %s [label=<%s<br/><font point-size='10' color='#7f8183'>%d %s</font>> fontsize=%d];\n",
+			out,
+			quoteString(archPkg.Package),
+			escapeHtml(a.pkgLabel(archPkg.Package)),
+			archPkg.Types,
+			plural(archPkg.Types, "type", "types"),
+			archFontSize(archPkg.Types, maxTypes),
+		)
+	}
+	for _, dependency := range a.Dependencies {
+		out = fmt.Sprintf("%s  %s -> %s [penwidth=%d label=\"%d\"];\n",
+			out,
+			quoteString(dependency.From),
+			quoteString(dependency.To),
+			linkPenWidth(dependency.References),
+			dependency.References,
+		)
+	}
+	return out + "}\n"
+}
+
+// pkgLabel returns a package's import path relative to the graphed
+// package's, or the graphed package's own.
+func (a Architecture) pkgLabel(pkgName string) string {
+	if pkgName == a.Package {
+		return pkgName
+	}
+	return strings.TrimPrefix(pkgName, a.Package+"/")
+}
+
+// archFontSize scales a package's font size by its number of types,
+// relative to the package with the most, by area.
+func archFontSize(types, maxTypes int) int {
+	if maxTypes == 0 {
+		return minArchFontSize
+	}
+	scale := math.Sqrt(float64(types) / float64(maxTypes))
+	return minArchFontSize + int(math.Round(scale*(maxArchFontSize-minArchFontSize)))
+}
@@ -0,0 +1,143 @@
+//go:build !js
+// +build !js
+
+package pkgviz
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The color behind the names of types that haven't been modified within
+// Options.StaleAfter.
+const staleHeaderColor = "#e8e8e8"
+
+type blameCommit struct {
+	author     string
+	authorTime time.Time
+}
+
+// annotateBlame annotates each type that's declared in a git repository with
+// when it was last modified and its owners from CODEOWNERS, or if it has
+// none, its primary author (the author of most of its lines). Types that
+// can't be blamed, e.g. in the module cache, are left as they are.
+func annotateBlame(p *pkg, opts *Options) {
+	blames := map[string][]*blameCommit{}
+	codeowners := map[string]*codeownersFile{}
+
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		filename := node.position.Filename
+		if filename == "" {
+			return
+		}
+		lines, ok := blames[filename]
+		if !ok {
+			lines, _ = blameFile(filename)
+			blames[filename] = lines
+		}
+		if node.endLine > len(lines) {
+			return
+		}
+
+		var lastModified time.Time
+		linesByAuthor := map[string]int{}
+		var primaryAuthor string
+		for _, commit := range lines[node.position.Line-1 : node.endLine] {
+			if commit.authorTime.After(lastModified) {
+				lastModified = commit.authorTime
+			}
+			linesByAuthor[commit.author]++
+			if linesByAuthor[commit.author] > linesByAuthor[primaryAuthor] ||
+				(linesByAuthor[commit.author] == linesByAuthor[primaryAuthor] && commit.author < primaryAuthor) {
+				primaryAuthor = commit.author
+			}
+		}
+
+		owner := primaryAuthor
+		dir := filepath.Dir(filename)
+		if _, ok := codeowners[dir]; !ok {
+			codeowners[dir], _ = readCodeowners(dir)
+		}
+		if owners := codeowners[dir].owners(filename); len(owners) > 0 {
+			owner = strings.Join(owners, " ")
+		}
+
+		if node.annotations == nil {
+			node.annotations = map[string]string{}
+		}
+		node.annotations["blame"] = fmt.Sprintf("modified %s by %s", lastModified.Format("2006-01-02"), owner)
+		if opts.StaleAfter > 0 && time.Since(lastModified) > opts.StaleAfter {
+			node.headerColor = staleHeaderColor
+		}
+	})
+}
+
+// readCodeowners reads the CODEOWNERS file of the git repository that dir
+// is in, if it has one.
+func readCodeowners(dir string) (*codeownersFile, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	// git prints the root with forward slashes, even on Windows.
+	root := filepath.FromSlash(strings.TrimSpace(string(out)))
+
+	for _, codeownersPath := range codeownersPaths {
+		data, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(codeownersPath)))
+		if err == nil {
+			return parseCodeowners(root, string(data)), nil
+		}
+	}
+	return nil, nil
+}
+
+// blameFile returns the commit that last modified each line of the file.
+func blameFile(filename string) ([]*blameCommit, error) {
+	cmd := exec.Command("git", "blame", "--porcelain", "--", filepath.Base(filename))
+	cmd.Dir = filepath.Dir(filename)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each line is preceded by a header with its commit's hash, and the
+	// first time a commit is seen, by the commit's details too.
+	commits := map[string]*blameCommit{}
+	var lines []*blameCommit
+	var commit *blameCommit
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			lines = append(lines, commit)
+		case strings.HasPrefix(line, "author "):
+			commit.author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-time "):
+			seconds, err := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid git blame output %q", line)
+			}
+			commit.authorTime = time.Unix(seconds, 0)
+		default:
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && len(fields[0]) >= 40 {
+				if commit = commits[fields[0]]; commit == nil {
+					commit = &blameCommit{}
+					commits[fields[0]] = commit
+				}
+			}
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,92 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/types"
+)
+
+// overBudgetColor is the color of the border of structs that are bigger
+// than their size budget.
+const overBudgetColor = "#d9534f"
+
+// A SizeBudget is the most bytes that the structs in a set of packages may
+// take up in memory, e.g. to keep them within a cache line.
+type SizeBudget struct {
+	// Packages is a pattern of the packages' import paths, like a pattern
+	// in Layer.Packages, e.g. "pkg/cache/...".
+	Packages      string `json:"packages"`
+	MaxStructSize int64  `json:"maxStructSize"`
+}
+
+// An OversizedStruct is a struct that's bigger than its size budget.
+type OversizedStruct struct {
+	Package string `json:"package"` // the struct's package's import path
+	Name    string `json:"name"`
+	Size    int64  `json:"size"`
+	Budget  int64  `json:"budget"`
+}
+
+func (s OversizedStruct) String() string {
+	return fmt.Sprintf("%s.%s: %s, over its budget of %s", s.Package, s.Name, formatBytes(s.Size), formatBytes(s.Budget))
+}
+
+// OversizedStructs returns the structs in the graph that are bigger than
+// the budget of their package, laid out for the architecture (e.g. "386"),
+// or the current one if it's empty. If more than one budget has a struct's
+// package, the first one applies. They're sorted by package and name.
+func (p *pkg) OversizedStructs(budgets []SizeBudget, arch string) ([]OversizedStruct, error) {
+	oversized, _, err := p.findOversizedStructs(budgets, arch)
+	return oversized, err
+}
+
+func (p *pkg) findOversizedStructs(budgets []SizeBudget, arch string) ([]OversizedStruct, []*graphNode, error) {
+	sizes, err := layoutSizes(arch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var oversized []OversizedStruct
+	var nodes []*graphNode
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil || node.typeType != "struct" {
+			return
+		}
+		s, ok := node.typeObj.Type().Underlying().(*types.Struct)
+		if !ok {
+			return
+		}
+		importPath := p.pkgImportPath(pkgPath)
+		for _, budget := range budgets {
+			if !matchesPkgPattern(budget.Packages, importPath) {
+				continue
+			}
+			if layout, ok := layoutStruct(sizes, s); ok && layout.size > budget.MaxStructSize {
+				oversized = append(oversized, OversizedStruct{
+					Package: importPath,
+					Name:    node.typeObj.Name(),
+					Size:    layout.size,
+					Budget:  budget.MaxStructSize,
+				})
+				nodes = append(nodes, node)
+			}
+			break
+		}
+	})
+	return oversized, nodes, nil
+}
+
+// highlightOversizedStructs colors the borders of the structs that are over
+// their size budget red, and annotates them with their size and budget.
+func highlightOversizedStructs(p *pkg, budgets []SizeBudget, arch string) {
+	oversized, nodes, err := p.findOversizedStructs(budgets, arch)
+	if err != nil {
+		return
+	}
+	for i, node := range nodes {
+		node.borderColor = overBudgetColor
+		if node.annotations == nil {
+			node.annotations = map[string]string{}
+		}
+		node.annotations["budget"] = fmt.Sprintf("%s, over budget of %s", formatBytes(oversized[i].Size), formatBytes(oversized[i].Budget))
+	}
+}
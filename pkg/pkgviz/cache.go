@@ -0,0 +1,89 @@
+package pkgviz
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// A Cache remembers the analysis of every package visited while building a
+// graph, so that rebuilding the graph (e.g. in watch mode) only re-analyzes
+// the packages that were invalidated since the last build.
+type Cache struct {
+	mu   sync.Mutex
+	pkgs map[cacheKey]*cachedPkg
+}
+
+// Types are named relative to the root package, so the same package
+// analyzed under a different root gets its own entry.
+type cacheKey struct {
+	rootPkgName string
+	pkgName     string
+}
+
+type cachedPkg struct {
+	dir        string
+	importPath string
+	imports    []string
+	fragment   *pkg
+}
+
+// NewCache returns an empty Cache.
+func NewCache() *Cache {
+	return &Cache{pkgs: map[cacheKey]*cachedPkg{}}
+}
+
+// Dirs returns the source directories of all the cached packages.
+func (c *Cache) Dirs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := map[string]bool{}
+	var dirs []string
+	for _, cached := range c.pkgs {
+		if !seen[cached.dir] {
+			seen[cached.dir] = true
+			dirs = append(dirs, cached.dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// InvalidateDir forgets the analysis of any package whose source lives in
+// dir, and returns the import paths of the packages that were forgotten.
+func (c *Cache) InvalidateDir(dir string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var pkgNames []string
+	for key, cached := range c.pkgs {
+		if filepath.Clean(cached.dir) == filepath.Clean(dir) {
+			delete(c.pkgs, key)
+			pkgNames = append(pkgNames, key.pkgName)
+		}
+	}
+	sort.Strings(pkgNames)
+	return pkgNames
+}
+
+func (c *Cache) get(rootPkgName, pkgName string) (*cachedPkg, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.pkgs[cacheKey{rootPkgName, pkgName}]
+	return cached, ok
+}
+
+func (c *Cache) put(rootPkgName, pkgName string, cached *cachedPkg) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pkgs[cacheKey{rootPkgName, pkgName}] = cached
+}
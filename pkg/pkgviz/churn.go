@@ -0,0 +1,88 @@
+//go:build !js
+// +build !js
+
+package pkgviz
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The colors behind the names of types, from the least to the most changed.
+var churnHeatScale = []string{"#fff5eb", "#fdd0a2", "#fdae6b", "#fd8d3c", "#e6550d", "#a63603"}
+
+// annotateChurn annotates each type that's declared in a git repository with
+// how many commits changed it within opts.ChurnWindow, and colors it on a heat
+// scale relative to the most changed type. Types that can't be found in the
+// history, e.g. in the module cache, are left as they are.
+func annotateChurn(p *pkg, opts *Options) {
+	since := time.Now().Add(-opts.ChurnWindow)
+
+	var nodes []*graphNode
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.position.Filename != "" {
+			nodes = append(nodes, node)
+		}
+	})
+
+	// git follows each type's lines back through history separately, so
+	// that's done for several types at a time.
+	changes := make([]int, len(nodes))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.NumCPU())
+	for i, node := range nodes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, node *graphNode) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			changes[i], _ = countChanges(node.position.Filename, node.position.Line, node.endLine, since)
+		}(i, node)
+	}
+	wg.Wait()
+
+	maxChanges := 0
+	for _, n := range changes {
+		if n > maxChanges {
+			maxChanges = n
+		}
+	}
+
+	days := int(opts.ChurnWindow.Hours() / 24)
+	for i, node := range nodes {
+		if node.annotations == nil {
+			node.annotations = map[string]string{}
+		}
+		plural := "s"
+		if changes[i] == 1 {
+			plural = ""
+		}
+		node.annotations["churn"] = fmt.Sprintf("%d change%s in %d days", changes[i], plural, days)
+		if changes[i] > 0 {
+			node.headerColor = churnHeatScale[(len(churnHeatScale)-1)*changes[i]/maxChanges]
+		}
+	}
+}
+
+// countChanges returns how many commits since the given time changed any of
+// the lines from startLine to endLine of the file (as they are at HEAD).
+func countChanges(filename string, startLine, endLine int, since time.Time) (int, error) {
+	cmd := exec.Command(
+		"git", "log",
+		"--no-patch",
+		"--format=%H",
+		"--since="+since.Format(time.RFC3339),
+		fmt.Sprintf("-L%d,%d:%s", startLine, endLine, filepath.Base(filename)),
+	)
+	cmd.Dir = filepath.Dir(filename)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, err
+	}
+	return len(strings.Fields(string(out))), nil
+}
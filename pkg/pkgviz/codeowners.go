@@ -0,0 +1,101 @@
+package pkgviz
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The places that GitHub and GitLab look for a CODEOWNERS file, relative to
+// the root of a repository, in order.
+var codeownersPaths = []string{"CODEOWNERS", ".github/CODEOWNERS", ".gitlab/CODEOWNERS", "docs/CODEOWNERS"}
+
+// A codeownersFile maps the files in a repository to their owners.
+type codeownersFile struct {
+	root  string
+	rules []codeownersRule
+}
+
+type codeownersRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// parseCodeowners parses the CODEOWNERS file of the repository at root.
+// Lines that can't be parsed are ignored.
+func parseCodeowners(root string, data string) *codeownersFile {
+	c := &codeownersFile{root: root}
+	for _, line := range strings.Split(data, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		// GitLab's "[Section]" headers are skipped, so their rules apply
+		// as if they were all in one section.
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "[") || strings.HasPrefix(fields[0], "^[") {
+			continue
+		}
+		pattern, err := codeownersPattern(fields[0])
+		if err != nil {
+			continue
+		}
+		c.rules = append(c.rules, codeownersRule{pattern: pattern, owners: fields[1:]})
+	}
+	return c
+}
+
+// codeownersPattern turns a gitignore-style CODEOWNERS pattern into a
+// regexp matching the slash-separated paths it applies to.
+func codeownersPattern(pattern string) (*regexp.Regexp, error) {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	// Patterns are relative to the root if they have a slash anywhere but
+	// the end, and otherwise match at any depth.
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	expr := "^(.*/)?"
+	if anchored {
+		expr = "^"
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr += ".*"
+			i++
+		case pattern[i] == '*':
+			expr += "[^/]*"
+		case pattern[i] == '?':
+			expr += "[^/]"
+		default:
+			expr += regexp.QuoteMeta(pattern[i : i+1])
+		}
+	}
+	// A pattern matching a directory applies to everything in it.
+	if dirOnly {
+		expr += "/.*$"
+	} else {
+		expr += "(/.*)?$"
+	}
+	return regexp.Compile(expr)
+}
+
+// owners returns the owners of the file, which are those of the last rule
+// that matches it.
+func (c *codeownersFile) owners(filename string) []string {
+	if c == nil {
+		return nil
+	}
+	rel, err := filepath.Rel(c.root, filename)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		if c.rules[i].pattern.MatchString(rel) {
+			return c.rules[i].owners
+		}
+	}
+	return nil
+}
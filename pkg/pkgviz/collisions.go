@@ -0,0 +1,144 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/types"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// IDs are made from types' package paths and names by labelizeName, which
+// can make the same ID for different types, e.g. for Node and node, which
+// only differ by case, or for a_b.c and a.b_c. Graphviz would draw them as
+// one node, with the arrows to either pointing to it, so each type whose
+// ID another type already has is given its own, with a suffix.
+
+// An IDCollision is a set of types whose IDs would have been the same.
+type IDCollision struct {
+	ID string // the ID that they would all have had
+	// Types are the types, named relative to the graphed package like the
+	// Options' Focus, e.g. "store.Item", sorted, and IDs the IDs that
+	// they're drawn with instead, in the same order.
+	Types []string
+	IDs   []string
+}
+
+func (c IDCollision) String() string {
+	drawnAs := make([]string, len(c.Types))
+	for i := range c.Types {
+		drawnAs[i] = fmt.Sprintf("%s as %s", c.Types[i], c.IDs[i])
+	}
+	return fmt.Sprintf("%s: %s", c.ID, strings.Join(drawnAs, ", "))
+}
+
+// IDCollisions returns the sets of types in the graph whose IDs would have
+// been the same, sorted by ID.
+func (p *pkg) IDCollisions() []IDCollision {
+	return p.collisions
+}
+
+// typeKey is a type's package path (relative to the graphed package) and
+// name, which labelizeName makes its ID from.
+type typeKey struct {
+	pkgName, typeName string
+}
+
+func (k typeKey) String() string {
+	if k.pkgName == "" {
+		return k.typeName
+	}
+	return k.pkgName + "." + k.typeName
+}
+
+// disambiguateTypeIds gives each type whose ID collides with another's its
+// own, and points the arrows to and from it at that ID. Of the types that
+// collide, the first by package path and name keeps the ID, and the others
+// get a suffix with a hash of their package path and name, so that they're
+// drawn with the same IDs every time.
+func disambiguateTypeIds(p *pkg) {
+	nodesByKey := map[typeKey][]*graphNode{}
+	keysById := map[string][]typeKey{}
+	addKey := func(key typeKey) {
+		id := labelizeName(key.pkgName, key.typeName)
+		for _, k := range keysById[id] {
+			if k == key {
+				return
+			}
+		}
+		keysById[id] = append(keysById[id], key)
+	}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		key := typeKey{node.pkgName, node.typeObj.Name()}
+		nodesByKey[key] = append(nodesByKey[key], node)
+		addKey(key)
+	})
+	// Types outside of the graph are drawn as placeholders with IDs too.
+	for _, nodeLink := range p.nodeLinks {
+		addKey(typeKey{nodeLink.toTypePkgName, nodeLink.toTypeName})
+	}
+
+	var ids []string
+	for id, keys := range keysById {
+		if len(keys) > 1 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	p.collisions = nil
+	renamed := map[typeKey]string{}
+	renamedObjs := map[types.Object]string{}
+	for _, id := range ids {
+		keys := keysById[id]
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		collision := IDCollision{ID: id}
+		for i, key := range keys {
+			typeId := id
+			if i > 0 {
+				h := fnv.New32a()
+				h.Write([]byte(key.String()))
+				typeId = fmt.Sprintf("%s_%08x", id, h.Sum32())
+				renamed[key] = typeId
+			}
+			// Nodes are shared with the cache, so they're given their IDs
+			// afresh each time, from their keys.
+			for _, node := range nodesByKey[key] {
+				node.typeId = typeId
+				if i > 0 {
+					renamedObjs[node.typeObj] = typeId
+				}
+			}
+			collision.Types = append(collision.Types, key.String())
+			collision.IDs = append(collision.IDs, typeId)
+		}
+		p.collisions = append(p.collisions, collision)
+	}
+	if len(renamed) == 0 {
+		return
+	}
+
+	for i, nodeLink := range p.nodeLinks {
+		if typeId, ok := renamed[typeKey{nodeLink.toTypePkgName, nodeLink.toTypeName}]; ok {
+			p.nodeLinks[i].toTypeId = typeId
+		}
+		if typeId, ok := renamedObjs[nodeLink.fromTypeObj]; ok && nodeLink.fromTypeObj != nil {
+			p.nodeLinks[i].fromStructTypeId = typeId
+		}
+	}
+	p.renamedIds = renamed
+}
+
+// typeIdOf returns the ID of the type with the given package path (relative
+// to the graphed package) and name.
+func (p *pkg) typeIdOf(pkgName, typeName string) string {
+	if typeId, ok := p.renamedIds[typeKey{pkgName, typeName}]; ok {
+		return typeId
+	}
+	return labelizeName(pkgName, typeName)
+}
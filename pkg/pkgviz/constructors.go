@@ -0,0 +1,96 @@
+package pkgviz
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// addConstructors lists each type's constructors on its node: the functions
+// in its package named New... or Must... (e.g. NewServer, or just New) that
+// return it, or a pointer to it, as their first result.
+func addConstructors(p *pkg) {
+	nodes := map[types.Object]*graphNode{}
+	pkgs := map[*types.Package]bool{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil || node.typeObj.Pkg() == nil {
+			return
+		}
+		nodes[node.typeObj] = node
+		pkgs[node.typeObj.Pkg()] = true
+	})
+
+	for typesPkg := range pkgs {
+		scope := typesPkg.Scope()
+		for _, name := range scope.Names() {
+			fn, ok := scope.Lookup(name).(*types.Func)
+			if !ok || !isConstructorName(name) {
+				continue
+			}
+			sig := fn.Type().(*types.Signature)
+			if sig.Results().Len() == 0 {
+				continue
+			}
+			t := sig.Results().At(0).Type()
+			if ptr, ok := t.(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			named, ok := t.(*types.Named)
+			if !ok {
+				continue
+			}
+			if node, ok := nodes[named.Obj()]; ok {
+				node.constructors = append(node.constructors, name+signatureString(sig, typesPkg))
+			}
+		}
+	}
+}
+
+// isConstructorName returns whether name is New or Must, or starts with one
+// of them and then another word, e.g. NewServer but not Newton.
+func isConstructorName(name string) bool {
+	for _, prefix := range []string{"New", "Must"} {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		if r, _ := utf8.DecodeRuneInString(rest); rest == "" || unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// signatureString returns the parameters and results of sig, with the
+// types in pkg unqualified and others qualified by their package's name,
+// e.g. "(addr string, opts ...Option) (*Server, error)".
+func signatureString(sig *types.Signature, pkg *types.Package) string {
+	var buf bytes.Buffer
+	types.WriteSignature(&buf, sig, func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	})
+	return buf.String()
+}
+
+// printConstructors returns a table row with the type's constructors, if it
+// has any, one per line.
+func (dgn *graphNode) printConstructors(colspan int) string {
+	if len(dgn.constructors) == 0 {
+		return ""
+	}
+	var lines []string
+	for _, constructor := range dgn.constructors {
+		lines = append(lines, escapeHtml(constructor))
+	}
+	return fmt.Sprintf(
+		"<tr><td align='left' colspan='%d'><font point-size='9' color='#7f8183'>%s</font></td></tr>",
+		colspan,
+		strings.Join(lines, "<br align='left'/>"),
+	)
+}
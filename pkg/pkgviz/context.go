@@ -0,0 +1,96 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/types"
+)
+
+// contextFieldColor is the color behind the rows of struct fields that
+// store a context.Context.
+const contextFieldColor = "#f9dedc"
+
+// A ContextField is a struct field that stores a context.Context, which the
+// context package's docs advise against: a context should be passed to each
+// function that needs it instead, so that it's scoped to one call.
+type ContextField struct {
+	// Type is the struct's name, qualified by its package if it isn't the
+	// graphed package itself (e.g. "nested.NestedStruct").
+	Type  string `json:"type"`
+	Field string `json:"field"`
+}
+
+func (f ContextField) String() string {
+	return fmt.Sprintf("%s.%s stores a context.Context", f.Type, f.Field)
+}
+
+// ContextFields returns the fields of the graph's structs that store a
+// context.Context, including embedded ones, sorted by package and struct.
+// The structs named in allowed (qualified like ContextField.Type), e.g.
+// ones that are only ever alive for one request, are skipped.
+func (p *pkg) ContextFields(allowed []string) []ContextField {
+	fields, _ := p.findContextFields(allowed)
+	return fields
+}
+
+// findContextFields returns the fields that store a context.Context, and
+// the nodes of their structs.
+func (p *pkg) findContextFields(allowed []string) ([]ContextField, []*graphNode) {
+	isAllowed := map[string]bool{}
+	for _, name := range allowed {
+		isAllowed[name] = true
+	}
+
+	var fields []ContextField
+	var nodes []*graphNode
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		name := node.typeObj.Name()
+		if pkgPath != "" {
+			name = pkgPath + "." + name
+		}
+		s, ok := node.typeObj.Type().Underlying().(*types.Struct)
+		if !ok || isAllowed[name] {
+			return
+		}
+		for i := 0; i < s.NumFields(); i++ {
+			if isContext(s.Field(i).Type()) {
+				fields = append(fields, ContextField{Type: name, Field: s.Field(i).Name()})
+				nodes = append(nodes, node)
+			}
+		}
+	})
+	return fields, nodes
+}
+
+// isContext returns whether t is context.Context.
+func isContext(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "context" && named.Obj().Name() == "Context"
+}
+
+// highlightContextFields colors the rows of the struct fields that store a
+// context.Context, notes them, and annotates their structs.
+func highlightContextFields(p *pkg, allowed []string) {
+	fields, nodes := p.findContextFields(allowed)
+	for i, node := range nodes {
+		if node.annotations == nil {
+			node.annotations = map[string]string{}
+		}
+		node.annotations["context"] = "stores a context.Context"
+		if node.fieldColors == nil {
+			node.fieldColors = map[string]string{}
+		}
+		if node.fieldNotes == nil {
+			node.fieldNotes = map[string]string{}
+		}
+		field := fields[i].Field
+		node.fieldColors[field] = contextFieldColor
+		if node.fieldNotes[field] != "" {
+			node.fieldNotes[field] += ", context in struct"
+		} else {
+			node.fieldNotes[field] = "context in struct"
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package pkgviz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// cycleColor is the color of the types, references and packages in cycles.
+const cycleColor = "#d9534f"
+
+// cycleBgColors are the colors behind the types in each cycle, so that the
+// types in the same cycle stand out together.
+var cycleBgColors = []string{"#fde2e2", "#fff1d6", "#e4f4e0", "#dfeefb", "#ece3f7", "#fbe3f1"}
+
+// maxCycleCuts is the most cuts suggested for each cycle.
+const maxCycleCuts = 3
+
+// A Cycle is a set of types, or of packages, that refer to each other
+// (directly or through the others in the set), so that none of them can be
+// understood or changed on its own.
+type Cycle struct {
+	// Packages are the packages in a cycle of packages, relative to the
+	// graphed package. They're empty for a cycle of types.
+	Packages []string `json:"packages,omitempty"`
+	// Types are the types in the cycle, qualified by their package if it
+	// isn't the graphed package itself (e.g. "nested.NestedStruct"), sorted.
+	Types []string `json:"types"`
+	// Cuts are the references that would break up a cycle of types the most
+	// if they were removed, best first.
+	Cuts []CycleCut `json:"cuts,omitempty"`
+}
+
+// A CycleCut is a reference from a struct's field to another type in the
+// same cycle, which could be removed (e.g. by referring to an interface
+// instead) to break up the cycle.
+type CycleCut struct {
+	From  string `json:"from"`
+	Field string `json:"field"`
+	To    string `json:"to"`
+	// Remaining is how many of the cycle's types would still be in a cycle
+	// together without the reference, which is 0 if it breaks the cycle
+	// completely.
+	Remaining int `json:"remaining"`
+}
+
+func (c CycleCut) String() string {
+	if c.Remaining == 0 {
+		return fmt.Sprintf("%s.%s -> %s (breaks the cycle)", c.From, c.Field, c.To)
+	}
+	return fmt.Sprintf("%s.%s -> %s (leaves a cycle of %d types)", c.From, c.Field, c.To, c.Remaining)
+}
+
+func (c Cycle) String() string {
+	if len(c.Packages) > 0 {
+		return fmt.Sprintf("packages %s refer to each other, through types %s", strings.Join(c.Packages, ", "), strings.Join(c.Types, ", "))
+	}
+	return fmt.Sprintf("types %s refer to each other", strings.Join(c.Types, ", "))
+}
+
+// Cycles returns the cycles of references between the graph's types, and
+// between its packages, sorted. Types that only refer to themselves (like a
+// linked list's nodes) aren't cycles.
+func (p *pkg) Cycles() []Cycle {
+	cycles, _, _ := p.findCycles()
+	return cycles
+}
+
+// highlightCycles colors the types, references and packages in cycles,
+// with the types in each cycle on the same background.
+func highlightCycles(p *pkg) {
+	_, typeIds, pkgPaths := p.findCycles()
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if i, ok := typeIds[node.typeId]; ok {
+			node.borderColor = cycleColor
+			node.bgColor = cycleBgColors[i%len(cycleBgColors)]
+		}
+	})
+	for i, nodeLink := range p.nodeLinks {
+		fromCycle, fromOk := typeIds[nodeLink.fromStructTypeId]
+		toCycle, toOk := typeIds[nodeLink.toId()]
+		if fromOk && toOk && fromCycle == toCycle {
+			p.nodeLinks[i].color = cycleColor
+		}
+	}
+	for pkgPath := range pkgPaths {
+		subPkg := p
+		for _, name := range strings.Split(pkgPath, "/") {
+			if subPkg = subPkg.subPkgs[name]; subPkg == nil {
+				break
+			}
+		}
+		if subPkg != nil {
+			subPkg.clusterColor = cycleColor
+		}
+	}
+}
+
+// findCycles returns the graph's cycles, along with the ids of the types
+// that are in cycles (mapped to the index of their cycle of types) and the
+// paths of the packages that are in cycles.
+func (p *pkg) findCycles() ([]Cycle, map[string]int, map[string]bool) {
+	names := map[string]string{}    // type id -> qualified name
+	pkgPaths := map[string]string{} // type id -> package path
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		names[node.typeId] = node.typeObj.Name()
+		if pkgPath != "" {
+			names[node.typeId] = pkgPath + "." + node.typeObj.Name()
+		}
+		pkgPaths[node.typeId] = pkgPath
+	})
+
+	typeRefs := map[string][]string{}
+	pkgRefs := map[string][]string{}
+	var fieldRefs []graphNodeLink
+	var crossPkgRefs [][2]string
+	for _, nodeLink := range p.nodeLinks {
+		from, to := nodeLink.fromStructTypeId, nodeLink.toId()
+		if _, ok := names[to]; !ok || from == to {
+			continue
+		}
+		typeRefs[from] = append(typeRefs[from], to)
+		fieldRefs = append(fieldRefs, nodeLink)
+		if pkgPaths[from] != pkgPaths[to] {
+			pkgRefs[pkgPaths[from]] = append(pkgRefs[pkgPaths[from]], pkgPaths[to])
+			crossPkgRefs = append(crossPkgRefs, [2]string{from, to})
+		}
+	}
+
+	var cycles []Cycle
+	typeIdsInCycles := map[string]int{}
+	for i, component := range stronglyConnectedComponents(typeRefs) {
+		cycle := Cycle{}
+		for _, typeId := range component {
+			typeIdsInCycles[typeId] = i
+			cycle.Types = append(cycle.Types, names[typeId])
+		}
+		sort.Strings(cycle.Types)
+		cycle.Cuts = cycleCuts(component, fieldRefs, names)
+		cycles = append(cycles, cycle)
+	}
+
+	pkgPathsInCycles := map[string]bool{}
+	for _, component := range stronglyConnectedComponents(pkgRefs) {
+		cycle := Cycle{Packages: component}
+		inCycle := map[string]bool{}
+		for _, pkgPath := range component {
+			pkgPathsInCycles[pkgPath] = true
+			inCycle[pkgPath] = true
+		}
+		sort.Strings(cycle.Packages)
+		// The types that refer from one of the packages to another.
+		crossing := map[string]bool{}
+		for _, ref := range crossPkgRefs {
+			if inCycle[pkgPaths[ref[0]]] && inCycle[pkgPaths[ref[1]]] {
+				crossing[names[ref[0]]] = true
+				crossing[names[ref[1]]] = true
+			}
+		}
+		for name := range crossing {
+			cycle.Types = append(cycle.Types, name)
+		}
+		sort.Strings(cycle.Types)
+		cycles = append(cycles, cycle)
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		if len(cycles[i].Packages) != len(cycles[j].Packages) {
+			return len(cycles[i].Packages) > len(cycles[j].Packages)
+		}
+		return strings.Join(cycles[i].Types, ",") < strings.Join(cycles[j].Types, ",")
+	})
+	return cycles, typeIdsInCycles, pkgPathsInCycles
+}
+
+// cycleCuts returns the references between the types of a cycle that would
+// break it up the most if they were removed, i.e. that leave the smallest
+// cycle of its types behind.
+func cycleCuts(component []string, refs []graphNodeLink, names map[string]string) []CycleCut {
+	inComponent := map[string]bool{}
+	for _, typeId := range component {
+		inComponent[typeId] = true
+	}
+	var componentRefs []graphNodeLink
+	for _, ref := range refs {
+		if inComponent[ref.fromStructTypeId] && inComponent[ref.toId()] {
+			componentRefs = append(componentRefs, ref)
+		}
+	}
+
+	var cuts []CycleCut
+	for i, cut := range componentRefs {
+		edges := map[string][]string{}
+		for j, ref := range componentRefs {
+			if j != i {
+				edges[ref.fromStructTypeId] = append(edges[ref.fromStructTypeId], ref.toId())
+			}
+		}
+		remaining := 0
+		for _, smaller := range stronglyConnectedComponents(edges) {
+			if len(smaller) > remaining {
+				remaining = len(smaller)
+			}
+		}
+		if remaining < len(component) {
+			cuts = append(cuts, CycleCut{
+				From:      names[cut.fromStructTypeId],
+				Field:     cut.fromStructFieldName,
+				To:        names[cut.toId()],
+				Remaining: remaining,
+			})
+		}
+	}
+
+	sort.Slice(cuts, func(i, j int) bool {
+		if cuts[i].Remaining != cuts[j].Remaining {
+			return cuts[i].Remaining < cuts[j].Remaining
+		}
+		if cuts[i].From != cuts[j].From {
+			return cuts[i].From < cuts[j].From
+		}
+		return cuts[i].Field < cuts[j].Field
+	})
+	if len(cuts) > maxCycleCuts {
+		cuts = cuts[:maxCycleCuts]
+	}
+	return cuts
+}
+
+// stronglyConnectedComponents returns the sets of vertices of the graph
+// that can all reach each other, with more than one vertex, using Tarjan's
+// algorithm.
+func stronglyConnectedComponents(edges map[string][]string) [][]string {
+	var vertices []string
+	for vertex := range edges {
+		vertices = append(vertices, vertex)
+	}
+	sort.Strings(vertices)
+
+	index := map[string]int{}
+	lowLink := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var components [][]string
+
+	var visit func(v string)
+	visit = func(v string) {
+		index[v] = len(index)
+		lowLink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range edges[v] {
+			if _, ok := index[w]; !ok {
+				visit(w)
+				if lowLink[w] < lowLink[v] {
+					lowLink[v] = lowLink[w]
+				}
+			} else if onStack[w] && index[w] < lowLink[v] {
+				lowLink[v] = index[w]
+			}
+		}
+
+		if lowLink[v] == index[v] {
+			var component []string
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component = append(component, w)
+				if w == v {
+					break
+				}
+			}
+			if len(component) > 1 {
+				components = append(components, component)
+			}
+		}
+	}
+	for _, v := range vertices {
+		if _, ok := index[v]; !ok {
+			visit(v)
+		}
+	}
+	return components
+}
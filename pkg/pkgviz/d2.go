@@ -0,0 +1,145 @@
+package pkgviz
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WriteD2 writes the graph in the D2 diagram language, for the d2 toolchain
+// to lay out and render. The graphed package and each of its subpackages are
+// containers, nested like the packages are, structs and interfaces are
+// classes with their fields or methods, other types are shapes labeled with
+// their underlying types, and each reference from a struct's field to
+// another type is an arrow labeled with the field. Types outside of the
+// graphed packages are drawn dashed, outside of the containers.
+func (p *pkg) WriteD2(w io.Writer) error {
+	model := p.Model()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# The types of %s\n", model.Package)
+
+	root := &d2Container{name: model.Package, children: map[string]*d2Container{}}
+	var outside []Node
+	keys := map[string]string{}
+	for _, node := range model.Nodes {
+		path, ok := d2Path(model.Package, node.Package)
+		if !ok {
+			outside = append(outside, node)
+			keys[node.ID] = d2Key(node.ID)
+			continue
+		}
+		container := root
+		for _, name := range path {
+			if container.children[name] == nil {
+				container.children[name] = &d2Container{name: name, children: map[string]*d2Container{}}
+			}
+			container = container.children[name]
+		}
+		container.nodes = append(container.nodes, node)
+		keys[node.ID] = d2Key(append(append([]string{model.Package}, path...), node.Name)...)
+	}
+
+	root.write(bw, 0)
+	for _, node := range outside {
+		fmt.Fprintf(bw, "%s: {\n  style.stroke-dash: 3\n}\n", d2Key(node.ID))
+	}
+	for _, edge := range model.Edges {
+		fmt.Fprintf(bw, "%s -> %s: %s\n", keys[edge.From], keys[edge.To], strconv.Quote(edge.Field))
+	}
+	return bw.Flush()
+}
+
+// A d2Container is a package's container, with its types and subpackages'.
+type d2Container struct {
+	name     string
+	nodes    []Node
+	children map[string]*d2Container
+}
+
+func (c *d2Container) write(w io.Writer, depth int) {
+	indent := strings.Repeat("  ", depth)
+	fmt.Fprintf(w, "%s%s: {\n", indent, strconv.Quote(c.name))
+	for _, node := range c.nodes {
+		writeD2Node(w, node, indent+"  ")
+	}
+	var names []string
+	for name := range c.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.children[name].write(w, depth+1)
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+}
+
+func writeD2Node(w io.Writer, node Node, indent string) {
+	fmt.Fprintf(w, "%s%s: {\n", indent, strconv.Quote(node.Name))
+	switch {
+	case node.Kind == "struct":
+		fmt.Fprintf(w, "%s  shape: class\n", indent)
+		for _, field := range node.Fields {
+			fmt.Fprintf(w, "%s  %s: %s\n", indent, strconv.Quote(field.Name), strconv.Quote(field.Type))
+		}
+	case node.Kind == "interface":
+		fmt.Fprintf(w, "%s  shape: class\n", indent)
+		for _, method := range node.Methods {
+			params, results := splitSignature(method.Type)
+			fmt.Fprintf(w, "%s  %s: %s\n", indent, strconv.Quote(method.Name+params), strconv.Quote(results))
+		}
+	case len(node.Constants) > 0:
+		fmt.Fprintf(w, "%s  shape: class\n", indent)
+		for _, c := range node.Constants {
+			fmt.Fprintf(w, "%s  %s: %s\n", indent, strconv.Quote(c.Name), strconv.Quote("= "+c.Value))
+		}
+	case node.Underlying != "":
+		fmt.Fprintf(w, "%s  label: %s\n", indent, strconv.Quote(node.Name+" "+node.Underlying))
+	}
+	fmt.Fprintf(w, "%s}\n", indent)
+}
+
+// d2Path returns the containers that a package's types are in, below the
+// graphed package's, e.g. ["store", "sql"] for its store/sql subpackage, or
+// false if it's outside of the graphed package.
+func d2Path(rootPkgName, pkgName string) ([]string, bool) {
+	if pkgName == rootPkgName {
+		return nil, true
+	}
+	if !strings.HasPrefix(pkgName, rootPkgName+"/") {
+		return nil, false
+	}
+	return strings.Split(strings.TrimPrefix(pkgName, rootPkgName+"/"), "/"), true
+}
+
+// d2Key returns the key of a shape nested in containers, which are quoted,
+// since import paths have dots in them, which D2 nests keys by.
+func d2Key(names ...string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	return strings.Join(quoted, ".")
+}
+
+// splitSignature splits a method's signature into its parameters and its
+// results, e.g. "(key string)" and "(string, error)" for
+// "func(key string) (string, error)".
+func splitSignature(signature string) (params, results string) {
+	signature = strings.TrimPrefix(signature, "func")
+	depth := 0
+	for i, r := range signature {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return signature[:i+1], strings.TrimSpace(signature[i+1:])
+			}
+		}
+	}
+	return signature, ""
+}
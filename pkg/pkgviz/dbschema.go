@@ -0,0 +1,252 @@
+package pkgviz
+
+import (
+	"go/types"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// A struct that's stored in a database table, by an ORM like gorm or sqlx.
+type dbModel struct {
+	node    *graphNode
+	s       *types.Struct
+	gorm    bool                // whether it's tagged for gorm, whose conventions then apply
+	table   string              // e.g. "users"
+	columns map[string]string   // field name -> column name
+	keys    map[string][]string // field name -> e.g. "PK" or "FK → orders"
+}
+
+// The graph's database models.
+type dbModels struct {
+	list []*dbModel
+	// The models by their type, and by their import path and name, for the
+	// references from other packages, which are checked separately.
+	byObj  map[types.Object]*dbModel
+	byPath map[string]*dbModel
+}
+
+// dbColumnTags are the struct tags that name a field's column.
+var dbColumnTags = []string{"db", "gorm"}
+
+// isGormModel returns whether the type is gorm's Model, which gives the
+// structs that embed it an id, created_at, updated_at and deleted_at.
+func isGormModel(t types.Type) bool {
+	s := t.String()
+	return s == "gorm.io/gorm.Model" || s == "github.com/jinzhu/gorm.Model"
+}
+
+// gormSettings returns the settings in a gorm tag, e.g.
+// `gorm:"column:user_id;primaryKey"`, by their lowercased name.
+func gormSettings(tag string) map[string]string {
+	settings := map[string]string{}
+	for _, setting := range strings.Split(reflect.StructTag(tag).Get("gorm"), ";") {
+		if setting = strings.TrimSpace(setting); setting == "" {
+			continue
+		}
+		kv := strings.SplitN(setting, ":", 2)
+		key := strings.Replace(strings.ToLower(kv[0]), "_", "", -1)
+		settings[key] = ""
+		if len(kv) == 2 {
+			settings[key] = kv[1]
+		}
+	}
+	return settings
+}
+
+// findDBModels returns the structs in the graph that have fields tagged with
+// their column (by gorm, or by sqlx's db tag), or that embed gorm's Model.
+func findDBModels(p *pkg) dbModels {
+	models := dbModels{byObj: map[types.Object]*dbModel{}, byPath: map[string]*dbModel{}}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil || node.typeType != "struct" {
+			return
+		}
+		s, ok := node.typeObj.Type().Underlying().(*types.Struct)
+		if !ok {
+			return
+		}
+		model := &dbModel{node: node, s: s, columns: map[string]string{}, keys: map[string][]string{}}
+		tagged := false
+		for i := 0; i < s.NumFields(); i++ {
+			tag := reflect.StructTag(s.Tag(i))
+			if _, ok := tag.Lookup("gorm"); ok || (s.Field(i).Anonymous() && isGormModel(s.Field(i).Type())) {
+				model.gorm = true
+			}
+			for _, key := range dbColumnTags {
+				if _, ok := tag.Lookup(key); ok {
+					tagged = true
+				}
+			}
+		}
+		if !tagged && !model.gorm {
+			return
+		}
+		model.table = snakeCase(node.typeObj.Name())
+		if model.gorm {
+			model.table = pluralize(model.table)
+		}
+		models.list = append(models.list, model)
+		models.byObj[node.typeObj] = model
+		models.byPath[p.pkgImportPath(pkgPath)+"."+node.typeObj.Name()] = model
+	})
+	return models
+}
+
+// relationTarget returns the model that a field refers to, directly or in
+// a slice, and whether it's through a slice.
+func (models dbModels) relationTarget(f *types.Var) (*dbModel, bool) {
+	t, many := f.Type(), false
+	if slice, ok := t.Underlying().(*types.Slice); ok {
+		t, many = slice.Elem(), true
+	}
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return nil, false
+	}
+	if model, ok := models.byObj[named.Obj()]; ok {
+		return model, many
+	}
+	if named.Obj().Pkg() != nil {
+		if model, ok := models.byPath[named.Obj().Pkg().Path()+"."+named.Obj().Name()]; ok {
+			return model, many
+		}
+	}
+	return nil, false
+}
+
+// drawDBSchema draws the structs that are database models as the tables
+// they're stored in: with a row for each column, in declaration order, named
+// as the column and marked as a primary or foreign key, and with the fields
+// that refer to other models drawn only as the relations between them.
+func drawDBSchema(p *pkg) {
+	models := findDBModels(p)
+
+	for _, model := range models.list {
+		for i := 0; i < model.s.NumFields(); i++ {
+			f, tag := model.s.Field(i), model.s.Tag(i)
+			if !f.Exported() || reflect.StructTag(tag).Get("db") == "-" || reflect.StructTag(tag).Get("gorm") == "-" {
+				continue
+			}
+			if target, _ := models.relationTarget(f); target != nil {
+				continue
+			}
+			settings := gormSettings(tag)
+			column := snakeCase(f.Name())
+			if name := strings.Split(reflect.StructTag(tag).Get("db"), ",")[0]; name != "" {
+				column = name
+			}
+			if name, ok := settings["column"]; ok && name != "" {
+				column = name
+			}
+			if f.Anonymous() && isGormModel(f.Type()) {
+				column = "id, created_at, updated_at, deleted_at"
+				model.keys[f.Name()] = append(model.keys[f.Name()], "PK id")
+			} else if _, ok := settings["primarykey"]; ok || column == "id" {
+				model.keys[f.Name()] = append(model.keys[f.Name()], "PK")
+			}
+			model.columns[f.Name()] = column
+		}
+	}
+
+	for _, model := range models.list {
+		for i := 0; i < model.s.NumFields(); i++ {
+			f := model.s.Field(i)
+			target, many := models.relationTarget(f)
+			if target == nil {
+				continue
+			}
+			foreignKey := gormSettings(model.s.Tag(i))["foreignkey"]
+
+			// The foreign key of a belongs-to relation is in the model
+			// itself, and of a has-one or has-many relation, in the target.
+			relation, keyModel, keyTable, defaultKey := "has one", target, model.table, model.node.typeObj.Name()+"ID"
+			if many {
+				relation = "has many"
+			} else if _, ok := model.columns[orDefault(foreignKey, f.Name()+"ID")]; ok {
+				relation, keyModel, keyTable, defaultKey = "belongs to", model, target.table, f.Name()+"ID"
+			}
+			foreignKey = orDefault(foreignKey, defaultKey)
+			if _, ok := keyModel.columns[foreignKey]; ok {
+				keyModel.keys[foreignKey] = append(keyModel.keys[foreignKey], "FK → "+keyTable)
+			}
+
+			for j, nodeLink := range p.nodeLinks {
+				if nodeLink.fromStructTypeId == model.node.typeId && nodeLink.fromStructFieldName == f.Name() {
+					p.nodeLinks[j].label = relation
+					if many {
+						p.nodeLinks[j].arrowhead = "crow"
+					}
+				}
+			}
+		}
+	}
+
+	for _, model := range models.list {
+		node := model.node
+		if node.annotations == nil {
+			node.annotations = map[string]string{}
+		}
+		node.annotations["table"] = "table " + model.table
+		node.fieldOrder = nil
+		node.fieldLabels = map[string]string{}
+		if node.fieldNotes == nil {
+			node.fieldNotes = map[string]string{}
+		}
+		for i := 0; i < model.s.NumFields(); i++ {
+			name := model.s.Field(i).Name()
+			column, ok := model.columns[name]
+			if !ok {
+				continue
+			}
+			node.fieldOrder = append(node.fieldOrder, name)
+			node.fieldLabels[name] = column
+			if keys := strings.Join(model.keys[name], ", "); keys != "" && node.fieldNotes[name] != "" {
+				node.fieldNotes[name] += ", " + keys
+			} else if keys != "" {
+				node.fieldNotes[name] = keys
+			}
+		}
+	}
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// snakeCase returns the name in snake case, as gorm names columns and tables,
+// e.g. "UserID" -> "user_id".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// pluralize returns the plural of an English noun, as gorm pluralizes table
+// names, for the regular cases.
+func pluralize(noun string) string {
+	switch {
+	case strings.HasSuffix(noun, "y") && !strings.HasSuffix(noun, "ay") && !strings.HasSuffix(noun, "ey") && !strings.HasSuffix(noun, "oy"):
+		return strings.TrimSuffix(noun, "y") + "ies"
+	case strings.HasSuffix(noun, "s"), strings.HasSuffix(noun, "x"), strings.HasSuffix(noun, "z"),
+		strings.HasSuffix(noun, "ch"), strings.HasSuffix(noun, "sh"):
+		return noun + "es"
+	}
+	return noun + "s"
+}
@@ -0,0 +1,273 @@
+package pkgviz
+
+import (
+	"fmt"
+	"sort"
+)
+
+// reduceDetail leaves detail out of the graph in stages, from the least
+// to the most, until it has at most maxNodes nodes and maxEdges arrows (if
+// they're more than 0), since graphviz can take a very long time to lay out
+// huge graphs. What was left out is noted in the graph's label, and can be
+// listed with Elided.
+func reduceDetail(p *pkg, maxNodes, maxEdges int) {
+	fits := func() bool {
+		nodes, edges := countElements(p)
+		return (maxNodes <= 0 || nodes <= maxNodes) && (maxEdges <= 0 || edges <= maxEdges)
+	}
+	if fits() {
+		return
+	}
+	p.oversize = sizeReport(p, maxNodes, maxEdges)
+	for _, stage := range []func(*pkg) string{hideFields, dropBasicTypes, collapseSubPkgs} {
+		if fits() {
+			break
+		}
+		if elided := stage(p); elided != "" {
+			p.elided = append(p.elided, elided)
+		}
+	}
+	p.oversize.Fits = fits()
+}
+
+// Elided returns what was left out of the graph to keep it under the
+// Options' MaxNodes and MaxEdges, if anything, e.g. "dropped 40 basic
+// types", in the order it was left out.
+func (p *pkg) Elided() []string {
+	return p.elided
+}
+
+// The most packages and hub types that a SizeReport lists.
+const sizeContributors = 5
+
+// A SizeReport describes a graph that had more nodes or arrows than the
+// Options' MaxNodes or MaxEdges, and what made it so big, so that it can be
+// narrowed down to one that graphviz can lay out and that can be read.
+type SizeReport struct {
+	Nodes    int  `json:"nodes"` // how many nodes the graph had before its detail was reduced
+	Edges    int  `json:"edges"` // how many arrows it had
+	MaxNodes int  `json:"maxNodes"`
+	MaxEdges int  `json:"maxEdges"`
+	Fits     bool `json:"fits"` // whether it fit once its detail was reduced
+
+	// Packages are the packages with the most types, by import path, and
+	// Hubs the types with the most arrows to and from them, named relative
+	// to the graphed package like the Options' Focus, e.g. "store.Item",
+	// most first.
+	Packages []SizeContributor `json:"packages"`
+	Hubs     []SizeContributor `json:"hubs"`
+
+	// Generated is how many of the types were generated (see
+	// GeneratedTypes).
+	Generated int `json:"generated"`
+}
+
+// A SizeContributor is a package or type that makes a graph bigger: by how
+// many types the package has, or how many arrows the type has.
+type SizeContributor struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func (c SizeContributor) String() string {
+	return fmt.Sprintf("%s (%d)", c.Name, c.Count)
+}
+
+// Oversize returns what made the graph bigger than the Options' MaxNodes or
+// MaxEdges, or nil if it wasn't.
+func (p *pkg) Oversize() *SizeReport {
+	return p.oversize
+}
+
+// sizeReport returns the size of the graph, and its biggest packages and
+// types.
+func sizeReport(p *pkg, maxNodes, maxEdges int) *SizeReport {
+	report := &SizeReport{MaxNodes: maxNodes, MaxEdges: maxEdges}
+	report.Nodes, report.Edges = countElements(p)
+
+	arrows := map[string]int{}
+	for _, nodeLink := range p.nodeLinks {
+		arrows[nodeLink.fromStructTypeId]++
+		arrows[nodeLink.toId()]++
+	}
+	types := map[string]int{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		types[p.pkgImportPath(pkgPath)]++
+		name := node.typeObj.Name()
+		if pkgPath != "" {
+			name = pkgPath + "." + name
+		}
+		if n := arrows[node.typeId]; n > 0 {
+			report.Hubs = append(report.Hubs, SizeContributor{name, n})
+		}
+		if node.generator != "" {
+			report.Generated++
+		}
+	})
+	for name, n := range types {
+		report.Packages = append(report.Packages, SizeContributor{name, n})
+	}
+	report.Packages = biggestContributors(report.Packages)
+	report.Hubs = biggestContributors(report.Hubs)
+	return report
+}
+
+// biggestContributors returns the sizeContributors biggest contributors,
+// biggest first, and then by name.
+func biggestContributors(contributors []SizeContributor) []SizeContributor {
+	sort.Slice(contributors, func(i, j int) bool {
+		if contributors[i].Count != contributors[j].Count {
+			return contributors[i].Count > contributors[j].Count
+		}
+		return contributors[i].Name < contributors[j].Name
+	})
+	if len(contributors) > sizeContributors {
+		contributors = contributors[:sizeContributors]
+	}
+	return contributors
+}
+
+// countElements returns how many nodes and arrows the graph is drawn with,
+// including the nodes of the types outside of it that it refers to.
+func countElements(p *pkg) (int, int) {
+	nodes := map[string]bool{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		nodes[node.typeId] = true
+	})
+	nodeLinks := p.nodeLinks
+	if p.weightLinks {
+		nodeLinks = weighLinks(nodeLinks)
+	}
+	for _, nodeLink := range nodeLinks {
+		nodes[nodeLink.toId()] = true
+	}
+	return len(nodes), len(nodeLinks)
+}
+
+// hideFields draws the structs and interfaces as just their names, with
+// one arrow from each type to each type it refers to.
+func hideFields(p *pkg) string {
+	hidden := 0
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if len(node.typeStructFields) > 0 || len(node.typeInterfaceMethods) > 0 || len(node.typeConstants) > 0 {
+			node.fieldsHidden = true
+			hidden++
+		}
+	})
+	p.weightLinks = true
+	if hidden == 0 {
+		return "merged the arrows between each pair of types"
+	}
+	return fmt.Sprintf("hid the fields and methods of %d %s, and merged the arrows between each pair of types", hidden, plural(hidden, "type", "types"))
+}
+
+// dropBasicTypes leaves out the types whose underlying types are basic
+// (e.g. `type Status int`), and the arrows to them.
+func dropBasicTypes(p *pkg) string {
+	dropped := map[string]bool{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeType == "basic" {
+			dropped[node.typeId] = true
+		}
+	})
+	if len(dropped) == 0 {
+		return ""
+	}
+	removeNodes(p, dropped)
+
+	var nodeLinks []graphNodeLink
+	for _, nodeLink := range p.nodeLinks {
+		if !dropped[nodeLink.fromStructTypeId] && !dropped[nodeLink.toId()] {
+			nodeLinks = append(nodeLinks, nodeLink)
+		}
+	}
+	p.nodeLinks = nodeLinks
+	return fmt.Sprintf("dropped %d basic %s", len(dropped), plural(len(dropped), "type", "types"))
+}
+
+// removeNodes removes the nodes with the given type ids from the package
+// and its subpackages.
+func removeNodes(p *pkg, typeIds map[string]bool) {
+	for name, node := range p.nodes {
+		if typeIds[node.typeId] {
+			delete(p.nodes, name)
+		}
+	}
+	for _, subPkg := range p.subPkgs {
+		removeNodes(subPkg, typeIds)
+	}
+}
+
+// collapseSubPkgs replaces each of the graphed package's subpackages (and
+// theirs) with one node, with one arrow to each other node that their types
+// refer to, as thick as how many fields do.
+func collapseSubPkgs(p *pkg) string {
+	root := p.subPkgs[""]
+	if root == nil {
+		root = &pkg{
+			pkgName:     p.pkgName,
+			rootPkgName: p.rootPkgName,
+			subPkgs:     map[string]*pkg{},
+			nodes:       map[string]*graphNode{},
+			nodeLinks:   []graphNodeLink{},
+		}
+	}
+
+	var subPkgNames []string
+	for subPkgName := range p.subPkgs {
+		if subPkgName != "" {
+			subPkgNames = append(subPkgNames, subPkgName)
+		}
+	}
+	if len(subPkgNames) == 0 {
+		return ""
+	}
+	sort.Strings(subPkgNames)
+
+	// The collapsed types' ids, and the nodes that stand for them.
+	collapsedInto := map[string]*graphNode{}
+	collapsedTypes := 0
+	for _, subPkgName := range subPkgNames {
+		node := &graphNode{
+			typeId:               labelizeName("", "package_"+subPkgName),
+			typeType:             "package",
+			typeName:             subPkgName,
+			typeNodes:            map[string]*graphNode{},
+			typeStructFields:     map[string]*structField{},
+			typeInterfaceMethods: map[string]string{},
+		}
+		n := 0
+		p.subPkgs[subPkgName].walkNodes(func(pkgPath string, typeNode *graphNode) {
+			collapsedInto[typeNode.typeId] = node
+			n++
+		})
+		node.annotations = map[string]string{"types": fmt.Sprintf("%d %s", n, plural(n, "type", "types"))}
+		collapsedTypes += n
+		root.nodes["package "+subPkgName] = node
+		delete(p.subPkgs, subPkgName)
+	}
+	p.subPkgs[""] = root
+
+	var nodeLinks []graphNodeLink
+	for _, nodeLink := range p.nodeLinks {
+		if from, ok := collapsedInto[nodeLink.fromStructTypeId]; ok {
+			nodeLink.fromStructTypeId = from.typeId
+			nodeLink.fromStructFieldName = ""
+		}
+		if to, ok := collapsedInto[nodeLink.toId()]; ok {
+			nodeLink.toTypePkgName = ""
+			nodeLink.toTypeName = "package_" + to.typeName
+			nodeLink.toTypeId = ""
+		}
+		if nodeLink.fromStructTypeId != nodeLink.toId() {
+			nodeLinks = append(nodeLinks, nodeLink)
+		}
+	}
+	p.nodeLinks = nodeLinks
+	p.weightLinks = true
+
+	return fmt.Sprintf("collapsed %d %s (%d %s) into one node each", len(subPkgNames), plural(len(subPkgNames), "subpackage", "subpackages"), collapsedTypes, plural(collapsedTypes, "type", "types"))
+}
@@ -0,0 +1,124 @@
+package pkgviz
+
+import "fmt"
+
+// The colors behind the names of types, from the leaf types (layer 0)
+// upwards. Types in higher layers than there are colors get the last one.
+var depthColors = []string{"#edf8e9", "#c7e9c0", "#a1d99b", "#74c476", "#41ab5d", "#238b45"}
+
+// upwardLinkColor is the color of references that don't point down to a
+// lower layer, i.e. that are part of a cycle.
+const upwardLinkColor = "#d9534f"
+
+// Depths returns each of the graph's types' layer, keyed by the type's
+// qualified name (e.g. "nested.NestedStruct"): the length of the longest
+// path of references from it down to a type that refers to no other type in
+// the graph, which is in layer 0. Types in a cycle share a layer.
+func (p *pkg) Depths() map[string]int {
+	depths, _ := p.findDepths()
+	names := map[string]string{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		names[node.typeId] = node.typeObj.Name()
+		if pkgPath != "" {
+			names[node.typeId] = pkgPath + "." + node.typeObj.Name()
+		}
+	})
+
+	byName := map[string]int{}
+	for typeId, depth := range depths {
+		byName[names[typeId]] = depth
+	}
+	return byName
+}
+
+// colorByDepth colors each type by its layer, and each reference that
+// doesn't point down to a lower layer in red.
+func colorByDepth(p *pkg) {
+	depths, refs := p.findDepths()
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		depth, ok := depths[node.typeId]
+		if !ok {
+			return
+		}
+		node.headerColor = depthColors[len(depthColors)-1]
+		if depth < len(depthColors) {
+			node.headerColor = depthColors[depth]
+		}
+		if node.annotations == nil {
+			node.annotations = map[string]string{}
+		}
+		node.annotations["depth"] = fmt.Sprintf("layer %d", depth)
+	})
+	for i, nodeLink := range p.nodeLinks {
+		if refs[i] && depths[nodeLink.fromStructTypeId] <= depths[nodeLink.toId()] {
+			p.nodeLinks[i].color = upwardLinkColor
+			p.nodeLinks[i].style = "bold"
+		}
+	}
+}
+
+// findDepths returns the layer of each of the graph's types by id, and
+// which of the graph's node links refer to another type in the graph.
+func (p *pkg) findDepths() (map[string]int, map[int]bool) {
+	inGraph := map[string]bool{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj != nil {
+			inGraph[node.typeId] = true
+		}
+	})
+
+	edges := map[string][]string{}
+	refs := map[int]bool{}
+	for i, nodeLink := range p.nodeLinks {
+		from, to := nodeLink.fromStructTypeId, nodeLink.toId()
+		if inGraph[from] && inGraph[to] && from != to {
+			edges[from] = append(edges[from], to)
+			refs[i] = true
+		}
+	}
+
+	// The types in each cycle are collapsed into one, so that the layers
+	// are well defined.
+	component := map[string]string{}
+	for typeId := range inGraph {
+		component[typeId] = typeId
+	}
+	for _, types := range stronglyConnectedComponents(edges) {
+		for _, typeId := range types {
+			component[typeId] = types[0]
+		}
+	}
+	componentEdges := map[string][]string{}
+	for from, tos := range edges {
+		for _, to := range tos {
+			if component[from] != component[to] {
+				componentEdges[component[from]] = append(componentEdges[component[from]], component[to])
+			}
+		}
+	}
+
+	componentDepths := map[string]int{}
+	var depthOf func(c string) int
+	depthOf = func(c string) int {
+		if depth, ok := componentDepths[c]; ok {
+			return depth
+		}
+		depth := 0
+		for _, to := range componentEdges[c] {
+			if d := depthOf(to) + 1; d > depth {
+				depth = d
+			}
+		}
+		componentDepths[c] = depth
+		return depth
+	}
+
+	depths := map[string]int{}
+	for typeId := range inGraph {
+		depths[typeId] = depthOf(component[typeId])
+	}
+	return depths, refs
+}
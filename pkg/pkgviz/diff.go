@@ -0,0 +1,139 @@
+package pkgviz
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A TypeChange is how a type differs between two graphs.
+type TypeChange string
+
+const (
+	TypeAdded   TypeChange = "added"
+	TypeRemoved TypeChange = "removed"
+	TypeChanged TypeChange = "changed"
+)
+
+// A TypeDiff is a type that was added, removed or changed between two graphs.
+type TypeDiff struct {
+	// Package is the type's package, relative to the graphed package (so
+	// it's empty for types in the graphed package itself).
+	Package string     `json:"package,omitempty"`
+	Name    string     `json:"name"`
+	Kind    string     `json:"kind"` // e.g. "struct" or "interface"
+	Change  TypeChange `json:"change"`
+
+	// Details lists what changed about a changed type, e.g.
+	// "added field Name string".
+	Details []string `json:"details,omitempty"`
+}
+
+// QualifiedName returns the type's name, qualified by its package if it
+// isn't in the graphed package itself.
+func (d TypeDiff) QualifiedName() string {
+	if d.Package == "" {
+		return d.Name
+	}
+	return d.Package + "." + d.Name
+}
+
+// DiffGraphs compares the types of two graphs of the same package (e.g. at
+// two versions), and returns the types that differ, sorted by package and
+// then by name.
+func DiffGraphs(base, head *pkg) []TypeDiff {
+	baseNodes := diffableNodes(base)
+	headNodes := diffableNodes(head)
+
+	var diffs []TypeDiff
+	for key, baseNode := range baseNodes {
+		headNode, ok := headNodes[key]
+		if !ok {
+			diffs = append(diffs, TypeDiff{Package: key.pkgPath, Name: key.name, Kind: baseNode.typeType, Change: TypeRemoved})
+			continue
+		}
+		if details := diffNodes(baseNode, headNode); len(details) > 0 {
+			diffs = append(diffs, TypeDiff{Package: key.pkgPath, Name: key.name, Kind: headNode.typeType, Change: TypeChanged, Details: details})
+		}
+	}
+	for key, headNode := range headNodes {
+		if _, ok := baseNodes[key]; !ok {
+			diffs = append(diffs, TypeDiff{Package: key.pkgPath, Name: key.name, Kind: headNode.typeType, Change: TypeAdded})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Package != diffs[j].Package {
+			return diffs[i].Package < diffs[j].Package
+		}
+		return diffs[i].Name < diffs[j].Name
+	})
+	return diffs
+}
+
+type diffKey struct {
+	pkgPath string
+	name    string
+}
+
+func diffableNodes(p *pkg) map[diffKey]*graphNode {
+	nodes := map[diffKey]*graphNode{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj != nil {
+			nodes[diffKey{pkgPath, node.typeObj.Name()}] = node
+		}
+	})
+	return nodes
+}
+
+// diffNodes describes how the same type differs between two graphs.
+func diffNodes(base, head *graphNode) []string {
+	if base.typeType != head.typeType {
+		return []string{fmt.Sprintf("changed from %s to %s", base.typeType, head.typeType)}
+	}
+
+	baseMembers, headMembers := nodeMembers(base), nodeMembers(head)
+	var memberNames []string
+	for name := range baseMembers {
+		memberNames = append(memberNames, name)
+	}
+	for name := range headMembers {
+		if _, ok := baseMembers[name]; !ok {
+			memberNames = append(memberNames, name)
+		}
+	}
+	sort.Strings(memberNames)
+
+	var details []string
+	for _, name := range memberNames {
+		baseType, inBase := baseMembers[name]
+		headType, inHead := headMembers[name]
+		switch {
+		case !inBase:
+			details = append(details, fmt.Sprintf("added %s %s", name, headType))
+		case !inHead:
+			details = append(details, fmt.Sprintf("removed %s %s", name, baseType))
+		case baseType != headType:
+			details = append(details, fmt.Sprintf("changed %s from %s to %s", name, baseType, headType))
+		}
+	}
+	return details
+}
+
+// nodeMembers returns the parts of a type that can change, e.g. the fields
+// of a struct, keyed by a description like "field Name".
+func nodeMembers(node *graphNode) map[string]string {
+	members := map[string]string{}
+	switch node.typeType {
+	case "struct":
+		for name, field := range node.typeStructFields {
+			members["field "+name] = field.structFieldTypeName
+		}
+	case "interface":
+		for name, methodType := range node.typeInterfaceMethods {
+			members["method "+name] = methodType
+		}
+	default:
+		members["underlying type"] = typeString(node.typeObj.Type().Underlying(), node.typeObj.Pkg())
+	}
+	return members
+}
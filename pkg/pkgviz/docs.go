@@ -0,0 +1,266 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// Which of an exported type's exported parts have doc comments.
+type typeDocs struct {
+	documented bool            // whether the type itself has one
+	fields     map[string]bool // struct field name -> whether it has one
+	methods    map[string]bool // method name -> whether it has one
+}
+
+// DocCoverage is how much of a package's API is documented: its exported
+// types, and their exported fields and methods.
+type DocCoverage struct {
+	Package    string `json:"package"` // the package's import path
+	Documented int    `json:"documented"`
+	Total      int    `json:"total"`
+	// Undocumented are the names of the types, fields and methods that have
+	// no doc comment, e.g. "Server" or "Server.Addr", sorted.
+	Undocumented []string `json:"undocumented"`
+}
+
+// Percent returns the percentage of the package's API that's documented.
+func (c DocCoverage) Percent() float64 {
+	if c.Total == 0 {
+		return 100
+	}
+	return 100 * float64(c.Documented) / float64(c.Total)
+}
+
+func (c DocCoverage) String() string {
+	return fmt.Sprintf("%s: %.1f%% (%d of %d)", c.Package, c.Percent(), c.Documented, c.Total)
+}
+
+// DocCoverage returns how much of the API of each package in the graph with
+// exported types is documented, sorted by package. A struct field counts as
+// documented if it has a comment before it or at the end of its line.
+func (p *pkg) DocCoverage() []DocCoverage {
+	var coverage []DocCoverage
+	byPkg := map[string]int{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.docs == nil {
+			return
+		}
+		importPath := p.pkgImportPath(pkgPath)
+		i, ok := byPkg[importPath]
+		if !ok {
+			i = len(coverage)
+			byPkg[importPath] = i
+			coverage = append(coverage, DocCoverage{Package: importPath})
+		}
+		c := &coverage[i]
+
+		name := node.typeObj.Name()
+		c.count(name, node.docs.documented)
+		for field, documented := range node.docs.fields {
+			c.count(name+"."+field, documented)
+		}
+		for method, documented := range node.docs.methods {
+			c.count(name+"."+method, documented)
+		}
+	})
+	for i := range coverage {
+		sort.Strings(coverage[i].Undocumented)
+	}
+	sort.Slice(coverage, func(i, j int) bool {
+		return coverage[i].Package < coverage[j].Package
+	})
+	return coverage
+}
+
+func (c *DocCoverage) count(name string, documented bool) {
+	c.Total++
+	if documented {
+		c.Documented++
+	} else {
+		c.Undocumented = append(c.Undocumented, name)
+	}
+}
+
+// addDocsToGraph records the doc comments of the package's types, and which
+// of its exported types, and their exported fields and methods, have them.
+func addDocsToGraph(files []*ast.File, info *types.Info, p *pkg) {
+	docs := map[types.Object]*typeDocs{}
+	synopses := map[types.Object]string{}
+	comments := map[types.Object]string{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				comment := typeSpec.Doc
+				if comment == nil && len(genDecl.Specs) == 1 {
+					comment = genDecl.Doc
+				}
+				if comment != nil {
+					synopses[info.Defs[typeSpec.Name]] = doc.Synopsis(comment.Text())
+					comments[info.Defs[typeSpec.Name]] = strings.TrimSpace(comment.Text())
+				}
+				if !typeSpec.Name.IsExported() {
+					continue
+				}
+				d := &typeDocs{documented: comment != nil, fields: map[string]bool{}, methods: map[string]bool{}}
+				switch t := typeSpec.Type.(type) {
+				case *ast.StructType:
+					addFieldDocs(t.Fields, d.fields)
+				case *ast.InterfaceType:
+					addFieldDocs(t.Methods, d.methods)
+				}
+				docs[info.Defs[typeSpec.Name]] = d
+			}
+		}
+	}
+
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || funcDecl.Recv == nil || !funcDecl.Name.IsExported() {
+				continue
+			}
+			fn, ok := info.Defs[funcDecl.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			recv := fn.Type().(*types.Signature).Recv().Type()
+			if ptr, ok := recv.(*types.Pointer); ok {
+				recv = ptr.Elem()
+			}
+			named, ok := recv.(*types.Named)
+			if !ok {
+				continue
+			}
+			if d, ok := docs[named.Obj()]; ok {
+				d.methods[funcDecl.Name.Name] = funcDecl.Doc != nil
+			}
+		}
+	}
+
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if d, ok := docs[node.typeObj]; ok {
+			node.docs = d
+		}
+		if synopsis, ok := synopses[node.typeObj]; ok {
+			node.synopsis = synopsis
+		}
+		node.doc = comments[node.typeObj]
+	})
+}
+
+// addFieldDocs records which of the exported, named fields (or interface
+// methods) have a comment before them or at the end of their line.
+func addFieldDocs(fields *ast.FieldList, documented map[string]bool) {
+	if fields == nil {
+		return
+	}
+	for _, field := range fields.List {
+		for _, name := range field.Names {
+			if name.IsExported() {
+				documented[name.Name] = field.Doc != nil || field.Comment != nil
+			}
+		}
+	}
+}
+
+// annotateDocs marks the exported types that have no doc comment, or that
+// have exported fields or methods without one, and the fields themselves.
+func annotateDocs(p *pkg) {
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.docs == nil {
+			return
+		}
+		var missing []string
+		if !node.docs.documented {
+			missing = append(missing, "no doc comment")
+		}
+		var fields []string
+		for field, documented := range node.docs.fields {
+			if !documented {
+				fields = append(fields, field)
+			}
+		}
+		if len(fields) > 0 {
+			missing = append(missing, fmt.Sprintf("%d undocumented %s", len(fields), plural(len(fields), "field", "fields")))
+		}
+		var methods int
+		for _, documented := range node.docs.methods {
+			if !documented {
+				methods++
+			}
+		}
+		if methods > 0 {
+			missing = append(missing, fmt.Sprintf("%d undocumented %s", methods, plural(methods, "method", "methods")))
+		}
+		if len(missing) == 0 {
+			return
+		}
+
+		if node.annotations == nil {
+			node.annotations = map[string]string{}
+		}
+		node.annotations["docs"] = "✎ " + strings.Join(missing, ", ")
+		if node.fieldNotes == nil {
+			node.fieldNotes = map[string]string{}
+		}
+		for _, field := range fields {
+			if node.fieldNotes[field] != "" {
+				node.fieldNotes[field] += ", undocumented"
+			} else {
+				node.fieldNotes[field] = "undocumented"
+			}
+		}
+	})
+}
+
+// maxSubtitleWidth is how many characters wide a type's subtitle gets before
+// it's wrapped.
+const maxSubtitleWidth = 40
+
+// addDocSummaries draws the first sentence of each type's doc comment under
+// its name.
+func addDocSummaries(p *pkg) {
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.synopsis != "" {
+			node.subtitle = node.synopsis
+		}
+	})
+}
+
+// wrapWords splits the text into lines of up to width characters, breaking
+// them between words, unless a word is longer.
+func wrapWords(text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
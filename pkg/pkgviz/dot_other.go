@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package pkgviz
+
+// dotInstallPaths returns where Homebrew, MacPorts and Linux distributions'
+// packages install `dot`, which may not be on the PATH of e.g. a GUI
+// editor's processes.
+func dotInstallPaths() []string {
+	return []string{
+		"/opt/homebrew/bin/dot",
+		"/usr/local/bin/dot",
+		"/opt/local/bin/dot",
+		"/usr/bin/dot",
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package pkgviz_test
+
+// fakeDot stands in for graphviz's dot command, writing out its arguments
+// and then the graph that it's given.
+const (
+	fakeDotName = "dot"
+	fakeDot     = "#!/bin/sh\necho \"$@\"\ncat\n"
+)
@@ -0,0 +1,30 @@
+//go:build windows
+// +build windows
+
+package pkgviz
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// dotInstallPaths returns where graphviz's installer, winget, Chocolatey and
+// Scoop install `dot`.
+func dotInstallPaths() []string {
+	var paths []string
+	for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)", "ProgramW6432"} {
+		if dir := os.Getenv(env); dir != "" {
+			paths = append(paths, filepath.Join(dir, "Graphviz", "bin", "dot.exe"))
+		}
+	}
+	if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "Programs", "Graphviz", "bin", "dot.exe"))
+	}
+	if dir := os.Getenv("ChocolateyInstall"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "bin", "dot.exe"))
+	}
+	if dir := os.Getenv("USERPROFILE"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "scoop", "shims", "dot.exe"))
+	}
+	return paths
+}
@@ -0,0 +1,82 @@
+package pkgviz
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Graphs are written with three kinds of text, each with its own characters
+// to escape: IDs, which name nodes and clusters and may only have letters,
+// digits and underscores; quoted strings, e.g. plain labels; and HTML-like
+// labels, which draw types as tables. Type strings, e.g.
+// `map[string]func(interface{}) error` or `List[T]`, can end up in any of
+// them, so they're always written through these functions.
+
+// idReplacer spells out the characters of type strings that can't be in an
+// ID. Pointers are dropped, since a type's node stands for its pointers too.
+var idReplacer = strings.NewReplacer(
+	"*", "",
+	" ", "",
+	"/", "_SLASH_",
+	"[]", "_ARY_",
+	"{}", "_BRACES_",
+	",", "_COMMA_",
+	"(", "_LPARENS_",
+	")", "_RPARENS_",
+	"[", "_LBRACKET_",
+	"]", "_RBRACKET_",
+	"{", "_LBRACE_",
+	"}", "_RBRACE_",
+	";", "_SEMI_",
+	"-", "_DASH_",
+	"~", "_TILDE_",
+	"|", "_PIPE_",
+	`"`, "_QUOTE_",
+	"`", "_QUOTE_",
+)
+
+// escapeName makes a type string safe to use in an ID, e.g. "[]Foo" =>
+// "_ARY_Foo", except for its dots, which labelizeName needs to tell a type's
+// package apart from its name. Any other character that can't be in an ID
+// is spelled out by its code point.
+func escapeName(name string) string {
+	var b strings.Builder
+	for _, r := range idReplacer.Replace(name) {
+		if r == '.' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			fmt.Fprintf(&b, "_U%04X_", r)
+		}
+	}
+	return b.String()
+}
+
+// htmlReplacer escapes the characters that HTML-like labels would take as
+// markup, including ampersands, which graphviz reads as the start of an
+// entity. Quotes only need escaping in attributes, and labels' text is
+// never written into one.
+var htmlReplacer = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+)
+
+// escapeHtml escapes text for an HTML-like label, e.g. "chan<- T" =>
+// "chan&lt;- T".
+func escapeHtml(s string) string {
+	return htmlReplacer.Replace(s)
+}
+
+// quoteReplacer escapes the characters that end a quoted string, or that
+// graphviz would take as an escape sequence (e.g. "\l" to left-justify).
+var quoteReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+)
+
+// quoteString quotes text as a dot string, e.g. for a plain label.
+func quoteString(s string) string {
+	return `"` + quoteReplacer.Replace(s) + `"`
+}
@@ -0,0 +1,46 @@
+package pkgviz
+
+import (
+	"go/types"
+	"regexp"
+)
+
+// excludeTypes leaves out the graph's types that re excludes, and the
+// references from and to them, including to the types outside of the graph
+// that it excludes, which would otherwise be drawn as placeholders.
+func excludeTypes(p *pkg, re *regexp.Regexp) {
+	excluded := map[string]bool{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj != nil && excludes(re, node.typeObj) {
+			excluded[node.typeId] = true
+		}
+	})
+	removeNodes(p, excluded)
+
+	var nodeLinks []graphNodeLink
+	for _, nodeLink := range p.nodeLinks {
+		if excluded[nodeLink.fromStructTypeId] || excluded[nodeLink.toId()] {
+			continue
+		}
+		if nodeLink.toTypeObj != nil && excludes(re, nodeLink.toTypeObj) {
+			continue
+		}
+		nodeLinks = append(nodeLinks, nodeLink)
+	}
+	p.nodeLinks = nodeLinks
+}
+
+// excludes returns whether re matches a type's package's import path, or
+// its qualified name.
+func excludes(re *regexp.Regexp, obj types.Object) bool {
+	return obj.Pkg() != nil && re.MatchString(obj.Pkg().Path()) || re.MatchString(qualifiedName(obj))
+}
+
+// qualifiedName returns a type's name qualified by its package's import
+// path, e.g. "example.com/foo/mocks.Store".
+func qualifiedName(obj types.Object) string {
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
@@ -0,0 +1,185 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strings"
+)
+
+// BuildGraphFromFiles builds a graph of the types declared in the given
+// in-memory source files, which map a slash-separated file path to its
+// contents. Files in a subdirectory are graphed as a subpackage of pkgName.
+//
+// Unlike BuildGraph, it never shells out to the go tool, so it also works
+// where there is no toolchain (e.g. compiled to WebAssembly). Imports of
+// packages outside of the given files are left unresolved, and the types
+// that depend on them are graphed as invalid.
+func BuildGraphFromFiles(pkgName string, files map[string]string) (*pkg, error) {
+	return BuildGraphFromFilesWithOptions(pkgName, files, Options{})
+}
+
+// BuildGraphFromFilesWithOptions is like BuildGraphFromFiles, but builds the
+// graph with the given options. Only the options that don't need the go tool
+// or git (like Focus, HighlightCycles and SizeBy) apply.
+func BuildGraphFromFilesWithOptions(pkgName string, files map[string]string, opts Options) (*pkg, error) {
+	root := graphNode{
+		pkgName:              pkgName,
+		typeId:               "root",
+		typeType:             "root",
+		typeName:             pkgName,
+		typeNodes:            map[string]*graphNode{},
+		typeStructFields:     map[string]*structField{},
+		typeInterfaceMethods: map[string]string{},
+	}
+
+	pkgGraph := pkg{
+		pkgName:     pkgName,
+		rootPkgName: pkgName,
+		subPkgs:     map[string]*pkg{},
+		nodeLinks:   []graphNodeLink{},
+	}
+
+	var filenames []string
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	imp := &filesImporter{
+		fset:     token.NewFileSet(),
+		pkgFiles: map[string][]*ast.File{},
+		pkgs:     map[string]*types.Package{},
+		checking: map[string]bool{},
+	}
+	var pkgNames []string
+	for _, filename := range filenames {
+		if !strings.HasSuffix(filename, ".go") || strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(imp.fset, filename, files[filename], parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		filePkgName := path.Join(pkgName, path.Dir(path.Clean("/"+filename)))
+		if _, ok := imp.pkgFiles[filePkgName]; !ok {
+			pkgNames = append(pkgNames, filePkgName)
+		}
+		imp.pkgFiles[filePkgName] = append(imp.pkgFiles[filePkgName], f)
+	}
+
+	for _, filesPkgName := range pkgNames {
+		info := types.Info{
+			Defs: make(map[*ast.Ident]types.Object),
+		}
+		typeErrs := imp.checkForGraph(filesPkgName, &info)
+
+		normalizedPkgName := relativePkgPath(filesPkgName, pkgName)
+		for _, err := range typeErrs {
+			addWarning(&pkgGraph, normalizedPkgName, WarningTypeError, "%v", err)
+		}
+		addTypesToGraph(&root, normalizedPkgName, imp.fset, imp.pkgFiles[filesPkgName], &info, &pkgGraph)
+	}
+	if files == nil {
+		files = map[string]string{}
+	}
+	return decorateGraph(&pkgGraph, &opts, files), nil
+}
+
+// WriteGraphFromFiles builds the graph of the given in-memory source files
+// (see BuildGraphFromFiles), and writes out the dot graph.
+func WriteGraphFromFiles(pkgName string, files map[string]string) (string, error) {
+	pkgGraph, err := BuildGraphFromFiles(pkgName, files)
+	if err != nil {
+		return "", err
+	}
+	return pkgGraph.String(), nil
+}
+
+// filesImporter type-checks imported packages from in-memory files, rather
+// than from the files on disk that the source importer would find.
+type filesImporter struct {
+	fset     *token.FileSet
+	pkgFiles map[string][]*ast.File    // import path -> parsed files
+	pkgs     map[string]*types.Package // import path -> checked package
+	checking map[string]bool           // guards against import cycles
+}
+
+func (imp *filesImporter) Import(importPath string) (*types.Package, error) {
+	if importPath == "unsafe" {
+		return types.Unsafe, nil
+	}
+	if p, ok := imp.pkgs[importPath]; ok {
+		return p, nil
+	}
+	if _, ok := imp.pkgFiles[importPath]; !ok {
+		return nil, fmt.Errorf("package %s is not in the given files", importPath)
+	}
+	if imp.checking[importPath] {
+		return nil, fmt.Errorf("import cycle through %s", importPath)
+	}
+	return imp.check(importPath, nil), nil
+}
+
+// check type-checks the files of the given package. Type errors are ignored,
+// so that whatever could be checked can still be graphed.
+func (imp *filesImporter) check(importPath string, info *types.Info) *types.Package {
+	imp.checking[importPath] = true
+	defer delete(imp.checking, importPath)
+
+	p, _ := imp.config().Check(importPath, imp.fset, imp.pkgFiles[importPath], info)
+	imp.pkgs[importPath] = p
+	return p
+}
+
+// checkForGraph type-checks the files of the given package to graph them.
+// Like BuildGraph, the package is checked without its import path, so that
+// its types' ids match the ids that references to them are given. It
+// returns the type errors, which are kept as warnings.
+func (imp *filesImporter) checkForGraph(importPath string, info *types.Info) []error {
+	imp.checking[importPath] = true
+	defer delete(imp.checking, importPath)
+
+	var errs []error
+	conf := imp.config()
+	conf.Error = func(err error) {
+		errs = append(errs, err)
+	}
+	conf.Check("", imp.fset, imp.pkgFiles[importPath], info)
+	return errs
+}
+
+func (imp *filesImporter) config() *types.Config {
+	return &types.Config{
+		Importer:                 imp,
+		DisableUnusedImportCheck: true,
+		FakeImportC:              true,
+		Error:                    func(err error) {},
+	}
+}
+
+// harnessFiles parses the given in-memory source files, including the tests,
+// to find the references to the graph's types. Files that can't be parsed
+// are skipped.
+func harnessFiles(pkgName string, files map[string]string) []harnessFile {
+	var parsed []harnessFile
+	for filename, src := range files {
+		if !strings.HasSuffix(filename, ".go") {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), filename, src, 0)
+		if err != nil {
+			continue
+		}
+		parsed = append(parsed, harnessFile{
+			importPath: path.Join(pkgName, path.Dir(path.Clean("/"+filename))),
+			test:       strings.HasSuffix(filename, "_test.go"),
+			f:          f,
+		})
+	}
+	return parsed
+}
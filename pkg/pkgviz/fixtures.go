@@ -0,0 +1,205 @@
+package pkgviz
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// fixturesPkgName is the import path that fixtures are graphed under, each
+// in a package named after it.
+const fixturesPkgName = "example.com/fixtures"
+
+// A Fixture is a small synthetic package, as in-memory files (like those
+// given to BuildGraphFromFiles), that has one kind of type or reference in
+// it, e.g. generics. Their golden files (see GoldenFiles) are checked in as
+// regression tests, so that any change to how a graph is drawn shows up.
+type Fixture struct {
+	Name  string
+	Files map[string]string
+}
+
+// fixtures are the fixtures, by name.
+var fixtures = map[string]map[string]string{
+	"basics": {
+		"basics.go": `package basics
+
+type Status int
+
+const (
+	StatusActive Status = iota
+	StatusInactive
+)
+
+type Name string
+
+type Account struct {
+	ID      int64
+	Name    Name
+	Status  Status
+	Balance float64
+	Tags    []string
+}
+`,
+	},
+	"containers": {
+		"containers.go": `package containers
+
+type Item struct{ Name string }
+
+type Items []Item
+
+type ItemsByName map[string]*Item
+
+type Handler func(item *Item) error
+
+type Box struct {
+	Items   []*Item
+	ByName  map[string]Item
+	Pending chan Item
+	Fixed   [4]Item
+	Handler Handler
+	Parent  *Box
+}
+`,
+	},
+	"generics": {
+		"generics.go": `package generics
+
+type List[T any] struct {
+	items []T
+	next  *List[T]
+}
+
+type Pair[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+type Item struct{ Name string }
+
+type Catalog struct {
+	Items List[Item]
+	Index Pair[string, *Item]
+}
+`,
+	},
+	"embedding": {
+		"embedding.go": `package embedding
+
+type Base struct{ ID int64 }
+
+type Logger interface {
+	Log(msg string)
+}
+
+type ReadLogger interface {
+	Logger
+	Read() ([]byte, error)
+}
+
+type Service struct {
+	Base
+	*Config
+	Logger
+	name string
+}
+
+type Config struct{ Debug bool }
+`,
+	},
+	"interfaces": {
+		"interfaces.go": `package interfaces
+
+type Store interface {
+	Get(key string) (interface{}, error)
+	Put(key string, value interface{}) error
+	Delete(key string)
+}
+
+type Closer interface {
+	Close() error
+}
+
+type memStore struct {
+	values map[string]interface{}
+}
+
+func (s *memStore) Get(key string) (interface{}, error) { return s.values[key], nil }
+
+func (s *memStore) Put(key string, value interface{}) error { return nil }
+
+func (s *memStore) Delete(key string) {}
+
+type Cache struct {
+	Store  Store
+	Closer Closer
+}
+`,
+	},
+	"crosspkg": {
+		"crosspkg.go": `package crosspkg
+
+import (
+	"example.com/fixtures/crosspkg/model"
+	"example.com/fixtures/crosspkg/store"
+)
+
+type Server struct {
+	Store *store.Store
+	Users []model.User
+}
+`,
+		"model/model.go": `package model
+
+type User struct {
+	ID   int64
+	Team *Team
+}
+
+type Team struct {
+	Members []User
+}
+`,
+		"store/store.go": `package store
+
+import "example.com/fixtures/crosspkg/model"
+
+type Store struct {
+	users map[int64]*model.User
+}
+`,
+	},
+}
+
+// Fixtures returns the fixtures, sorted by name.
+func Fixtures() []Fixture {
+	var all []Fixture
+	for name, files := range fixtures {
+		all = append(all, Fixture{Name: name, Files: files})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+// Build builds the graph of the fixture, with its types spelled the same
+// whichever Go release builds it (see the Options' NormalizeTypes).
+func (f Fixture) Build() (*pkg, error) {
+	return BuildGraphFromFilesWithOptions(fixturesPkgName+"/"+f.Name, f.Files, Options{NormalizeTypes: true})
+}
+
+// GoldenFiles returns the contents of a graph's golden files: its dot graph,
+// and its records as indented JSON. Both come out the same every time the
+// same graph is built.
+func (p *pkg) GoldenFiles() (dot, records []byte, err error) {
+	records, err = json.MarshalIndent(p.Records(), "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	return []byte(p.String()), append(records, '\n'), nil
+}
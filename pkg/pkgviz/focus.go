@@ -0,0 +1,107 @@
+package pkgviz
+
+import "strings"
+
+// focusBorderColor is the color of the border of the types focused on, so
+// that they stand out from their neighborhood.
+const focusBorderColor = "#f0ad4e"
+
+// focusPkg returns a copy of the graph with only the named types, and the
+// types within hops node links of them. Types can be named relative to the
+// graphed package (e.g. "store.Item") or by their package's import path
+// (e.g. "example.com/foo/store.Item").
+func focusPkg(p *pkg, typeNames []string, hops int) *pkg {
+	// Node links can be followed in either direction.
+	neighbors := map[string][]string{}
+	for _, nodeLink := range p.nodeLinks {
+		toTypeId := nodeLink.toId()
+		neighbors[nodeLink.fromStructTypeId] = append(neighbors[nodeLink.fromStructTypeId], toTypeId)
+		neighbors[toTypeId] = append(neighbors[toTypeId], nodeLink.fromStructTypeId)
+	}
+
+	keep, focusedIds := map[string]bool{}, map[string]bool{}
+	var frontier []string
+	for _, typeName := range typeNames {
+		focusPkgName, focusTypeName := "", typeName
+		if i := strings.LastIndex(typeName, "."); i >= 0 {
+			focusPkgName, focusTypeName = typeName[:i], typeName[i+1:]
+		}
+		p.walkNodes(func(pkgPath string, node *graphNode) {
+			if node.typeObj == nil || node.typeObj.Name() != focusTypeName {
+				return
+			}
+			if pkgPath != focusPkgName && p.pkgImportPath(pkgPath) != focusPkgName {
+				return
+			}
+			focusedIds[node.typeId] = true
+			if !keep[node.typeId] {
+				keep[node.typeId] = true
+				frontier = append(frontier, node.typeId)
+			}
+		})
+	}
+	for hop := 0; hop < hops; hop++ {
+		var next []string
+		for _, typeId := range frontier {
+			for _, neighbor := range neighbors[typeId] {
+				if !keep[neighbor] {
+					keep[neighbor] = true
+					next = append(next, neighbor)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	focused := filterPkg(p, keep)
+	highlightFocused(focused, focusedIds)
+	focused.nodeLinks = []graphNodeLink{}
+	for _, nodeLink := range p.nodeLinks {
+		if keep[nodeLink.fromStructTypeId] && keep[nodeLink.toId()] {
+			focused.nodeLinks = append(focused.nodeLinks, nodeLink)
+		}
+	}
+	return focused
+}
+
+// highlightFocused borders the types focused on. The nodes may be shared
+// with other graphs, e.g. through the Options' Cache, so they're copied
+// rather than changed.
+func highlightFocused(p *pkg, focusedIds map[string]bool) {
+	for name, node := range p.nodes {
+		if focusedIds[node.typeId] {
+			highlighted := *node
+			highlighted.borderColor = focusBorderColor
+			p.nodes[name] = &highlighted
+		}
+	}
+	for _, subPkg := range p.subPkgs {
+		highlightFocused(subPkg, focusedIds)
+	}
+}
+
+// filterPkg returns a copy of the package with only the nodes whose type ids
+// are kept, leaving out any subpackages that end up empty.
+func filterPkg(p *pkg, keep map[string]bool) *pkg {
+	filtered := &pkg{
+		pkgName:     p.pkgName,
+		rootPkgName: p.rootPkgName,
+		subPkgs:     map[string]*pkg{},
+		nodes:       map[string]*graphNode{},
+		nodeLinks:   []graphNodeLink{},
+		collisions:  p.collisions,
+		renamedIds:  p.renamedIds,
+		warnings:    p.warnings,
+	}
+	for name, node := range p.nodes {
+		if keep[node.typeId] {
+			filtered.nodes[name] = node
+		}
+	}
+	for subPkgName, subPkg := range p.subPkgs {
+		if filteredSubPkg := filterPkg(subPkg, keep); len(filteredSubPkg.nodes) > 0 || len(filteredSubPkg.subPkgs) > 0 {
+			filtered.subPkgs[subPkgName] = filteredSubPkg
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,65 @@
+package pkgviz
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+)
+
+// GraphForFile builds a graph of the package that the given Go source file
+// belongs to. If opts.FocusFile is set, the graph only has the types that
+// are declared in the file, and those within opts.Hops references of them.
+// If opts.Dir is empty, the go tool is run in the file's directory.
+func GraphForFile(filename string, opts Options) (*pkg, error) {
+	if opts.Dir == "" {
+		opts.Dir = filepath.Dir(filename)
+	}
+	pkgName, err := PackageForFile(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var typeNames []string
+	if opts.FocusFile {
+		if typeNames, err = typesDeclaredInFile(filename); err != nil {
+			return nil, err
+		}
+	}
+
+	pkgGraph, err := GraphForPackage(pkgName, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.FocusFile {
+		pkgGraph = focusPkg(pkgGraph, typeNames, opts.Hops)
+		noteIfEmpty(pkgGraph, &opts)
+	}
+	return pkgGraph, nil
+}
+
+// WriteGraphForFile builds the graph of the package that the given Go
+// source file belongs to (see GraphForFile), and writes out the dot graph.
+func WriteGraphForFile(filename string, opts Options) (string, error) {
+	pkgGraph, err := GraphForFile(filename, opts)
+	if err != nil {
+		return "", err
+	}
+	return pkgGraph.String(), nil
+}
+
+func typesDeclaredInFile(filename string) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), filename, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var typeNames []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		if typeSpec, ok := n.(*ast.TypeSpec); ok {
+			typeNames = append(typeNames, typeSpec.Name.Name)
+		}
+		return true
+	})
+	return typeNames, nil
+}
@@ -0,0 +1,70 @@
+package pkgviz
+
+import (
+	"go/types"
+	"sort"
+)
+
+// addFuncsToGraph adds the package-level functions of the graph's packages
+// as small nodes in their package, with their signatures, linked to the
+// types in the graph that they take or return (or pointers to them, or
+// containers of them).
+func addFuncsToGraph(p *pkg) {
+	nodes := map[types.Object]*graphNode{}
+	pkgs := map[string]*types.Package{}
+	var pkgPaths []string
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil || node.typeObj.Pkg() == nil {
+			return
+		}
+		nodes[node.typeObj] = node
+		if _, ok := pkgs[pkgPath]; !ok {
+			pkgs[pkgPath] = node.typeObj.Pkg()
+			pkgPaths = append(pkgPaths, pkgPath)
+		}
+	})
+	sort.Strings(pkgPaths)
+
+	for _, pkgPath := range pkgPaths {
+		scope := pkgs[pkgPath].Scope()
+		for _, name := range scope.Names() {
+			fn, ok := scope.Lookup(name).(*types.Func)
+			if !ok {
+				continue
+			}
+			typeId := labelizeName(pkgPath, name) + "_func"
+			deepSetNodeOnSubPkg(p, &graphNode{
+				pkgName:              pkgPath,
+				typeId:               typeId,
+				typeType:             "func",
+				typeName:             objectStringIn(fn, fn.Pkg()),
+				typeNodes:            map[string]*graphNode{},
+				typeStructFields:     map[string]*structField{},
+				typeInterfaceMethods: map[string]string{},
+			}, pkgPath)
+
+			sig := fn.Type().(*types.Signature)
+			linked := map[*graphNode]bool{}
+			for _, tuple := range []*types.Tuple{sig.Params(), sig.Results()} {
+				for i := 0; i < tuple.Len(); i++ {
+					named := namedTypeOf(tuple.At(i).Type())
+					if named == nil {
+						continue
+					}
+					typeNode, ok := nodes[named.Obj()]
+					if !ok || linked[typeNode] || p.typeIdOf(typeNode.pkgName, typeNode.typeName) != typeNode.typeId {
+						continue
+					}
+					linked[typeNode] = true
+					p.nodeLinks = append(p.nodeLinks, graphNodeLink{
+						fromStructTypeId: typeId,
+						toTypePkgName:    typeNode.pkgName,
+						toTypeName:       typeNode.typeName,
+						toTypeId:         typeNode.typeId,
+						color:            "#7f8183",
+					})
+				}
+			}
+		}
+	}
+}
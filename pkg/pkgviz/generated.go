@@ -0,0 +1,205 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// GeneratedTag annotates generated types with their generator.
+	GeneratedTag = "tag"
+	// GeneratedGroup draws the generated types of each package together in
+	// a cluster, as just their names.
+	GeneratedGroup = "group"
+)
+
+// generatedHeaderColor is the color behind the names of generated types.
+const generatedHeaderColor = "#eeeeee"
+
+// generatedHeader matches the comment that marks a file as generated, by
+// the convention of https://golang.org/s/generatedcode.
+var generatedHeader = regexp.MustCompile(`^// Code generated (.*)DO NOT EDIT\.$`)
+
+// knownGenerators are the generators recognized from the headers of the
+// files they generate, by a word in the header.
+var knownGenerators = []struct {
+	word      string
+	generator string
+}{
+	{"protoc-gen-go", "protoc-gen-go"},
+	{"mockgen", "mockgen"},
+	{"stringer", "stringer"},
+	{"ent", "ent"},
+}
+
+// A GeneratedType is a type in the graph that was generated by a tool,
+// rather than written by hand.
+type GeneratedType struct {
+	Package   string `json:"package"` // the type's package's import path
+	Name      string `json:"name"`
+	Generator string `json:"generator"` // e.g. "protoc-gen-go", or "unknown"
+}
+
+func (t GeneratedType) String() string {
+	return fmt.Sprintf("%s.%s (%s)", t.Package, t.Name, t.Generator)
+}
+
+// GeneratedTypes returns the types in the graph that were generated, sorted
+// by package and name. Types are recognized as generated by the "Code
+// generated ... DO NOT EDIT." header of their file, or by the fields that
+// protoc-gen-go and mockgen give their types.
+func (p *pkg) GeneratedTypes() []GeneratedType {
+	var generated []GeneratedType
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.generator != "" {
+			generated = append(generated, GeneratedType{
+				Package:   p.pkgImportPath(pkgPath),
+				Name:      node.typeObj.Name(),
+				Generator: node.generator,
+			})
+		}
+	})
+	return generated
+}
+
+// addGeneratorsToGraph records which generator, if any, generated each of
+// the package's types.
+func addGeneratorsToGraph(files []*ast.File, info *types.Info, p *pkg) {
+	generators := map[types.Object]string{}
+	for _, f := range files {
+		generator, ok := fileGenerator(f)
+		if !ok {
+			continue
+		}
+		for _, decl := range f.Decls {
+			if genDecl, ok := decl.(*ast.GenDecl); ok {
+				for _, spec := range genDecl.Specs {
+					if typeSpec, ok := spec.(*ast.TypeSpec); ok {
+						generators[info.Defs[typeSpec.Name]] = generator
+					}
+				}
+			}
+		}
+	}
+
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if generator, ok := generators[node.typeObj]; ok {
+			node.generator = generator
+		} else if generator, ok := generatorByFields(node.typeObj); ok {
+			node.generator = generator
+		}
+	})
+}
+
+// fileGenerator returns the generator named in the file's header, or
+// "unknown" if it has a header that doesn't name a known one, or false if it
+// has none.
+func fileGenerator(f *ast.File) (string, bool) {
+	for _, group := range f.Comments {
+		if group.Pos() > f.Package {
+			break
+		}
+		for _, comment := range group.List {
+			m := generatedHeader.FindStringSubmatch(comment.Text)
+			if m == nil {
+				continue
+			}
+			words := strings.FieldsFunc(strings.ToLower(m[1]), func(r rune) bool {
+				return r == ' ' || r == ',' || r == '.' || r == '"' || r == ';' || r == '/'
+			})
+			for _, known := range knownGenerators {
+				for _, word := range words {
+					if word == known.word {
+						return known.generator, true
+					}
+				}
+			}
+			return "unknown", true
+		}
+	}
+	return "", false
+}
+
+// generatorByFields returns the generator of a struct whose file has no
+// header, by the unexported fields that protoc-gen-go and mockgen add.
+func generatorByFields(obj types.Object) (string, bool) {
+	if obj == nil {
+		return "", false
+	}
+	s, ok := obj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return "", false
+	}
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		fieldType := f.Type().String()
+		switch {
+		case strings.HasSuffix(fieldType, "protoimpl.MessageState"), f.Name() == "XXX_unrecognized":
+			return "protoc-gen-go", true
+		case strings.HasSuffix(fieldType, "gomock.Controller") && strings.HasPrefix(obj.Name(), "Mock"):
+			return "mockgen", true
+		}
+	}
+	return "", false
+}
+
+// markGenerated tags each generated type with its generator, or with
+// GeneratedGroup, collapses them into just their names.
+func markGenerated(p *pkg, mode string) {
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.generator == "" {
+			return
+		}
+		switch mode {
+		case GeneratedTag:
+			if node.annotations == nil {
+				node.annotations = map[string]string{}
+			}
+			node.annotations["generated"] = "generated by " + node.generator
+			node.headerColor = generatedHeaderColor
+		case GeneratedGroup:
+			node.collapsed = true
+		}
+	})
+}
+
+// printGeneratedCluster returns the cluster of a package's collapsed
+// generated types, labeled with their generators.
+func printGeneratedCluster(out string, indentLevel int, nodes []*graphNode, typeIdsPrinted map[string]bool) (string, map[string]bool) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].typeId < nodes[j].typeId
+	})
+	var generators []string
+	seen := map[string]bool{}
+	for _, node := range nodes {
+		if !seen[node.generator] {
+			seen[node.generator] = true
+			generators = append(generators, node.generator)
+		}
+	}
+	sort.Strings(generators)
+
+	indent := strings.Repeat("  ", indentLevel+1)
+	out = fmt.Sprintf("%s%ssubgraph cluster_generated_%s { \n", out, indent, nodes[0].typeId)
+	for _, node := range nodes {
+		out = fmt.Sprintf("%s%s  %s [shape=plaintext label=<"+
+			"<table border='1' cellborder='0' cellspacing='0' style='rounded' color='#cccccc'>"+
+			"<tr><td bgcolor='%s' align='center'>%s</td></tr>"+
+			"</table> >];\n",
+			out,
+			indent,
+			node.typeId,
+			generatedHeaderColor,
+			node.printName(),
+		)
+		typeIdsPrinted[node.typeId] = true
+	}
+	out = fmt.Sprintf("%s%s  label=%s;\n", out, indent, quoteString("generated ("+strings.Join(generators, ", ")+")"))
+	out = fmt.Sprintf("%s%s  graph[style=\"filled,rounded\" fillcolor=\"#fafafa\" color=\"#cccccc\"];\n", out, indent)
+	out = fmt.Sprintf("%s%s}\n", out, indent)
+	return out, typeIdsPrinted
+}
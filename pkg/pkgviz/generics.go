@@ -0,0 +1,92 @@
+package pkgviz
+
+import (
+	"go/types"
+	"strings"
+)
+
+// addTypeParamsToGraph records the type parameters of a generic type and
+// their constraints, to draw after its name, e.g. "Pair[K comparable, V
+// any]", and links it to the constraints that are named interfaces in the
+// graphed packages, with a dotted arrow labeled with the type parameter.
+func addTypeParamsToGraph(dg *graphNode, obj types.Object, pkgName string, p *pkg) {
+	named, ok := obj.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() == 0 {
+		return
+	}
+	typeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
+	node, ok := dg.typeNodes[typeId]
+	if !ok {
+		return
+	}
+
+	var params []string
+	tparams := named.TypeParams()
+	for i := 0; i < tparams.Len(); i++ {
+		tparam := tparams.At(i)
+		params = append(params, tparam.Obj().Name()+" "+typeStringIn(tparam.Constraint(), obj.Pkg()))
+
+		// Predeclared constraints (any and comparable), and unnamed ones
+		// (e.g. ~int | ~string), have nothing in the graph to link to.
+		constraint, ok := tparam.Constraint().(*types.Named)
+		if !ok || constraint.Obj().Pkg() == nil {
+			continue
+		}
+		toTypePkgName := pkgName
+		if path := constraint.Obj().Pkg().Path(); path != "" {
+			toTypePkgName = relativePkgPath(path, p.rootPkgName)
+			if toTypePkgName == path {
+				continue
+			}
+		}
+		p.nodeLinks = append(p.nodeLinks, graphNodeLink{
+			fromStructTypeId: typeId,
+			toTypePkgName:    toTypePkgName,
+			toTypeName:       constraint.Obj().Name(),
+			style:            "dotted",
+			label:            tparam.Obj().Name(),
+			ghost:            true,
+			fromTypeObj:      obj,
+			toTypeObj:        constraint.Obj(),
+		})
+	}
+	node.typeParams = "[" + strings.Join(params, ", ") + "]"
+}
+
+// typeTermsOf returns the types that a constraint interface's embedded
+// unions and types restrict it to, e.g. "~int | ~float64", or "" if it's an
+// ordinary interface.
+func typeTermsOf(i *types.Interface, pkg *types.Package) string {
+	var terms []string
+	for idx := 0; idx < i.NumEmbeddeds(); idx++ {
+		embedded := i.EmbeddedType(idx)
+		if _, ok := embedded.Underlying().(*types.Interface); ok {
+			continue
+		}
+		terms = append(terms, typeStringIn(embedded, pkg))
+	}
+	return strings.Join(terms, "; ")
+}
+
+// typeStringIn returns how t is spelled in pkg's source, e.g. "Item" for a
+// type of pkg's own, or "model.User" for one from another package.
+func typeStringIn(t types.Type, pkg *types.Package) string {
+	return types.TypeString(t, qualifierIn(pkg))
+}
+
+// objectStringIn returns how obj is declared in pkg's source, e.g.
+// "func NewServer(addr string) *Server".
+func objectStringIn(obj types.Object, pkg *types.Package) string {
+	return types.ObjectString(obj, qualifierIn(pkg))
+}
+
+// qualifierIn qualifies the types of packages other than pkg by their
+// packages' names, as pkg's source does.
+func qualifierIn(pkg *types.Package) types.Qualifier {
+	return func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	}
+}
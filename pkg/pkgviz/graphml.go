@@ -0,0 +1,101 @@
+package pkgviz
+
+import (
+	"encoding/xml"
+	"go/token"
+	"io"
+	"strconv"
+)
+
+// A graphML is the GraphML document that WriteGraphML writes.
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph as GraphML, to load into graph analysis
+// tools like yEd or Gephi. Each type is a node with its ID (see Records),
+// and its label (its name), kind, package and whether it's exported as
+// attributes, and each reference from a struct's field to another type is
+// a directed edge with the field, and whether it's embedded, as attributes.
+func (p *pkg) WriteGraphML(w io.Writer) error {
+	model := p.Model()
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "label", For: "node", Name: "label", Type: "string"},
+			{ID: "kind", For: "node", Name: "kind", Type: "string"},
+			{ID: "package", For: "node", Name: "package", Type: "string"},
+			{ID: "exported", For: "node", Name: "exported", Type: "boolean"},
+			{ID: "field", For: "edge", Name: "field", Type: "string"},
+			{ID: "embedded", For: "edge", Name: "embedded", Type: "boolean"},
+		},
+		Graph: graphMLGraph{ID: model.Package, EdgeDefault: "directed"},
+	}
+	for _, node := range model.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: node.ID,
+			Data: []graphMLData{
+				{Key: "label", Value: node.Name},
+				{Key: "kind", Value: node.Kind},
+				{Key: "package", Value: node.Package},
+				{Key: "exported", Value: strconv.FormatBool(token.IsExported(node.Name))},
+			},
+		})
+	}
+	for i, edge := range model.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     "e" + strconv.Itoa(i),
+			Source: edge.From,
+			Target: edge.To,
+			Data: []graphMLData{
+				{Key: "field", Value: edge.Field},
+				{Key: "embedded", Value: strconv.FormatBool(edge.Embedded)},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
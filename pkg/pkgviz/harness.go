@@ -0,0 +1,184 @@
+package pkgviz
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+const (
+	// HarnessTag marks the types declared in main packages, and the types
+	// that only tests refer to, in their own colors.
+	HarnessTag = "tag"
+	// HarnessHide leaves them out of the graph.
+	HarnessHide = "hide"
+)
+
+// The colors of the harness types' names and borders.
+const (
+	harnessHeaderColor = "#f5ecdc"
+	harnessBorderColor = "#c8a165"
+)
+
+// A Go source file that may refer to the graph's types, and the import path
+// of its package.
+type harnessFile struct {
+	importPath string
+	test       bool
+	f          *ast.File
+}
+
+// markHarnessTypes marks the graph's harness types, which are declared in
+// main packages or only referred to from tests, by mode: HarnessTag or
+// HarnessHide. The module's packages are listed to find the references to
+// them, and if they can't be, only the types in main packages are marked.
+func markHarnessTypes(p *pkg, mode string, opts *Options) *pkg {
+	modulePkgs, err := listModulePackages(p, opts)
+	if err != nil {
+		return markHarness(p, mode, p.pkgImportPath, nil)
+	}
+
+	var files []harnessFile
+	for _, mp := range modulePkgs {
+		for _, filename := range mp.files {
+			f, err := parser.ParseFile(token.NewFileSet(), filename, nil, 0)
+			if err == nil {
+				test := mp.xtest || strings.HasSuffix(filename, "_test.go")
+				files = append(files, harnessFile{importPath: mp.importPath, test: test, f: f})
+			}
+		}
+	}
+	return markHarness(p, mode, p.pkgImportPath, files)
+}
+
+// markHarness marks the types in main packages, and the types that the
+// files only refer to from tests.
+func markHarness(p *pkg, mode string, importPath func(pkgPath string) string, files []harnessFile) *pkg {
+	// The graph's types, by their package's import path and name.
+	nodes := map[string]map[string]*graphNode{}
+	pkgNames := map[string]string{}
+	harness := map[*graphNode]string{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil || node.typeObj.Pkg() == nil {
+			return
+		}
+		if node.typeObj.Pkg().Name() == "main" {
+			harness[node] = "package main"
+			return
+		}
+		if nodes[importPath(pkgPath)] == nil {
+			nodes[importPath(pkgPath)] = map[string]*graphNode{}
+		}
+		nodes[importPath(pkgPath)][node.typeObj.Name()] = node
+		pkgNames[importPath(pkgPath)] = node.typeObj.Pkg().Name()
+	})
+
+	used, testUsed := map[*graphNode]bool{}, map[*graphNode]bool{}
+	for _, file := range files {
+		uses := used
+		if file.test {
+			uses = testUsed
+		}
+		addHarnessUses(uses, file, nodes, pkgNames)
+	}
+	for _, pkgNodes := range nodes {
+		for _, node := range pkgNodes {
+			if testUsed[node] && !used[node] {
+				harness[node] = "only used by tests"
+			}
+		}
+	}
+
+	if mode == HarnessHide {
+		keep := map[string]bool{}
+		hidden := map[string]bool{}
+		p.walkNodes(func(pkgPath string, node *graphNode) {
+			if _, ok := harness[node]; ok {
+				hidden[node.typeId] = true
+			} else {
+				keep[node.typeId] = true
+			}
+		})
+		filtered := filterPkg(p, keep)
+		for _, nodeLink := range p.nodeLinks {
+			if !hidden[nodeLink.fromStructTypeId] && !hidden[nodeLink.toId()] {
+				filtered.nodeLinks = append(filtered.nodeLinks, nodeLink)
+			}
+		}
+		return filtered
+	}
+
+	for node, reason := range harness {
+		if node.annotations == nil {
+			node.annotations = map[string]string{}
+		}
+		node.annotations["harness"] = reason
+		node.headerColor = harnessHeaderColor
+		node.borderColor = harnessBorderColor
+	}
+	return p
+}
+
+// addHarnessUses adds the graph's types that the file refers to to uses:
+// its own package's by their name, and other packages' as pkgname.Type. The
+// types' own declarations, and the receivers of their methods, don't count.
+func addHarnessUses(uses map[*graphNode]bool, file harnessFile, nodes map[string]map[string]*graphNode, pkgNames map[string]string) {
+	// An external test is another package, even though it has the same
+	// import path.
+	own := nodes[file.importPath]
+	if strings.HasSuffix(file.f.Name.Name, "_test") {
+		own = nil
+	}
+
+	imported := map[string]string{} // local name -> import path
+	for _, spec := range file.f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || nodes[importPath] == nil {
+			continue
+		}
+		name := pkgNames[importPath]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imported[name] = importPath
+	}
+
+	var inspect func(n ast.Node) bool
+	inspect = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.TypeSpec:
+			ast.Inspect(n.Type, inspect)
+			return false
+		case *ast.FuncDecl:
+			ast.Inspect(n.Type, inspect)
+			if n.Body != nil {
+				ast.Inspect(n.Body, inspect)
+			}
+			return false
+		case *ast.Field:
+			ast.Inspect(n.Type, inspect)
+			return false
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok {
+				if importPath, ok := imported[x.Name]; ok {
+					if node, ok := nodes[importPath][n.Sel.Name]; ok {
+						uses[node] = true
+					}
+					return false
+				}
+			}
+			ast.Inspect(n.X, inspect)
+			return false
+		case *ast.Ident:
+			if node, ok := own[n.Name]; ok {
+				uses[node] = true
+			}
+		}
+		return true
+	}
+	for _, decl := range file.f.Decls {
+		ast.Inspect(decl, inspect)
+	}
+}
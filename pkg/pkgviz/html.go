@@ -0,0 +1,210 @@
+//go:build !js
+// +build !js
+
+package pkgviz
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+)
+
+// RenderHTML renders the dot graph that g writes as a single, self-contained
+// HTML page to explore it with: the graph is pre-rendered as SVG, which can
+// be panned by dragging it and zoomed with the mouse wheel, types can be
+// searched for by name, and clicking a type highlights the types it refers
+// to or is referred to by, and the references between them. Static images
+// of packages with a hundred types or more are too big to read.
+func RenderHTML(w io.Writer, g io.WriterTo, title string) error {
+	var svg bytes.Buffer
+	if err := RenderSVG(&svg, g); err != nil {
+		return err
+	}
+	// The SVG's XML declaration and doctype aren't allowed inside HTML.
+	doc := svg.Bytes()
+	if i := bytes.Index(doc, []byte("<svg")); i >= 0 {
+		doc = doc[i:]
+	}
+	return explorerTemplate.Execute(w, struct {
+		Title string
+		SVG   template.HTML
+	}{title, template.HTML(doc)})
+}
+
+var explorerTemplate = template.Must(template.New("explorer").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Types of {{.Title}}</title>
+<style>
+html, body { margin: 0; height: 100%; font-family: Arial, sans-serif; }
+#toolbar { position: fixed; top: 8px; left: 8px; z-index: 1; background: #ffffff; border: 1px solid #cccccc; padding: 4px 8px; }
+#graph { width: 100%; height: 100%; cursor: grab; }
+#graph svg { width: 100%; height: 100%; }
+#graph g.node { cursor: pointer; }
+#graph .dim { opacity: 0.15; }
+#graph g.node.match { filter: drop-shadow(0 0 4px #d9534f); }
+#graph g.node.selected { filter: drop-shadow(0 0 4px #4baad3); }
+</style>
+</head>
+<body>
+<div id="toolbar">
+<b>{{.Title}}</b>
+<input id="search" type="search" placeholder="Search types" autofocus>
+<span id="matches"></span>
+<button id="fit">Fit</button>
+</div>
+<div id="graph">
+{{.SVG}}
+</div>
+<script>
+(function() {
+  var svg = document.querySelector("#graph svg");
+  svg.removeAttribute("width");
+  svg.removeAttribute("height");
+  var viewBox = svg.viewBox.baseVal;
+  var fitted = [viewBox.x, viewBox.y, viewBox.width, viewBox.height];
+
+  // The nodes by their names, and the edges to and from each of them, from
+  // the titles that graphviz gives them, e.g. "server:port_Store->store".
+  var nodes = {}, edges = {};
+  svg.querySelectorAll("g.node").forEach(function(node) {
+    var name = node.querySelector("title").textContent;
+    nodes[name] = node;
+    edges[name] = [];
+  });
+  svg.querySelectorAll("g.edge").forEach(function(edge) {
+    var ends = edge.querySelector("title").textContent.split("->");
+    if (ends.length != 2) {
+      return;
+    }
+    var from = ends[0].split(":")[0], to = ends[1].split(":")[0];
+    if (edges[from]) {
+      edges[from].push({edge: edge, node: to});
+    }
+    if (edges[to]) {
+      edges[to].push({edge: edge, node: from});
+    }
+  });
+
+  function toSVG(x, y) {
+    var p = svg.createSVGPoint();
+    p.x = x;
+    p.y = y;
+    return p.matrixTransform(svg.getScreenCTM().inverse());
+  }
+
+  function center(node) {
+    var box = node.getBoundingClientRect();
+    var p = toSVG(box.left + box.width / 2, box.top + box.height / 2);
+    viewBox.x = p.x - viewBox.width / 2;
+    viewBox.y = p.y - viewBox.height / 2;
+  }
+
+  svg.addEventListener("wheel", function(e) {
+    e.preventDefault();
+    var p = toSVG(e.clientX, e.clientY);
+    var k = e.deltaY < 0 ? 0.8 : 1.25;
+    viewBox.x = p.x - (p.x - viewBox.x) * k;
+    viewBox.y = p.y - (p.y - viewBox.y) * k;
+    viewBox.width *= k;
+    viewBox.height *= k;
+  });
+
+  var dragFrom = null, dragged = false;
+  svg.addEventListener("mousedown", function(e) {
+    dragFrom = toSVG(e.clientX, e.clientY);
+    dragged = false;
+  });
+  window.addEventListener("mousemove", function(e) {
+    if (!dragFrom) {
+      return;
+    }
+    var p = toSVG(e.clientX, e.clientY);
+    viewBox.x -= p.x - dragFrom.x;
+    viewBox.y -= p.y - dragFrom.y;
+    dragged = true;
+  });
+  window.addEventListener("mouseup", function() {
+    dragFrom = null;
+  });
+
+  function clearSelection() {
+    svg.querySelectorAll(".dim, .selected").forEach(function(el) {
+      el.classList.remove("dim", "selected");
+    });
+  }
+
+  function select(name) {
+    clearSelection();
+    var neighbors = {}, neighborEdges = [];
+    neighbors[name] = true;
+    edges[name].forEach(function(e) {
+      neighbors[e.node] = true;
+      neighborEdges.push(e.edge);
+    });
+    Object.keys(nodes).forEach(function(other) {
+      if (!neighbors[other]) {
+        nodes[other].classList.add("dim");
+      }
+    });
+    svg.querySelectorAll("g.edge").forEach(function(edge) {
+      if (neighborEdges.indexOf(edge) < 0) {
+        edge.classList.add("dim");
+      }
+    });
+    nodes[name].classList.add("selected");
+  }
+
+  svg.addEventListener("click", function(e) {
+    if (dragged) {
+      return;
+    }
+    var node = e.target.closest("g.node");
+    if (node) {
+      select(node.querySelector("title").textContent);
+    } else {
+      clearSelection();
+    }
+  });
+
+  // Searching marks the types whose names (or fields) match, and Enter
+  // centers the graph on each of them in turn.
+  var search = document.getElementById("search");
+  var matches = [], current = -1;
+  search.addEventListener("input", function() {
+    var query = search.value.trim().toLowerCase();
+    matches = [];
+    current = -1;
+    Object.keys(nodes).forEach(function(name) {
+      var node = nodes[name];
+      var text = "";
+      node.querySelectorAll("text").forEach(function(t) {
+        text += t.textContent.toLowerCase() + "\n";
+      });
+      var match = query != "" && text.indexOf(query) >= 0;
+      node.classList.toggle("match", match);
+      if (match) {
+        matches.push(node);
+      }
+    });
+    document.getElementById("matches").textContent = query == "" ? "" : matches.length + " found";
+  });
+  search.addEventListener("keydown", function(e) {
+    if (e.key == "Enter" && matches.length > 0) {
+      current = (current + 1) % matches.length;
+      center(matches[current]);
+    }
+  });
+
+  document.getElementById("fit").addEventListener("click", function() {
+    viewBox.x = fitted[0];
+    viewBox.y = fitted[1];
+    viewBox.width = fitted[2];
+    viewBox.height = fitted[3];
+  });
+})();
+</script>
+</body>
+</html>
+`))
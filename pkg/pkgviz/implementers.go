@@ -0,0 +1,156 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// An Implementer is a type that implements an interface.
+type Implementer struct {
+	Package string `json:"package"` // the type's package's import path
+	Name    string `json:"name"`
+	// Pointer is whether only a pointer to the type implements the
+	// interface, since some of the methods have pointer receivers.
+	Pointer bool `json:"pointer,omitempty"`
+}
+
+func (i Implementer) String() string {
+	if i.Pointer {
+		return fmt.Sprintf("*%s.%s", i.Package, i.Name)
+	}
+	return fmt.Sprintf("%s.%s", i.Package, i.Name)
+}
+
+// FindImplementers returns the concrete types in the packages matching the
+// patterns (e.g. "./...") that implement the named interface, which is
+// qualified by its package's import path (e.g. "io.Reader" or
+// "example.com/mod/store.Store"), sorted. It also returns a graph of the
+// types, with a reference from each of them to the interface.
+func FindImplementers(ifaceName string, patterns []string, opts Options) ([]Implementer, *pkg, error) {
+	i := strings.LastIndex(ifaceName, ".")
+	if i < 0 {
+		return nil, nil, fmt.Errorf("%q isn't qualified by its package, e.g. io.Reader", ifaceName)
+	}
+	ifacePkgPath, ifaceTypeName := ifaceName[:i], ifaceName[i+1:]
+
+	pkgs, all, err := loadImplementerPackages(ifacePkgPath, patterns, &opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	ifacePkg, ok := all[ifacePkgPath]
+	if !ok {
+		return nil, nil, fmt.Errorf("cannot find package %s", ifacePkgPath)
+	}
+	ifaceObj, ok := ifacePkg.Scope().Lookup(ifaceTypeName).(*types.TypeName)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s has no type %s", ifacePkgPath, ifaceTypeName)
+	}
+	iface, ok := ifaceObj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s isn't an interface", ifaceName)
+	}
+
+	var implementers []Implementer
+	var objs []*types.TypeName
+	for _, checked := range pkgs {
+		for _, name := range checked.Scope().Names() {
+			obj, ok := checked.Scope().Lookup(name).(*types.TypeName)
+			if !ok || obj.IsAlias() || types.IsInterface(obj.Type()) {
+				continue
+			}
+			implementer := Implementer{Package: checked.Path(), Name: name}
+			if !types.Implements(obj.Type(), iface) {
+				if !types.Implements(types.NewPointer(obj.Type()), iface) {
+					continue
+				}
+				implementer.Pointer = true
+			}
+			implementers = append(implementers, implementer)
+			objs = append(objs, obj)
+		}
+	}
+
+	graph := implementersGraph(ifaceName, ifaceObj, implementers, objs)
+	sort.Slice(implementers, func(i, j int) bool {
+		return implementers[i].String() < implementers[j].String()
+	})
+	return implementers, graph, nil
+}
+
+// implementersGraph returns a graph of the interface and its implementers,
+// with the implementers in clusters by their package, relative to the
+// packages' common prefix.
+func implementersGraph(ifaceName string, ifaceObj *types.TypeName, implementers []Implementer, objs []*types.TypeName) *pkg {
+	var importPaths []string
+	for _, implementer := range implementers {
+		importPaths = append(importPaths, implementer.Package)
+	}
+	root := commonPkgPrefix(importPaths)
+
+	dg := &graphNode{
+		typeNodes:            map[string]*graphNode{},
+		typeStructFields:     map[string]*structField{},
+		typeInterfaceMethods: map[string]string{},
+	}
+	p := &pkg{
+		pkgName:     "implementers of " + ifaceName,
+		rootPkgName: root,
+		subPkgs:     map[string]*pkg{},
+		nodeLinks:   []graphNodeLink{},
+	}
+
+	addTypeToGraph(dg, ifaceObj, "", p)
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		node.typeName = ifaceName
+	})
+
+	pointers := map[types.Object]bool{}
+	for i, obj := range objs {
+		pkgPath := relativePkgPath(implementers[i].Package, root)
+		addTypeToGraph(dg, obj, pkgPath, p)
+		pointers[obj] = implementers[i].Pointer
+	}
+
+	// Only the references to the interface are drawn, not the implementers'
+	// fields'.
+	p.nodeLinks = []graphNodeLink{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		pointer, ok := pointers[node.typeObj]
+		if !ok {
+			return
+		}
+		p.nodeLinks = append(p.nodeLinks, graphNodeLink{
+			fromStructTypeId: node.typeId,
+			toTypeName:       ifaceObj.Type().String(),
+			style:            "dashed",
+			arrowhead:        "empty",
+		})
+		if pointer {
+			node.annotations = map[string]string{"implements": "through a pointer"}
+		}
+	})
+	sort.Slice(p.nodeLinks, func(i, j int) bool {
+		return p.nodeLinks[i].fromStructTypeId < p.nodeLinks[j].fromStructTypeId
+	})
+	return p
+}
+
+// commonPkgPrefix returns the longest import path that all of the import
+// paths are in.
+func commonPkgPrefix(importPaths []string) string {
+	if len(importPaths) == 0 {
+		return ""
+	}
+	prefix := strings.Split(importPaths[0], "/")
+	for _, importPath := range importPaths[1:] {
+		parts := strings.Split(importPath, "/")
+		n := 0
+		for n < len(prefix) && n < len(parts) && prefix[n] == parts[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return strings.Join(prefix, "/")
+}
@@ -0,0 +1,260 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// An ImplementsMatrix relates the graph's interfaces to its concrete types:
+// which implement which, and which nearly do.
+type ImplementsMatrix struct {
+	// Interfaces and Types are the graph's non-empty interfaces and its
+	// other types, qualified by their package if it isn't the graphed package
+	// itself (e.g. "nested.NestedStruct"), sorted.
+	Interfaces []string `json:"interfaces"`
+	Types      []string `json:"types"`
+	// Implementations are the pairs of interfaces and types where the type
+	// implements the interface, or nearly does, sorted.
+	Implementations []Implementation `json:"implementations"`
+}
+
+// An Implementation is a type that implements an interface, or that is only
+// missing one of the interface's methods.
+type Implementation struct {
+	Interface string `json:"interface"`
+	Type      string `json:"type"`
+	// Pointer is whether only a pointer to the type implements the
+	// interface, since some of the methods have pointer receivers.
+	Pointer bool `json:"pointer,omitempty"`
+	// Missing is the method that the type is missing (or has with the wrong
+	// signature), if it doesn't implement the interface.
+	Missing string `json:"missing,omitempty"`
+}
+
+// Implements returns whether the type implements the interface, and isn't
+// just a near miss.
+func (i Implementation) Implements() bool {
+	return i.Missing == ""
+}
+
+// Implementation returns the implementation of the interface by the type,
+// if it implements it or nearly does.
+func (m ImplementsMatrix) Implementation(iface, typeName string) (Implementation, bool) {
+	for _, i := range m.Implementations {
+		if i.Interface == iface && i.Type == typeName {
+			return i, true
+		}
+	}
+	return Implementation{}, false
+}
+
+// Implements computes which of the graph's types implement which of its
+// interfaces. Types that are missing one method of an interface with more
+// than one are included as near misses.
+func (p *pkg) Implements() ImplementsMatrix {
+	var ifaces, concretes []*types.TypeName
+	names := map[*types.TypeName]string{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		obj, ok := node.typeObj.(*types.TypeName)
+		if !ok {
+			return
+		}
+		names[obj] = obj.Name()
+		if pkgPath != "" {
+			names[obj] = pkgPath + "." + obj.Name()
+		}
+		if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+			if iface.NumMethods() > 0 {
+				ifaces = append(ifaces, obj)
+			}
+		} else {
+			concretes = append(concretes, obj)
+		}
+	})
+
+	matrix := ImplementsMatrix{}
+	for _, obj := range ifaces {
+		matrix.Interfaces = append(matrix.Interfaces, names[obj])
+	}
+	for _, obj := range concretes {
+		matrix.Types = append(matrix.Types, names[obj])
+	}
+	sort.Strings(matrix.Interfaces)
+	sort.Strings(matrix.Types)
+
+	for _, ifaceObj := range ifaces {
+		iface := ifaceObj.Type().Underlying().(*types.Interface)
+		for _, obj := range concretes {
+			impl := Implementation{Interface: names[ifaceObj], Type: names[obj]}
+			if types.Implements(obj.Type(), iface) {
+				matrix.Implementations = append(matrix.Implementations, impl)
+				continue
+			}
+			ptr := types.NewPointer(obj.Type())
+			if types.Implements(ptr, iface) {
+				impl.Pointer = true
+				matrix.Implementations = append(matrix.Implementations, impl)
+				continue
+			}
+			if missing := missingMethods(ptr, iface); len(missing) == 1 && iface.NumMethods() > 1 {
+				impl.Missing = missing[0]
+				matrix.Implementations = append(matrix.Implementations, impl)
+			}
+		}
+	}
+	sort.Slice(matrix.Implementations, func(i, j int) bool {
+		a, b := matrix.Implementations[i], matrix.Implementations[j]
+		if a.Interface != b.Interface {
+			return a.Interface < b.Interface
+		}
+		return a.Type < b.Type
+	})
+	return matrix
+}
+
+// drawImplements draws an arrow from each of the graph's concrete types to
+// each of its non-empty interfaces that the type, or a pointer to it,
+// implements, dashed and with an open triangle, like UML's realizations.
+// They're ghosts, since no field makes the reference.
+func drawImplements(p *pkg) {
+	var ifaces, concretes []*graphNode
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		obj, ok := node.typeObj.(*types.TypeName)
+		if !ok {
+			return
+		}
+		// Generic types only implement interfaces once they're instantiated.
+		if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+			return
+		}
+		if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+			if iface.NumMethods() > 0 {
+				ifaces = append(ifaces, node)
+			}
+		} else {
+			concretes = append(concretes, node)
+		}
+	})
+	sort.Slice(ifaces, func(i, j int) bool {
+		return ifaces[i].typeId < ifaces[j].typeId
+	})
+	sort.Slice(concretes, func(i, j int) bool {
+		return concretes[i].typeId < concretes[j].typeId
+	})
+
+	for _, node := range concretes {
+		t := node.typeObj.Type()
+		for _, ifaceNode := range ifaces {
+			iface := ifaceNode.typeObj.Type().Underlying().(*types.Interface)
+			if !types.Implements(t, iface) && !types.Implements(types.NewPointer(t), iface) {
+				continue
+			}
+			p.nodeLinks = append(p.nodeLinks, graphNodeLink{
+				fromStructTypeId: node.typeId,
+				toTypePkgName:    ifaceNode.pkgName,
+				toTypeName:       ifaceNode.typeName,
+				toTypeId:         ifaceNode.typeId,
+				style:            "dashed",
+				arrowhead:        "empty",
+				ghost:            true,
+				fromTypeObj:      node.typeObj,
+			})
+		}
+	}
+}
+
+// maxAlmostMissing is the most methods that a type can be missing to almost
+// implement an interface.
+const maxAlmostMissing = 2
+
+// An AlmostImplementation is a type that's one or two methods short of
+// implementing an interface with more methods than that, e.g. an unfinished
+// implementation, or an accidental near match.
+type AlmostImplementation struct {
+	Interface string `json:"interface"`
+	Type      string `json:"type"`
+	// Missing are the signatures of the interface's methods that the type
+	// (or a pointer to it) is missing, or has with another signature, e.g.
+	// "Close() error".
+	Missing []string `json:"missing"`
+}
+
+func (a AlmostImplementation) String() string {
+	return fmt.Sprintf("%s almost implements %s, missing %s", a.Type, a.Interface, strings.Join(a.Missing, ", "))
+}
+
+// AlmostImplements returns the pairs of the graph's types and interfaces
+// where the type, or a pointer to it, is missing one or two of the
+// interface's methods, but has the rest of them, sorted by interface and
+// type. Types and interfaces are named as in Implements.
+func (p *pkg) AlmostImplements() []AlmostImplementation {
+	var ifaces, concretes []*types.TypeName
+	names := map[*types.TypeName]string{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		obj, ok := node.typeObj.(*types.TypeName)
+		if !ok {
+			return
+		}
+		names[obj] = obj.Name()
+		if pkgPath != "" {
+			names[obj] = pkgPath + "." + obj.Name()
+		}
+		if _, ok := obj.Type().Underlying().(*types.Interface); ok {
+			ifaces = append(ifaces, obj)
+		} else {
+			concretes = append(concretes, obj)
+		}
+	})
+
+	var almost []AlmostImplementation
+	for _, ifaceObj := range ifaces {
+		iface := ifaceObj.Type().Underlying().(*types.Interface)
+		for _, obj := range concretes {
+			missing := missingMethods(types.NewPointer(obj.Type()), iface)
+			if len(missing) == 0 || len(missing) > maxAlmostMissing || len(missing) >= iface.NumMethods() {
+				continue
+			}
+			var signatures []string
+			for _, name := range missing {
+				signatures = append(signatures, methodSignature(iface, name, ifaceObj.Pkg()))
+			}
+			almost = append(almost, AlmostImplementation{Interface: names[ifaceObj], Type: names[obj], Missing: signatures})
+		}
+	}
+	sort.Slice(almost, func(i, j int) bool {
+		a, b := almost[i], almost[j]
+		if a.Interface != b.Interface {
+			return a.Interface < b.Interface
+		}
+		return a.Type < b.Type
+	})
+	return almost
+}
+
+// methodSignature returns the name and signature of the interface's method,
+// e.g. "Close() error", with the types in pkg unqualified.
+func methodSignature(iface *types.Interface, name string, pkg *types.Package) string {
+	for i := 0; i < iface.NumMethods(); i++ {
+		if m := iface.Method(i); m.Name() == name {
+			return name + signatureString(m.Type().(*types.Signature), pkg)
+		}
+	}
+	return name
+}
+
+// missingMethods returns the names of the interface's methods that the type
+// doesn't have, or has with a different signature.
+func missingMethods(t types.Type, iface *types.Interface) []string {
+	var missing []string
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		obj, _, _ := types.LookupFieldOrMethod(t, false, m.Pkg(), m.Name())
+		fn, ok := obj.(*types.Func)
+		if !ok || !types.Identical(fn.Type(), m.Type()) {
+			missing = append(missing, m.Name())
+		}
+	}
+	return missing
+}
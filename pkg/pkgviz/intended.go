@@ -0,0 +1,262 @@
+package pkgviz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// An IntendedArchitecture is a hand-written description of the types that a
+// package is meant to have, and the references between them, to compare
+// the package's graph against (see ParseIntendedArchitecture).
+type IntendedArchitecture struct {
+	// Types are the types' names, qualified by their package if it isn't
+	// the graphed package itself (e.g. "store.DB"), or by their import path
+	// if they aren't in the graph (e.g. "database/sql.DB").
+	Types      []string            `json:"types"`
+	References []IntendedReference `json:"references"`
+}
+
+// An IntendedReference is a reference from one type to another that an
+// IntendedArchitecture expects, from any of the first type's fields.
+type IntendedReference struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ParseIntendedArchitecture parses an intended architecture, which lists a
+// type, or a reference between two types, on each line:
+//
+//	# The HTTP layer.
+//	Server
+//	Server -> store.DB
+//	store.DB -> database/sql.DB
+//
+// The types in references don't have to be listed on their own. Lines
+// starting with "#" or "//" are comments. It also accepts the subset of
+// the dot language with the same statements, e.g. `digraph { A -> B; }`,
+// ignoring attributes in brackets.
+func ParseIntendedArchitecture(src string) (IntendedArchitecture, error) {
+	var intended IntendedArchitecture
+	seen := map[string]bool{}
+	addType := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			intended.Types = append(intended.Types, name)
+		}
+	}
+
+	for i, line := range strings.Split(src, "\n") {
+		if j := strings.Index(line, "["); j >= 0 {
+			line = line[:j]
+		}
+		line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), ";"))
+		switch {
+		case line == "", line == "}", strings.HasPrefix(line, "#"), strings.HasPrefix(line, "//"):
+			continue
+		case strings.HasSuffix(line, "{"):
+			// The opening of a dot graph, e.g. "digraph intended {".
+			continue
+		}
+
+		names := strings.Split(line, "->")
+		if len(names) > 2 {
+			return intended, fmt.Errorf("line %d: expected a type or a reference from one type to another, got %q", i+1, line)
+		}
+		for j := range names {
+			names[j] = strings.Trim(strings.TrimSpace(names[j]), `"`)
+			if names[j] == "" || strings.ContainsAny(names[j], " \t") {
+				return intended, fmt.Errorf("line %d: expected a type or a reference from one type to another, got %q", i+1, line)
+			}
+			addType(names[j])
+		}
+		if len(names) == 2 {
+			intended.References = append(intended.References, IntendedReference{From: names[0], To: names[1]})
+		}
+	}
+	return intended, nil
+}
+
+// A DiscrepancyKind is how a graph differs from its intended architecture.
+type DiscrepancyKind string
+
+const (
+	// MissingType is an intended type that the graph doesn't have.
+	MissingType DiscrepancyKind = "missing type"
+	// UnexpectedType is a type in the graph that isn't intended.
+	UnexpectedType DiscrepancyKind = "unexpected type"
+	// MissingReference is an intended reference that no field makes.
+	MissingReference DiscrepancyKind = "missing reference"
+	// UnexpectedReference is a reference between two intended types that
+	// isn't intended.
+	UnexpectedReference DiscrepancyKind = "unexpected reference"
+)
+
+// A Discrepancy is a difference between a graph and its intended
+// architecture.
+type Discrepancy struct {
+	Kind DiscrepancyKind `json:"kind"`
+	// Type is the type that's missing or unexpected, or the type that a
+	// reference is from, named as in the IntendedArchitecture.
+	Type string `json:"type"`
+	// To is the type that a reference is to.
+	To string `json:"to,omitempty"`
+}
+
+func (d Discrepancy) String() string {
+	if d.To != "" {
+		return fmt.Sprintf("%s: %s -> %s", d.Kind, d.Type, d.To)
+	}
+	return fmt.Sprintf("%s: %s", d.Kind, d.Type)
+}
+
+// The types and references that a graph actually has, named as in an
+// IntendedArchitecture.
+type actualArchitecture struct {
+	types      map[string]*graphNode
+	targets    map[string]bool // the names of every type that's referred to
+	references map[IntendedReference][]int
+}
+
+func (p *pkg) actualArchitecture() actualArchitecture {
+	actual := actualArchitecture{
+		types:      map[string]*graphNode{},
+		targets:    map[string]bool{},
+		references: map[IntendedReference][]int{},
+	}
+	names := map[string]string{} // type id -> name
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		name := node.typeObj.Name()
+		if pkgPath != "" {
+			name = pkgPath + "." + name
+		}
+		actual.types[name] = node
+		names[node.typeId] = name
+	})
+	for i, nodeLink := range p.nodeLinks {
+		from, ok := names[nodeLink.fromStructTypeId]
+		if !ok || nodeLink.ghost {
+			continue
+		}
+		to := nodeLink.toTypeName
+		if nodeLink.toTypePkgName != "" {
+			to = nodeLink.toTypePkgName + "." + to
+		}
+		actual.targets[to] = true
+		reference := IntendedReference{From: from, To: to}
+		actual.references[reference] = append(actual.references[reference], i)
+	}
+	return actual
+}
+
+// CompareIntended returns how the graph differs from its intended
+// architecture, sorted by kind and type: the intended types that it doesn't
+// have, the types it has that aren't intended, the intended references that
+// none of its fields make, and the references between intended types that
+// aren't intended. Types outside of the graph are only missing if nothing
+// in the graph refers to them.
+func (p *pkg) CompareIntended(intended IntendedArchitecture) []Discrepancy {
+	actual := p.actualArchitecture()
+
+	isIntended := map[string]bool{}
+	var discrepancies []Discrepancy
+	for _, name := range intended.Types {
+		isIntended[name] = true
+		if _, ok := actual.types[name]; !ok && !actual.targets[name] {
+			discrepancies = append(discrepancies, Discrepancy{Kind: MissingType, Type: name})
+		}
+	}
+	for name := range actual.types {
+		if !isIntended[name] {
+			discrepancies = append(discrepancies, Discrepancy{Kind: UnexpectedType, Type: name})
+		}
+	}
+
+	isIntendedReference := map[IntendedReference]bool{}
+	for _, reference := range intended.References {
+		isIntendedReference[reference] = true
+		if _, ok := actual.references[reference]; !ok {
+			discrepancies = append(discrepancies, Discrepancy{Kind: MissingReference, Type: reference.From, To: reference.To})
+		}
+	}
+	for reference := range actual.references {
+		if isIntended[reference.From] && isIntended[reference.To] && !isIntendedReference[reference] {
+			discrepancies = append(discrepancies, Discrepancy{Kind: UnexpectedReference, Type: reference.From, To: reference.To})
+		}
+	}
+
+	order := map[DiscrepancyKind]int{MissingType: 0, UnexpectedType: 1, MissingReference: 2, UnexpectedReference: 3}
+	sort.Slice(discrepancies, func(i, j int) bool {
+		a, b := discrepancies[i], discrepancies[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.To < b.To
+	})
+	return discrepancies
+}
+
+// highlightIntended marks how the graph differs from its intended
+// architecture, like a VisualDiff from the intended graph to the actual
+// one: unexpected types and references are green, and missing ones are
+// added in red, with the types ghosted and the references dashed.
+func highlightIntended(p *pkg, intended IntendedArchitecture) {
+	actual := p.actualArchitecture()
+	for _, discrepancy := range p.CompareIntended(intended) {
+		switch discrepancy.Kind {
+		case MissingType:
+			pkgPath, name := splitIntendedName(discrepancy.Type)
+			deepSetNodeOnSubPkg(p, &graphNode{
+				typeId:               labelizeName(pkgPath, name),
+				typeType:             "struct",
+				typeName:             name,
+				typeNodes:            map[string]*graphNode{},
+				typeStructFields:     map[string]*structField{},
+				typeInterfaceMethods: map[string]string{},
+				annotations:          map[string]string{"intended": "missing"},
+				headerColor:          removedColor,
+				borderColor:          removedBorderColor,
+			}, pkgPath)
+		case UnexpectedType:
+			node := actual.types[discrepancy.Type]
+			node.headerColor = addedColor
+			if node.annotations == nil {
+				node.annotations = map[string]string{}
+			}
+			node.annotations["intended"] = "not intended"
+		case MissingReference:
+			fromPkgPath, fromName := splitIntendedName(discrepancy.Type)
+			toPkgPath, toName := splitIntendedName(discrepancy.To)
+			p.nodeLinks = append(p.nodeLinks, graphNodeLink{
+				fromStructTypeId: p.typeIdOf(fromPkgPath, fromName),
+				toTypePkgName:    toPkgPath,
+				toTypeName:       toName,
+				toTypeId:         p.typeIdOf(toPkgPath, toName),
+				color:            removedLinkColor,
+				style:            "dashed",
+				label:            "missing",
+				ghost:            true,
+			})
+		case UnexpectedReference:
+			reference := IntendedReference{From: discrepancy.Type, To: discrepancy.To}
+			for _, i := range actual.references[reference] {
+				p.nodeLinks[i].color = addedLinkColor
+			}
+		}
+	}
+}
+
+// splitIntendedName splits a type's name in an IntendedArchitecture into
+// its package and name.
+func splitIntendedName(name string) (string, string) {
+	if i := strings.LastIndex(name, "."); i >= 0 && i > strings.LastIndex(name, "/") {
+		return name[:i], name[i+1:]
+	}
+	return "", name
+}
@@ -0,0 +1,157 @@
+package pkgviz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// internalClusterFillColor is the background of the clusters of internal
+// packages.
+const internalClusterFillColor = "#f4f4f4"
+
+// internalViolationColor is the color of references to internal packages
+// from outside the tree that may import them.
+const internalViolationColor = "#d9534f"
+
+// An InternalViolation is a reference from a struct's field to a type in an
+// internal package that the struct's package isn't allowed to import.
+type InternalViolation struct {
+	// From and To are the types' names, qualified by their package if it
+	// isn't the graphed package itself (e.g. "nested.NestedStruct").
+	From  string `json:"from"`
+	Field string `json:"field"`
+	To    string `json:"to"`
+	// Root is the import path of the tree that may import To's package.
+	Root string `json:"root"`
+}
+
+func (v InternalViolation) String() string {
+	return fmt.Sprintf("%s.%s -> %s: only packages in %s may refer to it", v.From, v.Field, v.To, v.Root)
+}
+
+// internalRoot returns the import path of the tree that may import the
+// package, i.e. the parent of its last "internal" element, or false if the
+// package isn't internal.
+func internalRoot(importPath string) (string, bool) {
+	switch {
+	case strings.HasSuffix(importPath, "/internal"):
+		return strings.TrimSuffix(importPath, "/internal"), true
+	case strings.Contains(importPath, "/internal/"):
+		return importPath[:strings.LastIndex(importPath, "/internal/")], true
+	case importPath == "internal", strings.HasPrefix(importPath, "internal/"):
+		return "", true
+	}
+	return "", false
+}
+
+// inPkgTree returns whether the package is the root or one of its
+// subpackages. Every package is in the tree of the empty root.
+func inPkgTree(root, importPath string) bool {
+	return root == "" || importPath == root || strings.HasPrefix(importPath, root+"/")
+}
+
+// InternalViolations returns the references in the graph from types to types
+// in internal packages that the types' packages aren't allowed to import,
+// since they aren't in the tree rooted at the parent of the "internal"
+// directory. The go command refuses to build these, but the graphs of
+// in-memory files aren't checked.
+func (p *pkg) InternalViolations() []InternalViolation {
+	violations, _, _ := p.findInternalReferences()
+	return violations
+}
+
+// findInternalReferences returns the violations of the internal packages'
+// rules, which of the graph's node links they are, and which of the links
+// are allowed references into an internal package from outside of it.
+func (p *pkg) findInternalReferences() ([]InternalViolation, map[int]bool, map[int]bool) {
+	importPaths := map[string]string{}
+	names := map[string]string{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		importPaths[node.typeId] = p.pkgImportPath(pkgPath)
+		names[node.typeId] = node.typeObj.Name()
+		if pkgPath != "" {
+			names[node.typeId] = pkgPath + "." + node.typeObj.Name()
+		}
+	})
+
+	var violations []InternalViolation
+	violating, crossing := map[int]bool{}, map[int]bool{}
+	for i, nodeLink := range p.nodeLinks {
+		from, to := nodeLink.fromStructTypeId, nodeLink.toId()
+		fromPath, ok := importPaths[from]
+		if !ok {
+			continue
+		}
+		toPath, ok := importPaths[to]
+		toName := names[to]
+		if !ok {
+			// Types outside of the graph are named by their import path.
+			toPath = nodeLink.toTypePkgName
+			toName = nodeLink.toTypePkgName + "." + nodeLink.toTypeName
+		}
+		root, ok := internalRoot(toPath)
+		if !ok {
+			continue
+		}
+		switch {
+		case !inPkgTree(root, fromPath):
+			violations = append(violations, InternalViolation{
+				From:  names[from],
+				Field: nodeLink.fromStructFieldName,
+				To:    toName,
+				Root:  root,
+			})
+			violating[i] = true
+		case !inPkgTree(strings.TrimPrefix(root+"/internal", "/"), fromPath):
+			crossing[i] = true
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].From != violations[j].From {
+			return violations[i].From < violations[j].From
+		}
+		return violations[i].Field < violations[j].Field
+	})
+	return violations, violating, crossing
+}
+
+// highlightInternalReferences dashes the references into internal packages
+// from the rest of the tree that may import them, and colors the ones from
+// outside of it in red.
+func highlightInternalReferences(p *pkg) {
+	_, violating, crossing := p.findInternalReferences()
+	for i := range crossing {
+		p.nodeLinks[i].style = "dashed"
+	}
+	for i := range violating {
+		p.nodeLinks[i].color = internalViolationColor
+		p.nodeLinks[i].style = "bold"
+	}
+}
+
+// clusterAttrs returns the attributes of the subpackage's cluster, which has
+// a shaded background if it's an internal package, and a thick solid border
+// if it's a module.
+func (p *pkg) clusterAttrs(subPkgName string) string {
+	if p.module != "" {
+		return fmt.Sprintf("style=rounded penwidth=2 color=\"%s\"", p.clusterColorOrDefault())
+	}
+	if subPkgName == "internal" {
+		return fmt.Sprintf("style=\"filled,dashed\" fillcolor=\"%s\" color=\"%s\"", internalClusterFillColor, p.clusterColorOrDefault())
+	}
+	return fmt.Sprintf("style=dotted color=\"%s\"", p.clusterColorOrDefault())
+}
+
+// clusterLabel returns the label of the subpackage's cluster, with a lock in
+// front of it if it's an internal package.
+func clusterLabel(subPkgName, pkgName string) string {
+	label := relativizeTypePkgName(subPkgName, pkgName)
+	if subPkgName == "internal" {
+		label = "🔒 " + label
+	}
+	return label
+}
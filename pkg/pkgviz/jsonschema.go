@@ -0,0 +1,226 @@
+package pkgviz
+
+import (
+	"go/types"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jsonSchemaDialect is the version of JSON Schema that schemas are written
+// in.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// A JSONSchema is a JSON Schema of how encoding/json encodes a type.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	ContentEncoding      string                 `json:"contentEncoding,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	// Defs are the schemas of the named structs that the type refers to,
+	// which are referred to as "#/$defs/NAME".
+	Defs map[string]*JSONSchema `json:"$defs,omitempty"`
+}
+
+// JSONSchemas returns a JSON Schema document for each of the exported structs
+// in the graph, by its name, qualified by its package if it isn't the graphed
+// package itself (e.g. "nested.NestedStruct"). The schemas follow the
+// struct's json tags: fields tagged "-" are left out, and fields are
+// required unless they're tagged omitempty or are pointers. The named
+// structs that a struct refers to are defined in the document's $defs, by
+// the same names if they're in the graph, or by their import path and name
+// if they aren't. A struct that refers to itself does so as "#".
+func (p *pkg) JSONSchemas() map[string]*JSONSchema {
+	names := map[*types.TypeName]string{}
+	importPathNames := map[string]string{}
+	var structs []*types.TypeName
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		obj, ok := node.typeObj.(*types.TypeName)
+		if !ok {
+			return
+		}
+		names[obj] = obj.Name()
+		if pkgPath != "" {
+			names[obj] = pkgPath + "." + obj.Name()
+		}
+		importPathNames[p.pkgImportPath(pkgPath)+"."+obj.Name()] = names[obj]
+		if _, ok := obj.Type().Underlying().(*types.Struct); ok && obj.Exported() {
+			structs = append(structs, obj)
+		}
+	})
+
+	schemas := map[string]*JSONSchema{}
+	for _, obj := range structs {
+		b := &jsonSchemaBuilder{
+			root:            names[obj],
+			names:           names,
+			importPathNames: importPathNames,
+			defs:            map[string]*JSONSchema{},
+		}
+		schema := b.structSchema(obj.Type().Underlying().(*types.Struct))
+		schema.Schema = jsonSchemaDialect
+		schema.Title = names[obj]
+		if len(b.defs) > 0 {
+			schema.Defs = b.defs
+		}
+		schemas[names[obj]] = schema
+	}
+	return schemas
+}
+
+// jsonSchemaBuilder builds the schema of a type, collecting the definitions
+// of the named structs it refers to.
+type jsonSchemaBuilder struct {
+	root  string                     // the name of the struct being described
+	names map[*types.TypeName]string // the graph's types -> their names
+	// The graph's types' names by their import path and name, for the types
+	// that other packages refer to, which are checked separately.
+	importPathNames map[string]string
+	defs            map[string]*JSONSchema
+}
+
+func (b *jsonSchemaBuilder) schema(t types.Type) *JSONSchema {
+	if named, ok := t.(*types.Named); ok {
+		obj := named.Obj()
+		switch {
+		case obj.Pkg() != nil && obj.Pkg().Path() == "time" && obj.Name() == "Time":
+			return &JSONSchema{Type: "string", Format: "date-time"}
+		case hasMethod(t, "MarshalJSON"):
+			// It could be encoded as anything.
+			return &JSONSchema{}
+		case hasMethod(t, "MarshalText"):
+			return &JSONSchema{Type: "string"}
+		}
+		if s, ok := named.Underlying().(*types.Struct); ok {
+			name := b.defName(obj)
+			if name == b.root {
+				return &JSONSchema{Ref: "#"}
+			}
+			if _, ok := b.defs[name]; !ok {
+				// Added before the struct's fields, in case they refer to it.
+				b.defs[name] = &JSONSchema{}
+				*b.defs[name] = *b.structSchema(s)
+			}
+			return &JSONSchema{Ref: "#/$defs/" + strings.Replace(strings.Replace(name, "~", "~0", -1), "/", "~1", -1)}
+		}
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch info := u.Info(); {
+		case info&types.IsBoolean != 0:
+			return &JSONSchema{Type: "boolean"}
+		case info&types.IsInteger != 0:
+			return &JSONSchema{Type: "integer"}
+		case info&types.IsFloat != 0:
+			return &JSONSchema{Type: "number"}
+		case info&types.IsString != 0:
+			return &JSONSchema{Type: "string"}
+		}
+	case *types.Pointer:
+		return b.schema(u.Elem())
+	case *types.Slice:
+		if elem, ok := u.Elem().Underlying().(*types.Basic); ok && elem.Kind() == types.Byte {
+			return &JSONSchema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &JSONSchema{Type: "array", Items: b.schema(u.Elem())}
+	case *types.Array:
+		return &JSONSchema{Type: "array", Items: b.schema(u.Elem())}
+	case *types.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: b.schema(u.Elem())}
+	case *types.Struct:
+		return b.structSchema(u)
+	}
+	// Interfaces could hold anything, and other types (e.g. channels) can't
+	// be encoded.
+	return &JSONSchema{}
+}
+
+// defName returns the name of a named type's definition.
+func (b *jsonSchemaBuilder) defName(obj *types.TypeName) string {
+	if name, ok := b.names[obj]; ok {
+		return name
+	}
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	name := obj.Pkg().Path() + "." + obj.Name()
+	if graphName, ok := b.importPathNames[name]; ok {
+		return graphName
+	}
+	return name
+}
+
+func (b *jsonSchemaBuilder) structSchema(s *types.Struct) *JSONSchema {
+	schema := &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{}}
+	required := map[string]bool{}
+	b.addFields(schema, required, s, map[*types.Struct]bool{s: true}, 0)
+	for name := range required {
+		schema.Required = append(schema.Required, name)
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// addFields adds the struct's fields to the schema, including the fields of
+// the structs embedded in it without a name in their tag, which are encoded
+// as if they were the struct's own. Fields that are embedded more deeply
+// don't replace the ones that aren't.
+func (b *jsonSchemaBuilder) addFields(schema *JSONSchema, required map[string]bool, s *types.Struct, embedding map[*types.Struct]bool, depth int) {
+	for i := 0; i < s.NumFields(); i++ {
+		f := s.Field(i)
+		tag := reflect.StructTag(s.Tag(i)).Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, opts = tag[:comma], tag[comma:]
+		}
+
+		fieldType := f.Type()
+		if ptr, ok := fieldType.(*types.Pointer); ok {
+			fieldType = ptr.Elem()
+		}
+		if embedded, ok := fieldType.Underlying().(*types.Struct); ok && f.Anonymous() && name == "" {
+			if !embedding[embedded] {
+				embedding[embedded] = true
+				b.addFields(schema, required, embedded, embedding, depth+1)
+			}
+			continue
+		}
+		if !f.Exported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name()
+		}
+		if _, ok := schema.Properties[name]; ok && depth > 0 {
+			continue
+		}
+
+		fieldSchema := b.schema(f.Type())
+		if strings.Contains(opts+",", ",string,") && (fieldSchema.Type == "boolean" || fieldSchema.Type == "integer" || fieldSchema.Type == "number") {
+			fieldSchema.Type = "string"
+		}
+		schema.Properties[name] = fieldSchema
+		if _, isPointer := f.Type().(*types.Pointer); isPointer || strings.Contains(opts+",", ",omitempty,") {
+			delete(required, name)
+		} else {
+			required[name] = true
+		}
+	}
+}
+
+// hasMethod returns whether the type, or a pointer to it, has the method.
+func hasMethod(t types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), false, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
@@ -0,0 +1,174 @@
+package pkgviz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// layerViolationColor is the color of references that point up from a
+// layer to a higher one.
+const layerViolationColor = "#d9534f"
+
+// A Layer is a named set of packages in a layered architecture, like
+// "handlers" or "repositories".
+type Layer struct {
+	Name string `json:"name"`
+	// Packages are patterns of the layer's packages' import paths: the
+	// path or its last elements (e.g. "pkg/handlers", which may also be
+	// written ".../handlers"), optionally ending in "/..." to include
+	// subpackages.
+	Packages []string `json:"packages"`
+}
+
+// A LayerViolation is a reference from a struct's field to a type in a
+// higher layer.
+type LayerViolation struct {
+	// From and To are the types' names, qualified by their package if it
+	// isn't the graphed package itself (e.g. "nested.NestedStruct").
+	From      string `json:"from"`
+	Field     string `json:"field"`
+	To        string `json:"to"`
+	FromLayer string `json:"fromLayer"`
+	ToLayer   string `json:"toLayer"`
+}
+
+func (v LayerViolation) String() string {
+	return fmt.Sprintf("%s.%s -> %s: %s must not reference %s, which is above it", v.From, v.Field, v.To, v.FromLayer, v.ToLayer)
+}
+
+// A row of the graph's types in the same layer.
+type layerRow struct {
+	name    string
+	typeIds []string
+}
+
+// layerOf returns the index of the first layer that has the package, or -1
+// if none does.
+func layerOf(layers []Layer, importPath string) int {
+	for i, layer := range layers {
+		for _, pattern := range layer.Packages {
+			if matchesPkgPattern(pattern, importPath) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// matchesPkgPattern returns whether the pattern (see Layer.Packages)
+// matches the import path.
+func matchesPkgPattern(pattern, importPath string) bool {
+	pattern = strings.TrimPrefix(pattern, ".../")
+	if strings.HasSuffix(pattern, "/...") {
+		prefix := strings.TrimSuffix(pattern, "/...")
+		return matchesPkgPattern(prefix, importPath) || strings.HasPrefix(importPath, prefix+"/") || strings.Contains(importPath, "/"+prefix+"/")
+	}
+	return importPath == pattern || strings.HasSuffix(importPath, "/"+pattern)
+}
+
+// LayerViolations returns the references in the graph from types in one of
+// the layers to types in a layer above it. The layers are ordered from the
+// top (e.g. handlers) to the bottom (e.g. repositories), so types may only
+// refer to types in their own layer or the ones below it. Types in packages
+// that aren't in any layer are ignored, but the types that the graph's
+// types refer to are checked even if they aren't in the graph.
+func (p *pkg) LayerViolations(layers []Layer) []LayerViolation {
+	violations, _ := p.findLayerViolations(layers)
+	return violations
+}
+
+// findLayerViolations returns the violations of the layers, and which of the
+// graph's node links they are.
+func (p *pkg) findLayerViolations(layers []Layer) ([]LayerViolation, map[int]bool) {
+	typeLayers := map[string]int{}
+	names := map[string]string{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		typeLayers[node.typeId] = layerOf(layers, p.pkgImportPath(pkgPath))
+		names[node.typeId] = node.typeObj.Name()
+		if pkgPath != "" {
+			names[node.typeId] = pkgPath + "." + node.typeObj.Name()
+		}
+	})
+
+	var violations []LayerViolation
+	links := map[int]bool{}
+	for i, nodeLink := range p.nodeLinks {
+		from, to := nodeLink.fromStructTypeId, nodeLink.toId()
+		fromLayer, ok := typeLayers[from]
+		if !ok {
+			continue
+		}
+		toLayer, ok := typeLayers[to]
+		toName := names[to]
+		if !ok {
+			// Types outside of the graph are named by their import path.
+			toLayer = layerOf(layers, nodeLink.toTypePkgName)
+			toName = nodeLink.toTypePkgName + "." + nodeLink.toTypeName
+		}
+		if fromLayer < 0 || toLayer < 0 || toLayer >= fromLayer {
+			continue
+		}
+		violations = append(violations, LayerViolation{
+			From:      names[from],
+			Field:     nodeLink.fromStructFieldName,
+			To:        toName,
+			FromLayer: layers[fromLayer].Name,
+			ToLayer:   layers[toLayer].Name,
+		})
+		links[i] = true
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].From != violations[j].From {
+			return violations[i].From < violations[j].From
+		}
+		return violations[i].Field < violations[j].Field
+	})
+	return violations, links
+}
+
+// highlightLayers makes the graph print the types of each layer in a row,
+// from the top layer down, and colors the references that point up a layer
+// in red.
+func highlightLayers(p *pkg, layers []Layer) {
+	_, links := p.findLayerViolations(layers)
+	for i := range links {
+		p.nodeLinks[i].color = layerViolationColor
+		p.nodeLinks[i].style = "bold"
+	}
+
+	p.layerRows = make([]layerRow, len(layers))
+	for i, layer := range layers {
+		p.layerRows[i].name = layer.Name
+	}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if i := layerOf(layers, p.pkgImportPath(pkgPath)); node.typeObj != nil && i >= 0 {
+			p.layerRows[i].typeIds = append(p.layerRows[i].typeIds, node.typeId)
+		}
+	})
+}
+
+// printLayerRows returns the graph's layers, as a label and the types in
+// each one at the same rank, in order from the top layer down.
+func (p *pkg) printLayerRows(out string) string {
+	if len(p.layerRows) == 0 {
+		return out
+	}
+	out = fmt.Sprintf("%s  /* layers: */\n", out)
+	for i, row := range p.layerRows {
+		sort.Strings(row.typeIds)
+		out = fmt.Sprintf("%s  layer_%d [shape=plaintext fontcolor=\"#7f8183\" label=%s];\n", out, i, quoteString(row.name))
+		out = fmt.Sprintf("%s  { rank=same; layer_%d;", out, i)
+		for _, typeId := range row.typeIds {
+			out = fmt.Sprintf("%s %s;", out, typeId)
+		}
+		out = fmt.Sprintf("%s }\n", out)
+		if i > 0 {
+			out = fmt.Sprintf("%s  layer_%d -> layer_%d [style=invis];\n", out, i-1, i)
+		}
+	}
+	return out
+}
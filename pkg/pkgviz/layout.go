@@ -0,0 +1,235 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/types"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// wastefulBorderColor is the color of the border of structs that would be
+// smaller with their fields in another order, when their layout is shown.
+const wastefulBorderColor = "#f0ad4e"
+
+// A PaddingSuggestion is an order of a struct's fields that would make it
+// smaller, by needing less padding between them.
+type PaddingSuggestion struct {
+	// Type is the struct's name, qualified by its package if it isn't the
+	// graphed package itself (e.g. "nested.NestedStruct").
+	Type string `json:"type"`
+	// Size is the struct's size in bytes, and OptimalSize is its size with
+	// its fields in the order of Fields.
+	Size        int64    `json:"size"`
+	OptimalSize int64    `json:"optimalSize"`
+	Fields      []string `json:"fields"`
+}
+
+// Wasted returns how many bytes the struct would save with its fields
+// reordered.
+func (s PaddingSuggestion) Wasted() int64 {
+	return s.Size - s.OptimalSize
+}
+
+func (s PaddingSuggestion) String() string {
+	return fmt.Sprintf("%s: %s, could be %s by ordering its fields %s", s.Type, formatBytes(s.Size), formatBytes(s.OptimalSize), strings.Join(s.Fields, ", "))
+}
+
+// PaddingSuggestions returns the structs in the graph that would be smaller
+// with their fields in another order, along with the order, laid out for
+// the architecture (e.g. "386"), or the current one if it's empty. They're
+// sorted by how many bytes they'd save, most first.
+func (p *pkg) PaddingSuggestions(arch string) ([]PaddingSuggestion, error) {
+	sizes, err := layoutSizes(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	var suggestions []PaddingSuggestion
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if suggestion, ok := suggestPadding(sizes, pkgPath, node); ok {
+			suggestions = append(suggestions, suggestion)
+		}
+	})
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Wasted() != suggestions[j].Wasted() {
+			return suggestions[i].Wasted() > suggestions[j].Wasted()
+		}
+		return suggestions[i].Type < suggestions[j].Type
+	})
+	return suggestions, nil
+}
+
+// suggestPadding returns the order of the struct's fields that would make
+// it smaller, if there is one.
+func suggestPadding(sizes types.Sizes, pkgPath string, node *graphNode) (PaddingSuggestion, bool) {
+	if node.typeObj == nil || node.typeType != "struct" {
+		return PaddingSuggestion{}, false
+	}
+	s, ok := node.typeObj.Type().Underlying().(*types.Struct)
+	if !ok {
+		return PaddingSuggestion{}, false
+	}
+	layout, ok := layoutStruct(sizes, s)
+	if !ok {
+		return PaddingSuggestion{}, false
+	}
+	optimal, ok := layoutStruct(sizes, optimalFieldOrder(layout, s))
+	if !ok || optimal.size >= layout.size {
+		return PaddingSuggestion{}, false
+	}
+
+	suggestion := PaddingSuggestion{
+		Type:        node.typeObj.Name(),
+		Size:        layout.size,
+		OptimalSize: optimal.size,
+	}
+	if pkgPath != "" {
+		suggestion.Type = pkgPath + "." + node.typeObj.Name()
+	}
+	for _, f := range optimal.fields {
+		suggestion.Fields = append(suggestion.Fields, f.name)
+	}
+	return suggestion, true
+}
+
+// optimalFieldOrder returns the struct with its fields ordered to need the
+// least padding: zero-sized fields first (since one at the end needs padding
+// after it), then from the most to the least strictly aligned, keeping the
+// declaration order otherwise.
+func optimalFieldOrder(layout structLayout, s *types.Struct) *types.Struct {
+	order := make([]int, s.NumFields())
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := layout.fields[order[i]], layout.fields[order[j]]
+		if (a.size == 0) != (b.size == 0) {
+			return a.size == 0
+		}
+		return a.align > b.align
+	})
+
+	fields := make([]*types.Var, len(order))
+	for i, field := range order {
+		fields[i] = s.Field(field)
+	}
+	return types.NewStruct(fields, nil)
+}
+
+// The layout of a struct in memory.
+type structLayout struct {
+	size   int64
+	fields []fieldLayout // in declaration order
+}
+
+type fieldLayout struct {
+	name    string
+	offset  int64
+	size    int64
+	align   int64
+	padding int64 // the bytes between the end of the field and the next one
+}
+
+// padding returns how many of the struct's bytes are padding.
+func (l structLayout) padding() int64 {
+	var padding int64
+	for _, f := range l.fields {
+		padding += f.padding
+	}
+	return padding
+}
+
+// layoutSizes returns the sizes of types for the architecture, or for the
+// current one if arch is empty.
+func layoutSizes(arch string) (types.Sizes, error) {
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+	sizes := types.SizesFor("gc", arch)
+	if sizes == nil {
+		return nil, fmt.Errorf("unknown architecture %q", arch)
+	}
+	return sizes, nil
+}
+
+// layoutStruct returns the layout of the struct, or false if it can't be
+// laid out (e.g. because its type has type parameters).
+func layoutStruct(sizes types.Sizes, s *types.Struct) (layout structLayout, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+
+	fields := make([]*types.Var, s.NumFields())
+	for i := range fields {
+		fields[i] = s.Field(i)
+	}
+	offsets := sizes.Offsetsof(fields)
+	layout.size = sizes.Sizeof(s)
+	for i, f := range fields {
+		field := fieldLayout{
+			name:   f.Name(),
+			offset: offsets[i],
+			size:   sizes.Sizeof(f.Type()),
+			align:  sizes.Alignof(f.Type()),
+		}
+		next := layout.size
+		if i+1 < len(fields) {
+			next = offsets[i+1]
+		}
+		field.padding = next - field.offset - field.size
+		layout.fields = append(layout.fields, field)
+	}
+	return layout, true
+}
+
+// annotateLayout annotates each struct with its size and how much of it is
+// padding, and each of its fields with its offset, size and the padding
+// after it, listing the fields in the order they're laid out in.
+func annotateLayout(p *pkg, opts *Options) {
+	sizes, err := layoutSizes(opts.LayoutArch)
+	if err != nil {
+		return
+	}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil || node.typeType != "struct" {
+			return
+		}
+		s, ok := node.typeObj.Type().Underlying().(*types.Struct)
+		if !ok {
+			return
+		}
+		layout, ok := layoutStruct(sizes, s)
+		if !ok {
+			return
+		}
+
+		if node.annotations == nil {
+			node.annotations = map[string]string{}
+		}
+		node.annotations["layout"] = fmt.Sprintf("%s, %s padding", formatBytes(layout.size), formatBytes(layout.padding()))
+		if suggestion, ok := suggestPadding(sizes, pkgPath, node); ok {
+			node.borderColor = wastefulBorderColor
+			node.annotations["layout"] += fmt.Sprintf(", %s wasted", formatBytes(suggestion.Wasted()))
+		}
+		node.fieldNotes = map[string]string{}
+		node.fieldOrder = nil
+		for _, f := range layout.fields {
+			note := fmt.Sprintf("@%d, %s", f.offset, formatBytes(f.size))
+			if f.padding > 0 {
+				note += fmt.Sprintf(" + %s padding", formatBytes(f.padding))
+			}
+			node.fieldNotes[f.name] = note
+			node.fieldOrder = append(node.fieldOrder, f.name)
+		}
+	})
+}
+
+func formatBytes(n int64) string {
+	if n == 1 {
+		return "1 byte"
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
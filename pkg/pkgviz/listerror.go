@@ -0,0 +1,52 @@
+package pkgviz
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultListTimeout is how long each run of the go tool may take when the
+// Options' ListTimeout isn't set. Listing a package can download its
+// modules, so it's generous.
+const DefaultListTimeout = 5 * time.Minute
+
+// The reasons that the go tool can fail to list packages, which ListErrors
+// wrap, so that they can be told apart with errors.Is.
+var (
+	// ErrPackageNotFound means that a package doesn't exist, or isn't in
+	// any module that the go tool can find.
+	ErrPackageNotFound = errors.New("package not found")
+
+	// ErrToolchainMissing means that the go tool isn't installed, or isn't
+	// on the PATH.
+	ErrToolchainMissing = errors.New("go toolchain not found")
+
+	// ErrTimeout means that the go tool took longer than the Options'
+	// ListTimeout, and was killed.
+	ErrTimeout = errors.New("timed out")
+)
+
+// A ListError is returned when the go tool fails to list packages.
+type ListError struct {
+	// Command is the command that failed, e.g. "go list -json -deps ./foo".
+	Command string
+
+	// Stderr is what the command wrote to stderr, if anything.
+	Stderr string
+
+	// Err is ErrPackageNotFound, ErrToolchainMissing or ErrTimeout, if the
+	// failure is one of them, or otherwise the command's own error.
+	Err error
+}
+
+func (e *ListError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("error running '%v': %v", e.Command, e.Err)
+	}
+	return fmt.Sprintf("error running '%v': %v: %s", e.Command, e.Err, e.Stderr)
+}
+
+func (e *ListError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,452 @@
+//go:build !js
+// +build !js
+
+package pkgviz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// listRetries is how many times loading packages is retried if it fails in
+// a way that's usually transient, and listRetryDelay how long the first
+// retry waits (doubling each time).
+var (
+	listRetries    = 2
+	listRetryDelay = time.Second
+)
+
+// The error output of the go tool that means a package doesn't exist.
+var packageNotFoundErrors = []string{
+	"cannot find package",
+	"cannot find module providing package",
+	"no required module provides package",
+	"is not in GOROOT",
+	"is not in std",
+	"does not contain package",
+	"directory not found",
+	"no such file or directory",
+	"package not found",
+	"404 Not Found",
+	"410 Gone",
+}
+
+// The error output of the go tool that usually means that a download failed
+// for a reason that may go away if it's tried again.
+var transientErrors = []string{
+	"connection reset by peer",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"temporary failure in name resolution",
+	"unexpected EOF",
+	"502 Bad Gateway",
+	"503 Service Unavailable",
+	"504 Gateway Timeout",
+}
+
+// loadMode is what go/packages loads of the packages to graph, and of every
+// package that they depend on: their files, parsed, and their types, with
+// the objects that their files declare.
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps |
+	packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax | packages.NeedModule
+
+// A pkgLoader loads the packages to graph with go/packages, which lists them
+// with the go tool (honoring the Options' Dir and Env), and parses and
+// type-checks them and their dependencies.
+type pkgLoader struct {
+	fset   *token.FileSet
+	opts   *Options
+	loaded map[string]*packages.Package // import path -> package
+}
+
+func newPkgLoader(fset *token.FileSet, opts *Options) *pkgLoader {
+	return &pkgLoader{fset: fset, opts: opts, loaded: map[string]*packages.Package{}}
+}
+
+// load loads the packages, along with every package they depend on, which
+// includes the subpackages that they import. Packages are loaded together
+// so that the dependencies that they have in common are only type-checked
+// once.
+func (l *pkgLoader) load(pkgNames ...string) error {
+	if len(pkgNames) == 0 {
+		return nil
+	}
+	timeout := listTimeout(l.opts)
+
+	// The packages are listed first, to find their directories: only the
+	// files in them (and their subpackages') are parsed with their
+	// functions' bodies, for the types that are declared in them. Without
+	// the bodies, the packages that they depend on are type-checked much
+	// faster.
+	cfg := packagesConfig(l.opts, l.opts.Dir, packages.NeedName|packages.NeedFiles|packages.NeedModule)
+	pkgs, err := loadWithRetries(timeout, cfg, pkgNames)
+	if err != nil {
+		return err
+	}
+	var dirs []string
+	for _, p := range pkgs {
+		if dir := pkgDir(p); dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	cfg.Mode = loadMode
+	cfg.Fset = l.fset
+	cfg.ParseFile = func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+		return parseFile(fset, filename, src, inAnyDir(filename, dirs))
+	}
+	if pkgs, err = loadWithRetries(timeout, cfg, pkgNames); err != nil {
+		return err
+	}
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		l.loaded[p.PkgPath] = p
+	})
+	return nil
+}
+
+// packagesConfig returns the config to load packages in dir with, in mode,
+// with the Options' Env.
+func packagesConfig(opts *Options, dir string, mode packages.LoadMode) packages.Config {
+	env := opts.Env
+	if env == nil {
+		env = append(os.Environ(), "GIT_TERMINAL_PROMPT=1")
+	}
+	return packages.Config{Mode: mode, Dir: dir, Env: env}
+}
+
+// listTimeout returns how long each try at loading packages may take.
+func listTimeout(opts *Options) time.Duration {
+	if opts.ListTimeout <= 0 {
+		return DefaultListTimeout
+	}
+	return opts.ListTimeout
+}
+
+// loadWithRetries loads the packages, killing each try after the timeout,
+// and retrying tries that fail in a transient way (e.g. a module proxy
+// reset the connection), up to listRetries times.
+func loadWithRetries(timeout time.Duration, cfg packages.Config, pkgNames []string) ([]*packages.Package, error) {
+	delay := listRetryDelay
+	for retry := 0; ; retry++ {
+		pkgs, err := loadOnce(timeout, cfg, pkgNames)
+		if err == nil {
+			return pkgs, nil
+		}
+		if retry == listRetries || err.Err == ErrTimeout || !containsAny(err.Stderr, transientErrors) {
+			return nil, err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// loadOnce loads the packages once, and works out why it failed if it did.
+// Packages that can't be found, or can't be listed for a reason that may go
+// away, fail the load, but the errors of the packages that could be listed
+// are left for the graph to warn about.
+func loadOnce(timeout time.Duration, cfg packages.Config, pkgNames []string) ([]*packages.Package, *ListError) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cfg.Context = ctx
+
+	pkgs, err := packages.Load(&cfg, pkgNames...)
+	listErr := &ListError{Command: "go list -e -json -deps " + strings.Join(pkgNames, " ")}
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		listErr.Err = ErrTimeout
+		return nil, listErr
+	case err != nil:
+		listErr.Stderr, listErr.Err = strings.TrimSpace(err.Error()), err
+		switch {
+		case errors.Is(err, exec.ErrNotFound), containsAny(listErr.Stderr, []string{"executable file not found", "cannot find GOROOT"}):
+			listErr.Err = ErrToolchainMissing
+		case containsAny(listErr.Stderr, packageNotFoundErrors):
+			listErr.Err = ErrPackageNotFound
+		}
+		return nil, listErr
+	}
+
+	for _, p := range pkgs {
+		for _, pkgErr := range p.Errors {
+			if pkgErr.Kind != packages.ListError {
+				continue
+			}
+			if containsAny(pkgErr.Msg, packageNotFoundErrors) {
+				listErr.Stderr, listErr.Err = pkgErr.Msg, ErrPackageNotFound
+				return nil, listErr
+			}
+			if containsAny(pkgErr.Msg, transientErrors) {
+				listErr.Stderr, listErr.Err = pkgErr.Msg, errors.New(pkgErr.Msg)
+				return nil, listErr
+			}
+		}
+	}
+	return pkgs, nil
+}
+
+// containsAny returns whether s contains any of the substrings.
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFile parses a file with its comments, and, unless withBodies is
+// set, without its functions' bodies.
+func parseFile(fset *token.FileSet, filename string, src []byte, withBodies bool) (*ast.File, error) {
+	f, err := parser.ParseFile(fset, filename, src, parser.AllErrors|parser.ParseComments)
+	if f != nil && !withBodies {
+		for _, decl := range f.Decls {
+			if funcDecl, ok := decl.(*ast.FuncDecl); ok {
+				funcDecl.Body = nil
+			}
+		}
+	}
+	return f, err
+}
+
+// inAnyDir returns whether the file is in any of the directories, or their
+// subdirectories.
+func inAnyDir(filename string, dirs []string) bool {
+	for _, dir := range dirs {
+		if rel, err := filepath.Rel(dir, filename); err == nil && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns the loaded package with the given import path, loading it if
+// it wasn't loaded yet.
+func (l *pkgLoader) get(pkgName string) (*loadedPkg, error) {
+	p, ok := l.loaded[pkgName]
+	if !ok {
+		if err := l.load(pkgName); err != nil {
+			return nil, err
+		}
+		if p, ok = l.loaded[pkgName]; !ok {
+			return nil, fmt.Errorf("go list didn't list %v", pkgName)
+		}
+	}
+
+	loaded := &loadedPkg{
+		importPath: p.PkgPath,
+		dir:        pkgDir(p),
+		files:      p.Syntax,
+		info:       p.TypesInfo,
+	}
+	for importPath := range p.Imports {
+		loaded.imports = append(loaded.imports, importPath)
+	}
+	sort.Strings(loaded.imports)
+	for _, pkgErr := range p.Errors {
+		if pkgErr.Kind == packages.ParseError || pkgErr.Kind == packages.TypeError {
+			loaded.errors = append(loaded.errors, pkgErr)
+		}
+	}
+	if len(p.GoFiles) == 0 {
+		loaded.testFiles, _ = filepath.Glob(filepath.Join(loaded.dir, "*_test.go"))
+		for i, file := range loaded.testFiles {
+			loaded.testFiles[i] = filepath.Base(file)
+		}
+	}
+	return loaded, nil
+}
+
+// pkgDir returns the directory of a loaded package's files, or, if it has
+// none (e.g. it only has tests), where it is in its module.
+func pkgDir(p *packages.Package) string {
+	for _, files := range [][]string{p.GoFiles, p.OtherFiles, p.IgnoredFiles} {
+		if len(files) > 0 {
+			return filepath.Dir(files[0])
+		}
+	}
+	if p.Module != nil && p.Module.Dir != "" {
+		return filepath.Join(p.Module.Dir, filepath.FromSlash(strings.TrimPrefix(p.PkgPath, p.Module.Path)))
+	}
+	return ""
+}
+
+// matchPackages returns the import paths of the packages that a pattern
+// (e.g. "./cmd/...") or a relative path matches, or a ListError wrapping
+// ErrPackageNotFound if it matches none.
+func matchPackages(pattern string, opts *Options) ([]string, error) {
+	cfg := packagesConfig(opts, opts.Dir, packages.NeedName)
+	pkgs, err := loadWithRetries(listTimeout(opts), cfg, []string{pattern})
+	if err != nil {
+		return nil, err
+	}
+	var matched []string
+	for _, p := range pkgs {
+		matched = append(matched, p.PkgPath)
+	}
+	if len(matched) == 0 {
+		return nil, &ListError{
+			Command: "go list -e -json " + pattern,
+			Stderr:  fmt.Sprintf("%q matched no packages", pattern),
+			Err:     ErrPackageNotFound,
+		}
+	}
+	return matched, nil
+}
+
+// PackageForFile returns the import path of the package that the given Go
+// source file belongs to.
+func PackageForFile(filename string, opts Options) (string, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filename); err != nil {
+		return "", err
+	}
+
+	cfg := packagesConfig(&opts, filepath.Dir(filename), packages.NeedName)
+	pkgs, err := loadWithRetries(listTimeout(&opts), cfg, []string{"."})
+	if err != nil {
+		return "", err
+	}
+	if len(pkgs) == 0 {
+		return "", fmt.Errorf("%v isn't in a package", filename)
+	}
+	return pkgs[0].PkgPath, nil
+}
+
+// listModulePackages lists every package in the module that the graph's
+// packages are in, with their tests' files.
+func listModulePackages(p *pkg, opts *Options) ([]modulePkg, error) {
+	return loadModulePackages(p, opts, packages.NeedName|packages.NeedFiles, nil)
+}
+
+// checkModulePackages loads every package in the module that the graph's
+// packages are in, with their tests, parsed and type-checked into fset.
+func checkModulePackages(p *pkg, fset *token.FileSet, opts *Options) ([]modulePkg, error) {
+	mode := packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps |
+		packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax
+	return loadModulePackages(p, opts, mode, fset)
+}
+
+// loadModulePackages loads every package in the module that the graph's
+// packages are in, in mode. The graph's root isn't necessarily a package
+// itself, e.g. when a pattern is graphed, so the module is found from the
+// first of its packages that has types in the graph. Each package is loaded
+// with its own tests, if it has any, and its external tests as another
+// package. The packages' functions' bodies are parsed, but not those of the
+// packages outside the module that they depend on.
+func loadModulePackages(p *pkg, opts *Options, mode packages.LoadMode, fset *token.FileSet) ([]modulePkg, error) {
+	pkgName := p.rootPkgName
+	found := false
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if !found && node.typeObj != nil {
+			pkgName, found = p.pkgImportPath(pkgPath), true
+		}
+	})
+
+	timeout := listTimeout(opts)
+	pkgs, err := loadWithRetries(timeout, packagesConfig(opts, opts.Dir, packages.NeedName|packages.NeedModule), []string{pkgName})
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 || pkgs[0].Module == nil || pkgs[0].Module.Dir == "" {
+		return nil, fmt.Errorf("%v is not in a module", pkgName)
+	}
+	moduleDir := pkgs[0].Module.Dir
+
+	cfg := packagesConfig(opts, moduleDir, mode)
+	cfg.Tests = true
+	cfg.Fset = fset
+	cfg.ParseFile = func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+		return parseFile(fset, filename, src, inAnyDir(filename, []string{moduleDir}))
+	}
+	if pkgs, err = loadWithRetries(timeout, cfg, []string{"./..."}); err != nil {
+		return nil, err
+	}
+
+	// With Tests, a package with tests is also loaded with them, with an ID
+	// like "p [p.test]", and so are its external tests ("p_test
+	// [p.test]"), along with a main package to run them ("p.test"). The
+	// packages that have tests are only kept with them.
+	isTest := func(loaded *packages.Package) bool {
+		return strings.Contains(loaded.ID, " [")
+	}
+	tested := map[string]bool{}
+	for _, loaded := range pkgs {
+		if isTest(loaded) {
+			tested[loaded.PkgPath] = true
+		}
+	}
+	var modulePkgs []modulePkg
+	for _, loaded := range pkgs {
+		if strings.HasSuffix(loaded.ID, ".test") || (tested[loaded.PkgPath] && !isTest(loaded)) {
+			continue
+		}
+		mp := modulePkg{
+			importPath: loaded.PkgPath,
+			files:      loaded.GoFiles,
+			syntax:     loaded.Syntax,
+			types:      loaded.Types,
+			info:       loaded.TypesInfo,
+		}
+		if isTest(loaded) && strings.HasSuffix(loaded.PkgPath, "_test") {
+			mp.importPath, mp.xtest = strings.TrimSuffix(loaded.PkgPath, "_test"), true
+		}
+		modulePkgs = append(modulePkgs, mp)
+	}
+	return modulePkgs, nil
+}
+
+// loadImplementerPackages type-checks the packages matching the patterns,
+// without their functions' bodies, and returns them, along with every
+// package that they depend on by import path. If they don't depend on the
+// interface's package, it's loaded with them, so that its types are the
+// same as theirs.
+func loadImplementerPackages(ifacePkgPath string, patterns []string, opts *Options) ([]*types.Package, map[string]*types.Package, error) {
+	cfg := packagesConfig(opts, opts.Dir, packages.NeedName|packages.NeedImports|packages.NeedDeps|packages.NeedTypes|packages.NeedSyntax)
+	cfg.ParseFile = func(fset *token.FileSet, filename string, src []byte) (*ast.File, error) {
+		return parseFile(fset, filename, src, false)
+	}
+
+	toLoad := patterns
+	for {
+		pkgs, err := loadWithRetries(listTimeout(opts), cfg, toLoad)
+		if err != nil {
+			return nil, nil, err
+		}
+		all := map[string]*types.Package{}
+		packages.Visit(pkgs, nil, func(p *packages.Package) {
+			if p.Types != nil {
+				all[p.PkgPath] = p.Types
+			}
+		})
+		if _, ok := all[ifacePkgPath]; !ok && len(toLoad) == len(patterns) {
+			toLoad = append(patterns[:len(patterns):len(patterns)], ifacePkgPath)
+			continue
+		}
+
+		var matched []*types.Package
+		for _, p := range pkgs {
+			// The interface's package is only one of them if it matched.
+			if p.Types == nil || (len(toLoad) > len(patterns) && p.PkgPath == ifacePkgPath) {
+				continue
+			}
+			matched = append(matched, p.Types)
+		}
+		return matched, all, nil
+	}
+}
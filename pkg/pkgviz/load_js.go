@@ -0,0 +1,70 @@
+//go:build js
+// +build js
+
+package pkgviz
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// There is no go tool for go/packages to run under js/wasm, so packages can
+// only be graphed from in-memory files there.
+func errNoGoTool(pkgNames []string, opts *Options) error {
+	return &ListError{
+		Command: "go list -e -json -deps " + strings.Join(pkgNames, " "),
+		Stderr:  fmt.Sprintf("cannot list %v in %q: go list is not available on js", pkgNames, opts.Dir),
+		Err:     fmt.Errorf("%w: use BuildGraphFromFiles instead", ErrToolchainMissing),
+	}
+}
+
+// A pkgLoader would load packages with go/packages, which runs the go tool.
+type pkgLoader struct {
+	fset *token.FileSet
+	opts *Options
+}
+
+func newPkgLoader(fset *token.FileSet, opts *Options) *pkgLoader {
+	return &pkgLoader{fset: fset, opts: opts}
+}
+
+func (l *pkgLoader) load(pkgNames ...string) error {
+	if len(pkgNames) == 0 {
+		return nil
+	}
+	return errNoGoTool(pkgNames, l.opts)
+}
+
+func (l *pkgLoader) get(pkgName string) (*loadedPkg, error) {
+	return nil, l.load(pkgName)
+}
+
+func matchPackages(pattern string, opts *Options) ([]string, error) {
+	return nil, errNoGoTool([]string{pattern}, opts)
+}
+
+// PackageForFile returns the import path of the package that the given Go
+// source file belongs to.
+func PackageForFile(filename string, opts Options) (string, error) {
+	return "", fmt.Errorf("cannot find the package of %v: go list is not available on js", filename)
+}
+
+func listModulePackages(p *pkg, opts *Options) ([]modulePkg, error) {
+	return nil, errNoGoTool([]string{p.rootPkgName}, opts)
+}
+
+func checkModulePackages(p *pkg, fset *token.FileSet, opts *Options) ([]modulePkg, error) {
+	return nil, errNoGoTool([]string{p.rootPkgName}, opts)
+}
+
+func loadImplementerPackages(ifacePkgPath string, patterns []string, opts *Options) ([]*types.Package, map[string]*types.Package, error) {
+	return nil, nil, errNoGoTool(append(patterns, ifacePkgPath), opts)
+}
+
+// There is no git to shell out to either, so types can't be annotated with
+// their history.
+func annotateBlame(p *pkg, opts *Options) {}
+
+func annotateChurn(p *pkg, opts *Options) {}
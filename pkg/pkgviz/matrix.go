@@ -0,0 +1,71 @@
+package pkgviz
+
+import "sort"
+
+// A DependencyMatrix counts the references between the types of each pair
+// of packages in a graph, to quantify how coupled areas of a codebase are.
+type DependencyMatrix struct {
+	// Package is the graphed package's import path.
+	Package string `json:"package"`
+	// Packages are the import paths of the graph's packages that have types,
+	// sorted.
+	Packages []string `json:"packages"`
+	// References[i][j] is how many references there are from the types in
+	// Packages[i] to the types in Packages[j]. References within a package
+	// aren't counted.
+	References [][]int `json:"references"`
+}
+
+// Count returns how many references there are from the types in one package
+// to the types in another.
+func (m DependencyMatrix) Count(from, to string) int {
+	i := sort.SearchStrings(m.Packages, from)
+	j := sort.SearchStrings(m.Packages, to)
+	if i == len(m.Packages) || m.Packages[i] != from || j == len(m.Packages) || m.Packages[j] != to {
+		return 0
+	}
+	return m.References[i][j]
+}
+
+// DependencyMatrix counts the references between the types of each pair of
+// packages in the graph. References to types outside of the graph aren't
+// counted.
+func (p *pkg) DependencyMatrix() DependencyMatrix {
+	records := p.Records()
+	matrix := DependencyMatrix{Package: records.Package}
+
+	// The packages of the graph's types, by their ids.
+	pkgs := map[string]string{}
+	index := map[string]int{}
+	for _, node := range records.Nodes {
+		if node.Kind == "external" {
+			continue
+		}
+		pkgs[node.ID] = node.Package
+		if _, ok := index[node.Package]; !ok {
+			index[node.Package] = -1
+			matrix.Packages = append(matrix.Packages, node.Package)
+		}
+	}
+	sort.Strings(matrix.Packages)
+	for i, pkgName := range matrix.Packages {
+		index[pkgName] = i
+	}
+
+	matrix.References = make([][]int, len(matrix.Packages))
+	for i := range matrix.References {
+		matrix.References[i] = make([]int, len(matrix.Packages))
+	}
+	for _, edge := range records.Edges {
+		from, ok := pkgs[edge.From]
+		if !ok {
+			continue
+		}
+		to, ok := pkgs[edge.To]
+		if !ok || from == to {
+			continue
+		}
+		matrix.References[index[from]][index[to]]++
+	}
+	return matrix
+}
@@ -0,0 +1,40 @@
+package pkgviz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// tooDeep returns whether a package is more than maxDepth levels of
+// subpackages below the graphed package, if maxDepth is set. Packages
+// outside of the graphed package aren't.
+func tooDeep(importPath, rootPkgName string, maxDepth int) bool {
+	if maxDepth <= 0 {
+		return false
+	}
+	relPath := relativePkgPath(importPath, rootPkgName)
+	if relPath == "" || (relPath == importPath && rootPkgName != "") {
+		return false
+	}
+	return strings.Count(relPath, "/")+1 > maxDepth
+}
+
+// noteTooDeep notes the subpackages that weren't graphed because they're
+// too deep in the cluster of their ancestor at maxDepth, e.g. that
+// example.com/foo/a has a/b/c and a/d, which aren't graphed, with a
+// maxDepth of 1.
+func noteTooDeep(p *pkg, built map[string]bool, rootPkgName string, maxDepth int) {
+	skipped := map[string][]string{}
+	for importPath := range built {
+		if tooDeep(importPath, rootPkgName, maxDepth) {
+			parts := strings.Split(relativePkgPath(importPath, rootPkgName), "/")
+			ancestor := strings.Join(parts[:maxDepth], "/")
+			skipped[ancestor] = append(skipped[ancestor], strings.Join(parts[maxDepth-1:], "/"))
+		}
+	}
+	for ancestor, subPkgs := range skipped {
+		sort.Strings(subPkgs)
+		addNote(p, ancestor, fmt.Sprintf("has %d subpackage(s) deeper than the maximum depth of %d, which aren't graphed: %s", len(subPkgs), maxDepth, strings.Join(subPkgs, ", ")))
+	}
+}
@@ -0,0 +1,75 @@
+package pkgviz
+
+import (
+	"go/types"
+	"sync"
+)
+
+// The same types' strings and IDs are worked out over and over while a
+// graph is built and written, e.g. for every field of type string, or every
+// arrow to a type, so they're memoized. This also interns the IDs: every
+// node, field and arrow that refers to a type shares one copy of its ID.
+
+// memoLimit is how many entries a memo keeps before it's cleared, so that a
+// long-running process (e.g. the server, or watch mode) doesn't keep those
+// of every graph it's ever built.
+const memoLimit = 1 << 16
+
+type labelKey struct {
+	pkgName, typeName string
+}
+
+// labels memoizes labelizeName.
+var labels = struct {
+	sync.Mutex
+	m map[labelKey]string
+}{m: map[labelKey]string{}}
+
+// labelizeName turns a type string into a graphviz-friendly ID, e.g.
+// `func(interface{}, uintptr)` => func_lparens_interface_braces__comma_uintptr_rparens_
+// (see makeLabel).
+func labelizeName(pkgName, typeName string) string {
+	key := labelKey{pkgName, typeName}
+	labels.Lock()
+	defer labels.Unlock()
+	label, ok := labels.m[key]
+	if !ok {
+		if len(labels.m) >= memoLimit {
+			labels.m = map[labelKey]string{}
+		}
+		label = makeLabel(pkgName, typeName)
+		labels.m[key] = label
+	}
+	return label
+}
+
+// typeStringKey is a type, and the package it's written in.
+type typeStringKey struct {
+	t    types.Type
+	from *types.Package
+}
+
+// typeStrings memoizes typeString, by the types' identity. Named and basic
+// types are unique, so e.g. all of the fields of type string or time.Time
+// share one string.
+var typeStrings = struct {
+	sync.Mutex
+	m map[typeStringKey]string
+}{m: map[typeStringKey]string{}}
+
+// typeString returns t as it's written in the package from: its types
+// unqualified, and other packages' qualified by their import paths.
+func typeString(t types.Type, from *types.Package) string {
+	key := typeStringKey{t, from}
+	typeStrings.Lock()
+	defer typeStrings.Unlock()
+	s, ok := typeStrings.m[key]
+	if !ok {
+		if len(typeStrings.m) >= memoLimit {
+			typeStrings.m = map[typeStringKey]string{}
+		}
+		s = types.TypeString(t, types.RelativeTo(from))
+		typeStrings.m[key] = s
+	}
+	return s
+}
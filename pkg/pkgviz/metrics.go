@@ -0,0 +1,177 @@
+package pkgviz
+
+import (
+	"math"
+	"sort"
+)
+
+// Metrics are coupling metrics of a graph's types and packages, computed
+// from the references between them, so that teams can track how coupled a
+// package is over time.
+type Metrics struct {
+	// Package is the graphed package's import path.
+	Package  string           `json:"package"`
+	Packages []PackageMetrics `json:"packages"`
+	Types    []TypeMetrics    `json:"types"`
+}
+
+// TypeMetrics are the coupling metrics of a type.
+type TypeMetrics struct {
+	ID      string `json:"id"` // the type's NodeRecord ID
+	Package string `json:"package"`
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	// FanIn is how many other types refer to the type, and FanOut is how
+	// many other types it refers to (including types outside of the graph).
+	FanIn  int `json:"fanIn"`
+	FanOut int `json:"fanOut"`
+	// Instability is FanOut / (FanIn + FanOut), from 0 for a type that
+	// only others depend on, to 1 for one that only depends on others.
+	Instability float64 `json:"instability"`
+}
+
+// PackageMetrics are the coupling metrics of a package, as defined by
+// Robert C. Martin.
+type PackageMetrics struct {
+	Package string `json:"package"`
+	// Types is how many types the package declares, and Abstract is how many
+	// of them are interfaces.
+	Types    int `json:"types"`
+	Abstract int `json:"abstract"`
+	// FanIn (afferent coupling) is how many types outside of the package
+	// refer to its types, and FanOut (efferent coupling) is how many of its
+	// types refer to types outside of it.
+	FanIn  int `json:"fanIn"`
+	FanOut int `json:"fanOut"`
+	// Instability is FanOut / (FanIn + FanOut).
+	Instability float64 `json:"instability"`
+	// Abstractness is Abstract / Types.
+	Abstractness float64 `json:"abstractness"`
+	// Distance is how far the package is from the "main sequence" of
+	// packages that balance abstractness and stability, |A + I - 1|.
+	Distance float64 `json:"distance"`
+}
+
+// The metrics that nodes can be sized by (see Options.SizeBy).
+const (
+	MetricFanIn  = "fan-in"
+	MetricFanOut = "fan-out"
+)
+
+// The sizes of the types' names, when they're sized by a metric.
+const (
+	minMetricFontSize = 14
+	maxMetricFontSize = 32
+)
+
+// Metrics computes the coupling metrics of the graph's types and packages,
+// sorted by ID and import path. Types outside of the graph are only counted
+// in the metrics of the types that refer to them.
+func (p *pkg) Metrics() Metrics {
+	records := p.Records()
+	metrics := Metrics{Package: records.Package}
+
+	nodes := map[string]NodeRecord{}
+	for _, node := range records.Nodes {
+		nodes[node.ID] = node
+	}
+
+	typeFanIn := map[string]map[string]bool{}
+	typeFanOut := map[string]map[string]bool{}
+	pkgFanIn := map[string]map[string]bool{}
+	pkgFanOut := map[string]map[string]bool{}
+	addTo := func(sets map[string]map[string]bool, key, value string) {
+		if sets[key] == nil {
+			sets[key] = map[string]bool{}
+		}
+		sets[key][value] = true
+	}
+	for _, edge := range records.Edges {
+		if edge.From == edge.To {
+			continue
+		}
+		addTo(typeFanOut, edge.From, edge.To)
+		addTo(typeFanIn, edge.To, edge.From)
+		if from, to := nodes[edge.From], nodes[edge.To]; from.Package != to.Package {
+			addTo(pkgFanOut, from.Package, edge.From)
+			addTo(pkgFanIn, to.Package, edge.From)
+		}
+	}
+
+	pkgs := map[string]*PackageMetrics{}
+	for _, node := range records.Nodes {
+		if node.Kind == "external" {
+			continue
+		}
+		t := TypeMetrics{
+			ID:      node.ID,
+			Package: node.Package,
+			Name:    node.Name,
+			Kind:    node.Kind,
+			FanIn:   len(typeFanIn[node.ID]),
+			FanOut:  len(typeFanOut[node.ID]),
+		}
+		t.Instability = ratio(t.FanOut, t.FanIn+t.FanOut)
+		metrics.Types = append(metrics.Types, t)
+
+		pkg := pkgs[node.Package]
+		if pkg == nil {
+			pkg = &PackageMetrics{
+				Package: node.Package,
+				FanIn:   len(pkgFanIn[node.Package]),
+				FanOut:  len(pkgFanOut[node.Package]),
+			}
+			pkgs[node.Package] = pkg
+		}
+		pkg.Types++
+		if node.Kind == "interface" {
+			pkg.Abstract++
+		}
+	}
+
+	for _, pkg := range pkgs {
+		pkg.Instability = ratio(pkg.FanOut, pkg.FanIn+pkg.FanOut)
+		pkg.Abstractness = ratio(pkg.Abstract, pkg.Types)
+		pkg.Distance = math.Abs(pkg.Abstractness + pkg.Instability - 1)
+		metrics.Packages = append(metrics.Packages, *pkg)
+	}
+	sort.Slice(metrics.Packages, func(i, j int) bool {
+		return metrics.Packages[i].Package < metrics.Packages[j].Package
+	})
+	return metrics
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// sizeNodes scales the names of the types by the given metric, so that
+// e.g. the types that the most others depend on stand out. Unknown metrics
+// are ignored.
+func sizeNodes(p *pkg, metric string) {
+	values := map[string]int{}
+	max := 0
+	for _, t := range p.Metrics().Types {
+		switch metric {
+		case MetricFanIn:
+			values[t.ID] = t.FanIn
+		case MetricFanOut:
+			values[t.ID] = t.FanOut
+		}
+		if values[t.ID] > max {
+			max = values[t.ID]
+		}
+	}
+	if max == 0 {
+		return
+	}
+
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj != nil {
+			node.nameFontSize = minMetricFontSize + (maxMetricFontSize-minMetricFontSize)*values[p.nodeID(pkgPath, node)]/max
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package pkgviz
+
+import (
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// A Graph is the analysis of a built graph: its types, with their fields,
+// methods and constants, and the references between them, for tools that
+// consume the analysis rather than the dot graph. It has the same nodes and
+// edges as the graph's Records.
+type Graph struct {
+	// Package is the graphed package's import path.
+	Package string `json:"package"`
+	Nodes   []Node `json:"nodes"` // sorted by ID
+	Edges   []Edge `json:"edges"` // sorted by From and Field
+}
+
+// A Node is a type in a graph.
+type Node struct {
+	// ID is the type's package and name, e.g. "net/http.Client", as in its
+	// NodeRecord.
+	ID      string `json:"id"`
+	Package string `json:"package"`
+	Name    string `json:"name"`
+	// Kind is e.g. "struct", "interface", "basic", "slice", "array", "map",
+	// "chan", "pointer" or "signature", or "external" for types that are
+	// referred to from outside the graphed packages, of which only the ID,
+	// package and name are known.
+	Kind string `json:"kind"`
+	// Underlying is the type's underlying type, for kinds other than
+	// structs and interfaces, e.g. "int" or "map[string]*Item".
+	Underlying string `json:"underlying,omitempty"`
+	// TypeParams are a generic type's type parameters and their
+	// constraints, e.g. "[K comparable, V any]".
+	TypeParams string `json:"typeParams,omitempty"`
+	// Terms are the types that a constraint interface is restricted to,
+	// e.g. "~int | ~float64".
+	Terms string `json:"terms,omitempty"`
+	// Fields are a struct's fields, in the order they're declared.
+	Fields []Field `json:"fields,omitempty"`
+	// Methods are an interface's methods, sorted by name.
+	Methods []Method `json:"methods,omitempty"`
+	// Constants are the package's constants of a basic type, in the order
+	// they're declared.
+	Constants []Constant `json:"constants,omitempty"`
+	// Position is where the type's declaration (including its doc comment)
+	// starts, and EndLine the line it ends on, if known.
+	Position token.Position `json:"position"`
+	EndLine  int            `json:"endLine,omitempty"`
+	// Synopsis is the first sentence of the type's doc comment, if it has
+	// one, and the Options' DocSummaries was set.
+	Synopsis string `json:"synopsis,omitempty"`
+}
+
+// A Field is a field of a struct.
+type Field struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // e.g. "*Item", or "time.Time"
+}
+
+// A Method is a method of an interface.
+type Method struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // its signature, e.g. "func(key string) error"
+}
+
+// A Constant is a constant of a basic type.
+type Constant struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// An Edge is a reference from a struct's field to another type, from an
+// interface to an interface that it embeds, or from a container type (e.g.
+// a slice type) to the type of its elements, with no field.
+type Edge struct {
+	From     string `json:"from"` // the struct's node ID
+	Field    string `json:"field"`
+	To       string `json:"to"` // the field type's node ID
+	Embedded bool   `json:"embedded,omitempty"`
+}
+
+// Model returns the analysis of the graph: its types and the references
+// between them.
+func (p *pkg) Model() *Graph {
+	records := p.Records()
+	nodesById := map[string]*graphNode{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj != nil {
+			nodesById[p.nodeID(pkgPath, node)] = node
+		}
+	})
+
+	g := &Graph{Package: records.Package}
+	for _, record := range records.Nodes {
+		n := Node{ID: record.ID, Package: record.Package, Name: record.Name, Kind: record.Kind}
+		if node, ok := nodesById[record.ID]; ok {
+			fillNode(&n, node)
+		}
+		g.Nodes = append(g.Nodes, n)
+	}
+	for _, record := range records.Edges {
+		g.Edges = append(g.Edges, Edge{From: record.From, Field: record.Field, To: record.To, Embedded: record.Embedded})
+	}
+	return g
+}
+
+// fillNode copies what's known of a graphed type into its Node.
+func fillNode(n *Node, node *graphNode) {
+	n.Position, n.EndLine, n.Synopsis = node.position, node.endLine, node.synopsis
+	n.TypeParams, n.Terms = node.typeParams, node.typeTerms
+
+	switch node.typeType {
+	case "basic", "slice", "array", "pointer", "chan", "unknown":
+		n.Underlying = node.typeUnderlyingType
+	case "map":
+		n.Underlying = node.typeMapType
+	case "signature":
+		n.Underlying = typeString(node.typeObj.Type().Underlying(), node.typeObj.Pkg())
+	}
+
+	if s, ok := node.typeObj.Type().Underlying().(*types.Struct); ok {
+		for i := 0; i < s.NumFields(); i++ {
+			if field, ok := node.typeStructFields[s.Field(i).Name()]; ok {
+				n.Fields = append(n.Fields, Field{Name: s.Field(i).Name(), Type: field.structFieldTypeName})
+			}
+		}
+	}
+	for name, methodType := range node.typeInterfaceMethods {
+		n.Methods = append(n.Methods, Method{Name: name, Type: methodType})
+	}
+	sort.Slice(n.Methods, func(i, j int) bool {
+		return n.Methods[i].Name < n.Methods[j].Name
+	})
+	for _, c := range node.typeConstants {
+		n.Constants = append(n.Constants, Constant{Name: c.name, Value: c.value})
+	}
+}
+
+// Node returns the node with the given ID, if there is one.
+func (g *Graph) Node(id string) (*Node, bool) {
+	i := sort.Search(len(g.Nodes), func(i int) bool {
+		return g.Nodes[i].ID >= id
+	})
+	if i < len(g.Nodes) && g.Nodes[i].ID == id {
+		return &g.Nodes[i], true
+	}
+	return nil, false
+}
+
+// EdgesFrom returns the references from the fields of the struct with the
+// given ID, sorted by field.
+func (g *Graph) EdgesFrom(id string) []Edge {
+	var edges []Edge
+	for _, edge := range g.Edges {
+		if edge.From == id {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// EdgesTo returns the references to the type with the given ID, sorted by
+// the struct that they're from and its field.
+func (g *Graph) EdgesTo(id string) []Edge {
+	var edges []Edge
+	for _, edge := range g.Edges {
+		if edge.To == id {
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
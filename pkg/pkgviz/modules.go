@@ -0,0 +1,117 @@
+package pkgviz
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Module is a Go module in a repository, found by FindModules.
+type Module struct {
+	Path string `json:"path"` // e.g. "example.com/mono/api"
+	Dir  string `json:"dir"`
+}
+
+// FindModules walks the directory for go.mod files, and returns the modules
+// that they declare, sorted by path. Like the go tool, it skips vendor and
+// testdata directories, and those whose names start with "." or "_".
+func FindModules(root string) ([]Module, error) {
+	var modules []Module
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if path != root && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() != "go.mod" {
+			return nil
+		}
+		modulePath, err := readModulePath(path)
+		if err != nil {
+			return err
+		}
+		if modulePath != "" {
+			modules = append(modules, Module{Path: modulePath, Dir: filepath.Dir(path)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(modules, func(i, j int) bool {
+		return modules[i].Path < modules[j].Path
+	})
+	return modules, nil
+}
+
+// readModulePath returns the module path that a go.mod file declares, or ""
+// if it doesn't declare one.
+func readModulePath(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "module" {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(fields[1]); err == nil {
+			return unquoted, nil
+		}
+		return fields[1], nil
+	}
+	return "", scanner.Err()
+}
+
+// GraphForModules builds one graph of every package in the modules, e.g. all
+// of a monorepo's (see FindModules), with a cluster per module. Each module's
+// packages are listed from its own directory, so that they're resolved with
+// its own dependencies. Options that look at a whole module, like Harness,
+// look at the one in opts.Dir, if any.
+func GraphForModules(modules []Module, opts Options) (*pkg, error) {
+	var modulePaths []string
+	var toBuild []pkgsToBuild
+	for _, module := range modules {
+		listOpts := opts
+		listOpts.Dir = module.Dir
+		// A module with no packages, e.g. one of tools, is graphed empty.
+		pkgNames, err := matchPackages("./...", &listOpts)
+		if err != nil && !errors.Is(err, ErrPackageNotFound) {
+			return nil, err
+		}
+		modulePaths = append(modulePaths, module.Path)
+		toBuild = append(toBuild, pkgsToBuild{dir: module.Dir, module: module.Path, pkgNames: pkgNames})
+	}
+
+	rootPkgName := commonPkgPrefix(modulePaths)
+	return buildGraph(rootPkgName, rootPkgName, toBuild, opts)
+}
+
+// markModule marks the subpackage at pkgPath (relative to the graphed
+// package) as the root of the module, if the graph has it.
+func markModule(p *pkg, pkgPath, module string) {
+	currentp := p
+	for _, currentPart := range strings.Split(pkgPath, "/") {
+		if currentp = currentp.subPkgs[currentPart]; currentp == nil {
+			return
+		}
+	}
+	currentp.module = module
+}
@@ -0,0 +1,121 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/types"
+)
+
+// noCopyColor is the color of the border of types that mustn't be copied,
+// and of the arrows from the fields that hold them by value.
+const noCopyColor = "#8e44ad"
+
+// The types in the sync package that mustn't be copied after they're first
+// used. Every type in sync/atomic mustn't be, either.
+var syncNoCopyTypes = map[string]bool{
+	"Cond":      true,
+	"Map":       true,
+	"Mutex":     true,
+	"Once":      true,
+	"Pool":      true,
+	"RWMutex":   true,
+	"WaitGroup": true,
+}
+
+// markNoCopy badges the types that contain a value that mustn't be copied,
+// like a sync.Mutex, a sync.WaitGroup or an atomic value, directly or in an
+// embedded or nested struct, as "no-copy", along with the arrows from the
+// fields that hold them by value, which are copied whenever their struct is.
+func markNoCopy(p *pkg) {
+	nodes := map[string]*graphNode{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		nodes[node.typeId] = node
+		if node.typeObj == nil {
+			return
+		}
+		if reason := noCopyReason(node.typeObj.Type(), map[types.Type]bool{}); reason != "" {
+			if node.annotations == nil {
+				node.annotations = map[string]string{}
+			}
+			node.annotations["nocopy"] = fmt.Sprintf("no-copy (%s)", reason)
+			node.borderColor = noCopyColor
+		}
+	})
+
+	for i, nodeLink := range p.nodeLinks {
+		node, ok := nodes[nodeLink.fromStructTypeId]
+		if !ok || node.typeObj == nil {
+			continue
+		}
+		s, ok := node.typeObj.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		for j := 0; j < s.NumFields(); j++ {
+			field := s.Field(j)
+			if field.Name() != nodeLink.fromStructFieldName {
+				continue
+			}
+			if noCopyReason(field.Type(), map[types.Type]bool{}) != "" {
+				p.nodeLinks[i].color = noCopyColor
+				p.nodeLinks[i].style = "bold"
+				p.nodeLinks[i].label = "by value"
+			}
+		}
+	}
+}
+
+// noCopyReason returns the type that makes values of t unsafe to copy (e.g.
+// "sync.Mutex"), or "" if they can be. Pointers, slices, maps and channels
+// can be copied whatever they point to; arrays and structs can't be if their
+// elements or fields can't. Like go vet's copylocks check, types whose
+// pointers have Lock and Unlock methods (e.g. a noCopy sentinel) can't be
+// copied either.
+func noCopyReason(t types.Type, seen map[types.Type]bool) string {
+	if seen[t] {
+		return ""
+	}
+	seen[t] = true
+
+	switch t := t.(type) {
+	case *types.Named:
+		if pkg := t.Obj().Pkg(); pkg != nil {
+			if pkg.Path() == "sync" && syncNoCopyTypes[t.Obj().Name()] {
+				return "sync." + t.Obj().Name()
+			}
+			if pkg.Path() == "sync/atomic" && t.Obj().Exported() {
+				return "atomic." + t.Obj().Name()
+			}
+		}
+		if reason := noCopyReason(t.Underlying(), seen); reason != "" {
+			return reason
+		}
+		if _, ok := t.Underlying().(*types.Struct); ok && hasLockMethods(t) {
+			return t.Obj().Name()
+		}
+	case *types.Array:
+		return noCopyReason(t.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if reason := noCopyReason(t.Field(i).Type(), seen); reason != "" {
+				return reason
+			}
+		}
+	}
+	return ""
+}
+
+// hasLockMethods returns whether a pointer to the type has Lock and Unlock
+// methods.
+func hasLockMethods(t *types.Named) bool {
+	methods := types.NewMethodSet(types.NewPointer(t))
+	for _, name := range []string{"Lock", "Unlock"} {
+		selection := methods.Lookup(nil, name)
+		if selection == nil {
+			return false
+		}
+		if sig, ok := selection.Type().(*types.Signature); !ok || sig.Params().Len() != 0 || sig.Results().Len() != 0 {
+			return false
+		}
+	}
+	return true
+}
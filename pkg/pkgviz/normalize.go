@@ -0,0 +1,45 @@
+package pkgviz
+
+import "strings"
+
+// How go/types spells a type changes between Go releases, e.g. the empty
+// interface is "interface{}" in some and "any" in others, which would make
+// the diagrams committed to a repository change with every toolchain
+// upgrade, though the types haven't. With the Options' NormalizeTypes, the
+// types are drawn with the spellings in typeStringReplacer instead.
+
+// typeStringReplacer replaces the spellings of types that differ between
+// Go releases with one of them.
+var typeStringReplacer = strings.NewReplacer(
+	"interface {}", "any",
+	"interface{}", "any",
+	"interface {", "interface{",
+	"struct {", "struct{",
+)
+
+// normalizeTypeString returns a type string spelled the same way whichever
+// Go release it's from, e.g. "map[string]interface{}" => "map[string]any".
+func normalizeTypeString(s string) string {
+	return typeStringReplacer.Replace(s)
+}
+
+// normalizeTypeStrings spells the types that the graph's nodes show (their
+// underlying types, fields' types and methods' signatures) the same way
+// whichever Go release they're from.
+func normalizeTypeStrings(p *pkg) {
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		node.typeUnderlyingType = normalizeTypeString(node.typeUnderlyingType)
+		node.typeMapType = normalizeTypeString(node.typeMapType)
+		node.typeTerms = normalizeTypeString(node.typeTerms)
+		node.typeParams = normalizeTypeString(node.typeParams)
+		for i := range node.typeSignatureRows {
+			node.typeSignatureRows[i].typeName = normalizeTypeString(node.typeSignatureRows[i].typeName)
+		}
+		for _, field := range node.typeStructFields {
+			field.structFieldTypeName = normalizeTypeString(field.structFieldTypeName)
+		}
+		for name, methodType := range node.typeInterfaceMethods {
+			node.typeInterfaceMethods[name] = normalizeTypeString(methodType)
+		}
+	})
+}
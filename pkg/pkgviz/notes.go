@@ -0,0 +1,90 @@
+package pkgviz
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// addNote adds a note to a package of the graph (relative to the graphed
+// package), to explain something about it that its types can't, e.g. that
+// it only has tests.
+func addNote(p *pkg, pkgPath, note string) {
+	currentp := p
+	for _, currentPart := range strings.Split(pkgPath, "/") {
+		if currentp.subPkgs[currentPart] == nil {
+			currentp.subPkgs[currentPart] = &pkg{
+				pkgName:     currentPart,
+				rootPkgName: p.rootPkgName,
+				subPkgs:     map[string]*pkg{},
+				nodes:       map[string]*graphNode{},
+				nodeLinks:   []graphNodeLink{},
+			}
+		}
+		currentp = currentp.subPkgs[currentPart]
+	}
+	currentp.notes = append(currentp.notes, note)
+}
+
+// Notes returns the notes about the graph's packages, each prefixed with
+// its package's import path, e.g. "example.com/foo/bar only has tests
+// (bar_test.go), which aren't graphed", sorted by package.
+func (p *pkg) Notes() []string {
+	var notes []string
+	p.walkNotes("", func(pkgPath, note string) {
+		notes = append(notes, p.pkgImportPath(pkgPath)+" "+note)
+	})
+	return notes
+}
+
+// walkNotes calls fn with each note in the package and its subpackages,
+// sorted by package.
+func (p *pkg) walkNotes(pkgPath string, fn func(pkgPath, note string)) {
+	for _, note := range p.notes {
+		fn(pkgPath, note)
+	}
+	var subPkgNames []string
+	for subPkgName := range p.subPkgs {
+		subPkgNames = append(subPkgNames, subPkgName)
+	}
+	sort.Strings(subPkgNames)
+	for _, subPkgName := range subPkgNames {
+		subPkgPath := subPkgName
+		if pkgPath != "" && subPkgName != "" {
+			subPkgPath = pkgPath + "/" + subPkgName
+		} else if subPkgName == "" {
+			subPkgPath = pkgPath
+		}
+		p.subPkgs[subPkgName].walkNotes(subPkgPath, fn)
+	}
+}
+
+// writeNotes writes the package's notes to w, as note-shaped nodes.
+func (p *pkg) writeNotes(w io.Writer, pkgPath string, indentLevel int) {
+	for i, note := range p.notes {
+		fmt.Fprintf(w, "%s%s [shape=note fontsize=10 fontcolor=\"#7f8183\" color=\"#cccccc\" label=%s];\n",
+			strings.Repeat("  ", indentLevel),
+			labelizeName(pkgPath, fmt.Sprintf("_note%d", i)),
+			quoteString(note),
+		)
+	}
+}
+
+// noteIfEmpty adds a note to a graph that has no types at all (and no other
+// notes explaining why), since graphviz would otherwise draw it as a blank
+// image.
+func noteIfEmpty(p *pkg, opts *Options) {
+	empty := true
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		empty = false
+	})
+	if !empty || len(p.Notes()) > 0 {
+		return
+	}
+	if opts.Focus != "" || opts.FocusFile || opts.Harness == HarnessHide {
+		addNote(p, "", "has no named types that match the current filters")
+	} else {
+		addNote(p, "", "has no named types")
+	}
+}
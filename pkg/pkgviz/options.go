@@ -0,0 +1,195 @@
+package pkgviz
+
+import (
+	"regexp"
+	"time"
+)
+
+// Options configures how a graph is built. The zero value builds a graph of
+// the package as the go tool sees it from the current directory.
+type Options struct {
+	// Dir is the directory that the go tool is run in, which decides the
+	// module that package names are resolved in. If empty, the current
+	// directory is used.
+	Dir string
+
+	// Env is the environment that the go tool is run with. If nil, the
+	// current process's environment is used.
+	Env []string
+
+	// ListTimeout limits how long each run of the go tool may take, after
+	// which the build fails with ErrTimeout. If 0, DefaultListTimeout is
+	// used.
+	ListTimeout time.Duration
+
+	// Cache, if set, remembers the analysis of each package, so that later
+	// builds with the same Cache only re-analyze invalidated packages.
+	Cache *Cache
+
+	// MaxDepth, if set, limits how many levels of subpackages below the
+	// graphed package are graphed, e.g. 1 to graph example.com/foo/a but
+	// not example.com/foo/a/b. The subpackages that are left out are noted
+	// in their ancestor's cluster at the deepest level that's graphed.
+	MaxDepth int
+
+	// Exclude, if set, leaves out the packages whose import paths match it,
+	// and the types whose names, qualified by their packages' import paths
+	// (e.g. "example.com/foo/mocks.Store"), match it, along with the
+	// references to them, e.g. to hide mocks, generated code and test
+	// helpers.
+	Exclude *regexp.Regexp
+
+	// Focus, if set, limits the graph to the named type and the types within
+	// Hops references of it, in either direction. Types in subpackages are
+	// named relative to the graphed package, e.g. "nested.NestedStruct".
+	Focus string
+	Hops  int
+
+	// FocusFile limits a graph built by GraphForFile to the types declared
+	// in the file, and the types within Hops references of them.
+	FocusFile bool
+
+	// Blame annotates each type that's in a git repository with when it was
+	// last modified, and its owners from the repository's CODEOWNERS file or,
+	// if it has none, the author of most of its lines. If StaleAfter is set,
+	// types that haven't been modified for that long are grayed out.
+	Blame      bool
+	StaleAfter time.Duration
+
+	// ChurnWindow, if set, annotates each type that's in a git repository
+	// with how many commits changed it within the window, and colors it on a
+	// heat scale so that the most changed types stand out. This overrides
+	// the coloring of stale types.
+	ChurnWindow time.Duration
+
+	// HighlightCycles colors the types and packages that refer to each other
+	// in cycles, and the references between them, in red (see Cycles).
+	HighlightCycles bool
+
+	// SizeBy, if set, scales the names of the types by one of their
+	// coupling metrics (see Metrics): MetricFanIn or MetricFanOut.
+	SizeBy string
+
+	// ColorByDepth colors each type by its layer (see Depths), from the leaf
+	// types up, and the references that don't point down to a lower layer,
+	// i.e. that are part of a cycle, in red.
+	ColorByDepth bool
+
+	// WeightReferences draws one arrow from each type to each type it refers
+	// to, as thick as the number of its fields that refer to it, and
+	// annotates the types that many others refer to (hubs) with how many do.
+	WeightReferences bool
+
+	// Layout annotates each struct with its size in memory and how much of
+	// it is padding, and lists its fields in the order they're laid out in,
+	// with their offsets, sizes and the padding after them. LayoutArch is
+	// the architecture to lay them out for, e.g. "386", or if empty, the
+	// current one.
+	Layout     bool
+	LayoutArch string
+
+	// SizeBudgets, if set, colors the borders of the structs that are bigger
+	// than the budget of their package in red (see OversizedStructs), laid
+	// out for LayoutArch.
+	SizeBudgets []SizeBudget
+
+	// NoCopy badges the types that mustn't be copied, because they contain
+	// a sync.Mutex, sync.WaitGroup, atomic value or the like, directly or in
+	// an embedded or nested struct, and colors the arrows from the fields
+	// that hold them by value.
+	NoCopy bool
+
+	// ContextFields marks the struct fields that store a context.Context
+	// (see ContextFields), other than in the structs in
+	// ContextFieldsAllowed.
+	ContextFields        bool
+	ContextFieldsAllowed []string
+
+	// Vars adds the package-level variables whose types are in the graph,
+	// or pointers to them, as small nodes linked to their types, showing the
+	// global state and singletons that the types alone don't.
+	Vars bool
+
+	// Funcs adds the package-level functions, with their signatures, as
+	// small nodes linked to the types in the graph that they take or
+	// return, showing the API that the types alone don't.
+	Funcs bool
+
+	// ImplementsArrows draws a dashed arrow with an open triangle from each
+	// of the graph's concrete types to each of its interfaces that the type,
+	// or a pointer to it, implements.
+	ImplementsArrows bool
+
+	// DocCoverage marks the exported types that have no doc comment, or whose
+	// exported fields or methods have none, and the undocumented fields
+	// (see DocCoverage).
+	DocCoverage bool
+
+	// DocSummaries draws the first sentence of each type's doc comment
+	// under its name, in a muted font.
+	DocSummaries bool
+
+	// ShowPositions draws where each type is declared, e.g. "server.go:42",
+	// in a small row at the bottom of it.
+	ShowPositions bool
+
+	// Constructors lists the functions that construct each type under its
+	// name: the functions named New... or Must... that return it, or a
+	// pointer to it, as their first result.
+	Constructors bool
+
+	// Harness, if set, marks the types that are declared in main packages,
+	// or that only tests refer to: HarnessTag draws them in their own
+	// colors, annotated with why, and HarnessHide leaves them out.
+	Harness string
+
+	// Generated, if set, marks the types that tools like protoc-gen-go or
+	// mockgen generated (see GeneratedTypes): GeneratedTag annotates them
+	// with their generator, and GeneratedGroup collapses each package's
+	// generated types into a cluster of just their names.
+	Generated string
+
+	// DBSchema draws the structs that gorm or sqlx store in a database (that
+	// have fields tagged "gorm" or "db", or embed gorm.Model) as their
+	// tables: a row for each column, marked as a primary or foreign key,
+	// with the fields that refer to other models drawn as the relations
+	// between them, e.g. "has many".
+	DBSchema bool
+
+	// ProtoCorrespondence collapses the protobuf messages that protoc-gen-go
+	// generated into a cluster of their names, and draws the conversions
+	// between them and the hand-written types instead (see
+	// ProtoConversions), showing the boundary between the wire and the
+	// domain.
+	ProtoCorrespondence bool
+
+	// Layers, if set, draws the types of each layer in a row, from the top
+	// layer down, and colors the references from a layer to one above it
+	// in red (see LayerViolations).
+	Layers []Layer
+
+	// Intended, if set, marks how the graph differs from the architecture
+	// it's intended to have (see CompareIntended): the types and references
+	// that it has but aren't intended in green, and the intended ones that
+	// it doesn't have in red.
+	Intended *IntendedArchitecture
+
+	// MaxNodes and MaxEdges, if more than 0, are the most nodes and arrows
+	// that the graph is drawn with. Bigger graphs are drawn with less detail,
+	// in stages, until they fit: first with just the names of the types, and
+	// one arrow between each pair of them, then without basic types, and
+	// then with each subpackage collapsed into one node (see Elided).
+	MaxNodes int
+	MaxEdges int
+
+	// NormalizeTypes draws the types of fields, methods and the like spelled
+	// the same way whichever Go release built the graph, e.g. "any" rather
+	// than "interface{}", so that committed graphs don't change when the
+	// toolchain is upgraded.
+	NormalizeTypes bool
+
+	// CountMergedArrows labels each arrow that stands for several that would
+	// be drawn the same, e.g. from fields that aren't drawn themselves, with
+	// how many it stands for, e.g. "×3". They're drawn once either way.
+	CountMergedArrows bool
+}
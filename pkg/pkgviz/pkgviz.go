@@ -1,27 +1,40 @@
 package pkgviz
 
 import (
-	"encoding/json"
+	"bufio"
+	"bytes"
 	"fmt"
 	"go/ast"
-	"go/importer"
-	"go/parser"
 	"go/token"
 	"go/types"
-	"log"
-	"os"
-	"os/exec"
-	"path"
+	"io"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 )
 
-type goListResult struct {
-	Dir        string
-	ImportPath string
-	GoFiles    []string
-	Imports    []string
+// A modulePkg is a package in the module that the graph's packages are in,
+// with its own tests, or a package's external tests.
+type modulePkg struct {
+	importPath string   // for external tests, of the package they test
+	xtest      bool     // whether it's a package's external tests
+	files      []string // its Go files, including its tests'
+	syntax     []*ast.File
+	types      *types.Package
+	info       *types.Info
+}
+
+// A loadedPkg is a package to graph, parsed and type-checked.
+type loadedPkg struct {
+	importPath string
+	dir        string
+	files      []*ast.File
+	info       *types.Info
+	imports    []string // the import paths of its imports, sorted
+	testFiles  []string // if it only has tests, their file names
+	errors     []error  // errors parsing and type-checking it
 }
 
 type structField struct {
@@ -29,6 +42,22 @@ type structField struct {
 	structFieldTypeName string
 }
 
+// The most constants listed under a basic type.
+const maxConstantRows = 20
+
+// A constant of a basic type, e.g. StatusOK = 200.
+type typeConstant struct {
+	name  string
+	value string
+}
+
+// A signatureRow is a parameter or result of a func type.
+type signatureRow struct {
+	name     string // its name, if it has one
+	typeName string // its type, e.g. "*Item", or "...string" if it's variadic
+	result   bool
+}
+
 // A named type that was parsed, and will be represented in the graph.
 type graphNode struct {
 	pkgName              string
@@ -40,6 +69,33 @@ type graphNode struct {
 	typeNodes            map[string]*graphNode   // id -> node
 	typeStructFields     map[string]*structField // name -> node (of field type)
 	typeInterfaceMethods map[string]string       // name -> type
+	typeTerms            string                  // for constraints, the types they're restricted to, e.g. "~int | ~float64"
+	typeParams           string                  // for generic types, their type parameters, e.g. "[K comparable, V any]"
+	typeConstants        []typeConstant          // for basic types, the package's constants of the type
+	typeSignatureRows    []signatureRow          // for func types, their parameters and then their results
+	typeObj              types.Object            // the declared type
+	position             token.Position          // where the type's declaration starts
+	endLine              int                     // the line the type's declaration ends on
+	namePosition         token.Position          // where the type's name is declared
+	docs                 *typeDocs               // which of its exported parts are documented, if it's exported
+	synopsis             string                  // the first sentence of its doc comment, if it has one
+	doc                  string                  // its whole doc comment, if it has one, shown as its tooltip
+	generator            string                  // the tool that generated the type, if any, e.g. "protoc-gen-go"
+
+	subtitle     string            // e.g. its doc summary, shown under the name
+	footer       string            // e.g. where it's declared, shown under everything else
+	annotations  map[string]string // e.g. "blame" -> "modified 2020-01-02 by Jane", shown under the name
+	constructors []string          // e.g. "NewServer(addr string) *Server", shown under the annotations
+	headerColor  string            // overrides the default color behind the name
+	borderColor  string            // overrides the default color of the border
+	bgColor      string            // the color behind the whole type, if any
+	nameFontSize int               // overrides the default size of the name
+	fieldColors  map[string]string // struct field name -> the color behind its row
+	fieldNotes   map[string]string // struct field name -> e.g. its offset, shown after its type
+	fieldOrder   []string          // the order of the struct's fields, if not alphabetical
+	fieldLabels  map[string]string // struct field name -> what its row is labeled, if not its name
+	collapsed    bool              // whether only the name is drawn, in a cluster of generated types
+	fieldsHidden bool              // whether its fields, methods and constants are left out, to keep the graph small
 }
 
 // A reference (e.g. arrow) from one type to another.
@@ -48,6 +104,27 @@ type graphNodeLink struct {
 	fromStructFieldName string
 	toTypePkgName       string
 	toTypeName          string
+
+	color     string // overrides the default color of the arrow
+	style     string // e.g. "dashed"
+	arrowhead string // e.g. "empty"
+	weight    int    // how many fields the arrow stands for, if more than one
+	label     string // e.g. "has many"
+	dir       string // e.g. "both", if not from the struct to the type
+	ghost     bool   // whether it's drawn for a reference that no field makes, e.g. an intended one
+	embedded  bool   // whether it's from an embedded field, or from an interface to one it embeds
+
+	fromTypeObj types.Object // the struct whose field the arrow is from, if it is
+	toTypeObj   types.Object // the type that the field refers to, if it's from one
+	toTypeId    string       // the ID of the type it points to, if not the one its package and name label to (see disambiguateTypeIds)
+}
+
+// toId returns the ID of the type that the link points to.
+func (nodeLink graphNodeLink) toId() string {
+	if nodeLink.toTypeId != "" {
+		return nodeLink.toTypeId
+	}
+	return labelizeName(nodeLink.toTypePkgName, nodeLink.toTypeName)
 }
 
 // "pkg1" => {
@@ -63,102 +140,405 @@ type pkg struct {
 	subPkgs     map[string]*pkg
 	nodes       map[string]*graphNode
 	nodeLinks   []graphNodeLink
+
+	clusterColor string // overrides the default color of a subpackage's border
+	weightLinks  bool   // whether to print one weighted arrow per pair of types
+	layerRows    []layerRow
+	elided       []string    // what was left out to keep the graph small enough, if anything
+	oversize     *SizeReport // what made the graph too big, if it was
+	countArrows  bool        // whether to label arrows that stand for several identical ones with how many
+	notes        []string    // drawn as notes in the package's cluster, e.g. that it only has tests
+	module       string      // the path of the module that the subpackage is the root of, if it's one
+	collisions   []IDCollision
+	warnings     []Warning // what went wrong building the graph, if anything
+	renamedIds   map[typeKey]string // the IDs of the types whose IDs collided with others', if they were changed
 }
 
 func (p *pkg) Print(str string, pkgName string, indentLevel int, typeIdsPrinted map[string]bool) (string, map[string]bool) {
-	for _, node := range (*p).nodes {
-		str, typeIdsPrinted = node.Print(str, pkgName, indentLevel+1, typeIdsPrinted)
-	}
-	for subPkgName, subPkg := range (*p).subPkgs {
-		if len(subPkgName) > 0 {
-			str = fmt.Sprintf(
-				"%s%ssubgraph cluster_%v { \n",
-				str,
-				strings.Repeat("  ", indentLevel+1),
-				subPkgName,
-			)
-			str, typeIdsPrinted = subPkg.Print(str, "FIXME", indentLevel+1, typeIdsPrinted)
-			// subgraph config
-			str = fmt.Sprintf("%s%snode [style=filled];\n", str, strings.Repeat("  ", indentLevel+2))
-			str = fmt.Sprintf("%s%slabel=\"%s\";\n", str, strings.Repeat("  ", indentLevel+2), relativizeTypePkgName(subPkgName, pkgName))
-			str = fmt.Sprintf("%s%sgraph[style=dotted color=\"#7f8183\"];\n", str, strings.Repeat("  ", indentLevel+2))
+	var b strings.Builder
+	b.WriteString(str)
+	p.writeNodes(&b, pkgName, "", indentLevel, typeIdsPrinted, p.renderWarner())
+	return b.String(), typeIdsPrinted
+}
 
-			str = fmt.Sprintf("%s%s}\n", str, strings.Repeat("  ", indentLevel+1))
-		} else {
-			str, typeIdsPrinted = subPkg.Print(str, "FIXME", indentLevel, typeIdsPrinted)
+// writeNodes writes the package's nodes, and its subpackages' clusters, to
+// w, one node at a time. Nodes that can't be drawn as they are are drawn as
+// generic nodes, and passed to warn.
+func (p *pkg) writeNodes(w io.Writer, pkgName, pkgPath string, indentLevel int, typeIdsPrinted map[string]bool, warn func(pkgPath string, err error)) {
+	// Nodes are written sorted by name, so that the graph comes out the same
+	// every time.
+	var nodeNames []string
+	for nodeName := range p.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	var collapsed []*graphNode
+	for _, nodeName := range nodeNames {
+		node := p.nodes[nodeName]
+		if node.collapsed {
+			collapsed = append(collapsed, node)
+			continue
+		}
+		out, err := node.print("", pkgName, indentLevel+1, typeIdsPrinted)
+		if err != nil {
+			warn(pkgPath, err)
+		}
+		io.WriteString(w, out)
+	}
+	if len(collapsed) > 0 {
+		out, _ := printGeneratedCluster("", indentLevel, collapsed, typeIdsPrinted)
+		io.WriteString(w, out)
+	}
+	p.writeNotes(w, pkgPath, indentLevel+1)
+	// Each subpackage's cluster is written into a buffer of its own, all at
+	// once, and then the buffers are written to w sorted by the subpackages'
+	// names, so that the graph comes out the same every time.
+	var subPkgNames []string
+	for subPkgName := range p.subPkgs {
+		subPkgNames = append(subPkgNames, subPkgName)
+	}
+	sort.Strings(subPkgNames)
+	clusters := make([]bytes.Buffer, len(subPkgNames))
+	clusterTypeIdsPrinted := make([]map[string]bool, len(subPkgNames))
+	var wg sync.WaitGroup
+	for i, subPkgName := range subPkgNames {
+		clusterTypeIdsPrinted[i] = map[string]bool{}
+		wg.Add(1)
+		go func(i int, subPkgName string) {
+			defer wg.Done()
+			p.writeCluster(&clusters[i], pkgName, pkgPath, subPkgName, indentLevel, clusterTypeIdsPrinted[i], warn)
+		}(i, subPkgName)
+	}
+	wg.Wait()
+	for i := range clusters {
+		clusters[i].WriteTo(w)
+		for typeId := range clusterTypeIdsPrinted[i] {
+			typeIdsPrinted[typeId] = true
 		}
 	}
+}
+
+// writeCluster writes the subpackage's cluster, with its nodes and its own
+// subpackages' clusters, to w.
+func (p *pkg) writeCluster(w io.Writer, pkgName, pkgPath, subPkgName string, indentLevel int, typeIdsPrinted map[string]bool, warn func(pkgPath string, err error)) {
+	subPkg := p.subPkgs[subPkgName]
+	if len(subPkgName) == 0 {
+		subPkg.writeNodes(w, "FIXME", pkgPath, indentLevel, typeIdsPrinted, warn)
+		return
+	}
+	subPkgPath := subPkgName
+	if pkgPath != "" {
+		subPkgPath = pkgPath + "/" + subPkgName
+	}
+	// Clusters are named by their full path, since dot merges the
+	// ones with the same name (e.g. a/config and b/config).
+	fmt.Fprintf(w, "%ssubgraph cluster_%v { \n", strings.Repeat("  ", indentLevel+1), labelizeName("", subPkgPath))
+	subPkg.writeNodes(w, "FIXME", subPkgPath, indentLevel+1, typeIdsPrinted, warn)
+	// subgraph config
+	fmt.Fprintf(w, "%snode [style=filled];\n", strings.Repeat("  ", indentLevel+2))
+	label := clusterLabel(subPkgName, pkgName)
+	if subPkg.module != "" {
+		label = "module " + subPkg.module
+	}
+	fmt.Fprintf(w, "%slabel=%s;\n", strings.Repeat("  ", indentLevel+2), quoteString(label))
+	fmt.Fprintf(w, "%sgraph[%s];\n", strings.Repeat("  ", indentLevel+2), subPkg.clusterAttrs(subPkgName))
 
-	return str, typeIdsPrinted
+	fmt.Fprintf(w, "%s}\n", strings.Repeat("  ", indentLevel+1))
+}
+
+// clusterColorOrDefault returns the color of the subpackage's border.
+func (p *pkg) clusterColorOrDefault() string {
+	if p.clusterColor != "" {
+		return p.clusterColor
+	}
+	return "#7f8183"
+}
+
+// walkNodes calls fn with every node in the package and its subpackages,
+// sorted by package and then by name. The package path of a node is
+// relative to the graphed package.
+func (p *pkg) walkNodes(fn func(pkgPath string, node *graphNode)) {
+	p.walkNodesIn("", fn)
+}
+
+func (p *pkg) walkNodesIn(pkgPath string, fn func(pkgPath string, node *graphNode)) {
+	var nodeNames []string
+	for nodeName := range p.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	for _, nodeName := range nodeNames {
+		fn(pkgPath, p.nodes[nodeName])
+	}
+
+	var subPkgNames []string
+	for subPkgName := range p.subPkgs {
+		subPkgNames = append(subPkgNames, subPkgName)
+	}
+	sort.Strings(subPkgNames)
+	for _, subPkgName := range subPkgNames {
+		subPkgPath := subPkgName
+		if pkgPath != "" {
+			subPkgPath = pkgPath + "/" + subPkgName
+		}
+		p.subPkgs[subPkgName].walkNodesIn(subPkgPath, fn)
+	}
 }
 
 func (p *pkg) PrintHeader() string {
 	out := fmt.Sprintf("digraph V {\n"+
-		"  graph [label=< <br/><b>%s</b> >, labelloc=b, fontsize=10 fontname=Arial];\n"+
+		"  graph [label=< <br/><b>%s</b>%s >, labelloc=b, fontsize=10 fontname=Arial];\n"+
 		"  node [fontname=Arial];\n"+
 		"  edge [fontname=Arial];\n",
-		p.pkgName,
+		escapeHtml(p.pkgName),
+		p.printElided(),
 	)
 	return out
 }
 
+// printElided returns a line for each thing that was left out of the
+// graph, for its label.
+func (p *pkg) printElided() string {
+	out := ""
+	for _, elided := range p.elided {
+		out = fmt.Sprintf("%s<br/><font point-size='9' color='#7f8183'>%s</font>", out, escapeHtml(elided))
+	}
+	return out
+}
+
 func (p *pkg) PrintFooter(out string) string {
 	return fmt.Sprintf("%s}\n", out)
 }
 
 func (p *pkg) PrintNodeLinks(out string, typeIdsPrinted map[string]bool) string {
-	out = fmt.Sprintf("%s  /* node links: */\n", out)
-	for _, nodeLink := range p.nodeLinks {
-		toTypeId := labelizeName(nodeLink.toTypePkgName, nodeLink.toTypeName)
-		out = fmt.Sprintf(
-			"%s  %s:port_%s -> %s;\n",
-			out,
-			nodeLink.fromStructTypeId,
-			nodeLink.fromStructFieldName,
-			toTypeId,
-		)
+	var b strings.Builder
+	b.WriteString(out)
+	p.writeNodeLinks(&b, typeIdsPrinted)
+	return b.String()
+}
+
+// writeNodeLinks writes the arrows between the nodes to w, one at a time.
+func (p *pkg) writeNodeLinks(w io.Writer, typeIdsPrinted map[string]bool) {
+	io.WriteString(w, "  /* node links: */\n")
+	nodeLinks := p.nodeLinks
+	if p.weightLinks {
+		nodeLinks = weighLinks(nodeLinks)
+	}
+	nodes := map[string]*graphNode{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		nodes[node.typeId] = node
+	})
+	// Arrows that would be drawn the same (e.g. from several fields that
+	// aren't drawn themselves) are drawn once, and each type that isn't
+	// drawn is drawn as a placeholder once, however many arrows point to it.
+	type arrow struct {
+		key, from, to string
+		nodeLink      graphNodeLink
+	}
+	var arrows []arrow
+	counts := map[string]int{}
+	for _, nodeLink := range nodeLinks {
+		toTypeId := nodeLink.toId()
+		from := fmt.Sprintf("%s:port_%s", nodeLink.fromStructTypeId, nodeLink.fromStructFieldName)
+		if node, ok := nodes[nodeLink.fromStructTypeId]; nodeLink.weight > 1 || nodeLink.fromStructFieldName == "" || (ok && !node.printsField(nodeLink.fromStructFieldName)) {
+			// The arrow stands for several fields, or none (e.g. it's from
+			// an implementation to its interface), or the field isn't
+			// drawn, so it starts at the type.
+			from = nodeLink.fromStructTypeId
+		}
+		key := from + " -> " + toTypeId + nodeLink.attrs()
+		if counts[key] == 0 {
+			arrows = append(arrows, arrow{key, from, toTypeId, nodeLink})
+		}
+		counts[key]++
+	}
+
+	placeholdersPrinted := map[string]bool{}
+	// The placeholders of the types of each package outside of the graphed
+	// ones are drawn together, in a cluster of their own, after the arrows.
+	externals := map[string][]graphNodeLink{}
+	var externalPaths []string
+	for _, a := range arrows {
+		nodeLink := a.nodeLink
+		if n := counts[a.key]; n > 1 && p.countArrows {
+			if nodeLink.label != "" {
+				nodeLink.label = fmt.Sprintf("%s ×%d", nodeLink.label, n)
+			} else {
+				nodeLink.label = fmt.Sprintf("×%d", n)
+			}
+		}
+		fmt.Fprintf(w, "  %s -> %s%s;\n", a.from, a.to, nodeLink.attrs())
 		// Render any referenced types that were not output (e.g. external packages)
-		if _, ok := typeIdsPrinted[toTypeId]; !ok {
-			out = fmt.Sprintf("%s  %s [shape=plaintext label=<"+
-				"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='#cccccc'>"+
-				"<tr><td align='center' colspan='2'>%s.%s</td></tr>"+
-				"</table> >];\n",
-				out,
-				toTypeId,
-				nodeLink.toTypePkgName,
-				nodeLink.toTypeName,
-			)
+		if _, ok := typeIdsPrinted[a.to]; !ok && !placeholdersPrinted[a.to] {
+			placeholdersPrinted[a.to] = true
+			if path := p.externalPkgPath(nodeLink); path != "" {
+				if externals[path] == nil {
+					externalPaths = append(externalPaths, path)
+				}
+				externals[path] = append(externals[path], nodeLink)
+			} else {
+				writePlaceholder(w, "  ", nodeLink)
+			}
 		}
 	}
-	return out
+
+	sort.Strings(externalPaths)
+	for _, path := range externalPaths {
+		fmt.Fprintf(w, "  subgraph cluster_external_%s {\n", labelizeName("", path))
+		fmt.Fprintf(w, "    label=%s;\n", quoteString(path))
+		fmt.Fprintf(w, "    graph[style=dotted color=\"#cccccc\" fontcolor=\"#7f8183\" fontsize=10];\n")
+		for _, nodeLink := range externals[path] {
+			writePlaceholder(w, "    ", nodeLink)
+		}
+		fmt.Fprintf(w, "  }\n")
+	}
+}
+
+// writePlaceholder writes the node of a type that an arrow points to, but
+// that isn't drawn itself, e.g. because it's from another package: just its
+// package and name, in gray.
+func writePlaceholder(w io.Writer, indent string, nodeLink graphNodeLink) {
+	name := nodeLink.toTypeName
+	if nodeLink.toTypePkgName != "" {
+		name = nodeLink.toTypePkgName + "." + name
+	}
+	fmt.Fprintf(w, "%s%s [shape=plaintext label=<"+
+		"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='#cccccc'>"+
+		"<tr><td align='center' colspan='2'>%s</td></tr>"+
+		"</table> >];\n",
+		indent,
+		nodeLink.toId(),
+		name,
+	)
+}
+
+// externalPkgPath returns the import path of the package that the type the
+// link points to is from, if it's outside of the graphed packages (e.g. a
+// standard library one), or "" if not.
+func (p *pkg) externalPkgPath(nodeLink graphNodeLink) string {
+	if nodeLink.toTypeObj == nil || nodeLink.toTypeObj.Pkg() == nil {
+		return ""
+	}
+	path := nodeLink.toTypeObj.Pkg().Path()
+	if path == "" || relativePkgPath(path, p.rootPkgName) != path {
+		return ""
+	}
+	return path
+}
+
+// attrs returns the dot attributes of the link's arrow, if it has any.
+func (nodeLink graphNodeLink) attrs() string {
+	var attrs []string
+	if nodeLink.color != "" {
+		attrs = append(attrs, fmt.Sprintf("color=\"%s\"", nodeLink.color))
+	}
+	if nodeLink.style != "" {
+		attrs = append(attrs, fmt.Sprintf("style=%s", nodeLink.style))
+	}
+	if nodeLink.arrowhead != "" {
+		attrs = append(attrs, fmt.Sprintf("arrowhead=%s", nodeLink.arrowhead))
+	}
+	if nodeLink.dir != "" {
+		attrs = append(attrs, fmt.Sprintf("dir=%s", nodeLink.dir))
+	}
+	if nodeLink.weight > 1 {
+		attrs = append(attrs, fmt.Sprintf("penwidth=%d label=\"%d\"", linkPenWidth(nodeLink.weight), nodeLink.weight))
+	} else if nodeLink.label != "" {
+		attrs = append(attrs, fmt.Sprintf("label=%s", quoteString(nodeLink.label)))
+	}
+	if len(attrs) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(attrs, " ") + "]"
 }
 
 // WriteGraph will build the graph based on the given pkgName, and write out the dot graph.
 func WriteGraph(pkgName string) string {
+	return WriteGraphWithOptions(pkgName, Options{})
+}
+
+// WriteGraphWithOptions is like WriteGraph, but builds the graph with the given options.
+func WriteGraphWithOptions(pkgName string, opts Options) string {
+	return BuildGraphWithOptions(pkgName, opts).String()
+}
+
+// WriteGraphForPackage is like WriteGraphWithOptions, but returns an error
+// if the graph can't be built (see GraphForPackage), rather than writing a
+// graph of a note saying why.
+func WriteGraphForPackage(pkgName string, opts Options) (string, error) {
+	pkgGraph, err := GraphForPackage(pkgName, opts)
+	if err != nil {
+		return "", err
+	}
+	return pkgGraph.String(), nil
+}
+
+// String writes out the dot graph of a built graph.
+func (p *pkg) String() string {
+	var b strings.Builder
+	p.WriteTo(&b)
+	return b.String()
+}
+
+// WriteTo writes the dot graph of a built graph to w as it goes, buffered,
+// rather than building the whole graph in memory first, e.g. to stream it
+// to a file or to the `dot` command.
+func (p *pkg) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
 	typeIdsPrinted := map[string]bool{}
-	pkgGraph := BuildGraph(pkgName)
 
-	out := pkgGraph.PrintHeader()
-	out, typeIdsPrinted = pkgGraph.Print(out, pkgName, 0, typeIdsPrinted)
-	out = pkgGraph.PrintNodeLinks(out, typeIdsPrinted)
-	out = pkgGraph.PrintFooter(out)
+	io.WriteString(bw, p.PrintHeader())
+	p.writeNodes(bw, p.pkgName, "", 0, typeIdsPrinted, p.renderWarner())
+	p.writeNodeLinks(bw, typeIdsPrinted)
+	io.WriteString(bw, p.printLayerRows(""))
+	io.WriteString(bw, p.PrintFooter(""))
 
-	return out
+	// The buffered writer holds on to the first error writing to w.
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// A countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.n += int64(n)
+	return n, err
 }
 
 func (dgn *graphNode) Print(out string, pkgName string, indentLevel int, typeIdsPrinted map[string]bool) (string, map[string]bool) {
+	out, _ = dgn.print(out, pkgName, indentLevel, typeIdsPrinted)
+	return out, typeIdsPrinted
+}
+
+// print returns out with the node's dot statement after it. A node of a
+// kind that it doesn't know how to draw is drawn as a generic node, and the
+// error says so, so that one odd type can't stop the whole graph from being
+// drawn.
+func (dgn *graphNode) print(out string, pkgName string, indentLevel int, typeIdsPrinted map[string]bool) (string, error) {
+	var err error
 	out = fmt.Sprintf("%s  /* %s */\n", out, dgn.typeType)
 	switch dgn.typeType {
 	case "root":
 		// no-op?
 	case "struct":
-		out = fmt.Sprintf("%s%s%s [shape=plaintext label=<"+
-			"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='#4BAAD3'>"+
-			"<tr><td bgcolor='#e0ebf5' align='center' colspan='2'>%s</td></tr>",
+		out = fmt.Sprintf("%s%s%s [shape=plaintext%s label=<"+
+			"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='%s'%s>"+
+			"<tr><td bgcolor='%s' align='center' colspan='2'>%s</td></tr>%s",
 			out,
 			strings.Repeat("  ", indentLevel),
 			dgn.typeId,
-			dgn.typeName,
+			dgn.tooltipAttr(),
+			dgn.borderColorOrDefault(),
+			dgn.tableBgColorAttr(),
+			dgn.headerBgColor(),
+			dgn.printName(),
+			dgn.printSubtitle(2)+dgn.printAnnotations(2)+dgn.printConstructors(2),
 		)
 
 		var alphabetizedKeys []string
@@ -166,107 +546,465 @@ func (dgn *graphNode) Print(out string, pkgName string, indentLevel int, typeIds
 			alphabetizedKeys = append(alphabetizedKeys, k)
 		}
 		sort.Strings(alphabetizedKeys)
+		if dgn.fieldOrder != nil {
+			alphabetizedKeys = dgn.fieldOrder
+		}
+		if dgn.fieldsHidden {
+			alphabetizedKeys = nil
+		}
 
 		for _, structFieldName := range alphabetizedKeys {
-			structFieldNode := dgn.typeStructFields[structFieldName]
+			structFieldNode, ok := dgn.typeStructFields[structFieldName]
+			if !ok {
+				continue
+			}
 			out = fmt.Sprintf(
-				"%s<tr><td port='port_%s' align='left'>%s</td><td align='left'><font color='#7f8183'>%s</font></td></tr>",
+				"%s<tr><td port='port_%s' align='left'%s>%s</td><td align='left'%s><font color='#7f8183'>%s</font>%s</td></tr>",
 				out,
 				structFieldName,
-				structFieldName,
+				dgn.fieldBgColorAttr(structFieldName),
+				dgn.fieldLabel(structFieldName),
+				dgn.fieldBgColorAttr(structFieldName),
 				escapeHtml(relativizeTypePkgName(structFieldNode.structFieldTypeName, pkgName)),
+				dgn.printFieldNote(structFieldName),
 			)
 		}
-		out = fmt.Sprintf("%s</table> >];\n", out)
+		out = fmt.Sprintf("%s%s</table> >];\n", out, dgn.printFooter(2))
 		typeIdsPrinted[dgn.typeId] = true
 	case "basic":
-		out = fmt.Sprintf("%s%s%s [shape=plaintext label=< "+
-			"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='#4BAAD3'>"+
-			"<tr><td bgcolor='#e0ebf5' align='center'>%v</td></tr>"+
-			"<tr><td align='center'>%s</td></tr>"+
+		out = fmt.Sprintf("%s%s%s [shape=plaintext%s label=< "+
+			"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='%s'%s>"+
+			"<tr><td bgcolor='%s' align='center'>%v</td></tr>%s"+
+			"<tr><td align='center'>%s</td></tr>%s%s"+
 			"</table> >];\n",
 			out,
 			strings.Repeat("  ", indentLevel),
 			dgn.typeId,
-			dgn.typeName,
+			dgn.tooltipAttr(),
+			dgn.borderColorOrDefault(),
+			dgn.tableBgColorAttr(),
+			dgn.headerBgColor(),
+			dgn.printName(),
+			dgn.printSubtitle(1)+dgn.printAnnotations(1)+dgn.printConstructors(1),
 			dgn.typeUnderlyingType,
+			dgn.printConstants(),
+			dgn.printFooter(1),
 		)
 		typeIdsPrinted[dgn.typeId] = true
 	case "interface":
-		out = fmt.Sprintf("%s%s%v [shape=plaintext label=< "+
-			"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='#4BAAD3'>"+
-			"<tr><td bgcolor='#e0ebf5' align='center' colspan='2'>%s</td></tr>",
+		out = fmt.Sprintf("%s%s%v [shape=plaintext%s label=< "+
+			"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='%s'%s>"+
+			"<tr><td bgcolor='%s' align='center' colspan='2'>%s</td></tr>%s",
 			out,
 			strings.Repeat("  ", indentLevel),
 			dgn.typeId,
-			dgn.typeName,
+			dgn.tooltipAttr(),
+			dgn.borderColorOrDefault(),
+			dgn.tableBgColorAttr(),
+			dgn.headerBgColor(),
+			dgn.printName(),
+			dgn.printSubtitle(2)+dgn.printAnnotations(2)+dgn.printConstructors(2),
 		)
-		for methodName, methodType := range dgn.typeInterfaceMethods {
+		if dgn.typeTerms != "" && !dgn.fieldsHidden {
+			out = fmt.Sprintf(
+				"%s<tr><td align='left' colspan='2'><font color='#7f8183'>%s</font></td></tr>",
+				out,
+				escapeHtml(dgn.typeTerms),
+			)
+		}
+		var methodNames []string
+		for methodName := range dgn.typeInterfaceMethods {
+			methodNames = append(methodNames, methodName)
+		}
+		sort.Strings(methodNames)
+		for _, methodName := range methodNames {
+			if dgn.fieldsHidden {
+				break
+			}
 			out = fmt.Sprintf(
 				"%s<tr><td align='left'>%s</td><td align='left'><font color='#7f8183'>%s</font></td></tr>",
 				out,
 				methodName,
-				escapeHtml(methodType),
+				escapeHtml(dgn.typeInterfaceMethods[methodName]),
 			)
 		}
-		out = fmt.Sprintf("%s</table>>];\n", out)
-	case "pointer":
+		out = fmt.Sprintf("%s%s</table>>];\n", out, dgn.printFooter(2))
+	case "var":
 		out = fmt.Sprintf(
-			"%s\n%s%v [shape=record, label=\"pointer\", color=\"#CCC\"]\n",
+			"%s%s%v [shape=box, style=\"rounded,dashed\", fontsize=10, color=\"#7f8183\", label=%s];\n",
 			out,
 			strings.Repeat("  ", indentLevel),
 			dgn.typeId,
+			quoteString(dgn.typeName),
 		)
-	case "signature":
+	case "func":
 		out = fmt.Sprintf(
-			"%s\n%s%v [shape=record, label=\"%s\", color=\"blue\"]\n",
+			"%s%s%v [shape=box, style=rounded, fontsize=10, color=\"#7f8183\", label=%s];\n",
 			out,
 			strings.Repeat("  ", indentLevel),
 			dgn.typeId,
-			// TODO: how can we escape in the label instead of removing {}?
-			strings.Replace(strings.Replace(dgn.typeName, "{", "", -1), "}", "", -1),
+			quoteString(dgn.typeName),
 		)
-	case "chan":
-		out = fmt.Sprintf(
-			"%s\n%s%v [shape=record, label=\"chan %s\", color=\"#CCC\"];\n",
+	case "package":
+		out = fmt.Sprintf("%s%s%s [shape=plaintext label=<"+
+			"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='%s'>"+
+			"<tr><td bgcolor='%s' align='center'>%s</td></tr>%s"+
+			"</table> >];\n",
 			out,
 			strings.Repeat("  ", indentLevel),
-			dgn.typeName, // TODO: should this be typeId?
-			dgn.typeUnderlyingType,
+			dgn.typeId,
+			dgn.borderColorOrDefault(),
+			dgn.headerBgColor(),
+			escapeHtml(dgn.typeName),
+			dgn.printAnnotations(1),
 		)
-	case "slice":
-		out = fmt.Sprintf("%s%s%v [shape=plaintext label=< "+
-			"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='#4BAAD3'>"+
-			"<tr><td bgcolor='#e0ebf5' align='center'>%s</td></tr><tr><td>%s</td></tr>"+
+	case "signature":
+		out = fmt.Sprintf("%s%s%v [shape=plaintext%s label=< "+
+			"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='%s'%s>"+
+			"<tr><td bgcolor='%s' align='center' colspan='2'>%s</td></tr>%s%s%s"+
 			"</table> >];\n",
 			out,
 			strings.Repeat("  ", indentLevel),
 			dgn.typeId,
-			dgn.typeName,
-			dgn.typeUnderlyingType,
+			dgn.tooltipAttr(),
+			dgn.borderColorOrDefault(),
+			dgn.tableBgColorAttr(),
+			dgn.headerBgColor(),
+			dgn.printName(),
+			dgn.printSubtitle(2)+dgn.printAnnotations(2)+dgn.printConstructors(2),
+			dgn.printSignatureRows(),
+			dgn.printFooter(2),
+		)
+	case "chan", "slice", "array", "pointer":
+		out = fmt.Sprintf("%s%s%v [shape=plaintext%s label=< "+
+			"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='%s'%s>"+
+			"<tr><td bgcolor='%s' align='center'>%s</td></tr>%s<tr><td>%s</td></tr>%s"+
+			"</table> >];\n",
+			out,
+			strings.Repeat("  ", indentLevel),
+			dgn.typeId,
+			dgn.tooltipAttr(),
+			dgn.borderColorOrDefault(),
+			dgn.tableBgColorAttr(),
+			dgn.headerBgColor(),
+			dgn.printName(),
+			dgn.printSubtitle(1)+dgn.printAnnotations(1)+dgn.printConstructors(1),
+			escapeHtml(dgn.typeUnderlyingType),
+			dgn.printFooter(1),
 		)
 	case "map":
-		// TODO: break down the map more and point each level to its type?
-		out = fmt.Sprintf("%s%s%v [shape=plaintext label=< "+
-			"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='#4BAAD3'>"+
-			"<tr><td bgcolor='#e0ebf5' align='center'>%s</td></tr><tr><td>%s</td></tr>"+
+		out = fmt.Sprintf("%s%s%v [shape=plaintext%s label=< "+
+			"<table border='2' cellborder='0' cellspacing='0' style='rounded' color='%s'%s>"+
+			"<tr><td bgcolor='%s' align='center'>%s</td></tr>%s<tr><td>%s</td></tr>%s"+
 			"</table> >];\n",
 			out,
 			strings.Repeat("  ", indentLevel),
 			dgn.typeId,
-			dgn.typeName,
-			dgn.typeMapType,
+			dgn.tooltipAttr(),
+			dgn.borderColorOrDefault(),
+			dgn.tableBgColorAttr(),
+			dgn.headerBgColor(),
+			dgn.printName(),
+			dgn.printSubtitle(1)+dgn.printAnnotations(1)+dgn.printConstructors(1),
+			escapeHtml(dgn.typeMapType),
+			dgn.printFooter(1),
 		)
+	case "unknown":
+		out = dgn.printGeneric(out, indentLevel)
 	default:
-		panic(dgn.typeType)
+		out = dgn.printGeneric(out, indentLevel)
+		err = fmt.Errorf("%s is a %q node, which can't be drawn, so it's drawn as a generic node", dgn.typeName, dgn.typeType)
 	}
 	typeIdsPrinted[dgn.typeId] = true
 
-	return out, typeIdsPrinted
+	return out, err
+}
+
+// printGeneric returns out with the node drawn as a generic type: its name
+// over its underlying type, if it has one, with a dashed border.
+func (dgn *graphNode) printGeneric(out string, indentLevel int) string {
+	underlying := ""
+	if dgn.typeUnderlyingType != "" {
+		underlying = fmt.Sprintf("<tr><td>%s</td></tr>", escapeHtml(dgn.typeUnderlyingType))
+	}
+	return fmt.Sprintf("%s%s%v [shape=plaintext%s label=< "+
+		"<table border='2' cellborder='0' cellspacing='0' style='rounded,dashed' color='%s'%s>"+
+		"<tr><td bgcolor='%s' align='center'>%s</td></tr>%s%s%s"+
+		"</table> >];\n",
+		out,
+		strings.Repeat("  ", indentLevel),
+		dgn.typeId,
+		dgn.tooltipAttr(),
+		dgn.borderColorOrDefault(),
+		dgn.tableBgColorAttr(),
+		dgn.headerBgColor(),
+		dgn.printName(),
+		dgn.printSubtitle(1)+dgn.printAnnotations(1)+dgn.printConstructors(1),
+		underlying,
+		dgn.printFooter(1),
+	)
+}
+
+// headerBgColor returns the color behind the type's name.
+func (dgn *graphNode) headerBgColor() string {
+	if dgn.headerColor != "" {
+		return dgn.headerColor
+	}
+	return "#e0ebf5"
+}
+
+// printName returns the type's name, in its font size if it has one.
+func (dgn *graphNode) printName() string {
+	name := dgn.typeName + escapeHtml(dgn.typeParams)
+	if dgn.nameFontSize > 0 {
+		return fmt.Sprintf("<font point-size='%d'>%s</font>", dgn.nameFontSize, name)
+	}
+	return name
+}
+
+// borderColorOrDefault returns the color of the type's border.
+func (dgn *graphNode) borderColorOrDefault() string {
+	if dgn.borderColor != "" {
+		return dgn.borderColor
+	}
+	return "#4BAAD3"
+}
+
+// tableBgColorAttr returns the bgcolor attribute for the type's table, if
+// it has a color.
+func (dgn *graphNode) tableBgColorAttr() string {
+	if dgn.bgColor != "" {
+		return fmt.Sprintf(" bgcolor='%s'", dgn.bgColor)
+	}
+	return ""
+}
+
+// fieldBgColorAttr returns the bgcolor attribute for the cells of a struct
+// field's row, if it has a color.
+func (dgn *graphNode) fieldBgColorAttr(structFieldName string) string {
+	if color, ok := dgn.fieldColors[structFieldName]; ok {
+		return fmt.Sprintf(" bgcolor='%s'", color)
+	}
+	return ""
+}
+
+// printsField returns whether the type is drawn with a row for the struct
+// field, that arrows from the field can start at.
+func (dgn *graphNode) printsField(structFieldName string) bool {
+	if dgn.collapsed || dgn.fieldsHidden {
+		return false
+	}
+	if _, ok := dgn.typeStructFields[structFieldName]; !ok || dgn.fieldOrder == nil {
+		return ok
+	}
+	for _, name := range dgn.fieldOrder {
+		if name == structFieldName {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldLabel returns the label of a struct field's row.
+func (dgn *graphNode) fieldLabel(structFieldName string) string {
+	if label, ok := dgn.fieldLabels[structFieldName]; ok {
+		return escapeHtml(label)
+	}
+	return structFieldName
+}
+
+// printFieldNote returns the note after a struct field's type, if it has
+// one.
+func (dgn *graphNode) printFieldNote(structFieldName string) string {
+	if note, ok := dgn.fieldNotes[structFieldName]; ok {
+		return fmt.Sprintf(" <font point-size='9' color='#7f8183'>%s</font>", escapeHtml(note))
+	}
+	return ""
+}
+
+// printConstants returns a table row for each of the basic type's
+// constants, up to maxConstantRows of them.
+func (dgn *graphNode) printConstants() string {
+	out := ""
+	if dgn.fieldsHidden {
+		return out
+	}
+	for i, constant := range dgn.typeConstants {
+		if i == maxConstantRows {
+			out = fmt.Sprintf("%s<tr><td align='left'><font color='#7f8183'>and %d more</font></td></tr>", out, len(dgn.typeConstants)-i)
+			break
+		}
+		out = fmt.Sprintf("%s<tr><td align='left'>%s <font color='#7f8183'>= %s</font></td></tr>", out, constant.name, escapeHtml(constant.value))
+	}
+	return out
+}
+
+// printSignatureRows returns a table row for each of the func type's
+// parameters, and then each of its results, after "returns".
+func (dgn *graphNode) printSignatureRows() string {
+	out := ""
+	if dgn.fieldsHidden {
+		return out
+	}
+	for _, row := range dgn.typeSignatureRows {
+		name := escapeHtml(row.name)
+		if row.result {
+			name = strings.TrimSpace("<font color='#7f8183'>returns</font> " + name)
+		}
+		out = fmt.Sprintf("%s<tr><td align='left'>%s</td><td align='left'><font color='#7f8183'>%s</font></td></tr>", out, name, escapeHtml(row.typeName))
+	}
+	return out
+}
+
+// printFooter returns a table row with the type's footer, if it has one.
+func (dgn *graphNode) printFooter(colspan int) string {
+	if dgn.footer == "" {
+		return ""
+	}
+	return fmt.Sprintf(
+		"<tr><td align='right' colspan='%d'><font point-size='8' color='#7f8183'>%s</font></td></tr>",
+		colspan,
+		escapeHtml(dgn.footer),
+	)
+}
+
+// tooltipAttr returns the dot attribute that shows the type's doc comment
+// when it's hovered over (e.g. in an SVG), if it has one.
+func (dgn *graphNode) tooltipAttr() string {
+	if dgn.doc == "" {
+		return ""
+	}
+	return " tooltip=" + quoteString(dgn.doc)
+}
+
+// printSubtitle returns a table row with the type's subtitle, if it has
+// one, wrapped at maxSubtitleWidth.
+func (dgn *graphNode) printSubtitle(colspan int) string {
+	if dgn.subtitle == "" {
+		return ""
+	}
+	var lines []string
+	for _, line := range wrapWords(dgn.subtitle, maxSubtitleWidth) {
+		lines = append(lines, escapeHtml(line))
+	}
+	return fmt.Sprintf(
+		"<tr><td align='center' colspan='%d'><font point-size='10' color='#7f8183'><i>%s</i></font></td></tr>",
+		colspan,
+		strings.Join(lines, "<br/>"),
+	)
+}
+
+// printAnnotations returns a table row for each of the type's annotations,
+// sorted by kind.
+func (dgn *graphNode) printAnnotations(colspan int) string {
+	var kinds []string
+	for kind := range dgn.annotations {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	var out string
+	for _, kind := range kinds {
+		out = fmt.Sprintf(
+			"%s<tr><td align='center' colspan='%d'><font point-size='9' color='#7f8183'>%s</font></td></tr>",
+			out,
+			colspan,
+			escapeHtml(dgn.annotations[kind]),
+		)
+	}
+	return out
 }
 
 // BuildGraph builds a graph of types in the given pkgName.
 func BuildGraph(pkgName string) *pkg {
+	return BuildGraphWithOptions(pkgName, Options{})
+}
+
+// BuildGraphWithOptions builds a graph of types in the given pkgName with the given options.
+// If the package can't be listed (see GraphForPackage), the graph is just a
+// note saying why.
+func BuildGraphWithOptions(pkgName string, opts Options) *pkg {
+	pkgGraph, err := GraphForPackage(pkgName, opts)
+	if err != nil {
+		pkgGraph = EmptyGraph(pkgName)
+		addNote(pkgGraph, "", "can't be graphed: "+err.Error())
+	}
+	return pkgGraph
+}
+
+// EmptyGraph returns a graph of the given pkgName without any types, e.g.
+// to diff a package against at a git ref that doesn't have it yet.
+func EmptyGraph(pkgName string) *pkg {
+	return &pkg{
+		pkgName:     pkgName,
+		rootPkgName: pkgName,
+		subPkgs:     map[string]*pkg{},
+		nodeLinks:   []graphNodeLink{},
+	}
+}
+
+// GraphForPackage builds a graph of types in the given pkgName with the
+// given options, like BuildGraphWithOptions, but returns an error if the go
+// tool fails to list the package or one that it imports: a *ListError,
+// which wraps ErrPackageNotFound, ErrToolchainMissing or ErrTimeout if it
+// failed for one of those reasons.
+func GraphForPackage(pkgName string, opts Options) (*pkg, error) {
+	return GraphForPackages([]string{pkgName}, opts)
+}
+
+// GraphForPackages builds one graph of the types in several packages, e.g.
+// sibling packages, like GraphForPackage, with the references between their
+// types. Each package is drawn in a cluster of its own, named by its path
+// relative to the longest import path that they're all in.
+func GraphForPackages(pkgNames []string, opts Options) (*pkg, error) {
+	// Patterns (e.g. ./cmd/...) and relative paths are graphed by the import
+	// paths of the packages that they match, relative to their longest
+	// common path, so that e.g. each command's main package gets a cluster
+	// of its own.
+	var importPaths []string
+	seen := map[string]bool{}
+	for _, pkgName := range pkgNames {
+		matched := []string{pkgName}
+		if isPackagePattern(pkgName) {
+			var err error
+			if matched, err = matchPackages(pkgName, &opts); err != nil {
+				return nil, err
+			}
+		}
+		for _, importPath := range matched {
+			if !seen[importPath] {
+				seen[importPath] = true
+				importPaths = append(importPaths, importPath)
+			}
+		}
+	}
+	pkgName := strings.Join(pkgNames, " ")
+	return buildGraph(pkgName, commonPkgPrefix(importPaths), []pkgsToBuild{{dir: opts.Dir, pkgNames: importPaths}}, opts)
+}
+
+// isPackagePattern returns whether a package argument is one that the go
+// tool has to match to import paths, like the go command's own: a relative
+// or absolute directory, a pattern with "..." wildcards (e.g. ./... or
+// net/...), or one of the meta-packages std, cmd and all.
+func isPackagePattern(pkgName string) bool {
+	switch pkgName {
+	case "std", "cmd", "all":
+		return true
+	}
+	return strings.HasPrefix(pkgName, ".") || filepath.IsAbs(pkgName) || strings.Contains(pkgName, "...")
+}
+
+// pkgsToBuild are packages to graph that the go tool lists from dir, e.g. a
+// module's, which get a cluster labeled with the module's path if it's set.
+type pkgsToBuild struct {
+	dir      string
+	module   string
+	pkgNames []string
+}
+
+// buildGraph graphs the packages relative to rootPkgName, and then adds to
+// the graph what the options ask for.
+func buildGraph(pkgName, rootPkgName string, toBuild []pkgsToBuild, opts Options) (*pkg, error) {
 	root := graphNode{
 		pkgName:              pkgName,
 		typeId:               "root",
@@ -279,108 +1017,289 @@ func BuildGraph(pkgName string) *pkg {
 
 	pkgGraph := pkg{
 		pkgName:     pkgName,
-		rootPkgName: pkgName,
+		rootPkgName: rootPkgName,
 		subPkgs:     map[string]*pkg{},
 		nodeLinks:   []graphNodeLink{},
 	}
 
-	recursivelyBuildGraph(&root, pkgName, pkgName, &pkgGraph)
+	built := map[string]bool{}
+	for _, pkgs := range toBuild {
+		listOpts := opts
+		listOpts.Dir = pkgs.dir
+
+		// The packages that aren't cached are loaded at once, so that the
+		// dependencies they have in common are only type-checked once.
+		loader := newPkgLoader(token.NewFileSet(), &listOpts)
+		var toLoad []string
+		for _, name := range pkgs.pkgNames {
+			if _, ok := opts.Cache.get(rootPkgName, name); !ok && !tooDeep(name, rootPkgName, opts.MaxDepth) && (opts.Exclude == nil || !opts.Exclude.MatchString(name)) {
+				toLoad = append(toLoad, name)
+			}
+		}
+		if err := loader.load(toLoad...); err != nil {
+			return nil, err
+		}
+		for _, name := range pkgs.pkgNames {
+			if err := recursivelyBuildGraph(&root, rootPkgName, name, &pkgGraph, &listOpts, loader, built); err != nil {
+				return nil, err
+			}
+		}
+		if relPath := relativePkgPath(pkgs.module, rootPkgName); pkgs.module != "" && relPath != "" {
+			markModule(&pkgGraph, relPath, pkgs.module)
+		}
+	}
 
-	return &pkgGraph
+	if opts.MaxDepth > 0 {
+		noteTooDeep(&pkgGraph, built, rootPkgName, opts.MaxDepth)
+	}
+	if opts.Exclude != nil {
+		excludeTypes(&pkgGraph, opts.Exclude)
+	}
+	return decorateGraph(&pkgGraph, &opts, nil), nil
 }
 
-func recursivelyBuildGraph(dg *graphNode, rootPkgName, pkgName string, p *pkg) {
-	listData := listGoFilesInPackage(pkgName)
+// decorateGraph adds to a built graph what the options ask for, and returns
+// it, or the part of it that the options focus on. Graphs of in-memory
+// source pass its files, which the harness types are found from instead of
+// the module's packages, and which aren't annotated from git.
+func decorateGraph(pkgGraph *pkg, opts *Options, files map[string]string) *pkg {
+	disambiguateTypeIds(pkgGraph)
+	if opts.NormalizeTypes {
+		normalizeTypeStrings(pkgGraph)
+	}
 
-	fset := token.NewFileSet()
-	var files []*ast.File
-	for _, file := range listData.GoFiles {
-		filepath := path.Join(listData.Dir, file)
-		f, err := parser.ParseFile(fset, filepath, nil, 0)
-		if err != nil {
-			log.Fatal(err)
-		}
-		files = append(files, f)
+	result := pkgGraph
+	if opts.Focus != "" {
+		result = focusPkg(result, []string{opts.Focus}, opts.Hops)
+	}
+	if opts.Harness != "" && files != nil {
+		result = markHarness(result, opts.Harness, result.pkgImportPath, harnessFiles(result.rootPkgName, files))
+	} else if opts.Harness != "" {
+		result = markHarnessTypes(result, opts.Harness, opts)
+	}
+	if opts.Blame && files == nil {
+		annotateBlame(result, opts)
+	}
+	if opts.ChurnWindow > 0 && files == nil {
+		annotateChurn(result, opts)
+	}
+	if opts.ColorByDepth {
+		colorByDepth(result)
+	}
+	if opts.HighlightCycles {
+		highlightCycles(result)
+	}
+	if opts.SizeBy != "" {
+		sizeNodes(result, opts.SizeBy)
+	}
+	if opts.WeightReferences {
+		weightLinks(result)
+	}
+	if opts.Layout {
+		annotateLayout(result, opts)
+	}
+	if len(opts.SizeBudgets) > 0 {
+		highlightOversizedStructs(result, opts.SizeBudgets, opts.LayoutArch)
+	}
+	if opts.NoCopy {
+		markNoCopy(result)
+	}
+	if opts.ContextFields {
+		highlightContextFields(result, opts.ContextFieldsAllowed)
+	}
+	if opts.DocCoverage {
+		annotateDocs(result)
+	}
+	if opts.DocSummaries {
+		addDocSummaries(result)
+	}
+	if opts.ShowPositions {
+		addPositionFooters(result)
+	}
+	if opts.Constructors {
+		addConstructors(result)
+	}
+	if opts.Generated != "" {
+		markGenerated(result, opts.Generated)
+	}
+	if opts.DBSchema {
+		drawDBSchema(result)
+	}
+	if opts.ProtoCorrespondence {
+		drawProtoCorrespondence(result)
+	}
+	if len(opts.Layers) > 0 {
+		highlightLayers(result, opts.Layers)
+	}
+	if opts.Intended != nil {
+		highlightIntended(result, *opts.Intended)
 	}
+	if opts.Vars {
+		addVarsToGraph(result)
+	}
+	if opts.Funcs {
+		addFuncsToGraph(result)
+	}
+	if opts.ImplementsArrows {
+		drawImplements(result)
+	}
+	highlightInternalReferences(result)
+	if opts.MaxNodes > 0 || opts.MaxEdges > 0 {
+		reduceDetail(result, opts.MaxNodes, opts.MaxEdges)
+	}
+	noteIfEmpty(result, opts)
+	result.countArrows = opts.CountMergedArrows
+	return result
+}
 
-	// If the package is a part of the root package, just trim the
-	// root package prefix so it's shorter to read.
-	normalizedPkgName := strings.TrimPrefix(strings.TrimPrefix(pkgName, rootPkgName), "/")
-	addTypesToGraph(dg, normalizedPkgName, fset, files, p)
+func recursivelyBuildGraph(dg *graphNode, rootPkgName, pkgName string, p *pkg, opts *Options, loader *pkgLoader, built map[string]bool) error {
+	// Packages that more than one graphed package imports are only added
+	// once.
+	if built[pkgName] {
+		return nil
+	}
+	built[pkgName] = true
+	if tooDeep(pkgName, rootPkgName, opts.MaxDepth) || (opts.Exclude != nil && opts.Exclude.MatchString(pkgName)) {
+		return nil
+	}
 
-	for _, pkgName := range listData.Imports {
-		if strings.HasPrefix(pkgName, listData.ImportPath) {
-			recursivelyBuildGraph(dg, rootPkgName, pkgName, p)
+	cached, ok := opts.Cache.get(rootPkgName, pkgName)
+	if !ok {
+		loaded, err := loader.get(pkgName)
+		if err != nil {
+			return err
 		}
-	}
-}
 
-func listGoFilesInPackage(pkg string) goListResult {
-	var listCmdOut []byte
-	var err error
+		// Each package's types are added to their own graph fragment, so
+		// that the fragment can be cached and merged into later graphs.
+		fragment := &pkg{
+			pkgName:     rootPkgName,
+			rootPkgName: rootPkgName,
+			subPkgs:     map[string]*pkg{},
+			nodeLinks:   []graphNodeLink{},
+		}
 
-	// TODO check if pkg exists first?
-	cmd := exec.Command("go", "list", "-json", pkg)
-	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=1")
-	if listCmdOut, err = cmd.CombinedOutput(); err != nil {
-		fmt.Printf("Error running '%v'\n", cmd.String())
-		fmt.Printf("Debug: %s\n", string(listCmdOut))
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
+		// If the package is a part of the root package, just trim the
+		// root package prefix so it's shorter to read.
+		normalizedPkgName := relativePkgPath(pkgName, rootPkgName)
+		for _, err := range loaded.errors {
+			addWarning(fragment, normalizedPkgName, WarningTypeError, "%v", err)
+		}
+		addTypesToGraph(dg, normalizedPkgName, loader.fset, loaded.files, loaded.info, fragment)
+		if len(loaded.files) == 0 && len(loaded.testFiles) > 0 {
+			addNote(fragment, normalizedPkgName, fmt.Sprintf("only has tests (%s), which aren't graphed", strings.Join(loaded.testFiles, ", ")))
+		}
 
-	var data goListResult
-	if err := json.Unmarshal(listCmdOut, &data); err != nil {
-		fmt.Printf("Error finding %v\n", pkg)
-		panic(err)
+		cached = &cachedPkg{dir: loaded.dir, importPath: loaded.importPath, imports: loaded.imports, fragment: fragment}
+		opts.Cache.put(rootPkgName, pkgName, cached)
 	}
+	mergePkg(p, cached.fragment)
 
-	return data
+	for _, pkgName := range cached.imports {
+		if strings.HasPrefix(pkgName, cached.importPath+"/") {
+			if err := recursivelyBuildGraph(dg, rootPkgName, pkgName, p, opts, loader, built); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
-func addTypesToGraph(dg *graphNode, pkgName string, fset *token.FileSet, files []*ast.File, p *pkg) {
-	// Type-check the package. Setup the maps that Check will fill.
-	info := types.Info{
-		Defs: make(map[*ast.Ident]types.Object),
+// mergePkg copies the nodes, subpackages and node links of src into dst.
+func mergePkg(dst, src *pkg) {
+	if dst.nodes == nil {
+		dst.nodes = map[string]*graphNode{}
 	}
-
-	var conf types.Config = types.Config{
-		Importer:                 importer.For("source", nil),
-		DisableUnusedImportCheck: true,
-		FakeImportC:              true,
-		Error: func(err error) {
-			fmt.Printf("There was an Importer err: %v\n", err)
-		},
+	for name, node := range src.nodes {
+		dst.nodes[name] = node
 	}
-
-	_, err := conf.Check("", fset, files, &info) // TODO: what is the first arg for?
-	if err != nil {
-		log.Fatal(err)
+	for subPkgName, subPkg := range src.subPkgs {
+		if dst.subPkgs[subPkgName] == nil {
+			dst.subPkgs[subPkgName] = &pkg{
+				pkgName:     subPkg.pkgName,
+				rootPkgName: dst.rootPkgName,
+				subPkgs:     map[string]*pkg{},
+				nodes:       map[string]*graphNode{},
+				nodeLinks:   []graphNodeLink{},
+			}
+		}
+		mergePkg(dst.subPkgs[subPkgName], subPkg)
 	}
+	dst.nodeLinks = append(dst.nodeLinks, src.nodeLinks...)
+	dst.notes = append(dst.notes, src.notes...)
+	dst.warnings = append(dst.warnings, src.warnings...)
+}
+
+// addTypesToGraph adds the types that a type-checked package's files
+// declare to the graph, with where they're declared, their docs, and what
+// generated them.
+func addTypesToGraph(dg *graphNode, pkgName string, fset *token.FileSet, files []*ast.File, info *types.Info, p *pkg) {
+	addDefsToGraph(dg, fset, info, pkgName, p)
+	addPositionsToGraph(fset, files, info, p)
+	addDocsToGraph(files, info, p)
+	addGeneratorsToGraph(files, info, p)
+}
 
-	// Print out all the Named types
+func addDefsToGraph(dg *graphNode, fset *token.FileSet, info *types.Info, pkgName string, p *pkg) {
+	// Print out all the Named types, in the order they're declared (by file
+	// name, since files may be parsed in any order), so that their node
+	// links are too.
+	var objs []types.Object
 	for _, obj := range info.Defs {
 		if _, ok := obj.(*types.TypeName); ok {
-			// NB to get the position of the type: fset.Position(id.Pos())
-			addTypeToGraph(dg, obj, pkgName, p)
+			objs = append(objs, obj)
 		}
 	}
+	sort.Slice(objs, func(i, j int) bool {
+		pi, pj := fset.Position(objs[i].Pos()), fset.Position(objs[j].Pos())
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	for _, obj := range objs {
+		// NB to get the position of the type: fset.Position(id.Pos())
+		addTypeToGraph(dg, obj, pkgName, p)
+	}
 }
 
-func escapeName(name string) string {
-	name = strings.Replace(name, "*", "", -1) // remove pointers, handle them separately by returning bool
-	name = strings.Replace(name, "/", "_SLASH_", -1)
-	name = strings.Replace(name, "[]", "_ARY_", -1)
-	name = strings.Replace(name, "{}", "_BRACES_", -1)
-	name = strings.Replace(name, ",", "_COMMA_", -1)
-	name = strings.Replace(name, "(", "_LPARENS_", -1)
-	name = strings.Replace(name, ")", "_RPARENS_", -1)
-	name = strings.Replace(name, " ", "", -1)
-	return name
+// addPositionsToGraph records where each of the package's types is declared,
+// including its doc comment.
+func addPositionsToGraph(fset *token.FileSet, files []*ast.File, info *types.Info, p *pkg) {
+	spans := map[types.Object][2]token.Pos{}
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				start, end, doc := typeSpec.Pos(), typeSpec.End(), typeSpec.Doc
+				// Ungrouped declarations start at the "type" keyword.
+				if !genDecl.Lparen.IsValid() {
+					start, end, doc = genDecl.Pos(), genDecl.End(), genDecl.Doc
+				}
+				if doc != nil {
+					start = doc.Pos()
+				}
+				spans[info.Defs[typeSpec.Name]] = [2]token.Pos{start, end}
+			}
+		}
+	}
+
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if span, ok := spans[node.typeObj]; ok {
+			node.position = fset.Position(span[0])
+			node.endLine = fset.Position(span[1]).Line
+			node.namePosition = fset.Position(node.typeObj.Pos())
+		}
+	})
 }
 
-// Turn a type string into a graphviz-friendly label, e.g. `func(interface{}, uintptr)` -> funclparensinterfacebracescommauintptrrparens
-func labelizeName(pkgName, typeName string) string {
+// makeLabel turns a type string into a graphviz-friendly ID. It's called
+// through labelizeName, which memoizes it.
+func makeLabel(pkgName, typeName string) string {
 	pkgName = escapeName(pkgName)
 	typeName = escapeName(typeName)
 
@@ -388,12 +1307,17 @@ func labelizeName(pkgName, typeName string) string {
 	// If the type is from another package, don't prepend this package's name to it
 	if strings.Contains(typeName, ".") {
 		// TODO: handle cases when it's in another package
-		label = strings.Replace(typeName, ".", "_DOT_", -1)
+		label = typeName
 	} else if len(pkgName) == 0 {
 		label = typeName
 	} else {
 		label = strings.Join([]string{pkgName, typeName}, "_")
 	}
+	label = strings.Replace(label, ".", "_DOT_", -1)
+	// IDs can't start with a digit, but package paths can (e.g. 9fans.net/go).
+	if label != "" && label[0] >= '0' && label[0] <= '9' {
+		label = "_" + label
+	}
 
 	return strings.ToLower(label)
 }
@@ -417,122 +1341,245 @@ func addTypeToGraph(node *graphNode, obj types.Object, pkgName string, p *pkg) {
 		addChanToGraph(node, obj, namedTypeType, pkgName, p)
 	case *types.Slice:
 		addSliceToGraph(node, obj, namedTypeType, pkgName, p)
+	case *types.Array:
+		addArrayToGraph(node, obj, namedTypeType, pkgName, p)
 	case *types.Map:
 		addMapToGraph(node, obj, namedTypeType, pkgName, p)
 	case *types.Struct:
 		addStructToGraph(node, obj, namedTypeType, pkgName, p)
 	default:
-		fmt.Printf(
-			"    // Unknown: %v <%T> - %v <%T>\n",
-			obj, obj,
-			namedTypeType, namedTypeType,
-		)
+		addUnknownToGraph(node, obj, namedTypeType, pkgName, p)
+	}
+	addTypeParamsToGraph(node, obj, pkgName, p)
+}
+
+// addUnknownToGraph adds a named type of a kind that isn't graphed (e.g.
+// one that a newer Go release adds) as a generic node, with its underlying
+// type, and warns that it's drawn that way.
+func addUnknownToGraph(dg *graphNode, obj types.Object, u types.Type, pkgName string, p *pkg) {
+	typeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
+
+	node := &graphNode{
+		pkgName:              pkgName,
+		typeId:               typeId,
+		typeType:             "unknown",
+		typeName:             obj.Name(),
+		typeUnderlyingType:   typeString(u, obj.Pkg()),
+		typeNodes:            map[string]*graphNode{},
+		typeStructFields:     map[string]*structField{},
+		typeInterfaceMethods: map[string]string{},
+		typeObj:              obj,
 	}
+	deepSetNodeOnSubPkg(p, node, pkgName)
+	dg.typeNodes[typeId] = node
+	addWarning(p, pkgName, WarningUnknownKind, "%s is a %T, which is drawn as a generic node", obj.Name(), u)
 }
 
 func addBasicToGraph(dg *graphNode, obj types.Object, b *types.Basic, pkgName string, p *pkg) {
-	typeId := getTypeId(obj.Type(), obj.Pkg().Name(), pkgName)
+	typeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
 
 	// TODO: check key first
 	node := &graphNode{
 		pkgName:              pkgName,
 		typeId:               typeId,
 		typeType:             "basic",
-		typeName:             obj.Type().String(),
+		typeName:             typeString(obj.Type(), obj.Pkg()),
 		typeUnderlyingType:   b.String(),
 		typeNodes:            map[string]*graphNode{},
 		typeStructFields:     map[string]*structField{},
 		typeInterfaceMethods: map[string]string{},
+		typeConstants:        constantsOfType(obj),
+		typeObj:              obj,
 	}
 
 	deepSetNodeOnSubPkg(p, node, pkgName)
 }
 
+// constantsOfType returns the constants of the named type in its package,
+// in the order they're declared.
+func constantsOfType(obj types.Object) []typeConstant {
+	var consts []*types.Const
+	scope := obj.Pkg().Scope()
+	for _, name := range scope.Names() {
+		if c, ok := scope.Lookup(name).(*types.Const); ok && types.Identical(c.Type(), obj.Type()) {
+			consts = append(consts, c)
+		}
+	}
+	sort.Slice(consts, func(i, j int) bool {
+		return consts[i].Pos() < consts[j].Pos()
+	})
+
+	var constants []typeConstant
+	for _, c := range consts {
+		constants = append(constants, typeConstant{name: c.Name(), value: c.Val().ExactString()})
+	}
+	return constants
+}
+
 func addChanToGraph(dg *graphNode, obj types.Object, c *types.Chan, pkgName string, p *pkg) { //, indentLevel int) {
-	typeId := getTypeId(obj.Type(), obj.Pkg().Name(), pkgName)
+	typeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
 
 	node := &graphNode{
 		pkgName:              pkgName,
 		typeId:               typeId,
 		typeType:             "chan",
-		typeName:             c.Elem().String(),
+		typeName:             obj.Name(),
+		typeUnderlyingType:   typeStringIn(c, obj.Pkg()),
 		typeNodes:            map[string]*graphNode{},
 		typeStructFields:     map[string]*structField{},
 		typeInterfaceMethods: map[string]string{},
+		typeObj:              obj,
 	}
 	deepSetNodeOnSubPkg(p, node, pkgName)
 	dg.typeNodes[typeId] = node
+	addElemLinkToGraph(p, obj, c.Elem(), "", pkgName)
+}
+
+// addElemLinkToGraph links a container type (e.g. a chan) to the named type
+// that its elements are, point to or hold, if any, like a struct's field is
+// linked to its type, with the label, if any, saying which of its parts
+// refers to it (e.g. a map's "key").
+func addElemLinkToGraph(p *pkg, obj types.Object, elem types.Type, label, pkgName string) {
+	named := namedTypeOf(elem)
+	if named == nil || named.Obj().Pkg() == nil {
+		return
+	}
+	toTypePkgName := pkgName
+	if path := named.Obj().Pkg().Path(); path != "" {
+		toTypePkgName = relativePkgPath(path, p.rootPkgName)
+	}
+	p.nodeLinks = append(p.nodeLinks, graphNodeLink{
+		fromStructTypeId: getTypeId(obj.Type(), obj.Pkg(), pkgName),
+		toTypePkgName:    toTypePkgName,
+		toTypeName:       named.Obj().Name(),
+		label:            label,
+		fromTypeObj:      obj,
+		toTypeObj:        named.Obj(),
+	})
 }
 
 func addSliceToGraph(dg *graphNode, obj types.Object, s *types.Slice, pkgName string, p *pkg) { //, indentLevel int) {
-	typeId := getTypeId(obj.Type(), obj.Pkg().Name(), pkgName)
+	typeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
 
 	node := &graphNode{
 		pkgName:              pkgName,
 		typeId:               typeId,
 		typeType:             "slice",
-		typeUnderlyingType:   s.String(),
+		typeUnderlyingType:   typeString(s, obj.Pkg()),
+		typeName:             obj.Name(),
+		typeNodes:            map[string]*graphNode{},
+		typeStructFields:     map[string]*structField{},
+		typeInterfaceMethods: map[string]string{},
+		typeObj:              obj,
+	}
+	deepSetNodeOnSubPkg(p, node, pkgName)
+	dg.typeNodes[typeId] = node
+	addElemLinkToGraph(p, obj, s.Elem(), "", pkgName)
+}
+
+func addArrayToGraph(dg *graphNode, obj types.Object, a *types.Array, pkgName string, p *pkg) {
+	typeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
+
+	node := &graphNode{
+		pkgName:              pkgName,
+		typeId:               typeId,
+		typeType:             "array",
+		typeUnderlyingType:   typeStringIn(a, obj.Pkg()),
 		typeName:             obj.Name(),
 		typeNodes:            map[string]*graphNode{},
 		typeStructFields:     map[string]*structField{},
 		typeInterfaceMethods: map[string]string{},
+		typeObj:              obj,
 	}
 	deepSetNodeOnSubPkg(p, node, pkgName)
 	dg.typeNodes[typeId] = node
+	addElemLinkToGraph(p, obj, a.Elem(), "", pkgName)
 }
 
 func addMapToGraph(dg *graphNode, obj types.Object, m *types.Map, pkgName string, p *pkg) { //, indentLevel int) {
-	typeId := getTypeId(obj.Type(), obj.Pkg().Name(), pkgName)
+	typeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
 
-	// fmt.Printf("Adding map to graph: %v, %v, %v\n", pkgName, typeId, m.String())
-	// fmt.Printf("                   : %v, %T, %v, %v, %v\n", obj, obj, obj.Name(), m.String(), m.Elem())
-	// fmt.Printf("Adding map %s, %s\n", typeId, m)
 	node := &graphNode{
 		pkgName:              pkgName,
 		typeId:               typeId,
 		typeType:             "map",
 		typeName:             obj.Name(),
 		typeNodes:            map[string]*graphNode{},
-		typeMapType:          m.String(),
+		typeMapType:          typeStringIn(m, obj.Pkg()),
 		typeStructFields:     map[string]*structField{},
 		typeInterfaceMethods: map[string]string{},
+		typeObj:              obj,
 	}
 	deepSetNodeOnSubPkg(p, node, pkgName)
 	dg.typeNodes[typeId] = node
+	addElemLinkToGraph(p, obj, m.Key(), "key", pkgName)
+	addElemLinkToGraph(p, obj, m.Elem(), "value", pkgName)
 }
 
 func addSignatureToGraph(dg *graphNode, obj types.Object, s *types.Signature, pkgName string, p *pkg) { //, indentLevel int) {
-	typeId := getTypeId(obj.Type(), obj.Pkg().Name(), pkgName)
-	typeString := obj.Type().String()
-	// TODO: how can we escape in the label instead of removing {}?
-	typeString = strings.Replace(strings.Replace(typeString, "{", "", -1), "}", "", -1)
+	typeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
 
 	node := &graphNode{
 		pkgName:              pkgName,
 		typeId:               typeId,
 		typeType:             "signature",
-		typeName:             typeString,
+		typeName:             obj.Name(),
 		typeNodes:            map[string]*graphNode{},
 		typeStructFields:     map[string]*structField{},
 		typeInterfaceMethods: map[string]string{},
+		typeSignatureRows:    signatureRowsOf(s, obj.Pkg()),
+		typeObj:              obj,
 	}
 	deepSetNodeOnSubPkg(p, node, pkgName)
 	dg.typeNodes[typeId] = node
+	for i := 0; i < s.Params().Len(); i++ {
+		addElemLinkToGraph(p, obj, s.Params().At(i).Type(), "param", pkgName)
+	}
+	for i := 0; i < s.Results().Len(); i++ {
+		addElemLinkToGraph(p, obj, s.Results().At(i).Type(), "result", pkgName)
+	}
 }
 
-func addPointerToGraph(dg *graphNode, obj types.Object, pointer *types.Pointer, pkgName string, p *pkg) { //, indentLevel int) {
-	// TODO finish? make sure it looks like a pointer
-	// dg.typeNodes[typeId] = &graphNode{
-	// pkgName:            pkgName,
-	//	typeId: typeId,
-	// 	typeType: "pointer",
-	// 	typeName: p.String(),
-	//  typeNodes: map[string]*graphNode{},
-	// }
+// signatureRowsOf returns the rows of a func type's parameters, and then
+// its results, with their types spelled as in pkg's source.
+func signatureRowsOf(s *types.Signature, pkg *types.Package) []signatureRow {
+	var rows []signatureRow
+	for i := 0; i < s.Params().Len(); i++ {
+		param := s.Params().At(i)
+		typeName := typeStringIn(param.Type(), pkg)
+		if s.Variadic() && i == s.Params().Len()-1 {
+			typeName = "..." + typeStringIn(param.Type().(*types.Slice).Elem(), pkg)
+		}
+		rows = append(rows, signatureRow{name: param.Name(), typeName: typeName})
+	}
+	for i := 0; i < s.Results().Len(); i++ {
+		result := s.Results().At(i)
+		rows = append(rows, signatureRow{name: result.Name(), typeName: typeStringIn(result.Type(), pkg), result: true})
+	}
+	return rows
+}
+
+func addPointerToGraph(dg *graphNode, obj types.Object, pointer *types.Pointer, pkgName string, p *pkg) {
+	typeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
+
+	node := &graphNode{
+		pkgName:              pkgName,
+		typeId:               typeId,
+		typeType:             "pointer",
+		typeUnderlyingType:   typeStringIn(pointer, obj.Pkg()),
+		typeName:             obj.Name(),
+		typeNodes:            map[string]*graphNode{},
+		typeStructFields:     map[string]*structField{},
+		typeInterfaceMethods: map[string]string{},
+		typeObj:              obj,
+	}
+	deepSetNodeOnSubPkg(p, node, pkgName)
+	dg.typeNodes[typeId] = node
+	addElemLinkToGraph(p, obj, pointer.Elem(), "", pkgName)
 }
 
 func addStructToGraph(dg *graphNode, obj types.Object, ss *types.Struct, pkgName string, p *pkg) {
-	typeId := getTypeId(obj.Type(), obj.Pkg().Name(), pkgName)
+	typeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
 
 	node := &graphNode{
 		pkgName:              pkgName,
@@ -542,13 +1589,15 @@ func addStructToGraph(dg *graphNode, obj types.Object, ss *types.Struct, pkgName
 		typeNodes:            map[string]*graphNode{},
 		typeStructFields:     map[string]*structField{},
 		typeInterfaceMethods: map[string]string{},
+		typeObj:              obj,
 	}
 
 	for i := 0; i < ss.NumFields(); i++ {
 		f := ss.Field(i)
 		fieldPkgName := f.Pkg().Name()
-		fieldTypeId := labelizeName(fieldPkgName, f.Type().String()) // TODO: this might break when the type of a struct field is from a different package
-		fieldTypeName := stripPkgPrefix(stripPointer(f.Type().String()), fieldPkgName)
+		fieldTypeString := typeString(f.Type(), obj.Pkg())
+		fieldTypeId := labelizeName(fieldPkgName, fieldTypeString) // TODO: this might break when the type of a struct field is from a different package
+		fieldTypeName := stripPkgPrefix(stripPointer(fieldTypeString), fieldPkgName)
 
 		node.typeStructFields[f.Name()] = &structField{
 			structFieldId:       fieldTypeId,
@@ -589,75 +1638,116 @@ func stripPointer(typeName string) string {
 	return strings.TrimPrefix(typeName, "*")
 }
 
+// namedTypeOf returns the named type that t is, or that it points to or
+// holds the elements of (e.g. map[string]*T), if any.
+func namedTypeOf(t types.Type) *types.Named {
+	switch t := t.(type) {
+	case *types.Named:
+		return t
+	case *types.Pointer:
+		return namedTypeOf(t.Elem())
+	case *types.Slice:
+		return namedTypeOf(t.Elem())
+	case *types.Array:
+		return namedTypeOf(t.Elem())
+	case *types.Map:
+		return namedTypeOf(t.Elem())
+	case *types.Chan:
+		return namedTypeOf(t.Elem())
+	}
+	return nil
+}
+
+// relativePkgPath returns the path of a package relative to the graphed
+// package, e.g. "a/cfg" for example.com/foo/a/cfg in example.com/foo, or ""
+// for the graphed package itself. Packages outside of it keep their import
+// path, even if it starts with the same characters (e.g. example.com/foobar).
+func relativePkgPath(importPath, rootPkgName string) string {
+	if importPath == rootPkgName {
+		return ""
+	}
+	if strings.HasPrefix(importPath, rootPkgName+"/") {
+		return strings.TrimPrefix(importPath, rootPkgName+"/")
+	}
+	return importPath
+}
+
 func stripPkgPrefix(typeName, pkgName string) string {
 	return strings.TrimPrefix(strings.TrimPrefix(typeName, pkgName), "/")
 }
 
 func addStructLinksToGraph(p *pkg, obj types.Object, ss *types.Struct, pkgName string) {
-	structTypeId := getTypeId(obj.Type(), obj.Pkg().Name(), pkgName)
+	structTypeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
 
 	// TODO: move this into the printTypeLinks() func?
 	for i := 0; i < ss.NumFields(); i++ {
 		f := ss.Field(i)
-		fieldId := getTypeId(f.Type(), f.Pkg().Name(), pkgName)
-		fTypeType := reflect.TypeOf(f.Type()).String()
-
-		// HACK: This is the only way I know to get the typeId when the pkgname
-		// is a fully-qualified package, which doesn't really work with getTypeId() :shruggie:
-		strippedType := stripPkgPrefix(stripPointer(f.Type().String()), p.rootPkgName)
-		pkgName := pkgName
-		typeName := strippedType
-		if strings.Contains(strippedType, ".") {
-			split := strings.Split(strippedType, ".")
-			pkgName = split[0]
-			typeName = split[1]
+
+		// Link to the named type that the field is, points to or holds, by
+		// its package's path, rather than by parsing the field's type
+		// string. Fields of basic types, containers of them, unnamed
+		// structs, interfaces and funcs, type parameters and predeclared
+		// types like error have nothing in the graph to link to.
+		named := namedTypeOf(f.Type())
+		if named == nil || named.Obj().Pkg() == nil {
+			continue
 		}
 		toTypePkgName := pkgName
-		toTypeTypeName := typeName
-
-		// Link to underlying type instead of slice-of-underlying type
-		if containerType := getContainerType(f.Type()); containerType != nil {
-			// TODO: pkgName may be wrong here, it could be another package. How to fix?
-			toTypeTypeName = containerType.String()
+		if path := named.Obj().Pkg().Path(); path != "" {
+			toTypePkgName = relativePkgPath(path, p.rootPkgName)
 		}
+		nodeLink := graphNodeLink{
+			fromStructTypeId:    structTypeId,
+			fromStructFieldName: f.Name(),
+			toTypePkgName:       toTypePkgName,
+			toTypeName:          named.Obj().Name(),
+			fromTypeObj:         obj,
+			toTypeObj:           named.Obj(),
+		}
+		if f.Embedded() {
+			nodeLink.embedded = true
+			nodeLink.arrowhead = embeddedArrowhead
+		}
+		p.nodeLinks = append(p.nodeLinks, nodeLink)
+	}
+}
 
-		// fmt.Printf(
-		// 	"debug: adding struct field link: %v, %v, %v, %v, %v, %v\n",
-		// 	f.Name(),
-		// 	pkgName,
-		// 	toTypePkgName,
-		// 	toTypeTypeName,
-		// 	fieldId,
-		// 	fTypeType,
-		// )
-
-		// Don't link to basic types or containers of basic types.
-		isSignature := fTypeType == "*types.Signature"
-		isBasic := fTypeType == "*types.Basic"
-		// HACK: better way to do this, e.g. checking NumExplicitMethods > 0?
-		isEmptyInterface := fieldId == "time_interfacebraces"
-		isContainerOfBuiltinType := isContainerOfBuiltinType(f.Type())
-		// isEmptyStruct := fieldId == "t"
-
-		if !isEmptyInterface && !isSignature && !isBasic && !isContainerOfBuiltinType {
-			p.nodeLinks = append(p.nodeLinks, graphNodeLink{
-				fromStructTypeId:    structTypeId,
-				fromStructFieldName: f.Name(),
-				toTypePkgName:       toTypePkgName,
-				toTypeName:          toTypeTypeName,
-			})
+// embeddedArrowhead is the arrowhead of the arrows from embedded fields, and
+// from interfaces to the ones they embed, which tells them apart from the
+// arrows from ordinary fields: the struct or interface is, in part, the type
+// that it embeds.
+const embeddedArrowhead = "diamond"
+
+// addEmbeddedInterfaceLinksToGraph links an interface to the named
+// interfaces that it embeds. Other embedded types, like the unions of
+// constraints, have nothing in the graph to link to.
+func addEmbeddedInterfaceLinksToGraph(p *pkg, obj types.Object, i *types.Interface, pkgName string) {
+	typeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
+	for idx := 0; idx < i.NumEmbeddeds(); idx++ {
+		named, ok := i.EmbeddedType(idx).(*types.Named)
+		if !ok || named.Obj().Pkg() == nil {
+			continue
 		}
+		p.nodeLinks = append(p.nodeLinks, graphNodeLink{
+			fromStructTypeId: typeId,
+			toTypePkgName:    relativePkgPath(named.Obj().Pkg().Path(), p.rootPkgName),
+			toTypeName:       named.Obj().Name(),
+			arrowhead:        embeddedArrowhead,
+			embedded:         true,
+			fromTypeObj:      obj,
+			toTypeObj:        named.Obj(),
+		})
 	}
 }
 
 func addInterfaceToGraph(dg *graphNode, obj types.Object, i *types.Interface, pkgName string, p *pkg) {
-	typeId := getTypeId(obj.Type(), obj.Pkg().Name(), pkgName)
+	typeId := getTypeId(obj.Type(), obj.Pkg(), pkgName)
 
 	methods := map[string]string{}
 	if i.NumMethods() > 0 {
 		for idx := 0; idx < i.NumMethods(); idx += 1 {
 			m := i.Method(idx)
-			methods[m.Name()] = m.Type().String()
+			methods[m.Name()] = typeString(m.Type(), obj.Pkg())
 		}
 	}
 	node := &graphNode{
@@ -668,119 +1758,45 @@ func addInterfaceToGraph(dg *graphNode, obj types.Object, i *types.Interface, pk
 		typeNodes:            map[string]*graphNode{},
 		typeStructFields:     map[string]*structField{},
 		typeInterfaceMethods: methods,
+		typeTerms:            typeTermsOf(i, obj.Pkg()),
+		typeObj:              obj,
 	}
 
 	dg.typeNodes[typeId] = node
 	deepSetNodeOnSubPkg(p, node, pkgName)
+	addEmbeddedInterfaceLinksToGraph(p, obj, i, pkgName)
 }
 
-func escapeHtml(s string) string {
-	return strings.Replace(
-		strings.Replace(
-			s,
-			"<",
-			"&lt;",
-			-1,
-		),
-		">",
-		"&gt;",
-		-1,
-	)
-}
-
-func getTypeAssertion(t types.Type) types.Type {
-	switch typeType := t.(type) {
-	default:
-		return typeType
-	}
-}
-
-func getContainerType(t types.Type) types.Type {
-	var containerType types.Type
-	switch typeType := t.(type) {
-	case *types.Array:
-		containerType = getTypeAssertion(typeType.Elem())
-	case *types.Map:
-		containerType = getTypeAssertion(typeType.Elem())
-	case *types.Chan:
-		containerType = getTypeAssertion(typeType.Elem())
-	case *types.Slice:
-		containerType = getTypeAssertion(typeType.Elem())
-	}
-	return containerType
-}
+func getTypeId(t types.Type, typePkg *types.Package, originalPkgName string) string {
+	var typeId, typeName string
 
-// For chans, slices, etc that have an underlying type.
-func isContainerOfBuiltinType(t types.Type) bool {
-	switch containerType := t.(type) {
-	case *types.Slice:
-		switch containerUnderlyingType := containerType.Elem().(type) {
-		default:
-			switch containerUnderlyingType.(type) {
-			case *types.Named:
-				return false
-			default:
-				return true
-			}
-		}
-	case *types.Chan:
-		switch containerUnderlyingType := containerType.Elem().(type) {
-		default:
-			switch containerUnderlyingType.(type) {
-			case *types.Named:
-				return false
-			default:
-				return true
-			}
-		}
-	case *types.Array:
-		switch containerUnderlyingType := containerType.Elem().(type) {
-		default:
-			switch containerUnderlyingType.(type) {
-			case *types.Named:
-				return false
-			default:
-				return true
-			}
-		}
-	case *types.Map:
-		switch containerUnderlyingType := containerType.Elem().(type) {
-		default:
-			switch containerUnderlyingType.(type) {
-			case *types.Named:
-				return false
-			default:
-				return true
-			}
-		}
-	default:
-		return false // not actually a slice
+	// Named types are identified by just their name, without their type
+	// parameters (e.g. List, not List[T any]), as the fields that refer to
+	// them (e.g. List[int]) are linked to them.
+	if named, ok := t.(*types.Named); ok {
+		return labelizeName(originalPkgName, named.Obj().Name())
 	}
-}
-
-func getTypeId(t types.Type, typePkgName, originalPkgName string) string {
-	var typeId, typeName string
 
 	switch namedTypeType := t.Underlying().(type) {
 	case *types.Basic:
-		typeName = t.String()
+		typeName = typeString(t, typePkg)
 	case *types.Chan:
-		typeName = t.String()
+		typeName = typeString(t, typePkg)
 	case *types.Slice:
-		typeName = t.String()
+		typeName = typeString(t, typePkg)
 	case *types.Struct:
-		typeName = t.String()
+		typeName = typeString(t, typePkg)
 	case *types.Interface:
-		typeName = t.String()
+		typeName = typeString(t, typePkg)
 		// TODO: do we need this still for interface?
 		// typeId = labelizeName(typePkgName, typeName)
 	case *types.Pointer:
 		pointerType := namedTypeType.Elem()
-		typeName = pointerType.String()
+		typeName = typeString(pointerType, typePkg)
 	case *types.Signature:
-		typeName = t.String()
+		typeName = typeString(t, typePkg)
 	case *types.Map:
-		typeName = t.String()
+		typeName = typeString(t, typePkg)
 	}
 
 	typeId = labelizeName(originalPkgName, typeName)
@@ -1,14 +1,2562 @@
 package pkgviz_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
 )
 
-func TestPlaceholder(t *testing.T) {
+// TestFixtures checks the graphs of the fixtures against their golden
+// files in testdata/fixtures, which are updated with:
+//
+//	go run ./cmd/pkgviz gen-fixtures -dir pkg/pkgviz/testdata/fixtures
+func TestFixtures(t *testing.T) {
+	for _, fixture := range pkgviz.Fixtures() {
+		graph, err := fixture.Build()
+		if err != nil {
+			t.Fatalf("%s: %v", fixture.Name, err)
+		}
+		dot, records, err := graph.GoldenFiles()
+		if err != nil {
+			t.Fatalf("%s: %v", fixture.Name, err)
+		}
+		for filename, actual := range map[string][]byte{fixture.Name + ".dot": dot, fixture.Name + ".json": records} {
+			expected, err := ioutil.ReadFile(filepath.Join("testdata", "fixtures", filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(actual, expected) {
+				t.Errorf("Expected %s to match its golden file, got %s", filename, actual)
+			}
+		}
+	}
+}
+
+func TestCacheInvalidateDir(t *testing.T) {
+	pkgName := "github.com/tiegz/pkgviz-go/pkg/fakepkg"
+	cache := pkgviz.NewCache()
+	expected := pkgviz.WriteGraphWithOptions(pkgName, pkgviz.Options{Cache: cache})
+
+	dirs := cache.Dirs()
+	if len(dirs) != 1 {
+		t.Fatalf("Expected 1 cached package dir, got %v", dirs)
+	}
+	if actual := pkgviz.WriteGraphWithOptions(pkgName, pkgviz.Options{Cache: cache}); len(actual) != len(expected) {
+		t.Errorf("Expected cached graph to match, got %s", actual)
+	}
+	if pkgNames := cache.InvalidateDir(dirs[0]); len(pkgNames) != 1 || pkgNames[0] != pkgName {
+		t.Errorf("Expected %s to be invalidated, got %v", pkgName, pkgNames)
+	}
+	if dirs := cache.Dirs(); len(dirs) != 0 {
+		t.Errorf("Expected no cached package dirs, got %v", dirs)
+	}
+}
+
+func TestCacheFocusDoesNotLeak(t *testing.T) {
+	pkgName := "github.com/tiegz/pkgviz-go/pkg/fakepkg"
+	cache := pkgviz.NewCache()
+	focused := pkgviz.WriteGraphWithOptions(pkgName, pkgviz.Options{Cache: cache, Focus: "anotherFakeStruct", Hops: 1})
+	if !strings.Contains(focused, "color='#f0ad4e'") {
+		t.Fatalf("Expected the focused type to be highlighted, got %s", focused)
+	}
+
+	// The cached nodes are shared with later graphs, which mustn't be
+	// highlighted.
+	if actual := pkgviz.WriteGraphWithOptions(pkgName, pkgviz.Options{Cache: cache}); strings.Contains(actual, "color='#f0ad4e'") {
+		t.Errorf("Expected no highlighted types in the unfocused graph, got %s", actual)
+	}
+}
+
+func TestWriteGraphFromFiles(t *testing.T) {
+	actual, err := pkgviz.WriteGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go":    "package main\n\nimport \"example.com/pasted/sub\"\n\ntype outer struct{ inner sub.Inner }\n",
+		"sub/sub.go": "package sub\n\ntype Inner struct{ name string }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"subgraph cluster_sub", ">outer<", ">Inner<", "port_inner"} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+}
+
+func TestWriteGraphFromFilesWithSyntaxError(t *testing.T) {
+	if _, err := pkgviz.WriteGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": "package main\n\ntype outer struct{",
+	}); err == nil {
+		t.Error("Expected a syntax error")
+	}
+}
+
+func TestSameNamedTypes(t *testing.T) {
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go":         "package main\n\nimport (\n\tapi \"example.com/pasted/api/config\"\n\t\"example.com/pasted/db/config\"\n)\n\ntype Config struct {\n\tAPI  []api.Config\n\tDB   map[string]*config.Config\n\tSelf *Config\n}\n",
+		"api/config/c.go": "package config\n\ntype Config struct{ Port int }\n",
+		"db/config/c.go":  "package config\n\nimport \"example.com/pasted/api/config\"\n\ntype Config struct{ Replicas []config.Config }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := graph.String()
+
+	for _, expected := range []string{
+		"config:port_API -> api_slash_config_config;",
+		"config:port_DB -> db_slash_config_config;",
+		"config:port_Self -> config;",
+		"db_slash_config_config:port_Replicas -> api_slash_config_config;",
+		"subgraph cluster_api_slash_config {",
+		"subgraph cluster_db_slash_config {",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	if strings.Contains(actual, "_dot_") {
+		t.Errorf("Expected every arrow to point to a graphed type, got %s", actual)
+	}
+}
+
+func TestIDCollisions(t *testing.T) {
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": "package main\n\ntype Node struct{ Next *node }\n\ntype node struct{ Prev *Node }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collisions := graph.IDCollisions()
+	if len(collisions) != 1 || collisions[0].ID != "node" || !reflect.DeepEqual(collisions[0].Types, []string{"Node", "node"}) {
+		t.Fatalf("Expected Node and node to collide, got %v", collisions)
+	}
+	renamed := collisions[0].IDs[1]
+	if renamed == "node" || !strings.HasPrefix(renamed, "node_") {
+		t.Fatalf("Expected node to get an ID of its own, got %v", collisions[0].IDs)
+	}
+
+	actual := graph.String()
+	for _, expected := range []string{
+		"node:port_Next -> " + renamed + ";",
+		renamed + ":port_Prev -> node;",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	if again, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": "package main\n\ntype Node struct{ Next *node }\n\ntype node struct{ Prev *Node }\n",
+	}); err != nil || !reflect.DeepEqual(again.IDCollisions(), collisions) {
+		t.Errorf("Expected the same IDs every time, got %v", again.IDCollisions())
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go":    "package main\n\nimport \"example.com/pasted/sub\"\n\ntype Hash [32]byte\n\ntype Root struct{ Sub sub.Sub }\n",
+		"sub/sub.go": "package sub\n\ntype Sub struct{ missing Missing }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every kind of named type is graphed, arrays included, so only the
+	// type error is a warning.
+	warnings := graph.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %v", warnings)
+	}
+	if w := warnings[0]; w.Kind != pkgviz.WarningTypeError || w.Package != "example.com/pasted/sub" || !strings.Contains(w.Message, "Missing") {
+		t.Errorf("Expected a type error in sub, got %v", w)
+	}
+	if actual := graph.String(); !strings.Contains(actual, "/* array */") || !strings.Contains(actual, ">Hash</td></tr><tr><td>[32]byte<") {
+		t.Errorf("Expected Hash to be drawn as an array, got %s", actual)
+	}
+}
+
+func TestNormalizeTypes(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\ntype Store interface {\n\tPut(key string, v interface{}) error\n\tGet(key string) (interface{}, error)\n\tDelete(key string)\n}\n\ntype Item struct {\n\tvalues map[string]interface{}\n\tany    any\n}\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{NormalizeTypes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := graph.String()
+
+	for _, expected := range []string{"map[string]any<", "func(key string, v any) error<", "func(key string) (any, error)<"} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	if strings.Contains(actual, "interface{}") {
+		t.Errorf("Expected no interface{}, got %s", actual)
+	}
+	if i, j, k := strings.Index(actual, ">Delete<"), strings.Index(actual, ">Get<"), strings.Index(actual, ">Put<"); i < 0 || i > j || j > k {
+		t.Errorf("Expected the interface's methods to be sorted, got %s", actual)
+	}
+}
+
+func TestModel(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":  "module example.com/pasted\n\ngo 1.16\n",
+		"main.go": "package main\n\ntype Status int\n\nconst (\n\tActive Status = iota\n\tInactive\n)\n\ntype Store interface {\n\tPut(key string) error\n\tGet(key string) (*Item, error)\n}\n\ntype Item struct {\n\tName   string\n\tStatus Status\n\tStore  Store\n}\n",
+	})
+	graph, err := pkgviz.GraphForPackage("example.com/pasted", pkgviz.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := graph.Model()
+
+	item, ok := model.Node("example.com/pasted.Item")
+	if !ok {
+		t.Fatalf("Expected an Item node, got %v", model.Nodes)
+	}
+	expectedFields := []pkgviz.Field{{Name: "Name", Type: "string"}, {Name: "Status", Type: "Status"}, {Name: "Store", Type: "Store"}}
+	if item.Kind != "struct" || !reflect.DeepEqual(item.Fields, expectedFields) || item.Position.Line != 15 || item.EndLine != 19 {
+		t.Errorf("Expected Item to be a struct with fields %v on lines 15-19, got %+v", expectedFields, item)
+	}
+
+	store, _ := model.Node("example.com/pasted.Store")
+	expectedMethods := []pkgviz.Method{{Name: "Get", Type: "func(key string) (*Item, error)"}, {Name: "Put", Type: "func(key string) error"}}
+	if store == nil || store.Kind != "interface" || !reflect.DeepEqual(store.Methods, expectedMethods) {
+		t.Errorf("Expected Store to be an interface with methods %v, got %+v", expectedMethods, store)
+	}
+
+	status, _ := model.Node("example.com/pasted.Status")
+	expectedConstants := []pkgviz.Constant{{Name: "Active", Value: "0"}, {Name: "Inactive", Value: "1"}}
+	if status == nil || status.Underlying != "int" || !reflect.DeepEqual(status.Constants, expectedConstants) {
+		t.Errorf("Expected Status to be an int with constants %v, got %+v", expectedConstants, status)
+	}
+
+	expectedEdges := []pkgviz.Edge{
+		{From: "example.com/pasted.Item", Field: "Status", To: "example.com/pasted.Status"},
+		{From: "example.com/pasted.Item", Field: "Store", To: "example.com/pasted.Store"},
+	}
+	if edges := model.EdgesFrom("example.com/pasted.Item"); !reflect.DeepEqual(edges, expectedEdges) {
+		t.Errorf("Expected edges %v, got %v", expectedEdges, edges)
+	}
+	if edges := model.EdgesTo("example.com/pasted.Store"); !reflect.DeepEqual(edges, expectedEdges[1:]) {
+		t.Errorf("Expected edges %v, got %v", expectedEdges[1:], edges)
+	}
+}
+
+func TestWritePlantUML(t *testing.T) {
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go":        "package main\n\nimport \"example.com/pasted/store\"\n\ntype Status int\n\nconst Active Status = 1\n\ntype Server struct {\n\tstore  store.Store\n\tstatus Status\n}\n",
+		"store/store.go": "package store\n\ntype Store interface {\n\tGet(key string) (string, error)\n}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := graph.WritePlantUML(&b); err != nil {
+		t.Fatal(err)
+	}
+	actual := b.String()
+
+	for _, expected := range []string{
+		"@startuml\n",
+		"package \"example.com/pasted\" {\n  class \"Server\" as example_com_pasted_Server {\n    {field} store : example.com/pasted/store.Store\n    {field} status : Status\n  }\n",
+		"  enum \"Status\" as example_com_pasted_Status <<int>> {\n    Active = 1\n  }\n",
+		"package \"example.com/pasted/store\" {\n  interface \"Store\" as example_com_pasted_store_Store {\n    {method} Get(key string) (string, error)\n  }\n}\n",
+		"example_com_pasted_Server --> example_com_pasted_store_Store : store\n",
+		"example_com_pasted_Server --> example_com_pasted_Status : status\n",
+		"@enduml\n",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected PlantUML to contain %q, got %s", expected, actual)
+		}
+	}
+}
+
+func TestExclude(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":         "module example.com/excl\n\ngo 1.16\n",
+		"excl.go":        "package excl\n\nimport (\n\t\"example.com/excl/mocks\"\n\t\"example.com/excl/store\"\n)\n\ntype Server struct {\n\tstore store.Store\n\tmock  *mocks.Store\n\thelper testHelper\n}\n\ntype testHelper struct{ n int }\n",
+		"store/store.go": "package store\n\ntype Store struct{ path string }\n",
+		"mocks/store.go": "package mocks\n\ntype Store struct{ calls int }\n",
+	})
+
+	graph, err := pkgviz.GraphForPackage("example.com/excl", pkgviz.Options{Dir: dir, Exclude: regexp.MustCompile(`/mocks$|\.testHelper$`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := graph.String()
+	for _, expected := range []string{">Server<", "server:port_store -> store_store;"} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %q, got %s", expected, actual)
+		}
+	}
+	for _, unexpected := range []string{"cluster_mocks", "colspan='2'>testHelper<", "port_mock ->", "port_helper ->", "mocks.Store</td>", "colspan='2'>Store</td></tr><tr><td port='port_calls'"} {
+		if strings.Contains(actual, unexpected) {
+			t.Errorf("Expected graph not to contain %q, got %s", unexpected, actual)
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":     "module example.com/deep\n\ngo 1.16\n",
+		"deep.go":    "package deep\n\nimport \"example.com/deep/a\"\n\ntype Root struct{ a a.A }\n",
+		"a/a.go":     "package a\n\nimport (\n\t\"example.com/deep/a/b\"\n\t\"example.com/deep/a/d\"\n)\n\ntype A struct {\n\tb b.B\n\td d.D\n}\n",
+		"a/b/b.go":   "package b\n\nimport \"example.com/deep/a/b/c\"\n\ntype B struct{ c c.C }\n",
+		"a/b/c/c.go": "package c\n\ntype C struct{}\n",
+		"a/d/d.go":   "package d\n\ntype D struct{}\n",
+	})
+
+	graph, err := pkgviz.GraphForPackage("example.com/deep", pkgviz.Options{Dir: dir, MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := graph.String()
+	if !strings.Contains(actual, ">Root<") || !strings.Contains(actual, ">A<") {
+		t.Errorf("Expected the packages down to the maximum depth to be graphed, got %s", actual)
+	}
+	if strings.Contains(actual, ">B<") || strings.Contains(actual, ">C<") || strings.Contains(actual, ">D<") {
+		t.Errorf("Expected the packages below the maximum depth not to be graphed, got %s", actual)
+	}
+	expected := []string{"example.com/deep/a has 2 subpackage(s) deeper than the maximum depth of 1, which aren't graphed: a/b, a/d"}
+	if notes := graph.Notes(); !reflect.DeepEqual(notes, expected) {
+		t.Errorf("Expected notes %v, got %v", expected, notes)
+	}
+
+	graph, err = pkgviz.GraphForPackage("./...", pkgviz.Options{Dir: dir, MaxDepth: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"example.com/deep/a/b has 1 subpackage(s) deeper than the maximum depth of 2, which aren't graphed: b/c"}
+	if notes := graph.Notes(); !reflect.DeepEqual(notes, expected) {
+		t.Errorf("Expected notes %v, got %v", expected, notes)
+	}
+}
+
+func TestGraphForPackages(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":           "module example.com/pasted\n\ngo 1.16\n",
+		"api/api.go":       "package api\n\nimport \"example.com/pasted/store\"\n\ntype Handler struct {\n\tstore *store.Store\n}\n",
+		"store/store.go":   "package store\n\ntype Store struct{ path string }\n",
+		"unused/unused.go": "package unused\n\ntype Unused struct{}\n",
+	})
+	graph, err := pkgviz.GraphForPackages([]string{"example.com/pasted/api", "./store"}, pkgviz.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := graph.String()
+
+	for _, expected := range []string{
+		"<b>example.com/pasted/api ./store</b>",
+		"subgraph cluster_api {",
+		"subgraph cluster_store {",
+		"api_handler:port_store -> store_store;",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %q, got %s", expected, actual)
+		}
+	}
+	if strings.Contains(actual, "Unused") {
+		t.Errorf("Expected graph not to contain the packages that weren't given, got %s", actual)
+	}
+}
+
+func TestWriteD2(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":         "module example.com/pasted\n\ngo 1.16\n",
+		"main.go":        "package main\n\nimport (\n\t\"time\"\n\n\t\"example.com/pasted/store\"\n)\n\ntype Items []string\n\ntype Server struct {\n\tstore   store.Store\n\tstarted time.Time\n}\n",
+		"store/store.go": "package store\n\ntype Store interface {\n\tGet(key string) (string, error)\n}\n",
+	})
+	graph, err := pkgviz.GraphForPackage("example.com/pasted", pkgviz.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := graph.WriteD2(&b); err != nil {
+		t.Fatal(err)
+	}
+	actual := b.String()
+
+	for _, expected := range []string{
+		"\"example.com/pasted\": {\n  \"Items\": {\n    label: \"Items []string\"\n  }\n  \"Server\": {\n    shape: class\n    \"store\": \"example.com/pasted/store.Store\"\n    \"started\": \"time.Time\"\n  }\n",
+		"  \"store\": {\n    \"Store\": {\n      shape: class\n      \"Get(key string)\": \"(string, error)\"\n    }\n  }\n}\n",
+		"\"time.Time\": {\n  style.stroke-dash: 3\n}\n",
+		"\"example.com/pasted\".\"Server\" -> \"example.com/pasted\".\"store\".\"Store\": \"store\"\n",
+		"\"example.com/pasted\".\"Server\" -> \"time.Time\": \"started\"\n",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected D2 to contain %q, got %s", expected, actual)
+		}
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": "package main\n\ntype Item struct{ Name string }\n\ntype store struct {\n\titems []*Item\n}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := graph.WriteGraphML(&b); err != nil {
+		t.Fatal(err)
+	}
+	actual := b.String()
+
+	for _, expected := range []string{
+		"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n",
+		"<key id=\"exported\" for=\"node\" attr.name=\"exported\" attr.type=\"boolean\"></key>",
+		"<graph id=\"example.com/pasted\" edgedefault=\"directed\">",
+		"<node id=\"example.com/pasted.Item\">\n      <data key=\"label\">Item</data>\n      <data key=\"kind\">struct</data>\n      <data key=\"package\">example.com/pasted</data>\n      <data key=\"exported\">true</data>\n    </node>",
+		"<data key=\"exported\">false</data>",
+		"<edge id=\"e0\" source=\"example.com/pasted.store\" target=\"example.com/pasted.Item\">\n      <data key=\"field\">items</data>\n      <data key=\"embedded\">false</data>\n    </edge>",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected GraphML to contain %q, got %s", expected, actual)
+		}
+	}
+}
+
+func TestFieldTypeResolution(t *testing.T) {
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go":    "package main\n\nimport \"example.com/pasted/a/b\"\n\ntype Root struct {\n\tBranch *b.Branch\n\terr    error\n\tanon   struct{ n int }\n}\n",
+		"a/b/b.go":   "package b\n\nimport \"example.com/pasted/a/b/c\"\n\ntype Branch struct {\n\tLeaves chan map[string][]*c.Leaf\n\tByName map[string]Branch\n}\n",
+		"a/b/c/c.go": "package c\n\ntype Leaf struct{ name string }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := graph.String()
+
+	for _, expected := range []string{
+		"root:port_Branch -> a_slash_b_branch;",
+		"a_slash_b_branch:port_Leaves -> a_slash_b_slash_c_leaf;",
+		"a_slash_b_branch:port_ByName -> a_slash_b_branch;",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	for _, unexpected := range []string{"port_err ->", "port_anon ->", "color='#cccccc'"} {
+		if strings.Contains(actual, unexpected) {
+			t.Errorf("Expected graph not to contain %s, got %s", unexpected, actual)
+		}
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	actual, err := pkgviz.WriteGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": `package main
+
+import (
+	"example.com/pasted/9p"
+	lib "example.com/pasted/my-lib"
+)
+
+type List[T any] struct {
+	items []T
+	next  *List[T]
+}
+
+type pair[K comparable, V any] struct {
+	key K
+	val V
+}
+
+type sep string
+
+const amp sep = "a&b<c>\"d\"\\"
+
+type handler func(map[string]interface{}, ...string) (chan<- pair[string, int], error)
+
+type everything struct {
+	ints   List[int]
+	pairs  []pair[string, *List[sep]]
+	meta   struct{ a, b int }
+	any    interface{}
+	set    map[string]struct{}
+	fn     func(interface{}) error
+	handle handler
+	out    chan<- sep
+	msg    p9.Msg
+	lib    *lib.Thing
+}
+`,
+		"9p/p9.go":    "package p9\n\ntype Msg struct{ size uint32 }\n",
+		"my-lib/l.go": "package lib\n\ntype Thing struct{ name string }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"list:port_next -> list;",
+		"everything:port_ints -> list;",
+		"everything:port_pairs -> pair;",
+		"everything:port_msg -> _9p_msg;",
+		"everything:port_lib -> my_dash_lib_thing;",
+		"subgraph cluster__9p {",
+		`label="my-lib";`,
+		"[]pair[string, *List[sep]]",
+		"chan&lt;- sep",
+		`= "a&amp;b&lt;c&gt;\"d\"\\"`,
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	for _, unexpected := range []string{"port_any ->", "port_set ->", "port_key ->", "port_val ->"} {
+		if strings.Contains(actual, unexpected) {
+			t.Errorf("Expected graph not to contain %s, got %s", unexpected, actual)
+		}
+	}
+
+	// Every node, arrow and cluster must be named by a valid ID, and every
+	// ampersand in a label must start an entity.
+	id := `[A-Za-z_][A-Za-z0-9_]*`
+	statement := regexp.MustCompile(`^\s*(?:` + strings.Join([]string{
+		`digraph V \{`,
+		`subgraph cluster_` + id + ` \{`,
+		id + `(?::port_` + id + `)? -> ` + id + `(?: \[.*\])?;`,
+		id + ` ?\[.*\];?`,
+		`label="(?:[^"\\]|\\.)*";`,
+		`/\*.*\*/`,
+		`\}`,
+		``,
+	}, "|") + `)\s*$`)
+	ampersand := regexp.MustCompile(`&(?:amp|lt|gt);`)
+	for _, line := range strings.Split(actual, "\n") {
+		if !statement.MatchString(line) {
+			t.Errorf("Expected a valid statement, got %s", line)
+		}
+		if strings.Count(line, "&") != len(ampersand.FindAllString(line, -1)) {
+			t.Errorf("Expected every & to be escaped, got %s", line)
+		}
+	}
+}
+
+func TestMainAndTestOnlyPackages(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":              "module example.com/tools\n\ngo 1.16\n",
+		"cmd/serve/main.go":   "package main\n\nimport \"example.com/tools/config\"\n\ntype server struct{ cfg config.Config }\n\nfunc main() {}\n",
+		"cmd/migrate/main.go": "package main\n\ntype step struct{ n int }\n\nfunc main() {}\n",
+		"config/config.go":    "package config\n\ntype Config struct{ Addr string }\n",
+		"e2e/e2e_test.go":     "package e2e\n\nimport \"testing\"\n\nfunc TestServe(t *testing.T) {}\n",
+		"e2e/helpers_test.go": "package e2e_test\n",
+		"docs/README.md":      "# Tools\n",
+	})
+
+	graph := pkgviz.BuildGraphWithOptions("./...", pkgviz.Options{Dir: dir})
+	actual := graph.String()
+	for _, expected := range []string{
+		"subgraph cluster_cmd_slash_serve {",
+		"subgraph cluster_cmd_slash_migrate {",
+		"cmd_slash_serve_server:port_cfg -> config_config;",
+		">step<",
+		`e2e__note0 [shape=note fontsize=10 fontcolor="#7f8183" color="#cccccc" label="only has tests (e2e_test.go, helpers_test.go), which aren't graphed"];`,
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	expected := []string{"example.com/tools/e2e only has tests (e2e_test.go, helpers_test.go), which aren't graphed"}
+	if actual := graph.Notes(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected notes %v, got %v", expected, actual)
+	}
+
+	actual = pkgviz.BuildGraphWithOptions("./cmd/...", pkgviz.Options{Dir: dir}).String()
+	for _, expected := range []string{"subgraph cluster_serve {", "subgraph cluster_migrate {", "serve_server:port_cfg -> example_dot_com_slash_tools_slash_config_config;"} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+
+	// Absolute directories are matched like relative ones.
+	actual = pkgviz.BuildGraphWithOptions(filepath.Join(dir, "config"), pkgviz.Options{Dir: dir}).String()
+	if !strings.Contains(actual, ">Config<") || strings.Contains(actual, ">step<") {
+		t.Errorf("Expected the package in the directory to be graphed, got %s", actual)
+	}
+
+	if _, err := pkgviz.GraphForPackage("./docs/...", pkgviz.Options{Dir: dir}); !errors.Is(err, pkgviz.ErrPackageNotFound) {
+		t.Errorf("Expected a pattern that matches no packages not to be found, got %v", err)
+	}
+}
+
+func TestListErrors(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.16\n",
+		"app.go": "package app\n\ntype App struct{}\n",
+	})
+
+	_, err := pkgviz.GraphForPackage("./missing", pkgviz.Options{Dir: dir})
+	var listErr *pkgviz.ListError
+	if !errors.Is(err, pkgviz.ErrPackageNotFound) || !errors.As(err, &listErr) || !strings.Contains(listErr.Stderr, "missing") {
+		t.Errorf("Expected a ListError for a missing package, got %v", err)
+	}
+	notes := pkgviz.BuildGraphWithOptions("./missing", pkgviz.Options{Dir: dir}).Notes()
+	if len(notes) != 1 || !strings.Contains(notes[0], "can't be graphed: ") {
+		t.Errorf("Expected a note that the package can't be graphed, got %v", notes)
+	}
+
+	if _, err := pkgviz.GraphForPackage("./...", pkgviz.Options{Dir: dir, ListTimeout: time.Nanosecond}); !errors.Is(err, pkgviz.ErrTimeout) {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", t.TempDir())
+	if _, err := pkgviz.GraphForPackage(".", pkgviz.Options{Dir: dir}); !errors.Is(err, pkgviz.ErrToolchainMissing) {
+		t.Errorf("Expected the go toolchain to be missing, got %v", err)
+	}
+}
+
+func TestGraphForWindows(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":          "module example.com/term\n\ngo 1.16\n",
+		"term.go":         "package term\n\ntype Terminal struct{ console console }\n",
+		"term_windows.go": "package term\n\ntype console struct{ handle uintptr }\n",
+		"term_unix.go":    "//go:build !windows\n// +build !windows\n\npackage term\n\ntype console struct{ fd int }\n",
+	})
+
+	graph, err := pkgviz.GraphForPackage(".", pkgviz.Options{Dir: dir, Env: append(os.Environ(), "GOOS=windows")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := graph.String()
+	for _, expected := range []string{"terminal:port_console -> console;", ">handle<"} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	if strings.Contains(actual, ">fd<") {
+		t.Errorf("Expected graph not to contain the unix console, got %s", actual)
+	}
+	declared := ""
+	for _, node := range graph.Records().Nodes {
+		if node.Name == "console" {
+			declared = filepath.Base(node.File)
+		}
+	}
+	if declared != "term_windows.go" {
+		t.Errorf("Expected console to be declared in term_windows.go, got %s", declared)
+	}
+}
+
+func TestGraphForModules(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":              "module example.com/mono\n\ngo 1.16\n",
+		"mono.go":             "package mono\n\ntype Version struct{ Major int }\n",
+		"api/go.mod":          "module example.com/mono/api\n\ngo 1.16\n\nrequire example.com/mono/lib v0.0.0\n\nreplace example.com/mono/lib => ../lib\n",
+		"api/server/a.go":     "package server\n\nimport \"example.com/mono/lib/store\"\n\ntype Server struct{ db *store.DB }\n",
+		"lib/go.mod":          "module \"example.com/mono/lib\" // shared\n\ngo 1.16\n",
+		"lib/store/store.go":  "package store\n\ntype DB struct{ dsn string }\n",
+		"lib/testdata/go.mod": "module example.com/fixture\n",
+		"_old/go.mod":         "module example.com/old\n",
+	})
+
+	modules, err := pkgviz.FindModules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []pkgviz.Module{
+		{Path: "example.com/mono", Dir: dir},
+		{Path: "example.com/mono/api", Dir: filepath.Join(dir, "api")},
+		{Path: "example.com/mono/lib", Dir: filepath.Join(dir, "lib")},
+	}
+	if !reflect.DeepEqual(modules, expected) {
+		t.Fatalf("Expected modules %v, got %v", expected, modules)
+	}
+
+	graph, err := pkgviz.GraphForModules(modules, pkgviz.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := graph.String()
+	for _, expected := range []string{
+		"<b>example.com/mono</b>",
+		"version [",
+		`label="module example.com/mono/api";`,
+		`label="module example.com/mono/lib";`,
+		"api_slash_server_server:port_db -> lib_slash_store_db;",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+}
+
+func TestEmptyGraph(t *testing.T) {
+	files := map[string]string{"main.go": "package main\n\nfunc main() {}\n"}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `_note0 [shape=note fontsize=10 fontcolor="#7f8183" color="#cccccc" label="has no named types"];`
+	if actual := graph.String(); !strings.Contains(actual, expected) {
+		t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+	}
+	if actual := graph.Notes(); !reflect.DeepEqual(actual, []string{"example.com/pasted has no named types"}) {
+		t.Errorf("Expected a note that there are no named types, got %v", actual)
+	}
+
+	files["config.go"] = "package main\n\ntype config struct{ addr string }\n"
+	graph, err = pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{Focus: "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := graph.Notes(); !reflect.DeepEqual(actual, []string{"example.com/pasted has no named types that match the current filters"}) {
+		t.Errorf("Expected a note that no named types match the filters, got %v", actual)
+	}
+
+	files["server.go"] = "package main\n\ntype server struct{ cfg config }\n"
+	graph, err = pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{Focus: "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := graph.Notes(); len(actual) > 0 {
+		t.Errorf("Expected no notes, got %v", actual)
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go":    "package main\n\nimport \"example.com/pasted/sub\"\n\ntype outer struct{ inner sub.Inner }\n",
+		"sub/sub.go": "package sub\n\ntype Inner struct{ name string }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	n, err := graph.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(b.Len()) || !strings.HasPrefix(b.String(), "digraph V {") || !strings.HasSuffix(b.String(), "}\n") {
+		t.Errorf("Expected %d bytes of a whole graph, got %s", n, b.String())
+	}
+	for _, expected := range []string{"subgraph cluster_sub", "outer:port_inner -> sub_inner;"} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, b.String())
+		}
+	}
+
+	if _, err := graph.WriteTo(failingWriter{}); err == nil {
+		t.Error("Expected the error from the writer")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestReduceDetail(t *testing.T) {
+	files := map[string]string{
+		"main.go":    "package main\n\nimport \"example.com/pasted/sub\"\n\ntype status int\n\ntype outer struct {\n\ta, b sub.Inner\n\ts    status\n}\n",
+		"sub/sub.go": "package sub\n\ntype Inner struct{ name string }\n\ntype Other struct{ inner Inner }\n",
+	}
+	for _, test := range []struct {
+		options    pkgviz.Options
+		elided     []string
+		expected   []string
+		unexpected []string
+	}{
+		{
+			options:    pkgviz.Options{MaxNodes: 4, MaxEdges: 4},
+			expected:   []string{"outer:port_a -> sub_inner;", "subgraph cluster_sub"},
+			unexpected: []string{"merged the arrows"},
+		},
+		{
+			options:    pkgviz.Options{MaxEdges: 3},
+			elided:     []string{"hid the fields and methods of 3 types, and merged the arrows between each pair of types"},
+			expected:   []string{`outer -> sub_inner [penwidth=2 label="2"];`, "status", "subgraph cluster_sub"},
+			unexpected: []string{"port_a"},
+		},
+		{
+			options: pkgviz.Options{MaxNodes: 2},
+			elided: []string{
+				"hid the fields and methods of 3 types, and merged the arrows between each pair of types",
+				"dropped 1 basic type",
+				"collapsed 1 subpackage (2 types) into one node each",
+			},
+			expected:   []string{`outer -> package_sub [penwidth=2 label="2"];`, ">2 types<"},
+			unexpected: []string{"subgraph cluster_sub", "status", "package_sub -> package_sub"},
+		},
+	} {
+		graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := graph.Elided(); !reflect.DeepEqual(actual, test.elided) {
+			t.Errorf("Expected %v to be elided with %+v, got %v", test.elided, test.options, actual)
+		}
+		actual := graph.String()
+		for _, expected := range append(test.expected, test.elided...) {
+			if !strings.Contains(actual, expected) {
+				t.Errorf("Expected graph with %+v to contain %s, got %s", test.options, expected, actual)
+			}
+		}
+		for _, unexpected := range test.unexpected {
+			if strings.Contains(actual, unexpected) {
+				t.Errorf("Expected graph with %+v not to contain %s, got %s", test.options, unexpected, actual)
+			}
+		}
+	}
+}
+
+func TestOversize(t *testing.T) {
+	files := map[string]string{
+		"main.go":    "package main\n\nimport \"example.com/pasted/sub\"\n\ntype status int\n\ntype outer struct {\n\ta, b sub.Inner\n\ts    status\n}\n",
+		"sub/sub.go": "// Code generated by stringer. DO NOT EDIT.\n\npackage sub\n\ntype Inner struct{ name string }\n\ntype Other struct{ inner Inner }\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{MaxNodes: 4, MaxEdges: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := graph.Oversize(); report != nil {
+		t.Errorf("Expected no size report for a graph that fits, got %+v", report)
+	}
+
+	for _, test := range []struct {
+		options  pkgviz.Options
+		expected pkgviz.SizeReport
+	}{
+		{
+			options: pkgviz.Options{MaxNodes: 2},
+			expected: pkgviz.SizeReport{
+				Nodes:     4,
+				Edges:     4,
+				MaxNodes:  2,
+				Fits:      true,
+				Packages:  []pkgviz.SizeContributor{{"example.com/pasted", 2}, {"example.com/pasted/sub", 2}},
+				Hubs:      []pkgviz.SizeContributor{{"outer", 3}, {"sub.Inner", 3}, {"status", 1}, {"sub.Other", 1}},
+				Generated: 2,
+			},
+		},
+		{
+			options: pkgviz.Options{MaxNodes: 1, MaxEdges: 1},
+			expected: pkgviz.SizeReport{
+				Nodes:     4,
+				Edges:     4,
+				MaxNodes:  1,
+				MaxEdges:  1,
+				Packages:  []pkgviz.SizeContributor{{"example.com/pasted", 2}, {"example.com/pasted/sub", 2}},
+				Hubs:      []pkgviz.SizeContributor{{"outer", 3}, {"sub.Inner", 3}, {"status", 1}, {"sub.Other", 1}},
+				Generated: 2,
+			},
+		},
+	} {
+		graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := graph.Oversize(); actual == nil || !reflect.DeepEqual(*actual, test.expected) {
+			t.Errorf("Expected size report %+v with %+v, got %+v", test.expected, test.options, actual)
+		}
+	}
+}
+
+func TestMergedArrows(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":    "module example.com/events\n\ngo 1.16\n",
+		"events.go": "package events\n\nimport \"time\"\n\ntype span struct{ start, end time.Time }\n\ntype event struct {\n\tat   time.Time\n\tspan span\n}\n",
+		"mock.go":   "// Code generated by MockGen. DO NOT EDIT.\n\npackage events\n\ntype mockEvent struct{ first, last event }\n",
+	})
+	for _, countArrows := range []bool{false, true} {
+		graph := pkgviz.BuildGraphWithOptions("example.com/events", pkgviz.Options{Dir: dir, Generated: "group", CountMergedArrows: countArrows})
+		actual := graph.String()
+
+		if n := strings.Count(actual, "time_time [shape=plaintext"); n != 1 {
+			t.Errorf("Expected time.Time to be drawn once, got %d times in %s", n, actual)
+		}
+		if n := strings.Count(actual, "mockevent -> event"); n != 1 {
+			t.Errorf("Expected one arrow from mockEvent to event, got %d in %s", n, actual)
+		}
+		for _, expected := range []string{"span:port_start -> time_time;", "span:port_end -> time_time;"} {
+			if !strings.Contains(actual, expected) {
+				t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+			}
+		}
+		if counted := strings.Contains(actual, `mockevent -> event [label="×2"];`); counted != countArrows {
+			t.Errorf("Expected the arrow from mockEvent to event to be counted: %v, got %s", countArrows, actual)
+		}
+	}
+}
+
+func TestDiffGraphs(t *testing.T) {
+	base, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": "package main\n\ntype kept struct{ name string }\n\ntype changed struct{ a int; b string }\n\ntype removed int\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": "package main\n\ntype kept struct{ name string }\n\ntype changed struct{ a int64; c bool }\n\ntype added interface{ Close() error }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []pkgviz.TypeDiff{
+		{Name: "added", Kind: "interface", Change: pkgviz.TypeAdded},
+		{Name: "changed", Kind: "struct", Change: pkgviz.TypeChanged, Details: []string{
+			"changed field a from int to int64",
+			"removed field b string",
+			"added field c bool",
+		}},
+		{Name: "removed", Kind: "basic", Change: pkgviz.TypeRemoved},
+	}
+	if actual := pkgviz.DiffGraphs(base, head); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, actual)
+	}
+
+	// A new package's types are all added.
+	expected = []pkgviz.TypeDiff{
+		{Name: "added", Kind: "interface", Change: pkgviz.TypeAdded},
+		{Name: "changed", Kind: "struct", Change: pkgviz.TypeAdded},
+		{Name: "kept", Kind: "struct", Change: pkgviz.TypeAdded},
+	}
+	if actual := pkgviz.DiffGraphs(pkgviz.EmptyGraph("example.com/pasted"), head); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, actual)
+	}
+}
+
+func TestDiffAPI(t *testing.T) {
+	base, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": "package main\n\ntype Kept struct{ Name string; id int }\n\nfunc (k Kept) Close() {}\n\ntype Reader interface{ Read() }\n\ntype sealed interface{ Read(); seal() }\n\ntype Sealed sealed\n\ntype Removed int\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": "package main\n\ntype Kept struct{ Name []string; Age int; id string }\n\nfunc (k Kept) Close() error { return nil }\n\ntype Reader interface{ Read(); Close() }\n\ntype sealed interface{ Read(); Close(); seal() }\n\ntype Sealed sealed\n\ntype Added struct{}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []pkgviz.APIChange{
+		{Type: "Added", Change: pkgviz.TypeAdded, Details: "struct"},
+		{Type: "Kept", Member: "field Age", Change: pkgviz.TypeAdded, Details: "int"},
+		{Type: "Kept", Member: "field Name", Change: pkgviz.TypeChanged, Breaking: true, Details: "changed from string to []string"},
+		{Type: "Kept", Member: "method Close", Change: pkgviz.TypeChanged, Breaking: true, Details: "changed from func() to func() error"},
+		{Type: "Reader", Member: "method Close", Change: pkgviz.TypeAdded, Breaking: true, Details: "func()"},
+		{Type: "Removed", Change: pkgviz.TypeRemoved, Breaking: true, Details: "basic"},
+		{Type: "Sealed", Member: "method Close", Change: pkgviz.TypeAdded, Details: "func()"},
+	}
+	if actual := pkgviz.DiffAPI(base, head); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, actual)
+	}
+}
+
+func TestVisualDiff(t *testing.T) {
+	base, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": "package main\n\ntype kept struct{ a int; b string; other removed }\n\ntype removed struct{}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": "package main\n\ntype kept struct{ a int64; c bool; other added }\n\ntype added struct{}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph := pkgviz.VisualDiff(base, head).String()
+	for _, expected := range []string{
+		"bgcolor='#fff2cc' align='center' colspan='2'>kept<",  // changed type
+		"bgcolor='#c6efce' align='center' colspan='2'>added<", // added type
+		"color='#f4b6bd'>", // ghosted removed type
+		"bgcolor='#ffc7ce' align='center' colspan='2'>removed<",   // removed type
+		"<td port='port_a' align='left' bgcolor='#fff2cc'>a</td>", // changed field
+		"<td port='port_b' align='left' bgcolor='#ffc7ce'>b</td>", // removed field
+		"<td port='port_c' align='left' bgcolor='#c6efce'>c</td>", // added field
+		"[color=\"#3c9a4f\"]",              // added reference
+		"[color=\"#d9534f\" style=dashed]", // removed reference
+	} {
+		if !strings.Contains(graph, expected) {
+			t.Errorf("Expected graph to contain %q, got:\n%v", expected, graph)
+		}
+	}
+}
+
+func TestCycles(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\ntype a struct{ b *b }\n\ntype b struct{ c c }\n\ntype c struct{ a []a }\n\ntype list struct{ next *list; a a }\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{HighlightCycles: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []pkgviz.Cycle{{
+		Types: []string{"a", "b", "c"},
+		Cuts: []pkgviz.CycleCut{
+			{From: "a", Field: "b", To: "b"},
+			{From: "b", Field: "c", To: "c"},
+			{From: "c", Field: "a", To: "a"},
+		},
+	}}
+	if actual := graph.Cycles(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, actual)
+	}
+	dot := graph.String()
+	if n := strings.Count(dot, "color='#d9534f' bgcolor='#fde2e2'"); n != 3 {
+		t.Errorf("Expected 3 types to be highlighted, got %d:\n%v", n, dot)
+	}
+	if n := strings.Count(dot, "[color=\"#d9534f\"]"); n != 3 {
+		t.Errorf("Expected 3 references to be highlighted, got %d:\n%v", n, dot)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\ntype store interface{ get() cache }\n\ntype cache struct{}\n\ntype service struct {\n\tstore store\n\tcache *cache\n}\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{SizeBy: pkgviz.MetricFanOut})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := graph.Metrics()
+
+	fanIns, fanOuts := map[string]int{}, map[string]int{}
+	for _, m := range metrics.Types {
+		fanIns[m.Name], fanOuts[m.Name] = m.FanIn, m.FanOut
+	}
+	if expected := map[string]int{"store": 1, "cache": 1, "service": 0}; !reflect.DeepEqual(fanIns, expected) {
+		t.Errorf("Expected fan-ins %v, got %v", expected, fanIns)
+	}
+	if expected := map[string]int{"store": 0, "cache": 0, "service": 2}; !reflect.DeepEqual(fanOuts, expected) {
+		t.Errorf("Expected fan-outs %v, got %v", expected, fanOuts)
+	}
+
+	if len(metrics.Packages) != 1 {
+		t.Fatalf("Expected metrics for 1 package, got %v", metrics.Packages)
+	}
+	if p := metrics.Packages[0]; p.Types != 3 || p.Abstract != 1 || p.Instability != 0 {
+		t.Errorf("Expected 3 types, 1 of them abstract, and no instability, got %+v", p)
+	}
+
+	if dot := graph.String(); !strings.Contains(dot, "<font point-size='32'>service</font>") {
+		t.Errorf("Expected service to be sized by its fan-out:\n%v", dot)
+	}
+}
+
+func TestDependencyMatrix(t *testing.T) {
+	files := map[string]string{
+		"main.go":        "package main\n\nimport (\n\t\"example.com/pasted/api\"\n\t\"example.com/pasted/store\"\n)\n\ntype app struct {\n\tserver api.Server\n\tdb     *store.DB\n\tcache  store.DB\n}\n",
+		"api/server.go":  "package api\n\nimport \"example.com/pasted/store\"\n\ntype Server struct {\n\tdb   *store.DB\n\tnext *Server\n}\n",
+		"store/store.go": "package store\n\nimport \"time\"\n\ntype DB struct{ opened time.Time }\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matrix := graph.DependencyMatrix()
+
+	if expected := []string{"example.com/pasted", "example.com/pasted/api", "example.com/pasted/store"}; !reflect.DeepEqual(matrix.Packages, expected) {
+		t.Errorf("Expected packages %v, got %v", expected, matrix.Packages)
+	}
+	if expected := [][]int{{0, 1, 2}, {0, 0, 1}, {0, 0, 0}}; !reflect.DeepEqual(matrix.References, expected) {
+		t.Errorf("Expected references %v, got %v", expected, matrix.References)
+	}
+	if count := matrix.Count("example.com/pasted", "example.com/pasted/store"); count != 2 {
+		t.Errorf("Expected 2 references from the main package to store, got %d", count)
+	}
+	if count := matrix.Count("example.com/pasted/store", "time"); count != 0 {
+		t.Errorf("Expected references out of the graph not to be counted, got %d", count)
+	}
+}
+
+func TestArchitecture(t *testing.T) {
+	files := map[string]string{
+		"main.go":        "package main\n\nimport \"example.com/pasted/store\"\n\ntype app struct {\n\tdb    *store.DB\n\tcache store.DB\n}\n",
+		"store/store.go": "package store\n\ntype DB struct{ conn Conn }\n\ntype Conn struct{}\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arch := graph.Architecture()
+
+	expected := pkgviz.Architecture{
+		Package: "example.com/pasted",
+		Packages: []pkgviz.ArchPackage{
+			{Package: "example.com/pasted", Types: 1},
+			{Package: "example.com/pasted/store", Types: 2},
+		},
+		Dependencies: []pkgviz.ArchDependency{
+			{From: "example.com/pasted", To: "example.com/pasted/store", References: 2},
+		},
+	}
+	if !reflect.DeepEqual(arch, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, arch)
+	}
+
+	dot := arch.String()
+	for _, expected := range []string{
+		"\"example.com/pasted/store\" [label=<store<br/><font point-size='10' color='#7f8183'>2 types</font>> fontsize=36];",
+		"\"example.com/pasted\" -> \"example.com/pasted/store\" [penwidth=2 label=\"2\"];",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+}
+
+func TestUnusedTypes(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":     "module example.com/unused\n\ngo 1.16\n",
+		"lib/lib.go": "package lib\n\ntype Used struct{}\n\ntype Unused struct{ used Used }\n\ntype notExported struct{}\n",
+		"app/app.go": "package app\n\nimport l \"example.com/unused/lib\"\n\nvar _ l.Used\n",
+	})
+
+	opts := pkgviz.Options{Dir: dir}
+	graph := pkgviz.BuildGraphWithOptions("example.com/unused/lib", opts)
+	unused, err := pkgviz.UnusedTypes(graph, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unused) != 1 || unused[0].Package != "example.com/unused/lib" || unused[0].Name != "Unused" {
+		t.Fatalf("Expected only lib.Unused to be unused, got %v", unused)
+	}
+
+	pkgviz.HighlightUnusedTypes(graph, unused)
+	if dot := graph.String(); strings.Count(dot, "not used by other packages") != 1 {
+		t.Errorf("Expected Unused to be marked as unused, got %v", dot)
+	}
+}
+
+func TestUnusedTypesOfPattern(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":     "module example.com/unused\n\ngo 1.16\n",
+		"lib/lib.go": "package lib\n\ntype Used struct{}\n\ntype Unused struct{ used Used }\n",
+		"app/app.go": "package app\n\nimport l \"example.com/unused/lib\"\n\nvar _ l.Used\n",
+	})
+
+	// The packages' common prefix, example.com/unused, isn't a package.
+	opts := pkgviz.Options{Dir: dir}
+	graph, err := pkgviz.GraphForPackages([]string{"./..."}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused, err := pkgviz.UnusedTypes(graph, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unused) != 1 || unused[0].Package != "example.com/unused/lib" || unused[0].Name != "Unused" {
+		t.Fatalf("Expected only lib.Unused to be unused, got %v", unused)
+	}
+}
+
+func TestUnreadFieldsOfPattern(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":     "module example.com/unread\n\ngo 1.16\n",
+		"lib/lib.go": "package lib\n\ntype Server struct {\n\tAddr string\n\tID   int\n}\n",
+		"app/app.go": "package app\n\nimport \"example.com/unread/lib\"\n\nfunc Run(s *lib.Server) int { return s.ID }\n",
+	})
+
+	opts := pkgviz.Options{Dir: dir}
+	graph, err := pkgviz.GraphForPackages([]string{"./..."}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unread, err := pkgviz.UnreadFields(graph, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unread) != 1 || unread[0].Field != "Addr" {
+		t.Fatalf("Expected only Server.Addr to be unread, got %v", unread)
+	}
+}
+
+func TestUnreadFields(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":          "module example.com/unread\n\ngo 1.16\n",
+		"lib/lib.go":      "package lib\n\ntype Base struct{ ID int }\n\ntype Server struct {\n\tBase\n\tAddr    string\n\thits    int\n\tretries int\n\tName    string `json:\"name\"`\n}\n\nfunc (s *Server) Serve() {\n\ts.hits++\n\ts.retries = 3\n}\n",
+		"lib/lib_test.go": "package lib\n\nimport \"testing\"\n\nfunc TestServe(t *testing.T) {\n\ts := &Server{}\n\ts.Serve()\n\tif s.retries != 3 {\n\t\tt.Fail()\n\t}\n}\n",
+		"app/app.go":      "package app\n\nimport \"example.com/unread/lib\"\n\nfunc Run(s *lib.Server) int { return s.ID }\n",
+	})
+
+	opts := pkgviz.Options{Dir: dir}
+	graph := pkgviz.BuildGraphWithOptions("example.com/unread/lib", opts)
+	unread, err := pkgviz.UnreadFields(graph, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, u := range unread {
+		names = append(names, u.Type+"."+u.Field)
+	}
+	if expected := []string{"Server.Addr", "Server.hits"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected %v to be unread, got %v", expected, unread)
+	}
+
+	pkgviz.HighlightUnreadFields(graph, unread)
+	dot := graph.String()
+	for _, expected := range []string{
+		"<font point-size='9' color='#7f8183'>2 unread fields</font>",
+		"<tr><td port='port_hits' align='left' bgcolor='#eeeeee'>hits</td><td align='left' bgcolor='#eeeeee'><font color='#7f8183'>int</font> <font point-size='9' color='#7f8183'>never read</font></td></tr>",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+}
+
+func TestDepths(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\ntype handler struct{ svc *service }\n\ntype service struct{ repo repo; cfg config }\n\ntype repo struct{ cfg config; svc *service }\n\ntype config struct{ name string }\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{ColorByDepth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int{"config": 0, "service": 1, "repo": 1, "handler": 2}
+	if actual := graph.Depths(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+	dot := graph.String()
+	if n := strings.Count(dot, ">layer 1<"); n != 2 {
+		t.Errorf("Expected 2 types in layer 1, got %d:\n%v", n, dot)
+	}
+	if n := strings.Count(dot, "[color=\"#d9534f\" style=bold]"); n != 2 {
+		t.Errorf("Expected the 2 references in the cycle to stand out, got %d:\n%v", n, dot)
+	}
+}
+
+func TestWeightReferences(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\ntype id struct{ value string }\n\ntype edge struct{ from, to id }\n\ntype user struct{ id id }\n\ntype group struct{ id id; owner *user }\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{WeightReferences: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dot := graph.String()
+
+	for _, expected := range []string{
+		"edge -> id [penwidth=2 label=\"2\"];",
+		"user:port_id -> id;",
+		"group:port_owner -> user;",
+		">referred to by 3 types<",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+	if strings.Contains(dot, "edge:port_") {
+		t.Errorf("Expected edge's references to be weighted, got %s", dot)
+	}
+}
+
+func TestImplements(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+type store interface {
+	get(key string) string
+	put(key, value string)
+}
+
+type closer interface{ close() error }
+
+type memStore struct{}
+
+func (m *memStore) get(key string) string { return "" }
+func (m *memStore) put(key, value string) {}
+
+type readOnly struct{}
+
+func (readOnly) get(key string) string { return "" }
+func (readOnly) close() error          { return nil }
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := pkgviz.ImplementsMatrix{
+		Interfaces: []string{"closer", "store"},
+		Types:      []string{"memStore", "readOnly"},
+		Implementations: []pkgviz.Implementation{
+			{Interface: "closer", Type: "readOnly"},
+			{Interface: "store", Type: "memStore", Pointer: true},
+			{Interface: "store", Type: "readOnly", Missing: "put"},
+		},
+	}
+	if actual := graph.Implements(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestAlmostImplements(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+type store interface {
+	get(key string) string
+	put(key, value string)
+	close() error
+}
+
+type closer interface{ close() error }
+
+type cache struct{}
+
+func (c *cache) get(key string) string { return "" }
+func (c cache) put(key string)          {}
+
+type full struct{ cache }
+
+func (full) put(key, value string) {}
+func (full) close() error          { return nil }
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Missing every method of closer isn't almost implementing it, and full
+	// implements store through its embedded cache.
+	expected := []pkgviz.AlmostImplementation{
+		{Interface: "store", Type: "cache", Missing: []string{"close() error", "put(key string, value string)"}},
+	}
+	if actual := graph.AlmostImplements(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestImplementsArrows(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+type store interface{ get(key string) string }
+
+type anything interface{}
+
+type memStore struct{}
+
+func (m *memStore) get(key string) string { return "" }
+
+type readOnly struct{}
+
+func (readOnly) get(key string) string { return "" }
+
+type counter int
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{ImplementsArrows: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := graph.String()
+	for _, expected := range []string{
+		"memstore -> store [style=dashed arrowhead=empty];",
+		"readonly -> store [style=dashed arrowhead=empty];",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	// Everything implements the empty interface, which would be noise.
+	for _, unexpected := range []string{"counter -> store", "-> anything"} {
+		if strings.Contains(actual, unexpected) {
+			t.Errorf("Expected graph not to contain %s, got %s", unexpected, actual)
+		}
+	}
+	if records := graph.Records(); len(records.Edges) != 0 {
+		t.Errorf("Expected implementations to be left out of the records, got %v", records)
+	}
+}
+
+func TestFindImplementers(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":     "module example.com/impl\n\ngo 1.16\n",
+		"buf/buf.go": "package buf\n\ntype Buf struct{}\n\nfunc (b *Buf) Read(p []byte) (int, error) { return 0, nil }\n",
+		"src/src.go": "package src\n\nimport \"io\"\n\ntype Src struct{ r io.Reader }\n\nfunc (s Src) Read(p []byte) (int, error) { return s.r.Read(p) }\n\ntype notReader struct{}\n",
+	})
+
+	found, graph, err := pkgviz.FindImplementers("io.Reader", []string{"./..."}, pkgviz.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []pkgviz.Implementer{
+		{Package: "example.com/impl/buf", Name: "Buf", Pointer: true},
+		{Package: "example.com/impl/src", Name: "Src"},
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Expected %v, got %v", expected, found)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{">io.Reader<", "subgraph cluster_buf", ">through a pointer<", "src_src -> io_dot_reader [style=dashed arrowhead=empty];"} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+
+	if _, _, err := pkgviz.FindImplementers("io.Nope", []string{"./..."}, pkgviz.Options{Dir: dir}); err == nil {
+		t.Error("Expected an error for a missing interface")
+	}
+}
+
+func TestLayout(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\ntype padded struct {\n\tflag  bool\n\tcount int64\n\tok    bool\n}\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{Layout: true, LayoutArch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := graph.String()
+	for _, expected := range []string{
+		">24 bytes, 14 bytes padding, 8 bytes wasted<",
+		">@0, 1 byte + 7 bytes padding<",
+		">@8, 8 bytes<",
+		">@16, 1 byte + 7 bytes padding<",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	// The fields are in the order they're laid out in, not alphabetical.
+	if strings.Index(actual, "port_ok") < strings.Index(actual, "port_count") {
+		t.Errorf("Expected ok to be after count, got %s", actual)
+	}
+	if !strings.Contains(actual, "color='#f0ad4e'") {
+		t.Errorf("Expected padded to be flagged as wasteful, got %s", actual)
+	}
+
+	suggestions, err := graph.PaddingSuggestions("amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []pkgviz.PaddingSuggestion{{
+		Type:        "padded",
+		Size:        24,
+		OptimalSize: 16,
+		Fields:      []string{"count", "flag", "ok"},
+	}}
+	if !reflect.DeepEqual(suggestions, expected) {
+		t.Errorf("Expected %v, got %v", expected, suggestions)
+	}
+}
+
+func TestOversizedStructs(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\ntype big struct {\n\ta, b, c int64\n}\n\ntype small struct {\n\ta int64\n}\n",
+	}
+	budgets := []pkgviz.SizeBudget{{Packages: "example.com/other", MaxStructSize: 1}, {Packages: "example.com/...", MaxStructSize: 16}}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{SizeBudgets: budgets, LayoutArch: "amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oversized, err := graph.OversizedStructs(budgets, "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []pkgviz.OversizedStruct{{Package: "example.com/pasted", Name: "big", Size: 24, Budget: 16}}
+	if !reflect.DeepEqual(oversized, expected) {
+		t.Errorf("Expected %v, got %v", expected, oversized)
+	}
+
+	actual := graph.String()
+	for _, expected := range []string{"color='#d9534f'", ">24 bytes, over budget of 16 bytes<"} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	if strings.Count(actual, "over budget") != 1 {
+		t.Errorf("Expected only big to be over budget, got %s", actual)
+	}
+}
+
+func TestNoCopy(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\ntype noCopy struct{}\n\nfunc (*noCopy) Lock()   {}\nfunc (*noCopy) Unlock() {}\n\ntype counter struct {\n\tguards [2]noCopy\n}\n\ntype service struct {\n\tcounter\n\tshared *counter\n}\n\ntype plain struct {\n\tshared *counter\n}\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{NoCopy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := graph.String()
+	// A type with Lock and Unlock methods can't be copied, like a
+	// sync.Mutex, and neither can the structs that hold one by value.
+	if n := strings.Count(actual, ">no-copy (noCopy)<"); n != 3 {
+		t.Errorf("Expected noCopy, counter and service to be no-copy, got %d in %s", n, actual)
+	}
+	for _, expected := range []string{
+		`counter:port_guards -> nocopy [color="#8e44ad" style=bold label="by value"];`,
+		`service:port_counter -> counter [color="#8e44ad" style=bold arrowhead=diamond label="by value"];`,
+		"service:port_shared -> counter;",
+		"plain:port_shared -> counter;",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+}
+
+func TestContextFields(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":  "module example.com/jobs\n\ngo 1.16\n",
+		"jobs.go": "package jobs\n\nimport \"context\"\n\ntype job struct {\n\tctx context.Context\n\tid  int\n}\n\ntype request struct {\n\tctx context.Context\n}\n",
+	})
+	opts := pkgviz.Options{Dir: dir, ContextFields: true, ContextFieldsAllowed: []string{"request"}}
+	graph := pkgviz.BuildGraphWithOptions("example.com/jobs", opts)
+
+	expected := []pkgviz.ContextField{{Type: "job", Field: "ctx"}}
+	if actual := graph.ContextFields(opts.ContextFieldsAllowed); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+	actual := graph.String()
+	for _, expected := range []string{">stores a context.Context<", "context in struct", "bgcolor='#f9dedc'"} {
+		if strings.Count(actual, expected) == 0 {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	if n := strings.Count(actual, "stores a context.Context"); n != 1 {
+		t.Errorf("Expected only job to be marked, got %d in %s", n, actual)
+	}
+}
+
+func TestVars(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\ntype server struct{ addr string }\n\nvar defaultServer = &server{}\n\nvar fallback server\n\nvar names []string\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{Vars: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := graph.String()
+	for _, expected := range []string{
+		`defaultserver_var [shape=box, style="rounded,dashed", fontsize=10, color="#7f8183", label="var defaultServer"];`,
+		`defaultserver_var -> server [color="#7f8183" style=dashed arrowhead=empty];`,
+		`fallback_var -> server [color="#7f8183" style=dashed arrowhead=empty];`,
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	if strings.Contains(actual, "names") {
+		t.Errorf("Expected only vars of graphed types, got %s", actual)
+	}
+	if records := graph.Records(); len(records.Nodes) != 1 || len(records.Edges) != 0 {
+		t.Errorf("Expected vars to be left out of the records, got %v", records)
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+type server struct{ addr string }
+
+type route struct{ path string }
+
+func newServer(addr string, routes []route) *server { return nil }
+
+func serve(s *server, fallback *server) error { return nil }
+
+func (s *server) close() {}
+
+func main() {}
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{Funcs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := graph.String()
+	for _, expected := range []string{
+		`newserver_func [shape=box, style=rounded, fontsize=10, color="#7f8183", label="func newServer(addr string, routes []route) *server"];`,
+		`newserver_func -> route [color="#7f8183"];`,
+		`newserver_func -> server [color="#7f8183"];`,
+		`main_func [shape=box, style=rounded, fontsize=10, color="#7f8183", label="func main()"];`,
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	if n := strings.Count(actual, "serve_func -> server"); n != 1 {
+		t.Errorf("Expected one arrow from serve to server, got %d in %s", n, actual)
+	}
+	if strings.Contains(actual, "close") {
+		t.Errorf("Expected only package-level functions, not methods, got %s", actual)
+	}
+	if records := graph.Records(); len(records.Nodes) != 2 || len(records.Edges) != 0 {
+		t.Errorf("Expected funcs to be left out of the records, got %v", records)
+	}
+}
+
+func TestLayerViolations(t *testing.T) {
+	files := map[string]string{
+		"main.go":                "package main\n\nimport \"example.com/pasted/handlers\"\n\ntype app struct{ h handlers.Handler }\n",
+		"handlers/handlers.go":   "package handlers\n\nimport \"example.com/pasted/service\"\n\ntype Handler struct{ svc *service.Service }\n",
+		"service/service.go":     "package service\n\nimport \"example.com/pasted/store\"\n\ntype Service struct{ store store.Store }\n",
+		"store/store.go":         "package store\n\nimport \"example.com/pasted/store/cache\"\n\ntype Store struct{ cache cache.Cache }\n",
+		"store/cache/cache.go":   "package cache\n\nimport \"example.com/pasted/handlers/hooks\"\n\ntype Cache struct{ onEvict hooks.Hook }\n",
+		"handlers/hooks/hook.go": "package hooks\n\ntype Hook struct{ name string }\n",
+	}
+	layers := []pkgviz.Layer{
+		{Name: "handlers", Packages: []string{"handlers/..."}},
+		{Name: "services", Packages: []string{"service"}},
+		{Name: "repositories", Packages: []string{".../store/..."}},
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{Layers: layers})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []pkgviz.LayerViolation{{
+		From:      "store/cache.Cache",
+		Field:     "onEvict",
+		To:        "handlers/hooks.Hook",
+		FromLayer: "repositories",
+		ToLayer:   "handlers",
+	}}
+	if actual := graph.LayerViolations(layers); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"{ rank=same; layer_0; handlers_handler; handlers_slash_hooks_hook; }",
+		"{ rank=same; layer_2; store_slash_cache_cache; store_store; }",
+		"layer_1 -> layer_2 [style=invis];",
+		"store_slash_cache_cache:port_onEvict -> handlers_slash_hooks_hook [color=\"#d9534f\" style=bold];",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+}
+
+func TestCompareIntended(t *testing.T) {
+	files := map[string]string{
+		"main.go":        "package main\n\nimport \"example.com/pasted/store\"\n\ntype app struct {\n\tdb    *store.DB\n\tcache cache\n}\n\ntype cache struct{ db *store.DB }\n",
+		"store/store.go": "package store\n\ntype DB struct{}\n",
+	}
+	intended, err := pkgviz.ParseIntendedArchitecture("digraph intended {\n  # the app only talks to the store through a service\n  app -> service;\n  service -> store.DB [label=\"queries\"];\n  cache\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{Intended: &intended})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []pkgviz.Discrepancy{
+		{Kind: pkgviz.MissingType, Type: "service"},
+		{Kind: pkgviz.MissingReference, Type: "app", To: "service"},
+		{Kind: pkgviz.MissingReference, Type: "service", To: "store.DB"},
+		{Kind: pkgviz.UnexpectedReference, Type: "app", To: "cache"},
+		{Kind: pkgviz.UnexpectedReference, Type: "app", To: "store.DB"},
+		{Kind: pkgviz.UnexpectedReference, Type: "cache", To: "store.DB"},
+	}
+	if actual := graph.CompareIntended(intended); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"<td bgcolor='#ffc7ce' align='center' colspan='2'>service</td>",
+		"app -> service [color=\"#d9534f\" style=dashed label=\"missing\"];",
+		"app:port_db -> store_db [color=\"#3c9a4f\"];",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+
+	if _, err := pkgviz.ParseIntendedArchitecture("app -> service -> store.DB\n"); err == nil {
+		t.Error("Expected an error for a chain of references")
+	}
+}
+
+func TestInternalViolations(t *testing.T) {
+	files := map[string]string{
+		"main.go":                 "package main\n\nimport \"example.com/pasted/api\"\n\ntype app struct{ api api.Server }\n",
+		"api/server.go":           "package api\n\nimport \"example.com/pasted/api/internal/auth\"\n\ntype Server struct{ auth auth.Token }\n",
+		"api/internal/auth/tk.go": "package auth\n\ntype Token struct{ value string }\n",
+		"cli/cli.go":              "package cli\n\nimport \"example.com/pasted/api/internal/auth\"\n\ntype Command struct{ token auth.Token }\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []pkgviz.InternalViolation{{
+		From:  "cli.Command",
+		Field: "token",
+		To:    "api/internal/auth.Token",
+		Root:  "example.com/pasted/api",
+	}}
+	if actual := graph.InternalViolations(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"label=\"🔒 internal\";",
+		"graph[style=\"filled,dashed\" fillcolor=\"#f4f4f4\" color=\"#7f8183\"];",
+		"api_server:port_auth -> api_slash_internal_slash_auth_token [style=dashed];",
+		"cli_command:port_token -> api_slash_internal_slash_auth_token [color=\"#d9534f\" style=bold];",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+}
+
+func TestDocCoverage(t *testing.T) {
+	files := map[string]string{
+		"server.go": `package server
+
+// Server serves requests.
+type Server struct {
+	// Addr is the address to listen on.
+	Addr    string
+	Timeout int // in seconds
+	Handler Handler
+	conns   int
+}
+
+// Serve serves requests until the server is closed.
+func (s *Server) Serve() {}
+
+func (s *Server) Close() {}
+
+type Handler interface {
+	Handle(string)
+}
+
+type conn struct{ Remote string }
+`,
+		"client/client.go": "package client\n\n// Client calls a server.\ntype Client struct{}\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{DocCoverage: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []pkgviz.DocCoverage{
+		{Package: "example.com/pasted", Documented: 4, Total: 8, Undocumented: []string{"Handler", "Handler.Handle", "Server.Close", "Server.Handler"}},
+		{Package: "example.com/pasted/client", Documented: 1, Total: 1},
+	}
+	actual := graph.DocCoverage()
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+	if len(actual) > 0 && actual[0].Percent() != 50 {
+		t.Errorf("Expected 50%% coverage, got %v", actual[0].Percent())
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		">✎ 1 undocumented field, 1 undocumented method<",
+		">✎ no doc comment, 1 undocumented method<",
+		"<font point-size='9' color='#7f8183'>undocumented</font>",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+}
+
+func TestDocSummaries(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\n" +
+			"// Server serves requests. It's started by main.\ntype Server struct{ addr string }\n\n" +
+			"// cache holds the responses that were served most recently, by their request's URL.\ntype cache map[string]string\n\n" +
+			"type undocumented int\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{DocSummaries: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"<tr><td align='center' colspan='2'><font point-size='10' color='#7f8183'><i>Server serves requests.</i></font></td></tr>",
+		"<i>cache holds the responses that were<br/>served most recently, by their request's<br/>URL.</i>",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+	// The whole doc comment is only in the tooltip.
+	if strings.Contains(dot, "<i>Server serves requests. It's") {
+		t.Errorf("Expected only the first sentence of the doc comment, got %s", dot)
+	}
+	if strings.Count(dot, "<i>") != 2 {
+		t.Errorf("Expected only the documented types to have summaries, got %s", dot)
+	}
+}
+
+func TestDocTooltips(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\n" +
+			"// Server serves requests.\n//\n// It's started by \"main\".\ntype Server struct{ addr string }\n\n" +
+			"type undocumented int\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := graph.String()
+	expected := `server [shape=plaintext tooltip="Server serves requests.\n\nIt's started by \"main\"." label=<`
+	if !strings.Contains(dot, expected) {
+		t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+	}
+	if strings.Count(dot, "tooltip=") != 1 {
+		t.Errorf("Expected only the documented types to have tooltips, got %s", dot)
+	}
+}
+
+func TestShowPositions(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":   "module example.com/srv\n\ngo 1.16\n",
+		"srv.go":   "package srv\n\n// server serves.\ntype server struct{ addr string }\n",
+		"ports.go": "package srv\n\ntype (\n\tport  int\n\tports []port\n)\n",
+	})
+	graph, err := pkgviz.GraphForPackage(".", pkgviz.Options{Dir: dir, ShowPositions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"<tr><td align='right' colspan='2'><font point-size='8' color='#7f8183'>srv.go:4</font></td></tr></table> >];",
+		"<tr><td align='right' colspan='1'><font point-size='8' color='#7f8183'>ports.go:4</font></td></tr></table> >];",
+		">ports.go:5<",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+
+	graph, err = pkgviz.GraphForPackage(".", pkgviz.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dot := graph.String(); strings.Contains(dot, "srv.go:") {
+		t.Errorf("Expected no positions without ShowPositions, got %s", dot)
+	}
+
+	// Pasted source goes through the same options.
+	graph, err = pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", map[string]string{
+		"srv.go": "package srv\n\n// server serves.\ntype server struct{ addr string }\n",
+	}, pkgviz.Options{ShowPositions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dot := graph.String(); !strings.Contains(dot, ">srv.go:4<") {
+		t.Errorf("Expected pasted source to show positions, got %s", dot)
+	}
+}
+
+func TestConstructors(t *testing.T) {
+	files := map[string]string{
+		"main.go": "package main\n\ntype server struct{ addr string }\n\nfunc NewServer(addr string) (*server, error) { return nil, nil }\n\nfunc MustServer(addr string) server { return server{} }\n\nfunc Newton() *server { return nil }\n\nfunc NewServers() []server { return nil }\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{Constructors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := graph.String()
+	expected := "<tr><td align='left' colspan='2'><font point-size='9' color='#7f8183'>MustServer(addr string) server<br align='left'/>NewServer(addr string) (*server, error)</font></td></tr>"
+	if !strings.Contains(actual, expected) {
+		t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+	}
+	for _, unexpected := range []string{"Newton", "NewServers"} {
+		if strings.Contains(actual, unexpected) {
+			t.Errorf("Expected %s not to be a constructor, got %s", unexpected, actual)
+		}
+	}
+}
+
+func TestHarnessTypes(t *testing.T) {
+	files := map[string]string{
+		"main.go":            "package main\n\nimport \"example.com/pasted/lib\"\n\ntype app struct{ server lib.Server }\n",
+		"lib/lib.go":         "package lib\n\ntype Server struct{ conn conn }\n\ntype conn struct{}\n\ntype fixture struct{ server *Server }\n\nfunc (f fixture) start() {}\n",
+		"lib/lib_test.go":    "package lib\n\nvar _ = fixture{}\n",
+		"lib/export_test.go": "package lib_test\n\nimport \"example.com/pasted/lib\"\n\nvar _ lib.Server\n",
+	}
+
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{Harness: pkgviz.HarnessTag})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dot := graph.String()
+	for _, expected := range []string{
+		"app [shape=plaintext label=<<table border='2' cellborder='0' cellspacing='0' style='rounded' color='#c8a165'><tr><td bgcolor='#f5ecdc' align='center' colspan='2'>app</td></tr><tr><td align='center' colspan='2'><font point-size='9' color='#7f8183'>package main</font></td></tr>",
+		"<td bgcolor='#f5ecdc' align='center' colspan='2'>fixture</td></tr><tr><td align='center' colspan='2'><font point-size='9' color='#7f8183'>only used by tests</font></td></tr>",
+		"<td bgcolor='#e0ebf5' align='center' colspan='2'>Server</td>",
+		"<td bgcolor='#e0ebf5' align='center' colspan='2'>conn</td>",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+
+	graph, err = pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{Harness: pkgviz.HarnessHide})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dot = graph.String()
+	if strings.Contains(dot, ">app<") || strings.Contains(dot, ">fixture<") || strings.Contains(dot, "-> lib_server") {
+		t.Errorf("Expected the harness types and their references to be hidden, got %s", dot)
+	}
+	if !strings.Contains(dot, "lib_server:port_conn -> lib_conn;") {
+		t.Errorf("Expected the library's types to be kept, got %s", dot)
+	}
+}
+
+func TestConstantsOfBasicTypes(t *testing.T) {
+	files := map[string]string{
+		"status.go": `package status
+
+type Status int
+
+const (
+	Active Status = iota + 1
+	Closed
+	Unknown = Status(-1)
+)
+
+type Unit string
+
+const Meter Unit = "m<"
+
+const maxRetries = 3
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"<tr><td align='center'>int</td></tr>" +
+			"<tr><td align='left'>Active <font color='#7f8183'>= 1</font></td></tr>" +
+			"<tr><td align='left'>Closed <font color='#7f8183'>= 2</font></td></tr>" +
+			"<tr><td align='left'>Unknown <font color='#7f8183'>= -1</font></td></tr></table>",
+		"<tr><td align='center'>string</td></tr>" +
+			"<tr><td align='left'>Meter <font color='#7f8183'>= \"m&lt;\"</font></td></tr></table>",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+}
+
+func TestGeneratedTypes(t *testing.T) {
+	files := map[string]string{
+		"user.go":       "package users\n\ntype User struct{ profile *Profile }\n",
+		"user.pb.go":    "// Code generated by protoc-gen-go. DO NOT EDIT.\n// source: user.proto\n\npackage users\n\ntype Profile struct{ Name string }\n",
+		"mock_store.go": "package users\n\nimport \"example.com/users/gomock\"\n\ntype MockStore struct{ ctrl *gomock.Controller }\n",
+		"gen.go":        "// Code generated by hand-rolled script; DO NOT EDIT.\n\npackage users\n\ntype Role int\n",
+		"gomock/c.go":   "package gomock\n\ntype Controller struct{}\n",
+	}
+
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/users", files, pkgviz.Options{Generated: pkgviz.GeneratedGroup})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []pkgviz.GeneratedType{
+		{Package: "example.com/users", Name: "MockStore", Generator: "mockgen"},
+		{Package: "example.com/users", Name: "Profile", Generator: "protoc-gen-go"},
+		{Package: "example.com/users", Name: "Role", Generator: "unknown"},
+	}
+	if actual := graph.GeneratedTypes(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"subgraph cluster_generated_mockstore {",
+		"label=\"generated (mockgen, protoc-gen-go, unknown)\";",
+		"profile [shape=plaintext label=<<table border='1' cellborder='0' cellspacing='0' style='rounded' color='#cccccc'><tr><td bgcolor='#eeeeee' align='center'>Profile</td></tr></table> >];",
+		"user:port_profile -> profile;",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+
+	graph, err = pkgviz.BuildGraphFromFilesWithOptions("example.com/users", files, pkgviz.Options{Generated: pkgviz.GeneratedTag})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dot := graph.String(); !strings.Contains(dot, ">generated by protoc-gen-go<") {
+		t.Errorf("Expected Profile to be tagged, got %s", dot)
+	}
+}
+
+func TestJSONSchemas(t *testing.T) {
+	files := map[string]string{
+		"user.go": `package api
+
+import "example.com/api/address"
+
+type Base struct {
+	ID int64 ` + "`json:\"id,string\"`" + `
+}
+
+type User struct {
+	Base
+	Name    string            ` + "`json:\"name\"`" + `
+	Email   string            ` + "`json:\"email,omitempty\"`" + `
+	Secret  string            ` + "`json:\"-\"`" + `
+	Friends []*User           ` + "`json:\"friends\"`" + `
+	Labels  map[string]string ` + "`json:\"labels\"`" + `
+	Home    *address.Address  ` + "`json:\"home\"`" + `
+	age     int
+}
+`,
+		"address/address.go": "package address\n\ntype Address struct{ Street string }\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/api", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemas := graph.JSONSchemas()
+	var names []string
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if expected := []string{"Base", "User", "address.Address"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected schemas of %v, got %v", expected, names)
+	}
+
+	actual, err := json.Marshal(schemas["User"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"User","type":"object",` +
+		`"properties":{"email":{"type":"string"},"friends":{"type":"array","items":{"$ref":"#"}},` +
+		`"home":{"$ref":"#/$defs/address.Address"},"id":{"type":"string"},` +
+		`"labels":{"type":"object","additionalProperties":{"type":"string"}},"name":{"type":"string"}},` +
+		`"required":["friends","id","labels","name"],` +
+		`"$defs":{"address.Address":{"type":"object","properties":{"Street":{"type":"string"}},"required":["Street"]}}}`
+	if string(actual) != expected {
+		t.Errorf("Expected %s, got %s", expected, actual)
+	}
+}
+
+func TestDBSchema(t *testing.T) {
+	files := map[string]string{
+		"models.go": `package models
+
+type User struct {
+	ID        uint    ` + "`gorm:\"primaryKey\"`" + `
+	Name      string  ` + "`gorm:\"column:full_name\"`" + `
+	CompanyID uint
+	Company   Company
+	Orders    []Order ` + "`gorm:\"foreignKey:BuyerID\"`" + `
+	Password  string  ` + "`gorm:\"-\"`" + `
+}
+
+type Company struct {
+	ID uint ` + "`gorm:\"primaryKey\"`" + `
+}
+
+type Order struct {
+	ID      uint ` + "`gorm:\"primaryKey\"`" + `
+	BuyerID uint
+}
+
+type Product struct {
+	SKU string ` + "`db:\"sku\"`" + `
+}
+
+type notAModel struct{ Name string }
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/models", files, pkgviz.Options{DBSchema: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"<tr><td align='center' colspan='2'><font point-size='9' color='#7f8183'>table users</font></td></tr>" +
+			"<tr><td port='port_ID' align='left'>id</td><td align='left'><font color='#7f8183'>uint</font> <font point-size='9' color='#7f8183'>PK</font></td></tr>" +
+			"<tr><td port='port_Name' align='left'>full_name</td><td align='left'><font color='#7f8183'>string</font></td></tr>" +
+			"<tr><td port='port_CompanyID' align='left'>company_id</td><td align='left'><font color='#7f8183'>uint</font> <font point-size='9' color='#7f8183'>FK → companies</font></td></tr>" +
+			"</table>",
+		"<tr><td port='port_BuyerID' align='left'>buyer_id</td><td align='left'><font color='#7f8183'>uint</font> <font point-size='9' color='#7f8183'>FK → users</font></td></tr>",
+		">table product<",
+		"user -> company [label=\"belongs to\"];",
+		"user -> order [arrowhead=crow label=\"has many\"];",
+		"<tr><td port='port_Name' align='left'>Name</td>",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+}
+
+func TestProtoConversions(t *testing.T) {
+	files := map[string]string{
+		"user.go": `package users
+
+import "example.com/users/pb"
+
+type User struct{ Name string }
+
+func (u *User) Proto() *pb.User { return nil }
+
+func usersFromProto(msgs []*pb.User) []User { return nil }
+
+type Account struct{ Owner *pb.User }
+`,
+		"pb/user.pb.go": "// Code generated by protoc-gen-go. DO NOT EDIT.\n\npackage pb\n\ntype User struct{ Name string; Address *Address }\n\ntype Address struct{ Street string }\n",
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/users", files, pkgviz.Options{ProtoCorrespondence: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []pkgviz.ProtoConversion{
+		{Type: "User", Message: "pb.User", Func: "(*User).Proto", ToProto: true},
+		{Type: "User", Message: "pb.User", Func: "usersFromProto"},
+	}
+	if actual := graph.ProtoConversions(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"subgraph cluster_generated_pb_address {",
+		"user -> pb_user [color=\"#5cb85c\" style=dashed dir=both label=\"(*User).Proto, usersFromProto\"];",
+		"account:port_Owner -> pb_user;",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+	if strings.Contains(dot, "pb_user:port_Address") {
+		t.Errorf("Expected the references between messages to be left out, got %s", dot)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	records := pkgviz.BuildGraph("github.com/tiegz/pkgviz-go/pkg/fakepkg").Records()
+
+	var fakeStruct *pkgviz.NodeRecord
+	for i, node := range records.Nodes {
+		if node.ID == "github.com/tiegz/pkgviz-go/pkg/fakepkg.fakeStruct" {
+			fakeStruct = &records.Nodes[i]
+		}
+	}
+	if fakeStruct == nil || fakeStruct.Kind != "struct" || !strings.HasSuffix(fakeStruct.File, "fakepkg.go") {
+		t.Errorf("Expected a fakeStruct struct node, got %v", records.Nodes)
+	}
+
+	expected := pkgviz.EdgeRecord{
+		From:  "github.com/tiegz/pkgviz-go/pkg/fakepkg.anotherFakeStruct",
+		Field: "otherTypeStruct",
+		To:    "github.com/tiegz/pkgviz-go/pkg/fakepkg.fakeStruct",
+	}
+	found := false
+	for _, edge := range records.Edges {
+		found = found || edge == expected
+	}
+	if !found {
+		t.Errorf("Expected edge %v, got %v", expected, records.Edges)
+	}
+}
+
+func TestEmbedding(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+type base struct{ id int64 }
+
+type stringer interface{ String() string }
+
+type named interface {
+	stringer
+	name() string
+}
+
+type number interface{ ~int | ~float64 }
+
+type user struct {
+	*base
+	named
+	friend *user
+}
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := graph.String()
+	for _, expected := range []string{
+		"user:port_base -> base [arrowhead=diamond];",
+		"user:port_named -> named [arrowhead=diamond];",
+		"user:port_friend -> user;",
+		"named -> stringer [arrowhead=diamond];",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	if strings.Contains(actual, "number ->") {
+		t.Errorf("Expected no arrows from a constraint's union, got %s", actual)
+	}
+
+	expected := pkgviz.EdgeRecord{From: "example.com/pasted.named", To: "example.com/pasted.stringer", Embedded: true}
+	found := false
+	for _, edge := range graph.Records().Edges {
+		found = found || edge == expected
+	}
+	if !found {
+		t.Errorf("Expected edge %v, got %v", expected, graph.Records().Edges)
+	}
+}
+
+func TestTypeParams(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+type number interface{ ~int | ~float64 }
+
+type sum[N number] struct{ total N }
+
+type cache[K comparable, V any] map[K]V
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFilesWithOptions("example.com/pasted", files, pkgviz.Options{NormalizeTypes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		">sum[N number]</td></tr>",
+		">cache[K comparable, V any]</td></tr>",
+		"<tr><td align='left' colspan='2'><font color='#7f8183'>~int | ~float64</font></td></tr>",
+		`sum -> number [style=dotted label="N"];`,
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+	if strings.Contains(dot, "cache ->") {
+		t.Errorf("Expected no arrows to predeclared constraints, got %s", dot)
+	}
+	if records := graph.Records(); len(records.Edges) != 0 {
+		t.Errorf("Expected constraints to be left out of the records, got %v", records)
+	}
+}
+
+func TestChans(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+type event struct{ name string }
+
+type inbox <-chan event
+
+type outbox chan<- *event
+
+type done chan struct{}
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"inbox [shape=plaintext label=< <table border='2' cellborder='0' cellspacing='0' style='rounded' color='#4BAAD3'><tr><td bgcolor='#e0ebf5' align='center'>inbox</td></tr><tr><td>&lt;-chan event</td></tr></table> >];",
+		"<tr><td>chan&lt;- *event</td></tr>",
+		"inbox -> event;",
+		"outbox -> event;",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+	if strings.Contains(dot, "done ->") {
+		t.Errorf("Expected no arrow from a chan of an unnamed type, got %s", dot)
+	}
+}
+
+func TestArrays(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+type cell struct{ alive bool }
+
+type board [8][8]*cell
+
+type row []cell
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"/* array */\n  board [shape=plaintext label=< <table border='2' cellborder='0' cellspacing='0' style='rounded' color='#4BAAD3'><tr><td bgcolor='#e0ebf5' align='center'>board</td></tr><tr><td>[8][8]*cell</td></tr></table> >];",
+		"board -> cell;",
+		"row -> cell;",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+	if warnings := graph.Warnings(); len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", warnings)
+	}
+}
+
+func TestMaps(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+type userID int64
+
+type session struct{ token string }
+
+type sessions map[userID]*session
+
+type counts map[string]int
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"<tr><td>map[userID]*session</td></tr>",
+		`sessions -> userid [label="key"];`,
+		`sessions -> session [label="value"];`,
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+	if strings.Contains(dot, "counts ->") {
+		t.Errorf("Expected no arrows from a map of basic types, got %s", dot)
+	}
+}
+
+func TestSignatures(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+type request struct{ path string }
+
+type response struct{ body []byte }
+
+type handler func(req *request, tags ...string) (resp response, err error)
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"<tr><td bgcolor='#e0ebf5' align='center' colspan='2'>handler</td></tr>" +
+			"<tr><td align='left'>req</td><td align='left'><font color='#7f8183'>*request</font></td></tr>" +
+			"<tr><td align='left'>tags</td><td align='left'><font color='#7f8183'>...string</font></td></tr>" +
+			"<tr><td align='left'><font color='#7f8183'>returns</font> resp</td><td align='left'><font color='#7f8183'>response</font></td></tr>" +
+			"<tr><td align='left'><font color='#7f8183'>returns</font> err</td><td align='left'><font color='#7f8183'>error</font></td></tr></table> >];",
+		`handler -> request [label="param"];`,
+		`handler -> response [label="result"];`,
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+}
+
+func TestPointers(t *testing.T) {
+	files := map[string]string{
+		"main.go": `package main
+
+type config struct{ debug bool }
+
+type configRef *config
+
+type name *string
+
+type app struct {
+	cfg  configRef
+	name name
+}
+`,
+	}
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"/* pointer */\n  configref [shape=plaintext label=< <table border='2' cellborder='0' cellspacing='0' style='rounded' color='#4BAAD3'><tr><td bgcolor='#e0ebf5' align='center'>configRef</td></tr><tr><td>*config</td></tr></table> >];",
+		"<tr><td>*string</td></tr>",
+		"app:port_cfg -> configref;",
+		"app:port_name -> name;",
+		"configref -> config;",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+	// The pointer types are drawn, so nothing is drawn as a placeholder.
+	if strings.Contains(dot, "color='#cccccc'") {
+		t.Errorf("Expected no placeholders, got %s", dot)
+	}
+}
+
+func TestExternalPlaceholderClusters(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod": "module example.com/jobs\n\ngo 1.16\n",
+		"jobs.go": "package jobs\n\nimport (\n\t\"sync\"\n\t\"time\"\n)\n\n" +
+			"type job struct {\n\tstarted time.Time\n\ttimeout time.Duration\n\tmu      sync.Mutex\n}\n\n" +
+			"type queue struct {\n\tjobs  []*job\n\tevery time.Duration\n}\n",
+	})
+	graph, err := pkgviz.GraphForPackage(".", pkgviz.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot := graph.String()
+	for _, expected := range []string{
+		"  subgraph cluster_external_sync {\n" +
+			"    label=\"sync\";\n" +
+			"    graph[style=dotted color=\"#cccccc\" fontcolor=\"#7f8183\" fontsize=10];\n" +
+			"    sync_mutex [shape=plaintext label=<<table border='2' cellborder='0' cellspacing='0' style='rounded' color='#cccccc'><tr><td align='center' colspan='2'>sync.Mutex</td></tr></table> >];\n" +
+			"  }\n",
+		"  subgraph cluster_external_time {\n",
+		"    time_duration [shape=plaintext",
+		"    time_time [shape=plaintext",
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, dot)
+		}
+	}
+	// Each placeholder is drawn once, however many arrows point to it.
+	if n := strings.Count(dot, "time_duration [shape=plaintext"); n != 1 {
+		t.Errorf("Expected time.Duration to be drawn once, got %d in %s", n, dot)
+	}
+}
+
+func TestWriteGraphWithFocus(t *testing.T) {
+	actual := pkgviz.WriteGraphWithOptions("github.com/tiegz/pkgviz-go/pkg/fakepkg", pkgviz.Options{
+		Focus: "anotherFakeStruct",
+		Hops:  1,
+	})
+
+	for _, expected := range []string{"anotherfakestruct [", "fakestruct [", "anotherfakestruct:port_otherTypeStruct -> fakestruct;"} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	// fakeMap is two hops away, via fakeStruct.
+	if strings.Contains(actual, "fakemap") {
+		t.Errorf("Expected graph not to contain fakemap, got %s", actual)
+	}
+
+	// Types can be named by their package's import path too, and the type
+	// focused on stands out from the others.
+	actual = pkgviz.WriteGraphWithOptions("github.com/tiegz/pkgviz-go/pkg/fakepkg", pkgviz.Options{
+		Focus: "github.com/tiegz/pkgviz-go/pkg/fakepkg.anotherFakeStruct",
+		Hops:  2,
+	})
+	for _, expected := range []string{"anotherfakestruct [shape=plaintext label=<<table border='2' cellborder='0' cellspacing='0' style='rounded' color='#f0ad4e'>", "fakemap ["} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+	if strings.Contains(actual, "\n  fakestruct [shape=plaintext label=<<table border='2' cellborder='0' cellspacing='0' style='rounded' color='#f0ad4e'>") {
+		t.Errorf("Expected only the type focused on to stand out, got %s", actual)
+	}
+}
+
+func TestWriteGraphWithBlame(t *testing.T) {
+	actual := pkgviz.WriteGraphWithOptions("github.com/tiegz/pkgviz-go/pkg/fakepkg", pkgviz.Options{
+		Blame:      true,
+		StaleAfter: time.Nanosecond,
+	})
+
+	for _, expected := range []string{">fakeStruct<", ">modified ", "bgcolor='#e8e8e8'"} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("Expected graph to contain %s, got %s", expected, actual)
+		}
+	}
+}
+
+func TestWriteGraphWithChurn(t *testing.T) {
+	actual := pkgviz.WriteGraphWithOptions("github.com/tiegz/pkgviz-go/pkg/fakepkg", pkgviz.Options{
+		ChurnWindow: 10 * 365 * 24 * time.Hour,
+	})
+
+	if !strings.Contains(actual, " in 3650 days<") {
+		t.Errorf("Expected types to be annotated with their changes, got %s", actual)
+	}
+}
+
+func TestWriteGraphForFile(t *testing.T) {
+	actual, err := pkgviz.WriteGraphForFile("../fakepkg/fakepkg.go", pkgviz.Options{FocusFile: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(actual, "<b>github.com/tiegz/pkgviz-go/pkg/fakepkg</b>") {
+		t.Errorf("Expected graph of fakepkg, got %s", actual)
+	}
+	if !strings.Contains(actual, "anotherfakestruct [") {
+		t.Errorf("Expected graph to contain anotherfakestruct, got %s", actual)
+	}
+
+	if _, err := pkgviz.WriteGraphForFile("../fakepkg/missing.go", pkgviz.Options{}); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
 }
 
 // TODO finish this one the package is public. Local dev is too tricky.
@@ -21,6 +2569,94 @@ func TestPlaceholder(t *testing.T) {
 // 	)
 // }
 
+func TestClusterOrder(t *testing.T) {
+	dir := writeModule(t, map[string]string{
+		"go.mod":           "module example.com/shop\n\ngo 1.16\n",
+		"zeta/zeta.go":     "package zeta\n\nimport \"example.com/shop/alpha\"\n\ntype Zeta struct{ a alpha.Alpha }\n",
+		"alpha/alpha.go":   "package alpha\n\ntype Alpha struct{ n int }\n",
+		"mid/mid.go":       "package mid\n\nimport \"example.com/shop/zeta\"\n\ntype Mid struct{ z zeta.Zeta }\n",
+		"mid/sub/sub.go":   "package sub\n\nimport \"example.com/shop/alpha\"\n\ntype Sub struct{ a alpha.Alpha }\n",
+		"beta/beta.go":     "package beta\n\ntype Beta struct{ s string }\n",
+		"gamma/gamma.go":   "package gamma\n\ntype Gamma struct{ s string }\n",
+		"delta/delta.go":   "package delta\n\ntype Delta struct{ s string }\n",
+		"epsilon/epsil.go": "package epsilon\n\ntype Epsilon struct{ s string }\n",
+	})
+
+	graph, err := pkgviz.GraphForPackage("./...", pkgviz.Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := graph.String()
+	last := -1
+	for _, cluster := range []string{"alpha", "beta", "delta", "epsilon", "gamma", "mid", "mid_slash_sub", "zeta"} {
+		i := strings.Index(actual, "subgraph cluster_"+cluster+" {")
+		if i < 0 || i < last {
+			t.Errorf("Expected the clusters to be sorted by package, got %s", actual)
+		}
+		last = i
+	}
+	// Types drawn in one cluster aren't drawn again as placeholders for
+	// the arrows to them from another.
+	if strings.Contains(actual, "<td align='center' colspan='2'>alpha.Alpha</td>") {
+		t.Errorf("Expected no placeholder for alpha.Alpha, got %s", actual)
+	}
+	for i := 0; i < 10; i++ {
+		if again := graph.String(); again != actual {
+			t.Fatalf("Expected the graph to be written the same every time, got %s and then %s", actual, again)
+		}
+	}
+}
+
+// largePackage returns the files of a package with n structs that refer to
+// each other, like a big domain model.
+func largePackage(n int) map[string]string {
+	var b strings.Builder
+	b.WriteString("package large\n\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "type Type%d struct {\n\tID int64\n\tName string\n\tTags []string\n\tNext *Type%d\n\tItems []Type%d\n\tByName map[string]*Type%d\n}\n\n", i, (i+1)%n, (i+2)%n, (i+3)%n)
+	}
+	return map[string]string{"large.go": b.String()}
+}
+
+func BenchmarkBuildGraph(b *testing.B) {
+	files := largePackage(500)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := pkgviz.BuildGraphFromFiles("example.com/large", files); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteGraph(b *testing.B) {
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/large", largePackage(500))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := graph.WriteTo(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// writeModule writes the files to a temporary directory, for a module.
+func writeModule(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, contents := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
 func assertGraph(t *testing.T, pkgPath, pkgExpectationPath string) {
 	actual := pkgviz.WriteGraph(pkgPath)
 	expected := getFixtureFile(pkgExpectationPath)
@@ -0,0 +1,117 @@
+package pkgviz
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WritePlantUML writes the graph as a PlantUML class diagram, e.g. to
+// include in architecture docs that are rendered by PlantUML. Each package
+// is a package of the diagram, structs are classes with their fields,
+// interfaces are interfaces with their methods, basic types with constants
+// are enums, and other types are classes with their kind as a stereotype.
+// Each reference from a struct's field to another type is an association,
+// labeled with the field.
+func (p *pkg) WritePlantUML(w io.Writer) error {
+	model := p.Model()
+	bw := bufio.NewWriter(w)
+	aliases := diagramAliases(model)
+
+	fmt.Fprintln(bw, "@startuml")
+	fmt.Fprintf(bw, "' The types of %s\n", model.Package)
+	// Types' names have dots in them, which aren't namespaces.
+	fmt.Fprintln(bw, "set separator none")
+	fmt.Fprintln(bw, "hide empty members")
+	for _, pkgNodes := range nodesByPackage(model) {
+		// Types that aren't graphed can have no package.
+		inPkg := pkgNodes[0].Package != ""
+		if inPkg {
+			fmt.Fprintf(bw, "package %q {\n", pkgNodes[0].Package)
+		}
+		for _, node := range pkgNodes {
+			writePlantUMLNode(bw, node, aliases[node.ID])
+		}
+		if inPkg {
+			fmt.Fprintln(bw, "}")
+		}
+	}
+	for _, edge := range model.Edges {
+		fmt.Fprintf(bw, "%s --> %s : %s\n", aliases[edge.From], aliases[edge.To], edge.Field)
+	}
+	fmt.Fprintln(bw, "@enduml")
+	return bw.Flush()
+}
+
+func writePlantUMLNode(w io.Writer, node Node, alias string) {
+	switch {
+	case node.Kind == "struct":
+		fmt.Fprintf(w, "  class %q as %s {\n", node.Name, alias)
+		for _, field := range node.Fields {
+			fmt.Fprintf(w, "    {field} %s : %s\n", field.Name, field.Type)
+		}
+		fmt.Fprintln(w, "  }")
+	case node.Kind == "interface":
+		fmt.Fprintf(w, "  interface %q as %s {\n", node.Name, alias)
+		for _, method := range node.Methods {
+			fmt.Fprintf(w, "    {method} %s%s\n", method.Name, strings.TrimPrefix(method.Type, "func"))
+		}
+		fmt.Fprintln(w, "  }")
+	case len(node.Constants) > 0:
+		fmt.Fprintf(w, "  enum %q as %s <<%s>> {\n", node.Name, alias, node.Underlying)
+		for _, c := range node.Constants {
+			fmt.Fprintf(w, "    %s = %s\n", c.Name, c.Value)
+		}
+		fmt.Fprintln(w, "  }")
+	case node.Underlying != "":
+		fmt.Fprintf(w, "  class %q as %s <<%s>> {\n", node.Name, alias, node.Kind)
+		fmt.Fprintf(w, "    {field} %s\n", node.Underlying)
+		fmt.Fprintln(w, "  }")
+	default:
+		fmt.Fprintf(w, "  class %q as %s <<%s>>\n", node.Name, alias, node.Kind)
+	}
+}
+
+// nodesByPackage returns the nodes of a graph in groups by their package,
+// sorted by it.
+func nodesByPackage(model *Graph) [][]Node {
+	var groups [][]Node
+	index := map[string]int{}
+	for _, node := range model.Nodes {
+		i, ok := index[node.Package]
+		if !ok {
+			i = len(groups)
+			index[node.Package] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], node)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i][0].Package < groups[j][0].Package
+	})
+	return groups
+}
+
+// diagramAliases returns an identifier for each of a graph's nodes, by ID,
+// for diagram languages whose identifiers can't have dots, slashes and the
+// like in them, e.g. example_com_store_Item for example.com/store.Item.
+func diagramAliases(model *Graph) map[string]string {
+	aliases := map[string]string{}
+	taken := map[string]bool{}
+	for _, node := range model.Nodes {
+		alias := strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+				return r
+			}
+			return '_'
+		}, node.ID)
+		for base, i := alias, 2; taken[alias]; i++ {
+			alias = fmt.Sprintf("%s_%d", base, i)
+		}
+		taken[alias] = true
+		aliases[node.ID] = alias
+	}
+	return aliases
+}
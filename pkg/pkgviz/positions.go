@@ -0,0 +1,16 @@
+package pkgviz
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// addPositionFooters draws the file and line that each type's name is
+// declared on, e.g. "server.go:42", at the bottom of it, to find it by.
+func addPositionFooters(p *pkg) {
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.namePosition.IsValid() {
+			node.footer = fmt.Sprintf("%s:%d", filepath.Base(node.namePosition.Filename), node.namePosition.Line)
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+)
+
+// protoConversionColor is the color of the arrows between hand-written
+// types and the protobuf messages they're converted to and from.
+const protoConversionColor = "#5cb85c"
+
+// A ProtoConversion is a function in the graph that converts a hand-written
+// type to a protobuf message, or a message to the type.
+type ProtoConversion struct {
+	// Type and Message are the types' names, qualified by their package if
+	// it isn't the graphed package itself (e.g. "pb.User"), or by their
+	// import path if they aren't in the graph.
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	// Func is the function's name, e.g. "userToProto" or "(*User).Proto",
+	// qualified by its package like the types.
+	Func string `json:"func"`
+	// ToProto is whether it converts the type to the message, rather than
+	// the message to the type.
+	ToProto bool `json:"toProto"`
+}
+
+func (c ProtoConversion) String() string {
+	if c.ToProto {
+		return fmt.Sprintf("%s -> %s: %s", c.Type, c.Message, c.Func)
+	}
+	return fmt.Sprintf("%s -> %s: %s", c.Message, c.Type, c.Func)
+}
+
+// A type that a conversion converts, in or out of the graph.
+type protoConversionEnd struct {
+	name    string
+	pkgName string // the package and type name that a link to it is drawn to
+	typ     string
+	node    *graphNode // if it's in the graph
+}
+
+// A conversion, with the types that it converts.
+type protoConversion struct {
+	ProtoConversion
+	typ, message protoConversionEnd
+}
+
+// ProtoConversions returns the functions and methods in the graph's packages
+// that take a hand-written type (or a pointer or slice of it) and return a
+// protobuf message, or the other way around, sorted by type and message.
+// Messages are recognized as types generated by protoc-gen-go (see
+// GeneratedTypes), and hand-written types as the graph's other types.
+func (p *pkg) ProtoConversions() []ProtoConversion {
+	var conversions []ProtoConversion
+	for _, conversion := range p.findProtoConversions() {
+		conversions = append(conversions, conversion.ProtoConversion)
+	}
+	return conversions
+}
+
+func (p *pkg) findProtoConversions() []protoConversion {
+	byObj := map[types.Object]protoConversionEnd{}
+	byPath := map[string]protoConversionEnd{}
+	pkgPaths := map[*types.Package]string{}
+	var pkgs []*types.Package
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		end := protoConversionEnd{name: node.typeObj.Name(), pkgName: pkgPath, typ: node.typeObj.Name(), node: node}
+		if pkgPath != "" {
+			end.name = pkgPath + "." + node.typeObj.Name()
+		}
+		byObj[node.typeObj] = end
+		byPath[p.pkgImportPath(pkgPath)+"."+node.typeObj.Name()] = end
+		if _, ok := pkgPaths[node.typeObj.Pkg()]; !ok && node.typeObj.Pkg() != nil {
+			pkgPaths[node.typeObj.Pkg()] = pkgPath
+			pkgs = append(pkgs, node.typeObj.Pkg())
+		}
+	})
+
+	// end returns the named type that a parameter or result is (or points
+	// to, or is a slice of), and whether it's a protobuf message.
+	end := func(t types.Type) (e protoConversionEnd, isMessage bool, ok bool) {
+		for {
+			if ptr, ok := t.(*types.Pointer); ok {
+				t = ptr.Elem()
+			} else if slice, ok := t.(*types.Slice); ok {
+				t = slice.Elem()
+			} else {
+				break
+			}
+		}
+		named, ok := t.(*types.Named)
+		if !ok {
+			return e, false, false
+		}
+		obj := named.Obj()
+		if e, ok = byObj[obj]; !ok && obj.Pkg() != nil {
+			e, ok = byPath[obj.Pkg().Path()+"."+obj.Name()]
+		}
+		if ok {
+			return e, e.node.generator == "protoc-gen-go", true
+		}
+		if generator, _ := generatorByFields(obj); generator == "protoc-gen-go" && obj.Pkg() != nil {
+			return protoConversionEnd{name: obj.Pkg().Path() + "." + obj.Name(), pkgName: obj.Pkg().Path(), typ: obj.Name()}, true, true
+		}
+		return e, false, false
+	}
+
+	var conversions []protoConversion
+	seen := map[ProtoConversion]bool{}
+	addConversions := func(pkgPath, name string, sig *types.Signature) {
+		if pkgPath != "" {
+			name = pkgPath + "." + name
+		}
+		var params []*types.Var
+		if sig.Recv() != nil {
+			params = append(params, sig.Recv())
+		}
+		for i := 0; i < sig.Params().Len(); i++ {
+			params = append(params, sig.Params().At(i))
+		}
+		for _, param := range params {
+			from, fromMessage, ok := end(param.Type())
+			if !ok {
+				continue
+			}
+			for i := 0; i < sig.Results().Len(); i++ {
+				to, toMessage, ok := end(sig.Results().At(i).Type())
+				if !ok || fromMessage == toMessage {
+					continue
+				}
+				conversion := protoConversion{ProtoConversion{Type: from.name, Message: to.name, Func: name, ToProto: true}, from, to}
+				if fromMessage {
+					conversion = protoConversion{ProtoConversion{Type: to.name, Message: from.name, Func: name}, to, from}
+				}
+				if !seen[conversion.ProtoConversion] {
+					seen[conversion.ProtoConversion] = true
+					conversions = append(conversions, conversion)
+				}
+			}
+		}
+	}
+	for _, pkg := range pkgs {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			switch obj := scope.Lookup(name).(type) {
+			case *types.Func:
+				addConversions(pkgPaths[pkg], name, obj.Type().(*types.Signature))
+			case *types.TypeName:
+				named, ok := obj.Type().(*types.Named)
+				if !ok {
+					continue
+				}
+				for i := 0; i < named.NumMethods(); i++ {
+					method := named.Method(i)
+					sig := method.Type().(*types.Signature)
+					recv := "(" + name + ")"
+					if _, ok := sig.Recv().Type().(*types.Pointer); ok {
+						recv = "(*" + name + ")"
+					}
+					addConversions(pkgPaths[pkg], recv+"."+method.Name(), sig)
+				}
+			}
+		}
+	}
+
+	sort.Slice(conversions, func(i, j int) bool {
+		a, b := conversions[i], conversions[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Message != b.Message {
+			return a.Message < b.Message
+		}
+		return a.Func < b.Func
+	})
+	return conversions
+}
+
+// drawProtoCorrespondence collapses the protobuf messages in the graph into
+// a cluster of just their names (see GeneratedGroup), leaving out the
+// references from them, and draws an arrow between each hand-written type
+// and each message it's converted to or from, labeled with the functions
+// that convert them and pointing in the directions they convert.
+func drawProtoCorrespondence(p *pkg) {
+	messages := map[string]bool{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.generator == "protoc-gen-go" {
+			node.collapsed = true
+			messages[node.typeId] = true
+		}
+	})
+	var nodeLinks []graphNodeLink
+	for _, nodeLink := range p.nodeLinks {
+		if !messages[nodeLink.fromStructTypeId] {
+			nodeLinks = append(nodeLinks, nodeLink)
+		}
+	}
+
+	// One arrow is drawn for all of the conversions between two types.
+	links := map[[2]string]int{}
+	for _, conversion := range p.findProtoConversions() {
+		from := p.typeIdOf(conversion.typ.pkgName, conversion.typ.typ)
+		key := [2]string{from, conversion.Message}
+		dir := "back"
+		if conversion.ToProto {
+			dir = "forward"
+		}
+		if i, ok := links[key]; ok {
+			nodeLinks[i].label += ", " + conversion.Func
+			if nodeLinks[i].dir != dir {
+				nodeLinks[i].dir = "both"
+			}
+			continue
+		}
+		links[key] = len(nodeLinks)
+		nodeLinks = append(nodeLinks, graphNodeLink{
+			fromStructTypeId: from,
+			toTypePkgName:    conversion.message.pkgName,
+			toTypeName:       conversion.message.typ,
+			toTypeId:         p.typeIdOf(conversion.message.pkgName, conversion.message.typ),
+			color:            protoConversionColor,
+			style:            "dashed",
+			dir:              dir,
+			label:            conversion.Func,
+		})
+	}
+	for i := range nodeLinks {
+		if nodeLinks[i].dir == "forward" {
+			nodeLinks[i].dir = ""
+		}
+	}
+	p.nodeLinks = nodeLinks
+}
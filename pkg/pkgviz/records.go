@@ -0,0 +1,107 @@
+package pkgviz
+
+import "sort"
+
+// Records is a flat view of a built graph, for exporting it to other tools
+// like graph databases, or as JSON.
+type Records struct {
+	// Package is the graphed package's import path.
+	Package string       `json:"package"`
+	Nodes   []NodeRecord `json:"nodes"`
+	Edges   []EdgeRecord `json:"edges"`
+}
+
+// A NodeRecord is a type in a graph.
+type NodeRecord struct {
+	// ID is the type's package and name, e.g. "net/http.Client", which is
+	// unique across graphs.
+	ID      string `json:"id"`
+	Package string `json:"package"`
+	Name    string `json:"name"`
+	// Kind is e.g. "struct" or "interface", or "external" for types that are
+	// referred to from outside the graphed packages.
+	Kind string `json:"kind"`
+	// File and Line are where the type is declared, if known.
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+// An EdgeRecord is a reference from a struct's field to another type, from an
+// interface to an interface that it embeds, or from a container type (e.g.
+// a slice type) to the type of its elements, with no field.
+type EdgeRecord struct {
+	From  string `json:"from"` // the struct's node ID
+	Field string `json:"field"`
+	To    string `json:"to"` // the field type's node ID
+	// Embedded is whether the field is embedded, or the interface is.
+	Embedded bool `json:"embedded,omitempty"`
+}
+
+// Records returns the types of the graph, and the references between them,
+// sorted by ID.
+func (p *pkg) Records() Records {
+	records := Records{Package: p.rootPkgName}
+	idsByTypeId := map[string]string{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		id := p.nodeID(pkgPath, node)
+		idsByTypeId[node.typeId] = id
+		records.Nodes = append(records.Nodes, NodeRecord{
+			ID:      id,
+			Package: p.pkgImportPath(pkgPath),
+			Name:    node.typeObj.Name(),
+			Kind:    node.typeType,
+			File:    node.position.Filename,
+			Line:    node.position.Line,
+		})
+	})
+
+	external := map[string]bool{}
+	for _, nodeLink := range p.nodeLinks {
+		fromID, ok := idsByTypeId[nodeLink.fromStructTypeId]
+		if !ok || nodeLink.ghost {
+			continue
+		}
+		toID, ok := idsByTypeId[nodeLink.toId()]
+		if !ok {
+			toID = nodeLink.toTypePkgName + "." + nodeLink.toTypeName
+		}
+		if !ok && !external[toID] {
+			external[toID] = true
+			records.Nodes = append(records.Nodes, NodeRecord{
+				ID:      toID,
+				Package: nodeLink.toTypePkgName,
+				Name:    nodeLink.toTypeName,
+				Kind:    "external",
+			})
+		}
+		records.Edges = append(records.Edges, EdgeRecord{From: fromID, Field: nodeLink.fromStructFieldName, To: toID, Embedded: nodeLink.embedded})
+	}
+
+	sort.Slice(records.Nodes, func(i, j int) bool {
+		return records.Nodes[i].ID < records.Nodes[j].ID
+	})
+	sort.Slice(records.Edges, func(i, j int) bool {
+		if records.Edges[i].From != records.Edges[j].From {
+			return records.Edges[i].From < records.Edges[j].From
+		}
+		return records.Edges[i].Field < records.Edges[j].Field
+	})
+	return records
+}
+
+// pkgImportPath returns the import path of a package in the graph, from its
+// path relative to the graphed package.
+func (p *pkg) pkgImportPath(pkgPath string) string {
+	if pkgPath == "" {
+		return p.rootPkgName
+	}
+	return p.rootPkgName + "/" + pkgPath
+}
+
+// nodeID returns the ID of a node's record.
+func (p *pkg) nodeID(pkgPath string, node *graphNode) string {
+	return p.pkgImportPath(pkgPath) + "." + node.typeObj.Name()
+}
@@ -0,0 +1,68 @@
+//go:build !js && !(graphviz && cgo)
+// +build !js
+// +build !graphviz !cgo
+
+package pkgviz
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// RenderGraph renders a dot graph (e.g. from WriteGraph) to the given
+// graphviz output format, like "png" or "svg", with the `dot` command (see
+// findDot). When built with the graphviz tag, it calls the Graphviz libraries
+// directly instead.
+func RenderGraph(dotFile, format string) ([]byte, error) {
+	var out bytes.Buffer
+	if err := RenderGraphTo(&out, strings.NewReader(dotFile), format); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// RenderGraphTo is like RenderGraph, but streams the dot graph from r (e.g.
+// a pipe from a graph's WriteTo) to `dot`, and the rendered graph from `dot`
+// to w, without holding either of them in memory.
+func RenderGraphTo(w io.Writer, r io.Reader, format string) error {
+	dot, err := findDot()
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(dot, "-T"+format)
+	cmd.Stdin = r
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error running '%v': %v: %s", cmd.String(), err, stderr.String())
+	}
+	return nil
+}
+
+// findDot returns the path of graphviz's `dot` command: $GRAPHVIZ_DOT if
+// it's set, or else the first `dot` on the PATH (which is `dot.exe` on
+// Windows), or else the first that's in one of the places that graphviz's
+// installers put it. Graphviz's Windows installer doesn't add itself to the
+// PATH by default, so without the last, most Windows users would have to.
+func findDot() (string, error) {
+	if dot := os.Getenv("GRAPHVIZ_DOT"); dot != "" {
+		return dot, nil
+	}
+	if dot, err := exec.LookPath("dot"); err == nil {
+		return dot, nil
+	}
+	for _, dot := range dotInstallPaths() {
+		if info, err := os.Stat(dot); err == nil && !info.IsDir() {
+			return dot, nil
+		}
+	}
+	return "", errors.New("cannot find graphviz's dot command: install graphviz (https://graphviz.org/download/) and add it to the PATH, or set $GRAPHVIZ_DOT to its path")
+}
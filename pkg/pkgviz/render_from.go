@@ -0,0 +1,27 @@
+//go:build !js
+// +build !js
+
+package pkgviz
+
+import "io"
+
+// RenderGraphFrom renders the dot graph that g writes (e.g. a built graph)
+// to the given graphviz output format, like RenderGraphTo, as g writes it.
+func RenderGraphFrom(w io.Writer, g io.WriterTo, format string) error {
+	r, pw := io.Pipe()
+	go func() {
+		_, err := g.WriteTo(pw)
+		pw.CloseWithError(err)
+	}()
+	err := RenderGraphTo(w, r, format)
+	// Stops writing the graph, if graphviz failed before reading all of it.
+	r.Close()
+	return err
+}
+
+// RenderSVG renders the dot graph that g writes as SVG. Unlike a PNG, an SVG
+// stays sharp however far it's zoomed in, and its text can be selected and
+// searched, so big packages' graphs stay readable.
+func RenderSVG(w io.Writer, g io.WriterTo) error {
+	return RenderGraphFrom(w, g, "svg")
+}
@@ -0,0 +1,122 @@
+//go:build graphviz && cgo && !js
+// +build graphviz,cgo,!js
+
+package pkgviz
+
+// Rendering in-process saves starting a `dot` process for every graph, which
+// adds up when rendering hundreds of graphs in one run. It needs the Graphviz
+// development headers and libraries (e.g. libgraphviz-dev), found with
+// pkg-config.
+
+/*
+#cgo pkg-config: libgvc
+#include <stdio.h>
+#include <stdlib.h>
+#include <gvc.h>
+
+enum {
+	RENDER_OK,
+	RENDER_PARSE_ERROR,
+	RENDER_LAYOUT_ERROR,
+	RENDER_MEMORY_ERROR,
+	RENDER_FORMAT_ERROR,
+};
+
+// render lays out the dot graph and renders it to format, in a buffer that
+// the caller must free. gvRender is used with a memory stream rather than
+// gvRenderData, whose length argument changed type between versions.
+static int render(GVC_t *gvc, const char *dot, const char *format, char **out, size_t *outLen) {
+	Agraph_t *g = agmemread(dot);
+	if (g == NULL) {
+		return RENDER_PARSE_ERROR;
+	}
+	if (gvLayout(gvc, g, "dot") != 0) {
+		agclose(g);
+		return RENDER_LAYOUT_ERROR;
+	}
+
+	int result = RENDER_OK;
+	FILE *f = open_memstream(out, outLen);
+	if (f == NULL) {
+		result = RENDER_MEMORY_ERROR;
+	} else {
+		if (gvRender(gvc, g, format, f) != 0) {
+			result = RENDER_FORMAT_ERROR;
+		}
+		fclose(f);
+		if (result != RENDER_OK) {
+			free(*out);
+			*out = NULL;
+		}
+	}
+
+	gvFreeLayout(gvc, g);
+	agclose(g);
+	return result;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"unsafe"
+)
+
+// Graphviz isn't thread-safe, so graphs are rendered one at a time, with one
+// context (which loads Graphviz's plugins) for the life of the process.
+var (
+	gvcMu sync.Mutex
+	gvc   *C.GVC_t
+)
+
+// RenderGraph renders a dot graph (e.g. from WriteGraph) to the given
+// graphviz output format, like "png" or "svg", with the Graphviz libraries.
+func RenderGraph(dotFile, format string) ([]byte, error) {
+	gvcMu.Lock()
+	defer gvcMu.Unlock()
+
+	if gvc == nil {
+		gvc = C.gvContext()
+	}
+
+	cDotFile := C.CString(dotFile)
+	defer C.free(unsafe.Pointer(cDotFile))
+	cFormat := C.CString(format)
+	defer C.free(unsafe.Pointer(cFormat))
+
+	var out *C.char
+	var outLen C.size_t
+	switch C.render(gvc, cDotFile, cFormat, &out, &outLen) {
+	case C.RENDER_OK:
+	case C.RENDER_PARSE_ERROR:
+		return nil, fmt.Errorf("error rendering graph: cannot parse the dot graph")
+	case C.RENDER_LAYOUT_ERROR:
+		return nil, fmt.Errorf("error rendering graph: cannot lay out the graph")
+	case C.RENDER_FORMAT_ERROR:
+		return nil, fmt.Errorf("error rendering graph: cannot render format %q", format)
+	default:
+		return nil, fmt.Errorf("error rendering graph: out of memory")
+	}
+	defer C.free(unsafe.Pointer(out))
+
+	return C.GoBytes(unsafe.Pointer(out), C.int(outLen)), nil
+}
+
+// RenderGraphTo is like RenderGraph, but reads the dot graph from r and
+// writes the rendered graph to w. Graphviz parses and renders graphs in
+// memory, so unlike with the `dot` command, both are held in memory.
+func RenderGraphTo(w io.Writer, r io.Reader, format string) error {
+	dotFile, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	out, err := RenderGraph(string(dotFile), format)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
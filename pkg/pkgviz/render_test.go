@@ -0,0 +1,91 @@
+//go:build !js && !(graphviz && cgo)
+// +build !js
+// +build !graphviz !cgo
+
+package pkgviz_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+func TestRenderGraphFindsDot(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, fakeDotName), []byte(fakeDot), 0755); err != nil {
+		t.Fatal(err)
+	}
+	graphvizDot, path := os.Getenv("GRAPHVIZ_DOT"), os.Getenv("PATH")
+	defer os.Setenv("GRAPHVIZ_DOT", graphvizDot)
+	defer os.Setenv("PATH", path)
+
+	os.Setenv("GRAPHVIZ_DOT", filepath.Join(dir, fakeDotName))
+	out, err := pkgviz.RenderGraph("digraph V {}\n", "svg")
+	if err != nil || !strings.Contains(string(out), "-Tsvg") || !strings.Contains(string(out), "digraph V {}") {
+		t.Errorf("Expected $GRAPHVIZ_DOT to render the graph, got %q, %v", out, err)
+	}
+
+	os.Setenv("GRAPHVIZ_DOT", "")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	out, err = pkgviz.RenderGraph("digraph V {}\n", "png")
+	if err != nil || !strings.Contains(string(out), "-Tpng") {
+		t.Errorf("Expected the dot on the PATH to render the graph, got %q, %v", out, err)
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, fakeDotName), []byte(fakeDot), 0755); err != nil {
+		t.Fatal(err)
+	}
+	graphvizDot := os.Getenv("GRAPHVIZ_DOT")
+	defer os.Setenv("GRAPHVIZ_DOT", graphvizDot)
+	os.Setenv("GRAPHVIZ_DOT", filepath.Join(dir, fakeDotName))
+
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": "package main\n\ntype Server struct{ addr string }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := pkgviz.RenderSVG(&out, graph); err != nil || !strings.Contains(out.String(), "-Tsvg") || !strings.Contains(out.String(), ">Server<") {
+		t.Errorf("Expected the graph to be rendered as SVG, got %q, %v", out.String(), err)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, fakeDotName), []byte(fakeDot), 0755); err != nil {
+		t.Fatal(err)
+	}
+	graphvizDot := os.Getenv("GRAPHVIZ_DOT")
+	defer os.Setenv("GRAPHVIZ_DOT", graphvizDot)
+	os.Setenv("GRAPHVIZ_DOT", filepath.Join(dir, fakeDotName))
+
+	graph, err := pkgviz.BuildGraphFromFiles("example.com/pasted", map[string]string{
+		"main.go": "package main\n\ntype Server struct{ addr string }\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := pkgviz.RenderHTML(&out, graph, "example.com/pasted"); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"<title>Types of example.com/pasted</title>",
+		"-Tsvg",
+		">Server<",
+		`<input id="search"`,
+		`svg.addEventListener("wheel"`,
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected the HTML to contain %q, got %s", expected, out.String())
+		}
+	}
+}
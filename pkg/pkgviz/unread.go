@@ -0,0 +1,254 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// unreadFieldColor is the color behind the rows of fields that are never
+// read.
+const unreadFieldColor = "#eeeeee"
+
+// An UnreadField is a field of a struct in the graph that's written (or
+// never even set) but never read anywhere in its module, so it could be
+// deleted.
+type UnreadField struct {
+	Package string `json:"package"` // the struct's package's import path
+	Type    string `json:"type"`
+	Field   string `json:"field"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+
+	pkgPath string // the struct's package, relative to the graphed package
+}
+
+func (u UnreadField) String() string {
+	if u.File == "" {
+		return fmt.Sprintf("%s.%s.%s", u.Package, u.Type, u.Field)
+	}
+	return fmt.Sprintf("%s.%s.%s (%s:%d)", u.Package, u.Type, u.Field, u.File, u.Line)
+}
+
+// A struct field, by its struct's package's import path and name.
+type fieldKey struct {
+	importPath string
+	typeName   string
+	field      string
+}
+
+// UnreadFields returns the fields of the graph's structs that no code in
+// the graphed packages' module reads, including tests, sorted. The module's
+// packages are type-checked, and a field counts as read wherever it's
+// selected (e.g. s.field), other than as the target of an assignment (or of
+// ++, --, or an assignment like +=, which only read it to write it again).
+// Fields with struct tags are skipped, since they're usually read by
+// reflection, e.g. by encoding/json. The module is found from opts' Dir and
+// Env, like when building the graph.
+func UnreadFields(p *pkg, opts Options) ([]UnreadField, error) {
+	fset := token.NewFileSet()
+	modulePkgs, err := checkModulePackages(p, fset, &opts)
+	if err != nil {
+		return nil, err
+	}
+	importPath := p.pkgImportPath
+
+	// The graph's structs' fields, and the packages they're in.
+	fields := map[fieldKey]bool{}
+	pkgPaths := map[string]string{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil || node.typeType != "struct" {
+			return
+		}
+		for name := range node.typeStructFields {
+			fields[fieldKey{importPath(pkgPath), node.typeObj.Name(), name}] = true
+		}
+		pkgPaths[importPath(pkgPath)] = pkgPath
+	})
+
+	read := map[fieldKey]bool{}
+	var unread []UnreadField
+	for _, mp := range modulePkgs {
+		if mp.info == nil {
+			continue
+		}
+		addFieldReads(read, mp.syntax, mp.info)
+		if !mp.xtest && mp.types != nil {
+			unread = append(unread, unreadFieldsIn(mp.types, fset, fields, pkgPaths)...)
+		}
+	}
+
+	var filtered []UnreadField
+	for _, u := range unread {
+		if !read[fieldKey{u.Package, u.Type, u.Field}] {
+			filtered = append(filtered, u)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool {
+		a, b := filtered[i], filtered[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Field < b.Field
+	})
+	return filtered, nil
+}
+
+// unreadFieldsIn returns the untagged fields of the checked package's
+// structs that are in the graph, to check whether they're read.
+func unreadFieldsIn(checked *types.Package, fset *token.FileSet, fields map[fieldKey]bool, pkgPaths map[string]string) []UnreadField {
+	var candidates []UnreadField
+	scope := checked.Scope()
+	for _, name := range scope.Names() {
+		typeName, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		s, ok := typeName.Type().Underlying().(*types.Struct)
+		if !ok {
+			continue
+		}
+		for i := 0; i < s.NumFields(); i++ {
+			field := s.Field(i)
+			key := fieldKey{checked.Path(), name, field.Name()}
+			if !fields[key] || s.Tag(i) != "" || field.Name() == "_" {
+				continue
+			}
+			position := fset.Position(field.Pos())
+			candidates = append(candidates, UnreadField{
+				Package: checked.Path(),
+				Type:    name,
+				Field:   field.Name(),
+				File:    position.Filename,
+				Line:    position.Line,
+				pkgPath: pkgPaths[checked.Path()],
+			})
+		}
+	}
+	return candidates
+}
+
+// addFieldReads adds the struct fields that the files read to read. The
+// fields of structs that are compared, or used as map keys, are all read.
+func addFieldReads(read map[fieldKey]bool, files []*ast.File, info *types.Info) {
+	readAll := func(expr ast.Expr) {
+		t := info.Types[expr].Type
+		named, ok := t.(*types.Named)
+		if !ok || named.Obj().Pkg() == nil {
+			return
+		}
+		if s, ok := named.Underlying().(*types.Struct); ok {
+			for i := 0; i < s.NumFields(); i++ {
+				read[fieldKey{named.Obj().Pkg().Path(), named.Obj().Name(), s.Field(i).Name()}] = true
+			}
+		}
+	}
+
+	// The selections that are only written to.
+	written := map[*ast.SelectorExpr]bool{}
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.MapType:
+				readAll(n.Key)
+			case *ast.BinaryExpr:
+				if n.Op == token.EQL || n.Op == token.NEQ {
+					readAll(n.X)
+				}
+			case *ast.AssignStmt:
+				for _, lhs := range n.Lhs {
+					if sel, ok := unparen(lhs).(*ast.SelectorExpr); ok {
+						written[sel] = true
+					}
+				}
+			case *ast.IncDecStmt:
+				if sel, ok := unparen(n.X).(*ast.SelectorExpr); ok {
+					written[sel] = true
+				}
+			}
+			return true
+		})
+	}
+
+	for sel, selection := range info.Selections {
+		if selection.Kind() != types.FieldVal {
+			continue
+		}
+		// A promoted field is selected through the embedded fields that
+		// it's promoted from, which are read to get to it.
+		t := selection.Recv()
+		for i, index := range selection.Index() {
+			if ptr, ok := t.(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			named, ok := t.(*types.Named)
+			if !ok {
+				break
+			}
+			s, ok := named.Underlying().(*types.Struct)
+			if !ok || index >= s.NumFields() {
+				break
+			}
+			field := s.Field(index)
+			last := i == len(selection.Index())-1
+			if !last || !written[sel] {
+				if obj := named.Obj(); obj.Pkg() != nil {
+					read[fieldKey{obj.Pkg().Path(), obj.Name(), field.Name()}] = true
+				}
+			}
+			t = field.Type()
+		}
+	}
+}
+
+func unparen(e ast.Expr) ast.Expr {
+	for {
+		paren, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = paren.X
+	}
+}
+
+// HighlightUnreadFields grays out the rows of the unread fields, notes them
+// as never read, and annotates their structs with how many they have.
+func HighlightUnreadFields(p *pkg, unread []UnreadField) {
+	byType := map[diffKey][]string{}
+	for _, u := range unread {
+		key := diffKey{u.pkgPath, u.Type}
+		byType[key] = append(byType[key], u.Field)
+	}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		fields, ok := byType[diffKey{pkgPath, node.typeObj.Name()}]
+		if !ok {
+			return
+		}
+		if node.annotations == nil {
+			node.annotations = map[string]string{}
+		}
+		node.annotations["unread"] = fmt.Sprintf("%d unread %s", len(fields), plural(len(fields), "field", "fields"))
+		if node.fieldColors == nil {
+			node.fieldColors = map[string]string{}
+		}
+		if node.fieldNotes == nil {
+			node.fieldNotes = map[string]string{}
+		}
+		for _, field := range fields {
+			node.fieldColors[field] = unreadFieldColor
+			if node.fieldNotes[field] != "" {
+				node.fieldNotes[field] += ", never read"
+			} else {
+				node.fieldNotes[field] = "never read"
+			}
+		}
+	})
+}
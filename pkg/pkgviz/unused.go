@@ -0,0 +1,174 @@
+package pkgviz
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+)
+
+// unusedBorderColor is the color of the border of exported types that no
+// other package refers to.
+const unusedBorderColor = "#a0a0a0"
+
+// An UnusedType is an exported type that no other package in its module
+// refers to, so it could be unexported or, if its own package doesn't use it
+// either, deleted.
+type UnusedType struct {
+	Package string `json:"package"` // the type's package's import path
+	Name    string `json:"name"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+
+	pkgPath string // the type's package, relative to the graphed package
+}
+
+func (u UnusedType) String() string {
+	if u.File == "" {
+		return fmt.Sprintf("%s.%s", u.Package, u.Name)
+	}
+	return fmt.Sprintf("%s.%s (%s:%d)", u.Package, u.Name, u.File, u.Line)
+}
+
+// UnusedTypes returns the graph's exported types that no other package in
+// the graphed packages' module refers to, including other packages' tests,
+// sorted. The module is found from opts' Dir and Env, like when building the
+// graph. Types in main packages are skipped, since they can't be imported.
+func UnusedTypes(p *pkg, opts Options) ([]UnusedType, error) {
+	modulePkgs, err := listModulePackages(p, &opts)
+	if err != nil {
+		return nil, err
+	}
+	importPath := p.pkgImportPath
+
+	// The graph's exported types, by their package's import path.
+	exported := map[string]map[string]*graphNode{}
+	pkgNames := map[string]string{}
+	pkgPaths := map[string]string{}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil || !node.typeObj.Exported() || node.typeObj.Pkg().Name() == "main" {
+			return
+		}
+		if exported[importPath(pkgPath)] == nil {
+			exported[importPath(pkgPath)] = map[string]*graphNode{}
+		}
+		exported[importPath(pkgPath)][node.typeObj.Name()] = node
+		pkgNames[importPath(pkgPath)] = node.typeObj.Pkg().Name()
+		pkgPaths[importPath(pkgPath)] = pkgPath
+	})
+
+	used := map[string]map[string]bool{}
+	for _, mp := range modulePkgs {
+		// A package's own tests are part of it, but its external tests are
+		// another package.
+		if _, ok := exported[mp.importPath]; ok && !mp.xtest {
+			continue
+		}
+		for _, filename := range mp.files {
+			addUsedTypes(used, filename, exported, pkgNames)
+		}
+	}
+
+	var unused []UnusedType
+	for pkgImportPath, nodes := range exported {
+		for name, node := range nodes {
+			if used[pkgImportPath][name] {
+				continue
+			}
+			unused = append(unused, UnusedType{
+				Package: pkgImportPath,
+				Name:    name,
+				File:    node.position.Filename,
+				Line:    node.position.Line,
+				pkgPath: pkgPaths[pkgImportPath],
+			})
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		if unused[i].Package != unused[j].Package {
+			return unused[i].Package < unused[j].Package
+		}
+		return unused[i].Name < unused[j].Name
+	})
+	return unused, nil
+}
+
+// addUsedTypes adds the exported types that the file refers to (as
+// pkgname.Type, or just Type if their package is dot-imported) to used. Files
+// that can't be parsed are skipped.
+func addUsedTypes(used map[string]map[string]bool, filename string, exported map[string]map[string]*graphNode, pkgNames map[string]string) {
+	f, err := parser.ParseFile(token.NewFileSet(), filename, nil, 0)
+	if err != nil {
+		return
+	}
+
+	imported := map[string]string{} // local name -> import path
+	var dotImported []string
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || exported[importPath] == nil {
+			continue
+		}
+		name := pkgNames[importPath]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		switch name {
+		case "_":
+		case ".":
+			dotImported = append(dotImported, importPath)
+		default:
+			imported[name] = importPath
+		}
+	}
+	if len(imported) == 0 && len(dotImported) == 0 {
+		return
+	}
+
+	use := func(importPath, name string) {
+		if _, ok := exported[importPath][name]; !ok {
+			return
+		}
+		if used[importPath] == nil {
+			used[importPath] = map[string]bool{}
+		}
+		used[importPath][name] = true
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok {
+				if importPath, ok := imported[x.Name]; ok {
+					use(importPath, n.Sel.Name)
+					return false
+				}
+			}
+		case *ast.Ident:
+			for _, importPath := range dotImported {
+				use(importPath, n.Name)
+			}
+		}
+		return true
+	})
+}
+
+// HighlightUnusedTypes grays out the borders of the unused types and
+// annotates them as unused.
+func HighlightUnusedTypes(p *pkg, unused []UnusedType) {
+	isUnused := map[diffKey]bool{}
+	for _, u := range unused {
+		isUnused[diffKey{u.pkgPath, u.Name}] = true
+	}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil || !isUnused[diffKey{pkgPath, node.typeObj.Name()}] {
+			return
+		}
+		node.borderColor = unusedBorderColor
+		if node.annotations == nil {
+			node.annotations = map[string]string{}
+		}
+		node.annotations["unused"] = "not used by other packages"
+	})
+}
@@ -0,0 +1,68 @@
+package pkgviz
+
+import (
+	"go/types"
+	"sort"
+)
+
+// addVarsToGraph adds the package-level variables whose types are in the
+// graph (or pointers to them), e.g. singletons and other global state, as
+// small nodes in their package, linked to their types.
+func addVarsToGraph(p *pkg) {
+	nodes := map[types.Object]*graphNode{}
+	pkgs := map[string]*types.Package{}
+	var pkgPaths []string
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil || node.typeObj.Pkg() == nil {
+			return
+		}
+		nodes[node.typeObj] = node
+		if _, ok := pkgs[pkgPath]; !ok {
+			pkgs[pkgPath] = node.typeObj.Pkg()
+			pkgPaths = append(pkgPaths, pkgPath)
+		}
+	})
+	sort.Strings(pkgPaths)
+
+	for _, pkgPath := range pkgPaths {
+		scope := pkgs[pkgPath].Scope()
+		for _, name := range scope.Names() {
+			v, ok := scope.Lookup(name).(*types.Var)
+			if !ok || name == "_" {
+				continue
+			}
+			t := v.Type()
+			if ptr, ok := t.(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			named, ok := t.(*types.Named)
+			if !ok {
+				continue
+			}
+			typeNode, ok := nodes[named.Obj()]
+			if !ok || p.typeIdOf(typeNode.pkgName, typeNode.typeName) != typeNode.typeId {
+				continue
+			}
+
+			typeId := labelizeName(pkgPath, name) + "_var"
+			deepSetNodeOnSubPkg(p, &graphNode{
+				pkgName:              pkgPath,
+				typeId:               typeId,
+				typeType:             "var",
+				typeName:             "var " + name,
+				typeNodes:            map[string]*graphNode{},
+				typeStructFields:     map[string]*structField{},
+				typeInterfaceMethods: map[string]string{},
+			}, pkgPath)
+			p.nodeLinks = append(p.nodeLinks, graphNodeLink{
+				fromStructTypeId: typeId,
+				toTypePkgName:    typeNode.pkgName,
+				toTypeName:       typeNode.typeName,
+				toTypeId:         typeNode.typeId,
+				color:            "#7f8183",
+				style:            "dashed",
+				arrowhead:        "empty",
+			})
+		}
+	}
+}
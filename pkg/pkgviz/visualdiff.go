@@ -0,0 +1,101 @@
+package pkgviz
+
+// The colors of the types, fields and references that VisualDiff marks.
+const (
+	addedColor         = "#c6efce"
+	removedColor       = "#ffc7ce"
+	removedBorderColor = "#f4b6bd"
+	changedColor       = "#fff2cc"
+	addedLinkColor     = "#3c9a4f"
+	removedLinkColor   = "#d9534f"
+)
+
+// VisualDiff returns a graph of the types of both graphs of the same
+// package (e.g. at two versions), marking what changed between them: added
+// types and references are green, removed ones are red (and the types are
+// ghosted), and changed types are yellow, with their added, removed and
+// changed fields in green, red and yellow.
+//
+// Neither graph is modified.
+func VisualDiff(base, head *pkg) *pkg {
+	merged := &pkg{
+		pkgName:     head.pkgName,
+		rootPkgName: head.rootPkgName,
+		subPkgs:     map[string]*pkg{},
+		nodes:       map[string]*graphNode{},
+		nodeLinks:   []graphNodeLink{},
+	}
+
+	baseNodes, headNodes := diffableNodes(base), diffableNodes(head)
+	head.walkNodes(func(pkgPath string, node *graphNode) {
+		diffNode := *node
+		if node.typeObj != nil {
+			key := diffKey{pkgPath, node.typeObj.Name()}
+			if baseNode, ok := baseNodes[key]; !ok {
+				diffNode.headerColor = addedColor
+			} else if len(diffNodes(baseNode, node)) > 0 {
+				diffNode.headerColor = changedColor
+				markChangedFields(&diffNode, baseNode)
+			}
+		}
+		deepSetNodeOnSubPkg(merged, &diffNode, pkgPath)
+	})
+	base.walkNodes(func(pkgPath string, node *graphNode) {
+		if node.typeObj == nil {
+			return
+		}
+		if _, ok := headNodes[diffKey{pkgPath, node.typeObj.Name()}]; !ok {
+			diffNode := *node
+			diffNode.headerColor = removedColor
+			diffNode.borderColor = removedBorderColor
+			deepSetNodeOnSubPkg(merged, &diffNode, pkgPath)
+		}
+	})
+
+	baseLinks := map[graphNodeLink]bool{}
+	for _, nodeLink := range base.nodeLinks {
+		baseLinks[nodeLink] = true
+	}
+	headLinks := map[graphNodeLink]bool{}
+	for _, nodeLink := range head.nodeLinks {
+		headLinks[nodeLink] = true
+		if !baseLinks[nodeLink] {
+			nodeLink.color = addedLinkColor
+		}
+		merged.nodeLinks = append(merged.nodeLinks, nodeLink)
+	}
+	for _, nodeLink := range base.nodeLinks {
+		if !headLinks[nodeLink] {
+			nodeLink.color = removedLinkColor
+			nodeLink.style = "dashed"
+			merged.nodeLinks = append(merged.nodeLinks, nodeLink)
+		}
+	}
+	return merged
+}
+
+// markChangedFields colors the fields of a changed struct by how they
+// changed, adding back the fields that were removed.
+func markChangedFields(node, baseNode *graphNode) {
+	if node.typeType != "struct" || baseNode.typeType != "struct" {
+		return
+	}
+
+	fields := map[string]*structField{}
+	node.fieldColors = map[string]string{}
+	for name, field := range node.typeStructFields {
+		fields[name] = field
+		if baseField, ok := baseNode.typeStructFields[name]; !ok {
+			node.fieldColors[name] = addedColor
+		} else if baseField.structFieldTypeName != field.structFieldTypeName {
+			node.fieldColors[name] = changedColor
+		}
+	}
+	for name, baseField := range baseNode.typeStructFields {
+		if _, ok := node.typeStructFields[name]; !ok {
+			fields[name] = baseField
+			node.fieldColors[name] = removedColor
+		}
+	}
+	node.typeStructFields = fields
+}
@@ -0,0 +1,72 @@
+package pkgviz
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// The kinds of Warning.
+const (
+	// WarningTypeError is for an error parsing or type-checking a package,
+	// e.g. an import that couldn't be found. Whatever could be checked is
+	// graphed.
+	WarningTypeError = "type error"
+	// WarningUnknownKind is for a named type of a kind that isn't graphed
+	// (e.g. an array), which is drawn as a generic node instead.
+	WarningUnknownKind = "unknown kind"
+	// WarningUnknownNode is for a node that the graph doesn't know how to
+	// draw, which is drawn as a generic node instead.
+	WarningUnknownNode = "unknown node"
+)
+
+// A Warning is something that went wrong building a graph, but not so
+// wrong that it couldn't be built, e.g. a type that couldn't be resolved.
+type Warning struct {
+	Kind    string `json:"kind"`    // e.g. WarningTypeError
+	Package string `json:"package"` // the import path of the package it's about
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s: %s", w.Package, w.Kind, w.Message)
+}
+
+// Warnings returns what went wrong building the graph, sorted by package,
+// and then in the order it went wrong in.
+func (p *pkg) Warnings() []Warning {
+	warnings := append([]Warning{}, p.warnings...)
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Package < warnings[j].Package
+	})
+	return warnings
+}
+
+// addWarning adds a warning about a package of the graph (relative to the
+// graphed package).
+func addWarning(p *pkg, pkgPath, kind, format string, args ...interface{}) {
+	p.warnings = append(p.warnings, Warning{
+		Kind:    kind,
+		Package: p.pkgImportPath(pkgPath),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// renderWarner returns a function that adds a warning to the graph for an
+// error drawing a node of a package (relative to the graphed package), once
+// however many times the graph is written. The goroutines that write the
+// subpackages' clusters call it at once.
+func (p *pkg) renderWarner() func(pkgPath string, err error) {
+	var mu sync.Mutex
+	return func(pkgPath string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		warning := Warning{Kind: WarningUnknownNode, Package: p.pkgImportPath(pkgPath), Message: err.Error()}
+		for _, w := range p.warnings {
+			if w == warning {
+				return
+			}
+		}
+		p.warnings = append(p.warnings, warning)
+	}
+}
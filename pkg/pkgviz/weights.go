@@ -0,0 +1,62 @@
+package pkgviz
+
+import "fmt"
+
+// maxLinkPenWidth is the widest that a weighted arrow gets, however many
+// fields it stands for.
+const maxLinkPenWidth = 8
+
+// minHubReferences is how many types have to refer to a type for it to be
+// annotated as a hub when weighting references.
+const minHubReferences = 3
+
+// weightLinks makes the graph print one arrow from each type to each type
+// that it refers to, as thick as the number of its fields that refer to it,
+// and annotates the types that many others refer to with how many do.
+func weightLinks(p *pkg) {
+	p.weightLinks = true
+
+	referrers := map[string]map[string]bool{}
+	for _, nodeLink := range p.nodeLinks {
+		to := nodeLink.toId()
+		if referrers[to] == nil {
+			referrers[to] = map[string]bool{}
+		}
+		referrers[to][nodeLink.fromStructTypeId] = true
+	}
+	p.walkNodes(func(pkgPath string, node *graphNode) {
+		if n := len(referrers[node.typeId]); n >= minHubReferences {
+			if node.annotations == nil {
+				node.annotations = map[string]string{}
+			}
+			node.annotations["references"] = fmt.Sprintf("referred to by %d types", n)
+		}
+	})
+}
+
+// weighLinks aggregates the links between each pair of types into one,
+// weighted by how many links there were, in the order they first appear.
+func weighLinks(nodeLinks []graphNodeLink) []graphNodeLink {
+	type pair struct{ from, to string }
+	var weighed []graphNodeLink
+	index := map[pair]int{}
+	for _, nodeLink := range nodeLinks {
+		key := pair{nodeLink.fromStructTypeId, nodeLink.toId()}
+		if i, ok := index[key]; ok {
+			weighed[i].weight++
+			continue
+		}
+		index[key] = len(weighed)
+		nodeLink.weight = 1
+		weighed = append(weighed, nodeLink)
+	}
+	return weighed
+}
+
+// linkPenWidth returns the width of an arrow that stands for weight fields.
+func linkPenWidth(weight int) int {
+	if weight > maxLinkPenWidth {
+		return maxLinkPenWidth
+	}
+	return weight
+}
@@ -0,0 +1,163 @@
+// Package rules checks the references between a graph's types against
+// architecture rules, like "the domain must not depend on http", e.g. in a
+// test:
+//
+//	func TestArchitecture(t *testing.T) {
+//		graph := pkgviz.BuildGraph("example.com/myapp")
+//		if err := rules.Check(graph,
+//			rules.Forbid(".../domain", ".../http"),
+//			rules.Only(".../store", "*sql.DB").Because("queries belong in the store"),
+//		); err != nil {
+//			t.Error(err)
+//		}
+//	}
+//
+// Rules name packages or types with patterns: an import path or type ID (e.g.
+// "database/sql.DB"), or its last elements (e.g. "pkg/http" or "sql.DB",
+// which may also be written ".../http"), optionally ending in "/..." to
+// include subpackages. Pointers are ignored, so "*sql.DB" and "sql.DB" are
+// the same.
+package rules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// A Graph is a built graph of types, like the one pkgviz.BuildGraph returns.
+type Graph interface {
+	Records() pkgviz.Records
+}
+
+// A Rule restricts which types may refer to others.
+type Rule struct {
+	from, to string
+	only     bool
+	reason   string
+}
+
+// Forbid returns a rule that types matching from must not refer to types
+// matching to.
+func Forbid(from, to string) *Rule {
+	return &Rule{from: from, to: to}
+}
+
+// Only returns a rule that only types matching from may refer to types
+// matching to.
+func Only(from, to string) *Rule {
+	return &Rule{from: from, to: to, only: true}
+}
+
+// Because sets why the rule exists, to show along with its violations.
+func (r *Rule) Because(reason string) *Rule {
+	r.reason = reason
+	return r
+}
+
+func (r *Rule) String() string {
+	if r.only {
+		return fmt.Sprintf("only %v may reference %v", r.from, r.to)
+	}
+	return fmt.Sprintf("%v must not reference %v", r.from, r.to)
+}
+
+// Check returns an *Error listing the references in the graph that break the
+// rule, or nil if there are none.
+func (r *Rule) Check(graph Graph) error {
+	return Check(graph, r)
+}
+
+func (r *Rule) violatedBy(from, to pkgviz.NodeRecord) bool {
+	if r.only {
+		return matches(r.to, to) && !matches(r.from, from)
+	}
+	return matches(r.from, from) && matches(r.to, to)
+}
+
+// matches returns whether the pattern matches a type, or the package it's in.
+func matches(pattern string, node pkgviz.NodeRecord) bool {
+	pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "*"), ".../")
+	if strings.HasSuffix(pattern, "/...") {
+		prefix := strings.TrimSuffix(pattern, "/...")
+		return matches(prefix, node) || strings.HasPrefix(node.Package, prefix+"/") || strings.Contains(node.Package, "/"+prefix+"/")
+	}
+	for _, name := range []string{node.ID, node.Package} {
+		if name == pattern || strings.HasSuffix(name, "/"+pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// A Violation is a reference from a struct's field to a type that breaks a
+// rule.
+type Violation struct {
+	Rule  *Rule
+	From  pkgviz.NodeRecord
+	Field string
+	To    pkgviz.NodeRecord
+}
+
+func (v Violation) String() string {
+	s := fmt.Sprintf("%v: %v.%v refers to %v, but %v", position(v.From), v.From.ID, v.Field, v.To.ID, v.Rule)
+	if v.Rule.reason != "" {
+		s += fmt.Sprintf(" (%v)", v.Rule.reason)
+	}
+	return s
+}
+
+// Error is the error returned for rules that are broken.
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	lines := []string{fmt.Sprintf("%d references break the architecture rules:", len(e.Violations))}
+	for _, v := range e.Violations {
+		lines = append(lines, "\t"+v.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Check returns an *Error listing the references in the graph that break
+// any of the rules, or nil if there are none.
+func Check(graph Graph, rules ...*Rule) error {
+	records := graph.Records()
+	nodes := map[string]pkgviz.NodeRecord{}
+	for _, node := range records.Nodes {
+		nodes[node.ID] = node
+	}
+
+	var violations []Violation
+	for _, edge := range records.Edges {
+		from, to := nodes[edge.From], nodes[edge.To]
+		for _, rule := range rules {
+			if rule.violatedBy(from, to) {
+				violations = append(violations, Violation{Rule: rule, From: from, Field: edge.Field, To: to})
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return &Error{Violations: violations}
+	}
+	return nil
+}
+
+// position returns where the type is declared, relative to the current
+// directory if it's under it.
+func position(node pkgviz.NodeRecord) string {
+	if node.File == "" {
+		return node.Package
+	}
+	file := node.File
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+	}
+	return fmt.Sprintf("%v:%d", file, node.Line)
+}
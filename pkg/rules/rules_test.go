@@ -0,0 +1,34 @@
+package rules_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+	"github.com/tiegz/pkgviz-go/pkg/rules"
+)
+
+func TestCheck(t *testing.T) {
+	graph := pkgviz.BuildGraph("github.com/tiegz/pkgviz-go/pkg/fakepkg")
+
+	if err := rules.Check(graph,
+		rules.Forbid(".../fakepkg", "nested/..."),
+		rules.Only(".../fakepkg.anotherFakeStruct", "fakepkg.fakeStruct"),
+	); err != nil {
+		t.Errorf("Expected no violations, got:\n%v", err)
+	}
+
+	err := rules.Forbid("fakepkg.anotherFakeStruct", "*fakepkg.fakeStruct").Because("just testing").Check(graph)
+	rulesErr, ok := err.(*rules.Error)
+	if !ok {
+		t.Fatalf("Expected a *rules.Error, got %v", err)
+	}
+	if len(rulesErr.Violations) != 1 {
+		t.Fatalf("Expected 1 violation, got:\n%v", err)
+	}
+	if msg := rulesErr.Violations[0].String(); !strings.Contains(msg, "github.com/tiegz/pkgviz-go/pkg/fakepkg.anotherFakeStruct.otherTypeStruct refers to github.com/tiegz/pkgviz-go/pkg/fakepkg.fakeStruct, but fakepkg.anotherFakeStruct must not reference *fakepkg.fakeStruct (just testing)") {
+		t.Errorf("Unexpected violation: %v", msg)
+	} else if !strings.Contains(msg, "fakepkg.go:") {
+		t.Errorf("Expected violation to include where the type is declared, got %v", msg)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// A Credential grants a client access to the graphs of some packages. The
+// client presents either its Token as a bearer token, or its Username and
+// Password with basic auth.
+type Credential struct {
+	Token    string `json:"token,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// Prefixes are the import paths (and their subpackages) that this
+	// credential may graph, e.g. "github.com/mycompany". If empty, any
+	// package may be graphed.
+	Prefixes []string `json:"prefixes,omitempty"`
+}
+
+type credentialKey struct{}
+
+// authenticate returns the credential that the request presented, if it's
+// one of the server's credentials.
+func (s *Server) authenticate(r *http.Request) (*Credential, bool) {
+	username, password, isBasic := r.BasicAuth()
+	token := ""
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	for i := range s.config.Credentials {
+		cred := &s.config.Credentials[i]
+		if token != "" && cred.Token != "" && secureEqual(token, cred.Token) {
+			return cred, true
+		}
+		if isBasic && cred.Username != "" && secureEqual(username, cred.Username) && secureEqual(password, cred.Password) {
+			return cred, true
+		}
+	}
+	return nil, false
+}
+
+// allows returns whether the credential may graph pkgName.
+func (cred *Credential) allows(pkgName string) bool {
+	if len(cred.Prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range cred.Prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if pkgName == prefix || strings.HasPrefix(pkgName, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// secureEqual compares secrets in constant time, so that they can't be
+// guessed from how long a comparison takes.
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// A fetchError is returned when a package can't be fetched, usually because
+// it doesn't exist at the requested version.
+type fetchError struct {
+	pkgName string
+	version string
+	err     error
+}
+
+func (e *fetchError) Error() string {
+	return fmt.Sprintf("cannot fetch %s@%s: %v", e.pkgName, e.version, e.err)
+}
+
+func (e *fetchError) Unwrap() error {
+	return e.err
+}
+
+// fetch adds pkgName at version to a fresh module in workDir, downloading
+// its module if needed, and returns the version that the module resolved to.
+func (s *Server) fetch(ctx context.Context, workDir, pkgName, version string) (string, error) {
+	env := s.goEnv()
+	if _, err := s.run(ctx, workDir, env, nil, "go", "mod", "init", "pkgviz.invalid/fetch"); err != nil {
+		return "", err
+	}
+	if _, err := s.run(ctx, workDir, env, nil, "go", "get", pkgName+"@"+version); err != nil {
+		return "", s.classifyFetchError(pkgName, version, err)
+	}
+
+	out, err := s.run(ctx, workDir, env, nil, "go", "list", "-f", "{{with .Module}}{{.Version}}{{end}}", pkgName)
+	if err != nil {
+		return "", &fetchError{pkgName: pkgName, version: version, err: err}
+	}
+	return strings.TrimSpace(string(out)), nil
+}
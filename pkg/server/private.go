@@ -0,0 +1,143 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// An authError is returned when a module can't be fetched because the server
+// couldn't authenticate with the host it's on, or because it's private and
+// was looked up in the public module proxy. Its hint says what to change
+// about the server's configuration.
+type authError struct {
+	pkgName string
+	version string
+	hint    string
+	err     error
+}
+
+func (e *authError) Error() string {
+	return fmt.Sprintf("cannot fetch %s@%s: %s\n\n%v", e.pkgName, e.version, e.hint, e.err)
+}
+
+func (e *authError) Unwrap() error {
+	return e.err
+}
+
+// The error output of the go tool (and the git and ssh commands it runs)
+// when authentication fails, by how it fails.
+var (
+	httpsAuthFailures = []string{
+		"terminal prompts disabled",
+		"could not read Username",
+		"could not read Password",
+		"Authentication failed",
+		"401 Unauthorized",
+		"403 Forbidden",
+	}
+	sshAuthFailures = []string{
+		"Permission denied (publickey",
+		"Host key verification failed",
+		"Could not open a connection to your authentication agent",
+	}
+	proxyNotFound = []string{
+		"404 Not Found",
+		"410 Gone",
+	}
+)
+
+// classifyFetchError turns the error from fetching a module into an
+// authError, if authentication was the problem, or otherwise a fetchError.
+func (s *Server) classifyFetchError(pkgName, version string, err error) error {
+	var stderr string
+	var cmdErr *commandError
+	if errors.As(err, &cmdErr) {
+		stderr = cmdErr.stderr
+	}
+	host := strings.Split(pkgName, "/")[0]
+
+	switch {
+	case containsAny(stderr, sshAuthFailures):
+		return &authError{pkgName, version, fmt.Sprintf(
+			"git couldn't authenticate with %s over SSH. Make sure the server's SSH agent (SSH_AUTH_SOCK=%s) has a key that can read the repository, and that %s is in the server's known_hosts.",
+			host, s.config.SSHAuthSock, host,
+		), err}
+	case containsAny(stderr, httpsAuthFailures):
+		return &authError{pkgName, version, fmt.Sprintf(
+			"the server has no credentials for %s. Add a \"machine %s login ... password ...\" line to the server's .netrc (%s), or configure git to fetch it over SSH with a url.\"git@%s:\".insteadOf rule.",
+			host, host, s.config.Netrc, host,
+		), err}
+	case containsAny(stderr, proxyNotFound) && !s.isPrivate(pkgName):
+		// The public proxy and checksum database can't see private modules,
+		// so they look like modules that don't exist.
+		return &fetchError{pkgName: pkgName, version: version, err: fmt.Errorf(
+			"%v\n\nIf %s is a private module, add it to the server's GOPRIVATE (e.g. %s) so that it's fetched directly from its repository",
+			err, pkgName, privatePatternFor(pkgName),
+		)}
+	}
+	return &fetchError{pkgName: pkgName, version: version, err: err}
+}
+
+// isPrivate reports whether the module that pkgName is in is fetched
+// directly from its repository, like `go help private` describes.
+func (s *Server) isPrivate(pkgName string) bool {
+	for _, pattern := range strings.Split(s.config.Private, ",") {
+		pattern = strings.TrimSuffix(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+		if matchPrefixPattern(pattern, pkgName) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPrefixPattern reports whether pattern, a glob like those in GOPRIVATE,
+// matches a prefix of pkgName's path elements.
+func matchPrefixPattern(pattern, pkgName string) bool {
+	patternElems := strings.Split(pattern, "/")
+	pkgElems := strings.Split(pkgName, "/")
+	if len(patternElems) > len(pkgElems) {
+		return false
+	}
+	for i, patternElem := range patternElems {
+		if ok, _ := path.Match(patternElem, pkgElems[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// privatePatternFor suggests a GOPRIVATE pattern for pkgName, which matches
+// the modules of its owner on hosts like github.com.
+func privatePatternFor(pkgName string) string {
+	elems := strings.Split(pkgName, "/")
+	if len(elems) >= 3 {
+		return elems[0] + "/" + elems[1] + "/*"
+	}
+	return elems[0] + "/*"
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// getenvAny returns the value of the first of the environment variables
+// that's set.
+func getenvAny(keys ...string) string {
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
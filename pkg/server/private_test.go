@@ -0,0 +1,34 @@
+package server
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClassifyFetchError(t *testing.T) {
+	s := &Server{config: Config{Private: "github.com/private/*", Netrc: "/home/pkgviz/.netrc"}}
+
+	for _, test := range []struct {
+		pkgName string
+		stderr  string
+		auth    bool
+		hint    string
+	}{
+		{"github.com/private/repo", "fatal: could not read Username for 'https://github.com': terminal prompts disabled", true, "/home/pkgviz/.netrc"},
+		{"github.com/private/repo", "git@github.com: Permission denied (publickey).", true, "SSH agent"},
+		{"github.com/someone/repo", "reading https://proxy.golang.org/...: 404 Not Found", false, "GOPRIVATE (e.g. github.com/someone/*)"},
+		{"github.com/private/repo", "reading https://proxy.golang.org/...: 404 Not Found", false, ""},
+	} {
+		err := s.classifyFetchError(test.pkgName, "latest", &commandError{command: "go get", err: errors.New("exit status 1"), stderr: test.stderr})
+		if _, ok := err.(*authError); ok != test.auth {
+			t.Errorf("Expected %q to be an auth error: %v, got %T", test.stderr, test.auth, err)
+		}
+		if test.hint != "" && !strings.Contains(err.Error(), test.hint) {
+			t.Errorf("Expected the error for %q to mention %q, got %v", test.stderr, test.hint, err)
+		}
+		if test.hint == "" && strings.Contains(err.Error(), "GOPRIVATE") {
+			t.Errorf("Expected no GOPRIVATE hint for private %v, got %v", test.pkgName, err)
+		}
+	}
+}
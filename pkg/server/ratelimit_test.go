@@ -0,0 +1,26 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(60, 2)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("Expected request %d to be within the burst", i)
+		}
+	}
+	if ok, retryAfter := l.allow("a", now); ok || retryAfter != time.Second {
+		t.Errorf("Expected to retry after 1s, got %v, %v", ok, retryAfter)
+	}
+	if ok, _ := l.allow("b", now); !ok {
+		t.Error("Expected another client to have its own bucket")
+	}
+	if ok, _ := l.allow("a", now.Add(time.Second)); !ok {
+		t.Error("Expected the bucket to have refilled")
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// run runs a command on behalf of an untrusted package: in dir, with env,
+// limited to the server's MaxMemory and MaxCPU, and killed (along with any
+// commands it started) once ctx is done. It returns the command's output, or
+// if it failed, an error including its error output.
+func (s *Server) run(ctx context.Context, dir string, env []string, stdin io.Reader, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := limitCommand(exec.Command(name, args...), s.config.MaxMemory, s.config.MaxCPU)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	waitErr := make(chan error, 1)
+	go func() {
+		waitErr <- cmd.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-waitErr:
+	case <-ctx.Done():
+		killCommand(cmd)
+		<-waitErr
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		return nil, &commandError{
+			command: strings.Join(append([]string{name}, args...), " "),
+			err:     err,
+			stderr:  strings.TrimSpace(stderr.String()),
+		}
+	}
+	return stdout.Bytes(), nil
+}
+
+type commandError struct {
+	command string
+	err     error
+	stderr  string
+}
+
+func (e *commandError) Error() string {
+	if e.stderr == "" {
+		return fmt.Sprintf("error running '%v': %v", e.command, e.err)
+	}
+	return fmt.Sprintf("error running '%v': %v: %s", e.command, e.err, e.stderr)
+}
+
+func (e *commandError) Unwrap() error {
+	return e.err
+}
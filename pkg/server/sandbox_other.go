@@ -0,0 +1,19 @@
+//go:build windows || js || plan9
+// +build windows js plan9
+
+package server
+
+import (
+	"os/exec"
+	"time"
+)
+
+// Resource limits aren't supported here, so commands are only bounded by the
+// server's Timeout.
+func limitCommand(cmd *exec.Cmd, maxMemory int64, maxCPU time.Duration) *exec.Cmd {
+	return cmd
+}
+
+func killCommand(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
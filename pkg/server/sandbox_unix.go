@@ -0,0 +1,33 @@
+//go:build !windows && !js && !plan9
+// +build !windows,!js,!plan9
+
+package server
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// limitCommand wraps cmd in a shell that sets its resource limits, since
+// os/exec has no way to set them on a child process directly. The command
+// gets its own process group, so that killCommand can kill its children too.
+func limitCommand(cmd *exec.Cmd, maxMemory int64, maxCPU time.Duration) *exec.Cmd {
+	script := ""
+	if maxCPU > 0 {
+		script += fmt.Sprintf("ulimit -t %d && ", int64((maxCPU+time.Second-1)/time.Second))
+	}
+	if maxMemory > 0 {
+		script += fmt.Sprintf("ulimit -v %d && ", (maxMemory+1023)/1024)
+	}
+	if script != "" {
+		cmd = exec.Command("/bin/sh", append([]string{"-c", script + `exec "$@"`, "sh"}, cmd.Args...)...)
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
+
+func killCommand(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
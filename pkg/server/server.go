@@ -0,0 +1,517 @@
+// Package server serves pkgviz graphs over HTTP for arbitrary import paths,
+// godoc.org-style, including those of private modules the server has
+// credentials for. Modules are fetched on demand into an isolated
+// module cache, and the rendered graphs are cached by module version.
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// Config configures a Server.
+type Config struct {
+	// CacheDir is where the module cache, the fetch workspaces, and the
+	// rendered graphs are kept. It is created if it doesn't exist.
+	CacheDir string
+
+	// MaxConcurrent is the most graphs that are built at once. Defaults to
+	// the number of CPUs.
+	MaxConcurrent int
+
+	// Worker is the command that analyzes a package in a sandboxed process,
+	// e.g. the pkgviz command itself, followed by the worker's flags. It's
+	// run with the arguments `-worker <flags...> <pkg>`, and must print the
+	// package's dot graph. If empty, packages are analyzed in the server's
+	// own process, where only the server's Timeout applies to them: it
+	// bounds each run of the go tool, and requests stop waiting on the
+	// analysis once it's up, but the analysis itself can't be killed.
+	Worker []string
+
+	// Timeout bounds the fetching, analysis and rendering of each graph.
+	// Defaults to 2 minutes.
+	Timeout time.Duration
+
+	// MaxMemory is the most memory, in bytes, that each command run for a
+	// graph may use. Defaults to 2GB. Only supported on unix.
+	MaxMemory int64
+
+	// MaxCPU is the most CPU time that each command run for a graph may
+	// use. Defaults to 1 minute. Only supported on unix.
+	MaxCPU time.Duration
+
+	// GoProxy is the GOPROXY that modules are fetched from. It defaults to
+	// the public module proxy alone, so that the go tool never runs version
+	// control tools against untrusted repositories (only against those of
+	// the Private modules).
+	GoProxy string
+
+	// Private is a comma-separated list of glob patterns, like GOPRIVATE, of
+	// the module paths that are fetched directly from their repositories
+	// instead of from GoProxy, and aren't checked against the public checksum
+	// database, e.g. "github.com/mycompany/*". Defaults to $GOPRIVATE.
+	Private string
+
+	// NoSumDB is a list like Private, of more module paths that aren't
+	// checked against the public checksum database. Defaults to $GONOSUMDB,
+	// or $GONOSUMCHECK.
+	NoSumDB string
+
+	// Netrc is the .netrc file with the credentials for the hosts of private
+	// modules that are fetched over https. Defaults to $NETRC, or ~/.netrc.
+	Netrc string
+
+	// SSHAuthSock is the socket of the SSH agent (which may be forwarded)
+	// with the keys for the hosts of private modules that are fetched over
+	// SSH. Defaults to $SSH_AUTH_SOCK.
+	SSHAuthSock string
+
+	// RateLimit is how many graphs each client may request per minute,
+	// after an initial burst of RateBurst requests. Zero means no limit.
+	RateLimit int
+	RateBurst int
+
+	// Credentials, if any are given, are required of every request, so that
+	// private graphs aren't world-readable. See Credential.
+	Credentials []Credential
+}
+
+// A Server is an http.Handler that serves rendered graphs:
+//
+//	GET /graph?pkg=github.com/foo/bar&version=v1.2.3&format=svg
+//
+// The version defaults to "latest" and may be anything `go get` accepts,
+// and the format is one of svg (the default), png or dot. The resolved
+// module version is returned in the X-Pkgviz-Version header.
+//
+// Pasted source can also be graphed, without fetching anything, by POSTing
+// it to /graph (see sourceRequest).
+type Server struct {
+	config    Config
+	goVersion string
+	stdPkgs   map[string]bool // the standard library's import paths
+	mux       *http.ServeMux
+	sem       chan struct{}
+	limiter   *rateLimiter
+
+	mu       sync.Mutex
+	inflight map[string]*call // cache key -> graph being built
+}
+
+// A call is a graph being built, which concurrent requests for the same
+// graph wait on instead of building it again.
+type call struct {
+	done    chan struct{}
+	graph   []byte
+	version string
+	err     error
+}
+
+var contentTypes = map[string]string{
+	"svg": "image/svg+xml",
+	"png": "image/png",
+	"dot": "text/vnd.graphviz; charset=utf-8",
+}
+
+// Import paths and versions are passed to the go tool, so only allow the
+// characters they're made of (and never a leading "-", which would be a flag).
+var (
+	validPkgName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._~+/-]*$`)
+	validVersion = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+/-]*$`)
+	// Only exact semantic versions can be looked up in the cache before fetching.
+	exactVersion = regexp.MustCompile(`^v[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?(\+incompatible)?$`)
+)
+
+// New returns a Server with the given config.
+func New(config Config) (*Server, error) {
+	if config.CacheDir == "" {
+		return nil, fmt.Errorf("no cache dir given")
+	}
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = runtime.NumCPU()
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 2 * time.Minute
+	}
+	if config.MaxMemory <= 0 {
+		config.MaxMemory = 2 << 30
+	}
+	if config.MaxCPU <= 0 {
+		config.MaxCPU = time.Minute
+	}
+	if config.GoProxy == "" {
+		config.GoProxy = "https://proxy.golang.org"
+	}
+	if config.Private == "" {
+		config.Private = os.Getenv("GOPRIVATE")
+	}
+	if config.NoSumDB == "" {
+		config.NoSumDB = getenvAny("GONOSUMDB", "GONOSUMCHECK")
+	}
+	if config.Netrc == "" {
+		config.Netrc = os.Getenv("NETRC")
+	}
+	if config.Netrc == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			config.Netrc = filepath.Join(home, ".netrc")
+		}
+	}
+	if config.SSHAuthSock == "" {
+		config.SSHAuthSock = os.Getenv("SSH_AUTH_SOCK")
+	}
+	for _, dir := range []string{"mod", "work", "graphs"} {
+		if err := os.MkdirAll(filepath.Join(config.CacheDir, dir), 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Server{
+		config:   config,
+		mux:      http.NewServeMux(),
+		sem:      make(chan struct{}, config.MaxConcurrent),
+		inflight: map[string]*call{},
+	}
+	if config.RateLimit > 0 {
+		s.limiter = newRateLimiter(config.RateLimit, config.RateBurst)
+	}
+
+	goVersion, err := s.run(context.Background(), config.CacheDir, s.goEnv(), nil, "go", "env", "GOVERSION")
+	if err != nil {
+		return nil, err
+	}
+	s.goVersion = strings.TrimSpace(string(goVersion))
+	stdPkgs, err := s.run(context.Background(), config.CacheDir, s.goEnv(), nil, "go", "list", "std")
+	if err != nil {
+		return nil, err
+	}
+	s.stdPkgs = map[string]bool{}
+	for _, stdPkg := range strings.Fields(string(stdPkgs)) {
+		s.stdPkgs[stdPkg] = true
+	}
+	s.mux.HandleFunc("/", s.handleIndex)
+	s.mux.HandleFunc("/graph", s.handleGraph)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(s.config.Credentials) > 0 {
+		cred, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Add("WWW-Authenticate", `Bearer realm="pkgviz"`)
+			w.Header().Add("WWW-Authenticate", `Basic realm="pkgviz"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), credentialKey{}, cred))
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	fmt.Fprintln(w, "usage: GET /graph?pkg=IMPORT_PATH[&version=VERSION][&format=svg|png|dot]")
+	fmt.Fprintln(w, `   or: POST /graph {"package": IMPORT_PATH, "files": {PATH: SOURCE, ...}, "format": "svg|png|dot", "focus": TYPE, "hops": N}`)
+}
+
+func (s *Server) handleGraph(w http.ResponseWriter, r *http.Request) {
+	if s.limiter != nil {
+		if ok, retryAfter := s.limiter.allow(clientAddr(r), time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	if r.Method == "POST" {
+		s.handleSourceGraph(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	pkgName := query.Get("pkg")
+	version := query.Get("version")
+	if version == "" {
+		version = "latest"
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = "svg"
+	}
+
+	if !isValidPkgName(pkgName) {
+		http.Error(w, fmt.Sprintf("invalid import path %q", pkgName), http.StatusBadRequest)
+		return
+	}
+	if !validVersion.MatchString(version) {
+		http.Error(w, fmt.Sprintf("invalid version %q", version), http.StatusBadRequest)
+		return
+	}
+	contentType, ok := contentTypes[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("invalid format %q", format), http.StatusBadRequest)
+		return
+	}
+	if cred, ok := r.Context().Value(credentialKey{}).(*Credential); ok && !cred.allows(pkgName) {
+		http.Error(w, fmt.Sprintf("not allowed to graph %s", pkgName), http.StatusForbidden)
+		return
+	}
+	// Anything else without a dot in its first element can't be fetched,
+	// and mustn't reach the go tool or the worker as an argument.
+	if isStdPkgName(pkgName) && !s.stdPkgs[pkgName] {
+		http.Error(w, fmt.Sprintf("%s is not in the standard library", pkgName), http.StatusNotFound)
+		return
+	}
+
+	graph, resolvedVersion, err := s.graph(r.Context(), pkgName, version, format)
+	if err != nil {
+		log.Printf("Error graphing %s@%s: %v", pkgName, version, err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		} else if _, ok := err.(*fetchError); ok {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else if _, ok := err.(*authError); ok {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Pkgviz-Version", resolvedVersion)
+	w.Write(graph)
+}
+
+// clientAddr is the address that requests are rate limited by.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func isValidPkgName(pkgName string) bool {
+	if !validPkgName.MatchString(pkgName) {
+		return false
+	}
+	for _, elem := range strings.Split(pkgName, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// graph returns the rendered graph of pkgName at version, and the module
+// version that it resolved to.
+func (s *Server) graph(ctx context.Context, pkgName, version, format string) ([]byte, string, error) {
+	key := cacheKey(pkgName, version, format)
+
+	s.mu.Lock()
+	if c, ok := s.inflight[key]; ok {
+		s.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.graph, c.version, c.err
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	s.inflight[key] = c
+	s.mu.Unlock()
+
+	// Other requests may be waiting on this graph, so it's built even if
+	// this request is canceled.
+	buildCtx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+	defer cancel()
+	c.graph, c.version, c.err = s.buildGraph(buildCtx, pkgName, version, format)
+	close(c.done)
+
+	s.mu.Lock()
+	delete(s.inflight, key)
+	s.mu.Unlock()
+
+	return c.graph, c.version, c.err
+}
+
+func (s *Server) buildGraph(ctx context.Context, pkgName, version, format string) ([]byte, string, error) {
+	if isStdPkgName(pkgName) {
+		version = s.goVersion
+	}
+	if version == s.goVersion || exactVersion.MatchString(version) {
+		if graph, err := ioutil.ReadFile(s.graphPath(pkgName, version, format)); err == nil {
+			return graph, version, nil
+		}
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+		defer func() { <-s.sem }()
+	case <-ctx.Done():
+		return nil, "", ctx.Err()
+	}
+
+	workDir, err := ioutil.TempDir(filepath.Join(s.config.CacheDir, "work"), "fetch")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(workDir)
+
+	if !isStdPkgName(pkgName) {
+		if version, err = s.fetch(ctx, workDir, pkgName, version); err != nil {
+			return nil, "", err
+		}
+		if graph, err := ioutil.ReadFile(s.graphPath(pkgName, version, format)); err == nil {
+			return graph, version, nil
+		}
+	}
+
+	graph, err := s.analyze(ctx, workDir, pkgName)
+	if err != nil {
+		return nil, "", err
+	}
+	if graph, err = s.render(ctx, workDir, graph, format); err != nil {
+		return nil, "", err
+	}
+
+	if err := writeFileAtomically(s.graphPath(pkgName, version, format), graph); err != nil {
+		return nil, "", err
+	}
+	return graph, version, nil
+}
+
+// analyze returns the dot graph of pkgName, which has been fetched into the
+// module in workDir. Everything that's needed has been fetched by now, so the
+// network is turned off.
+func (s *Server) analyze(ctx context.Context, workDir, pkgName string) ([]byte, error) {
+	env := append(s.goEnv(), "GOPROXY=off", "GOFLAGS=-mod=readonly")
+
+	if len(s.config.Worker) == 0 {
+		return analyzeInProcess(ctx, pkgName, workDir, env)
+	}
+
+	// The worker's memory limit is also its garbage collector's goal.
+	env = append(env, fmt.Sprintf("GOMEMLIMIT=%d", s.config.MaxMemory/10*8))
+	args := append(append([]string{"-worker"}, s.config.Worker[1:]...), pkgName)
+	return s.run(ctx, workDir, env, nil, s.config.Worker[0], args...)
+}
+
+// analyzeInProcess returns the dot graph of pkgName, analyzed in the
+// server's own process. The go tool is killed once ctx is done, and the
+// graph isn't waited for after that.
+func analyzeInProcess(ctx context.Context, pkgName, workDir string, env []string) ([]byte, error) {
+	opts := pkgviz.Options{Dir: workDir, Env: env}
+	if deadline, ok := ctx.Deadline(); ok {
+		if opts.ListTimeout = time.Until(deadline); opts.ListTimeout <= 0 {
+			return nil, ctx.Err()
+		}
+	}
+
+	type result struct {
+		dotFile string
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		dotFile, err := pkgviz.WriteGraphForPackage(pkgName, opts)
+		done <- result{dotFile, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return nil, r.err
+		}
+		return []byte(r.dotFile), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// render renders a dot graph to format, in dir.
+func (s *Server) render(ctx context.Context, dir string, graph []byte, format string) ([]byte, error) {
+	if format == "dot" {
+		return graph, nil
+	}
+	return s.run(ctx, dir, s.goEnv(), bytes.NewReader(graph), "dot", "-T"+format)
+}
+
+// goEnv is the environment of every command the server runs. Modules are
+// fetched into the server's own module cache from its GOPROXY (or directly
+// from their repositories, if they're private), cgo is off so that no C
+// toolchain is ever invoked, and nothing ever prompts.
+func (s *Server) goEnv() []string {
+	env := append(
+		os.Environ(),
+		"GOMODCACHE="+filepath.Join(s.config.CacheDir, "mod"),
+		"GOPROXY="+s.config.GoProxy,
+		"GOPRIVATE="+s.config.Private,
+		"GONOSUMDB="+s.config.NoSumDB,
+		"NETRC="+s.config.Netrc,
+		"SSH_AUTH_SOCK="+s.config.SSHAuthSock,
+		"GO111MODULE=on",
+		"GOFLAGS=-mod=mod",
+		"GOWORK=off",
+		"GOTOOLCHAIN=local",
+		"CGO_ENABLED=0",
+		"GIT_TERMINAL_PROMPT=0",
+	)
+	// ssh would otherwise prompt for passphrases and unknown host keys.
+	if os.Getenv("GIT_SSH_COMMAND") == "" {
+		env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+	return env
+}
+
+func (s *Server) graphPath(pkgName, version, format string) string {
+	return filepath.Join(s.config.CacheDir, "graphs", cacheKey(pkgName, version, format))
+}
+
+func cacheKey(pkgName, version, format string) string {
+	return url.PathEscape(pkgName) + "@" + version + "." + format
+}
+
+// Standard library import paths have no dot in their first element.
+func isStdPkgName(pkgName string) bool {
+	return !strings.Contains(strings.Split(pkgName, "/")[0], ".")
+}
+
+func writeFileAtomically(filename string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
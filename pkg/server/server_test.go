@@ -0,0 +1,140 @@
+package server_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tiegz/pkgviz-go/pkg/server"
+)
+
+func newTestServer(t *testing.T) *server.Server {
+	cacheDir, err := ioutil.TempDir("", "pkgviz-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := server.New(server.Config{CacheDir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestGraphRejectsInvalidRequests(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, query := range []string{
+		"pkg=",
+		"pkg=-modfile=foo",
+		"pkg=github.com/../etc",
+		"pkg=github.com/foo/bar&version=-x",
+		"pkg=github.com/foo/bar&format=exe",
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/graph?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be a bad request, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestGraphStdPackage(t *testing.T) {
+	s := newTestServer(t)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/graph?pkg=container/list&format=dot", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected OK, got %d: %s", w.Code, w.Body.String())
+		}
+		if body := w.Body.String(); !strings.Contains(body, "digraph V") || !strings.Contains(body, ">Element<") {
+			t.Errorf("Expected a graph of container/list, got %s", body)
+		}
+		if version := w.Header().Get("X-Pkgviz-Version"); !strings.HasPrefix(version, "go") {
+			t.Errorf("Expected a go version, got %q", version)
+		}
+	}
+}
+
+func TestGraphRejectsUnknownStdPackages(t *testing.T) {
+	s := newTestServer(t)
+
+	// Dotless paths that aren't in the standard library, like the command's
+	// own subcommands, are never analyzed.
+	for _, pkgName := range []string{"serve", "gen-fixtures", "container/lists"} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/graph?pkg="+pkgName+"&format=dot", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected %s to be not found, got %d: %s", pkgName, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestGraphRequiresCredentials(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "pkgviz-server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := server.New(server.Config{
+		CacheDir: cacheDir,
+		Credentials: []server.Credential{
+			{Token: "secret", Prefixes: []string{"container"}},
+			{Username: "admin", Password: "hunter2"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		query    string
+		auth     func(r *http.Request)
+		expected int
+	}{
+		{"pkg=container/list&format=dot", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"pkg=container/list&format=dot", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{"pkg=container/list&format=dot", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusOK},
+		{"pkg=containers&format=dot", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusForbidden},
+		{"pkg=container/list&format=dot", func(r *http.Request) { r.SetBasicAuth("admin", "wrong") }, http.StatusUnauthorized},
+		{"pkg=container/list&format=dot", func(r *http.Request) { r.SetBasicAuth("admin", "hunter2") }, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/graph?"+test.query, nil)
+		test.auth(r)
+		s.ServeHTTP(w, r)
+		if w.Code != test.expected {
+			t.Errorf("Expected %s to be %d, got %d", test.query, test.expected, w.Code)
+		}
+	}
+}
+
+func TestGraphPastedSource(t *testing.T) {
+	s := newTestServer(t)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/graph", strings.NewReader(`{
+		"package": "example.com/pasted",
+		"files": {"main.go": "package main\n\ntype outer struct{ inner inner }\n\ntype inner struct{}\n\ntype unrelated int\n"},
+		"format": "dot",
+		"focus": "outer",
+		"hops": 1
+	}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected OK, got %d: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, ">outer<") || !strings.Contains(body, ">inner<") {
+		t.Errorf("Expected a graph of outer and inner, got %s", body)
+	}
+	if strings.Contains(body, ">unrelated<") {
+		t.Errorf("Expected unrelated to be left out, got %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/graph", strings.NewReader(`{"files": {"main.go": "package main\n\ntype outer struct{"}, "format": "dot"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a syntax error to be a bad request, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/tiegz/pkgviz-go/pkg/pkgviz"
+)
+
+// The most source that may be POSTed to be graphed.
+const maxSourceSize = 1 << 20
+
+// A sourceRequest is the JSON body of a POST /graph request, which graphs
+// pasted source:
+//
+//	{"package": "example.com/pasted", "files": {"main.go": "package main..."},
+//	 "format": "svg", "focus": "Server", "hops": 1}
+//
+// Files map slash-separated paths to their source, and files in
+// subdirectories are graphed as subpackages of the package (which defaults
+// to "main"). Imports of anything but the given files are left unresolved.
+// The format defaults to svg, and focus and hops work like the options of
+// the same names.
+type sourceRequest struct {
+	Package string            `json:"package"`
+	Files   map[string]string `json:"files"`
+	Format  string            `json:"format"`
+	Focus   string            `json:"focus"`
+	Hops    int               `json:"hops"`
+}
+
+func (s *Server) handleSourceGraph(w http.ResponseWriter, r *http.Request) {
+	var req sourceRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSourceSize)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Package == "" {
+		req.Package = "main"
+	}
+	if req.Format == "" {
+		req.Format = "svg"
+	}
+	if !isValidPkgName(req.Package) {
+		http.Error(w, fmt.Sprintf("invalid package %q", req.Package), http.StatusBadRequest)
+		return
+	}
+	contentType, ok := contentTypes[req.Format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("invalid format %q", req.Format), http.StatusBadRequest)
+		return
+	}
+	if len(req.Files) == 0 {
+		http.Error(w, "no files given", http.StatusBadRequest)
+		return
+	}
+
+	graph, err := s.graphSource(r.Context(), &req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		} else if _, ok := err.(*sourceError); ok {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			log.Printf("Error rendering pasted source: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(graph)
+}
+
+// A sourceError is an error in pasted source, which can't be graphed.
+type sourceError struct {
+	err error
+}
+
+func (e *sourceError) Error() string {
+	return e.err.Error()
+}
+
+// graphSource graphs and renders pasted source, within the same limits as
+// graphs of fetched modules: among the server's MaxConcurrent graphs, and
+// within its Timeout.
+func (s *Server) graphSource(ctx context.Context, req *sourceRequest) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	// Pasted source is only parsed and type-checked, never built or run, so
+	// it's analyzed in-process. It isn't waited for past the timeout, but it
+	// keeps its place among the MaxConcurrent graphs until it's done.
+	type result struct {
+		dotFile string
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		pkgGraph, err := pkgviz.BuildGraphFromFilesWithOptions(req.Package, req.Files, pkgviz.Options{Focus: req.Focus, Hops: req.Hops})
+		if err != nil {
+			done <- result{err: &sourceError{err}}
+			return
+		}
+		done <- result{dotFile: pkgGraph.String()}
+	}()
+
+	var graph []byte
+	select {
+	case r := <-done:
+		defer func() { <-s.sem }()
+		if r.err != nil {
+			return nil, r.err
+		}
+		graph = []byte(r.dotFile)
+	case <-ctx.Done():
+		go func() {
+			<-done
+			<-s.sem
+		}()
+		return nil, ctx.Err()
+	}
+
+	workDir, err := ioutil.TempDir(filepath.Join(s.config.CacheDir, "work"), "source")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	return s.render(ctx, workDir, graph, req.Format)
+}